@@ -13,7 +13,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/element-of-surprise/coercion/interceptors"
 	"github.com/element-of-surprise/coercion/internal/execute"
+	"github.com/element-of-surprise/coercion/internal/execute/sm"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
@@ -40,12 +42,58 @@ type Workstream struct {
 	reg   *registry.Register
 	exec  *execute.Plans
 	store storage.Vault
+
+	smOptions   []sm.Option
+	healthEvery time.Duration
 }
 
-// Option is an optional argument for New(). For future use.
+// Option is an optional argument for New().
 type Option func(*Workstream) error
 
-// New creates a new Workstream.
+// WithExecuteInterceptors adds interceptors that wrap every plugin execution. Interceptors are called in
+// the order they are given, so the first one is the outermost. This can be passed multiple times.
+func WithExecuteInterceptors(ics ...interceptors.ExecuteFunc) Option {
+	return func(w *Workstream) error {
+		for _, ic := range ics {
+			if ic == nil {
+				return fmt.Errorf("execute interceptor cannot be nil")
+			}
+		}
+		w.smOptions = append(w.smOptions, sm.WithExecuteInterceptors(ics...))
+		return nil
+	}
+}
+
+// WithTransitionInterceptors adds interceptors that are called every time an object in a Plan changes
+// its status. Interceptors are called in the order they are given. This can be passed multiple times.
+func WithTransitionInterceptors(ts ...interceptors.TransitionFunc) Option {
+	return func(w *Workstream) error {
+		for _, t := range ts {
+			if t == nil {
+				return fmt.Errorf("transition interceptor cannot be nil")
+			}
+		}
+		w.smOptions = append(w.smOptions, sm.WithTransitionInterceptors(ts...))
+		return nil
+	}
+}
+
+// WithPluginCheckInterval sets how often the plugins are checked in the background. A plugin whose Init()
+// failed has it retried, and a plugin that implements plugins.HealthChecker has Check() called.
+// Defaults to 30 seconds.
+func WithPluginCheckInterval(d time.Duration) Option {
+	return func(w *Workstream) error {
+		if d <= 0 {
+			return fmt.Errorf("plugin check interval must be greater than 0")
+		}
+		w.healthEvery = d
+		return nil
+	}
+}
+
+// New creates a new Workstream. The plugins in reg are initialized before New returns. A plugin that fails
+// to initialize does not cause an error, instead it is Unavailable and Init() is retried in the background
+// until ctx is cancelled. See PluginHealth().
 func New(ctx context.Context, reg *registry.Register, store storage.Vault, options ...Option) (*Workstream, error) {
 	if store == nil {
 		return nil, fmt.Errorf("storage is required")
@@ -61,7 +109,7 @@ func New(ctx context.Context, reg *registry.Register, store storage.Vault, optio
 		}
 	}
 
-	exec, err := execute.New(ctx, store, reg)
+	exec, err := execute.New(ctx, store, reg, ws.healthEvery, ws.smOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create executor: %w", err)
 	}
@@ -75,9 +123,9 @@ type defaulter interface {
 }
 
 // Submit submits a workflow.Plan to the Workstream for execution. It returns the UUID of the plan.
-// If the plan is invalid, an error is returned. The plan is not executed on Submit(), you must use
-// Start() to begin execution. Using the Plan object after submitting it results in undefined behavior.
-// To get the status of the plan, use the Status method.
+// If the plan is invalid or uses a plugin that is registry.Unavailable, an error is returned.
+// The plan is not executed on Submit(), you must use Start() to begin execution. Using the Plan
+// object after submitting it results in undefined behavior. To get the status of the plan, use the Status method.
 func (w *Workstream) Submit(ctx context.Context, plan *workflow.Plan) (uuid.UUID, error) {
 	if err := w.populateRegistry(ctx, plan); err != nil {
 		return uuid.Nil, err
@@ -85,6 +133,9 @@ func (w *Workstream) Submit(ctx context.Context, plan *workflow.Plan) (uuid.UUID
 	if err := workflow.Validate(plan); err != nil {
 		return uuid.Nil, fmt.Errorf("Plan did not validate: %s", err)
 	}
+	if err := w.checkHealth(ctx, plan); err != nil {
+		return uuid.Nil, err
+	}
 
 	for item := range walk.Plan(context.WithoutCancel(ctx), plan) {
 		if def, ok := item.Value.(defaulter); ok {
@@ -113,11 +164,83 @@ func (w *Workstream) populateRegistry(ctx context.Context, plan *workflow.Plan)
 	return nil
 }
 
+// checkHealth returns an error if an Action in plan uses a plugin that is registry.Unavailable.
+func (w *Workstream) checkHealth(ctx context.Context, plan *workflow.Plan) error {
+	for item := range walk.Plan(context.WithoutCancel(ctx), plan) {
+		if item.Value.Type() != workflow.OTAction {
+			continue
+		}
+		a := item.Action()
+		p := w.reg.PluginVersion(a.Plugin, a.Version)
+		if p == nil {
+			continue // workflow.Validate() reports this.
+		}
+		if h := w.reg.Health(p); h.Health == registry.Unavailable {
+			return fmt.Errorf("action(%s) uses plugin(%s) which is unavailable: %s", a.Name, a.Plugin, h.Reason)
+		}
+	}
+	return nil
+}
+
 // Start begins execution of a plan with the given id. The plan must have been submitted to the workstream.
 func (w *Workstream) Start(ctx context.Context, id uuid.UUID) error {
 	return w.exec.Start(ctx, id)
 }
 
+// Output is a line or progress update from a plugin that is executing an Action. Plugins write these
+// with execinfo.Log() and execinfo.Progress().
+type Output struct {
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int
+	// Line is a line the plugin logged. This is the zero value if this is only a progress update.
+	Line workflow.LogLine
+	// Progress is the last progress percentage the plugin reported.
+	Progress int
+}
+
+// Output returns a channel that receives the output of the Action with id while it is running. The channel
+// is closed when the Action ends or the Context is cancelled. If the receiver falls behind, updates are dropped.
+// An error is returned if the Action is not running. The output is also stored with each workflow.Attempt,
+// which is bounded to the last lines.
+func (w *Workstream) Output(ctx context.Context, id uuid.UUID) (chan Output, error) {
+	events, err := w.exec.Output(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan Output, 1)
+	go func() {
+		defer close(ch)
+		for e := range events {
+			select {
+			case <-ctx.Done():
+				return
+			case ch <- Output{Attempt: e.Attempt, Line: e.Line, Progress: e.Progress}:
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// StorageDegraded returns true if writes to storage are failing. While degraded, running Plans keep their
+// state in memory and stop starting new work until storage recovers. If storage does not recover, the
+// Plans whose writes were buffered fail with workflow.FRStorage and the Workstream tries storage again.
+func (w *Workstream) StorageDegraded() bool {
+	return w.exec.StorageDegraded()
+}
+
+// PluginHealth returns the health of every plugin, sorted by name and then from the oldest to the latest version.
+// Plans that use a plugin that is registry.Unavailable are rejected by Submit().
+func (w *Workstream) PluginHealth() []registry.PluginHealth {
+	return w.reg.AllHealth()
+}
+
+// CheckPlugins checks the plugins now instead of waiting for the next check, such as after fixing what
+// made a plugin Unavailable. It returns when the checks are done. See WithPluginCheckInterval().
+func (w *Workstream) CheckPlugins(ctx context.Context) {
+	w.exec.CheckPlugins(ctx)
+}
+
 // Status returns a channel that will receive updates on the status of the plan with the given id. The interval
 // is the time between updates. The channel will be closed when the plan is complete or an error occurs.
 // If the Context is canceled, the channel will be closed and the final Result will have Err set. Otherwise, regardless
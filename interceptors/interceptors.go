@@ -0,0 +1,68 @@
+/*
+Package interceptors provides hooks that wrap every plugin execution and observe every object state
+transition in a Workstream. They are added with coercion.WithExecuteInterceptors() and
+coercion.WithTransitionInterceptors().
+
+Interceptors let you add logging, inject auth tokens, change requests or veto executions in one
+place instead of wrapping every plugin by hand.
+
+A simple logging interceptor:
+
+	logger := func(ctx context.Context, call interceptors.Call, next interceptors.Next) (any, *plugins.Error) {
+		log.Printf("action(%s) attempt %d: starting", call.Action.Name, call.Attempt)
+		resp, err := next(ctx, call)
+		log.Printf("action(%s) attempt %d: done, err: %v", call.Action.Name, call.Attempt, err)
+		return resp, err
+	}
+
+Interceptors are called concurrently for different Actions and objects, so they must be safe for concurrent use.
+*/
+package interceptors
+
+import (
+	"context"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/workflow"
+)
+
+// Call describes a single execution of a plugin for an Action.
+type Call struct {
+	// Action is the Action being executed. It must not be modified.
+	Action *workflow.Action
+	// Chain is the chain of objects that led to the Action, starting with the Plan. It must not be modified.
+	Chain []workflow.Object
+	// Attempt is the number of this attempt, starting at 1.
+	Attempt int
+	// Req is the request that will be passed to the plugin. An interceptor may replace it before
+	// calling next. This does not change Action.Req.
+	Req any
+}
+
+// Next calls the next interceptor, or the plugin if there are no more interceptors.
+type Next func(ctx context.Context, call Call) (any, *plugins.Error)
+
+// ExecuteFunc wraps the execution of a plugin. It must call next to execute the plugin and may change
+// the Context or call.Req before doing so. To veto the execution, return an error without calling next.
+// The returned values are handled like the plugin had returned them, so a non-permanent error may be retried.
+// The Context has the attempt's timeout. If the ExecuteFunc ignores it, the execution is abandoned like a
+// plugin that ignores it.
+type ExecuteFunc func(ctx context.Context, call Call, next Next) (any, *plugins.Error)
+
+// Transition describes an object changing its status.
+type Transition struct {
+	// Object is the object that changed. It is one of *workflow.Plan, *workflow.Checks, *workflow.Block,
+	// *workflow.Sequence or *workflow.Action. It must not be modified.
+	Object workflow.Object
+	// Chain is the chain of objects that led to the Object, starting with the Plan. This is empty for a Plan.
+	// It must not be modified.
+	Chain []workflow.Object
+	// From is the status the object had.
+	From workflow.Status
+	// To is the status the object has now.
+	To workflow.Status
+}
+
+// TransitionFunc is called after an object's status has changed and the change has been written to storage.
+// It is called before the engine continues, so it should not block for long.
+type TransitionFunc func(ctx context.Context, t Transition)
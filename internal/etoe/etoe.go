@@ -0,0 +1,69 @@
+// Package etoe holds the end to end tests of the Workstream. It also has helpers that the tests of other
+// packages use to run a Plan end to end.
+package etoe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	workstream "github.com/element-of-surprise/coercion"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/builder"
+	"github.com/element-of-surprise/coercion/workflow/storage/sqlite"
+)
+
+// Plan builds a Plan with one Block that has one Sequence of actions.
+func Plan(t testing.TB, actions ...*workflow.Action) *workflow.Plan {
+	t.Helper()
+
+	build, err := builder.New("etoe", "an end to end test")
+	if err != nil {
+		t.Fatalf("builder.New(): %s", err)
+	}
+	build.AddBlock(builder.BlockArgs{Name: "block", Descr: "block", Concurrency: 1})
+	build.AddSequence(&workflow.Sequence{Name: "seq", Descr: "seq"})
+	for _, a := range actions {
+		build.AddAction(a)
+	}
+	plan, err := build.Plan()
+	if err != nil {
+		t.Fatalf("build.Plan(): %s", err)
+	}
+	return plan
+}
+
+// Run runs plan with a Workstream that uses reg and an in-memory vault. It returns the Plan as read
+// from the vault after it ended.
+func Run(t testing.TB, reg *registry.Register, plan *workflow.Plan) *workflow.Plan {
+	t.Helper()
+	ctx := context.Background()
+
+	vault, err := sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	if err != nil {
+		t.Fatalf("sqlite.New(): %s", err)
+	}
+	ws, err := workstream.New(ctx, reg, vault)
+	if err != nil {
+		t.Fatalf("workstream.New(): %s", err)
+	}
+	id, err := ws.Submit(ctx, plan)
+	if err != nil {
+		t.Fatalf("Submit(): %s", err)
+	}
+	if err := ws.Start(ctx, id); err != nil {
+		t.Fatalf("Start(): %s", err)
+	}
+	for result := range ws.Status(ctx, id, 10*time.Millisecond) {
+		if result.Err != nil {
+			t.Fatalf("Status(): %s", result.Err)
+		}
+	}
+
+	read, err := vault.Read(ctx, id)
+	if err != nil {
+		t.Fatalf("vault.Read(): %s", err)
+	}
+	return read
+}
@@ -0,0 +1,79 @@
+package etoe
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	workstream "github.com/element-of-surprise/coercion"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage/sqlite"
+
+	testplugin "github.com/element-of-surprise/coercion/internal/execute/sm/testing/plugins"
+)
+
+// TestPluginHealth tests that a plugin that fails to initialize does not stop the Workstream, that only
+// Plans that use it are rejected, and that it can be used once it recovers.
+func TestPluginHealth(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	up := &testplugin.Plugin{PlugName: "up", AlwaysRespond: true}
+	flaky := &testplugin.Plugin{PlugName: "flaky", AlwaysRespond: true, InitFails: 1}
+	reg := registry.New()
+	reg.MustRegister(up)
+	reg.MustRegister(flaky)
+
+	vault, err := sqlite.New(ctx, "", reg, sqlite.WithInMemory())
+	if err != nil {
+		t.Fatalf("TestPluginHealth: sqlite.New(): %s", err)
+	}
+	// The interval is long so that only CheckPlugins() retries Init().
+	ws, err := workstream.New(ctx, reg, vault, workstream.WithPluginCheckInterval(time.Hour))
+	if err != nil {
+		t.Fatalf("TestPluginHealth: got err == %s, want err == nil with a plugin that failed Init()", err)
+	}
+
+	got := map[string]registry.Health{}
+	for _, ph := range ws.PluginHealth() {
+		got[ph.Name] = ph.Health
+	}
+	if got["up"] != registry.Healthy || got["flaky"] != registry.Unavailable {
+		t.Fatalf("TestPluginHealth(PluginHealth): got %v, want up Healthy and flaky Unavailable", got)
+	}
+
+	if _, err := ws.Submit(ctx, healthPlan(t, "up")); err != nil {
+		t.Errorf("TestPluginHealth(Submit healthy): got err == %s, want err == nil", err)
+	}
+	if _, err := ws.Submit(ctx, healthPlan(t, "flaky")); err == nil {
+		t.Errorf("TestPluginHealth(Submit unavailable): got err == nil, want err != nil")
+	}
+
+	ws.CheckPlugins(ctx)
+
+	id, err := ws.Submit(ctx, healthPlan(t, "flaky"))
+	if err != nil {
+		t.Fatalf("TestPluginHealth(Submit recovered): got err == %s, want err == nil", err)
+	}
+	if err := ws.Start(ctx, id); err != nil {
+		t.Fatalf("TestPluginHealth(Start): %s", err)
+	}
+	var result workstream.Result[*workflow.Plan]
+	for result = range ws.Status(ctx, id, 10*time.Millisecond) {
+		if result.Err != nil {
+			t.Fatalf("TestPluginHealth(Status): %s", result.Err)
+		}
+	}
+	if result.Data.State.Status != workflow.Completed {
+		t.Errorf("TestPluginHealth: got status %v, want %v", result.Data.State.Status, workflow.Completed)
+	}
+}
+
+// healthPlan builds a Plan with one Action that uses the plugin with name.
+func healthPlan(t *testing.T, name string) *workflow.Plan {
+	t.Helper()
+	return Plan(t, &workflow.Action{Name: "action", Descr: "action", Plugin: name, Req: testplugin.Req{}})
+}
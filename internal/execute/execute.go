@@ -1,5 +1,6 @@
-// Package executes validates Plan objects, checks Plugins can run in this environment (via Plugins.Init()) and
-// allows execution of the Plan objects by starting a statemachine that runs a Plan to completion.
+// Package executes validates Plan objects, keeps the health of Plugins in this environment (via Plugins.Init()
+// and plugins.HealthChecker) and allows execution of the Plan objects by starting a statemachine that runs a Plan
+// to completion.
 package execute
 
 import (
@@ -8,6 +9,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/element-of-surprise/coercion/internal/execute/guard"
+	"github.com/element-of-surprise/coercion/internal/execute/health"
+	"github.com/element-of-surprise/coercion/internal/execute/output"
 	"github.com/element-of-surprise/coercion/internal/execute/sm"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
@@ -15,7 +19,6 @@ import (
 	"github.com/element-of-surprise/coercion/workflow/utils/walk"
 	"github.com/google/uuid"
 
-	"github.com/gostdlib/concurrency/prim/wait"
 	"github.com/gostdlib/ops/statemachine"
 )
 
@@ -32,6 +35,13 @@ type Plans struct {
 	registry *registry.Register
 	// store is the storage backend for the Plans.
 	store storage.Vault
+	// guard wraps store for the statemachine so that write failures put us in a degraded mode
+	// instead of failing the Plan.
+	guard *guard.Vault
+	// outputs holds the running Actions so that their output can be watched.
+	outputs *output.Hub
+	// health keeps the health of the plugins in registry.
+	health *health.Monitor
 
 	// states is the statemachine that runs the Plans.
 	states *sm.States
@@ -47,21 +57,32 @@ type Plans struct {
 	validators []validator
 }
 
-// New creates a new Executor. This should only be created once.
-func New(ctx context.Context, store storage.Vault, reg *registry.Register) (*Plans, error) {
+// New creates a new Executor. This should only be created once. The plugins are checked before New returns
+// and then every healthEvery until ctx is cancelled, see health.Monitor. A plugin that fails its checks does
+// not cause an error. options are passed to the statemachine.
+func New(ctx context.Context, store storage.Vault, reg *registry.Register, healthEvery time.Duration, options ...sm.Option) (*Plans, error) {
 	e := &Plans{
 		registry: reg,
 		store:    store,
+		outputs:  output.NewHub(),
 		stoppers: map[uuid.UUID]context.CancelFunc{},
 		runner:   statemachine.Run[sm.Data],
 	}
 
-	if err := e.initPlugins(ctx); err != nil {
-		return nil, fmt.Errorf("failed to initialize plugins: %w", err)
+	var err error
+	e.health, err = health.New(reg, healthEvery)
+	if err != nil {
+		return nil, err
 	}
+	e.health.Check(ctx)
+	go e.health.Run(ctx)
 
-	var err error
-	e.states, err = sm.New(store, e.registry)
+	e.guard, err = guard.New(store)
+	if err != nil {
+		return nil, err
+	}
+	options = append([]sm.Option{sm.WithOutputs(e.outputs)}, options...)
+	e.states, err = sm.New(e.guard, e.registry, options...)
 	if err != nil {
 		return nil, err
 	}
@@ -71,6 +92,20 @@ func New(ctx context.Context, store storage.Vault, reg *registry.Register) (*Pla
 	return e, nil
 }
 
+// StorageDegraded returns true if writes to storage are failing and Plans have stopped starting new work
+// until storage recovers.
+func (e *Plans) StorageDegraded() bool {
+	if e.guard == nil {
+		return false
+	}
+	return e.guard.Degraded()
+}
+
+// Output returns a channel that receives the output of the running Action with id. See output.Hub.Watch().
+func (e *Plans) Output(ctx context.Context, id uuid.UUID) (chan output.Event, error) {
+	return e.outputs.Watch(ctx, id)
+}
+
 func (e *Plans) addValidators() {
 	e.validators = []validator{
 		e.validateID,
@@ -80,22 +115,10 @@ func (e *Plans) addValidators() {
 	}
 }
 
-// initPlugins initializes all plugins in the registry to make sure they
-// meet the preconditions for execution.
-func (e *Plans) initPlugins(ctx context.Context) error {
-	g := wait.Group{}
-	for plugin := range e.registry.Plugins() {
-		plugin := plugin
-		g.Go(ctx, func(ctx context.Context) error {
-			err := plugin.Init()
-			if err != nil {
-				return fmt.Errorf("plugin(%s) failed to initialize: %w", plugin.Name(), err)
-			}
-			return nil
-		})
-	}
-
-	return g.Wait(ctx)
+// CheckPlugins checks the health of the plugins now instead of waiting for the next check. It returns
+// when the checks are done. The results are in the registry.
+func (e *Plans) CheckPlugins(ctx context.Context) {
+	e.health.Check(ctx)
 }
 
 // Start starts a previously Submitted Plan by its ID. Cancelling the Context will not Stop execution.
@@ -196,8 +219,11 @@ func (p *Plans) validateAction(i walk.Item) error {
 		return fmt.Errorf("action(%s).Attempts was non-nil", action.Name)
 	}
 
-	plug := p.registry.Plugin(action.Plugin)
+	plug := p.registry.PluginVersion(action.Plugin, action.Version)
 	if plug == nil {
+		if action.Version != "" {
+			return fmt.Errorf("plugin(%s) with version %q not found", action.Plugin, action.Version)
+		}
 		return fmt.Errorf("plugin(%s) not found", action.Plugin)
 	}
 
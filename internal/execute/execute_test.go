@@ -78,17 +78,15 @@ func TestInitPlugins(t *testing.T) {
 	tests := []struct {
 		name    string
 		plugins []pluginsLib.Plugin
-		wantErr bool
+		want    map[string]registry.Health
 	}{
 		{
-			name: "no plugins",
-		},
-		{
-			name: "good plugins",
+			name: "all good plugins",
 			plugins: []pluginsLib.Plugin{
 				goodPlugin{name: "good1"},
 				goodPlugin{name: "good2"},
 			},
+			want: map[string]registry.Health{"good1": registry.Healthy, "good2": registry.Healthy},
 		},
 		{
 			name: "has bad plugin",
@@ -96,7 +94,7 @@ func TestInitPlugins(t *testing.T) {
 				goodPlugin{name: "good1"},
 				badPlugin{},
 			},
-			wantErr: true,
+			want: map[string]registry.Health{"good1": registry.Healthy, "bad": registry.Unavailable},
 		},
 	}
 
@@ -105,18 +103,23 @@ func TestInitPlugins(t *testing.T) {
 		for _, p := range test.plugins {
 			reg.Register(p)
 		}
-		p := &Plans{
-			registry: reg,
-		}
-		err := p.initPlugins(context.Background())
-		switch {
-		case test.wantErr && err == nil:
-			t.Errorf("TestInitPlugins(%s): got err == nil, want err != nil", test.name)
-		case !test.wantErr && err != nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		// A plugin that fails to initialize must not cause an error.
+		_, err := New(ctx, &fakeStore{}, reg, time.Hour)
+		cancel()
+		if err != nil {
 			t.Errorf("TestInitPlugins(%s): got err == %v, want err == nil", test.name, err)
+			continue
 		}
-	}
 
+		got := map[string]registry.Health{}
+		for _, ph := range reg.AllHealth() {
+			got[ph.Name] = ph.Health
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestInitPlugins(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
 }
 
 type fakeStore struct {
@@ -0,0 +1,334 @@
+// Package guard provides a storage.Vault that keeps a Plan running through transient storage write failures.
+// When a write fails, the Vault enters a degraded mode. In degraded mode, writes are buffered in memory and
+// retried with backoff until they all succeed, which ends the degraded mode. Writes block while the Vault is
+// degraded so that callers do not start new work. If the writes cannot be recovered within the recovery window,
+// the writes that were buffered are dropped and fail with an error wrapping ErrUnrecoverable. The Vault then
+// leaves degraded mode and the next write tries storage again, so only the callers of the dropped writes fail.
+package guard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/element-of-surprise/coercion/workflow/utils/clone"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+// ErrUnrecoverable is wrapped by errors returned when buffered writes could not be written within
+// the recovery window.
+var ErrUnrecoverable = errors.New("storage could not be recovered")
+
+// DefaultRecovery is the default amount of time the Vault retries buffered writes before giving up.
+const DefaultRecovery = 5 * time.Minute
+
+var defaultPolicy = exponential.Policy{
+	InitialInterval:     500 * time.Millisecond,
+	Multiplier:          2,
+	RandomizationFactor: 0.2,
+	MaxInterval:         30 * time.Second,
+}
+
+var cloneOpts = []clone.Option{clone.WithKeepSecrets(), clone.WithKeepState()}
+
+// key identifies a buffered write. Only the latest write for a key is kept.
+type key struct {
+	id uuid.UUID
+	// op is the name of the write method.
+	op string
+	// iteration is the CheckRun.Iteration for RecordCheckRun, as every run must be written.
+	iteration int
+}
+
+// write is a buffered write.
+type write struct {
+	// gen is incremented every time a write is buffered, so that we can detect if a write for the same
+	// key was replaced while it was being written.
+	gen uint64
+	fn  func(context.Context) error
+}
+
+// Vault wraps a storage.Vault. Only writes made through the Updater methods are guarded.
+type Vault struct {
+	storage.Vault
+
+	policy   exponential.Policy
+	recovery time.Duration
+
+	degraded atomic.Bool
+
+	mu sync.Mutex
+	// pending holds the latest buffered write for each key.
+	pending map[key]write
+	// order is the order in which keys were first buffered.
+	order []key
+	gen   uint64
+	// outage is the current outage. It is nil when the Vault is healthy.
+	outage *outage
+}
+
+// outage is a period in which writes fail. Writes and Wait() calls made during it wait for it to end.
+type outage struct {
+	// done is closed when the outage ends.
+	done chan struct{}
+	// err is set before done is closed if the buffered writes could not be recovered.
+	err error
+}
+
+// Option is an optional argument for New().
+type Option func(*Vault) error
+
+// WithRecovery sets the amount of time the Vault retries buffered writes before giving up.
+// The default is DefaultRecovery.
+func WithRecovery(d time.Duration) Option {
+	return func(v *Vault) error {
+		if d <= 0 {
+			return fmt.Errorf("recovery must be greater than 0")
+		}
+		v.recovery = d
+		return nil
+	}
+}
+
+// WithPolicy sets the backoff policy used to retry buffered writes.
+func WithPolicy(p exponential.Policy) Option {
+	return func(v *Vault) error {
+		v.policy = p
+		return nil
+	}
+}
+
+// New creates a new Vault that guards writes to store.
+func New(store storage.Vault, options ...Option) (*Vault, error) {
+	if store == nil {
+		return nil, fmt.Errorf("store is required")
+	}
+
+	v := &Vault{
+		Vault:    store,
+		policy:   defaultPolicy,
+		recovery: DefaultRecovery,
+		pending:  map[key]write{},
+	}
+	for _, o := range options {
+		if err := o(v); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := exponential.New(exponential.WithPolicy(v.policy)); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+	return v, nil
+}
+
+// Degraded returns true if writes to storage are failing and are being buffered.
+func (v *Vault) Degraded() bool {
+	return v.degraded.Load()
+}
+
+// Wait blocks until the Vault is not degraded. It returns an error if the Vault gave up on recovering
+// the outage it was waiting on or the Context is cancelled.
+func (v *Vault) Wait(ctx context.Context) error {
+	v.mu.Lock()
+	o := v.outage
+	v.mu.Unlock()
+
+	if o == nil {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-o.done:
+	}
+	return o.err
+}
+
+// UpdatePlan implements storage.PlanUpdater.UpdatePlan().
+func (v *Vault) UpdatePlan(ctx context.Context, plan *workflow.Plan) error {
+	return v.write(
+		ctx,
+		key{id: plan.ID, op: "UpdatePlan"},
+		func(ctx context.Context) error { return v.Vault.UpdatePlan(ctx, plan) },
+		func() func(context.Context) error {
+			p := clone.Plan(ctx, plan, cloneOpts...)
+			return func(ctx context.Context) error { return v.Vault.UpdatePlan(ctx, p) }
+		},
+	)
+}
+
+// UpdateBlock implements storage.BlockUpdater.UpdateBlock().
+func (v *Vault) UpdateBlock(ctx context.Context, block *workflow.Block) error {
+	return v.write(
+		ctx,
+		key{id: block.ID, op: "UpdateBlock"},
+		func(ctx context.Context) error { return v.Vault.UpdateBlock(ctx, block) },
+		func() func(context.Context) error {
+			b := clone.Block(ctx, block, cloneOpts...)
+			return func(ctx context.Context) error { return v.Vault.UpdateBlock(ctx, b) }
+		},
+	)
+}
+
+// UpdateChecks implements storage.ChecksUpdater.UpdateChecks().
+func (v *Vault) UpdateChecks(ctx context.Context, checks *workflow.Checks) error {
+	return v.write(
+		ctx,
+		key{id: checks.ID, op: "UpdateChecks"},
+		func(ctx context.Context) error { return v.Vault.UpdateChecks(ctx, checks) },
+		func() func(context.Context) error {
+			c := clone.Checks(ctx, checks, cloneOpts...)
+			return func(ctx context.Context) error { return v.Vault.UpdateChecks(ctx, c) }
+		},
+	)
+}
+
+// RecordCheckRun implements storage.ChecksUpdater.RecordCheckRun().
+func (v *Vault) RecordCheckRun(ctx context.Context, checks *workflow.Checks, run *workflow.CheckRun) error {
+	return v.write(
+		ctx,
+		key{id: checks.ID, op: "RecordCheckRun", iteration: run.Iteration},
+		func(ctx context.Context) error { return v.Vault.RecordCheckRun(ctx, checks, run) },
+		func() func(context.Context) error {
+			c := clone.Checks(ctx, checks, cloneOpts...)
+			r := *run
+			return func(ctx context.Context) error { return v.Vault.RecordCheckRun(ctx, c, &r) }
+		},
+	)
+}
+
+// UpdateSequence implements storage.SequenceUpdater.UpdateSequence().
+func (v *Vault) UpdateSequence(ctx context.Context, seq *workflow.Sequence) error {
+	return v.write(
+		ctx,
+		key{id: seq.ID, op: "UpdateSequence"},
+		func(ctx context.Context) error { return v.Vault.UpdateSequence(ctx, seq) },
+		func() func(context.Context) error {
+			s := clone.Sequence(ctx, seq, cloneOpts...)
+			return func(ctx context.Context) error { return v.Vault.UpdateSequence(ctx, s) }
+		},
+	)
+}
+
+// UpdateAction implements storage.ActionUpdater.UpdateAction().
+func (v *Vault) UpdateAction(ctx context.Context, action *workflow.Action) error {
+	return v.write(
+		ctx,
+		key{id: action.ID, op: "UpdateAction"},
+		func(ctx context.Context) error { return v.Vault.UpdateAction(ctx, action) },
+		func() func(context.Context) error {
+			a := clone.Action(ctx, action, cloneOpts...)
+			return func(ctx context.Context) error { return v.Vault.UpdateAction(ctx, a) }
+		},
+	)
+}
+
+// write writes with fn if the Vault is healthy. If the write fails or the Vault is degraded, the write
+// is buffered with the func returned by snapshot and write blocks until the Vault recovers or gives up.
+// snapshot must capture the current state of the object, as the caller may change it after write returns.
+func (v *Vault) write(ctx context.Context, k key, fn func(context.Context) error, snapshot func() func(context.Context) error) error {
+	v.mu.Lock()
+	if v.outage == nil {
+		v.mu.Unlock()
+		if err := fn(ctx); err == nil {
+			return nil
+		}
+		v.mu.Lock()
+	}
+
+	v.buffer(k, snapshot())
+	o := v.outage
+	if o == nil {
+		o = &outage{done: make(chan struct{})}
+		v.outage = o
+		v.degraded.Store(true)
+		go v.recover(o)
+	}
+	v.mu.Unlock()
+
+	// Once buffered, the write will happen even if the Context is cancelled, unless the Vault gives up.
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-o.done:
+	}
+	return o.err
+}
+
+// buffer adds a write to the buffer, replacing any write with the same key. v.mu must be held.
+func (v *Vault) buffer(k key, fn func(context.Context) error) {
+	v.gen++
+	if _, ok := v.pending[k]; !ok {
+		v.order = append(v.order, k)
+	}
+	v.pending[k] = write{gen: v.gen, fn: fn}
+}
+
+// recover retries the buffered writes until they all succeed or the recovery window has passed. If the window
+// passes, the buffered writes are dropped and fail with ErrUnrecoverable. This fails the Plans that made them,
+// while the Vault goes back to writing to storage for everyone else.
+func (v *Vault) recover(o *outage) {
+	ctx, cancel := context.WithTimeout(context.Background(), v.recovery)
+	defer cancel()
+
+	// The policy was validated in New().
+	backoff, _ := exponential.New(exponential.WithPolicy(v.policy))
+
+	err := backoff.Retry(
+		ctx,
+		func(ctx context.Context, r exponential.Record) error {
+			return v.flush(ctx, o)
+		},
+	)
+	if err == nil {
+		return
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	o.err = fmt.Errorf("%w: %w", ErrUnrecoverable, err)
+	v.pending = map[key]write{}
+	v.order = nil
+	v.outage = nil
+	v.degraded.Store(false)
+	close(o.done)
+}
+
+// flush writes the buffered writes in the order they were buffered. If all of them succeed, the Vault
+// leaves degraded mode and o ends.
+func (v *Vault) flush(ctx context.Context, o *outage) error {
+	for {
+		v.mu.Lock()
+		if len(v.order) == 0 {
+			v.outage = nil
+			v.degraded.Store(false)
+			close(o.done)
+			v.mu.Unlock()
+			return nil
+		}
+		k := v.order[0]
+		w := v.pending[k]
+		v.mu.Unlock()
+
+		if err := w.fn(ctx); err != nil {
+			return err
+		}
+
+		v.mu.Lock()
+		// If the write was replaced while we were writing, the newer one must still be written.
+		if v.pending[k].gen == w.gen {
+			delete(v.pending, k)
+			v.order = v.order[1:]
+		}
+		v.mu.Unlock()
+	}
+}
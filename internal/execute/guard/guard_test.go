@@ -0,0 +1,180 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/google/uuid"
+
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+var testPolicy = exponential.Policy{
+	InitialInterval:     time.Millisecond,
+	Multiplier:          2,
+	RandomizationFactor: 0,
+	MaxInterval:         10 * time.Millisecond,
+}
+
+type fakeVault struct {
+	mu sync.Mutex
+	// failures is the number of writes that fail before writes succeed. If < 0, writes always fail.
+	failures int
+	actions  []*workflow.Action
+	// onFail is called when a write fails.
+	onFail func()
+
+	storage.Vault
+}
+
+func (f *fakeVault) UpdateAction(ctx context.Context, action *workflow.Action) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failures != 0 {
+		if f.failures > 0 {
+			f.failures--
+		}
+		if f.onFail != nil {
+			f.onFail()
+		}
+		return errors.New("disk hiccup")
+	}
+	f.actions = append(f.actions, action)
+	return nil
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name         string
+		failures     int
+		wantErr      bool
+		wantDegraded bool
+	}{
+		{
+			name: "Success: healthy",
+		},
+		{
+			name:     "Success: recovered after failures",
+			failures: 3,
+		},
+		{
+			name:     "Error: unrecoverable",
+			failures: -1,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		fake := &fakeVault{failures: test.failures}
+		v, err := New(fake, WithPolicy(testPolicy), WithRecovery(100*time.Millisecond))
+		if err != nil {
+			panic(err)
+		}
+		sawDegraded := false
+		fake.onFail = func() {
+			if v.Degraded() {
+				sawDegraded = true
+			}
+		}
+
+		action := &workflow.Action{ID: uuid.New(), Name: "action", State: &workflow.State{Status: workflow.Running}}
+		err = v.UpdateAction(context.Background(), action)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestWrite(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestWrite(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if !errors.Is(err, ErrUnrecoverable) {
+				t.Errorf("TestWrite(%s): got err == %s, want err wrapping ErrUnrecoverable", test.name, err)
+			}
+			// The Vault gave up on this outage, so it is no longer degraded or waiting.
+			if err := v.Wait(context.Background()); err != nil {
+				t.Errorf("TestWrite(%s): got Wait() err == %v, want err == nil", test.name, err)
+			}
+		}
+
+		if v.Degraded() != test.wantDegraded {
+			t.Errorf("TestWrite(%s): got Degraded() == %v, want %v", test.name, v.Degraded(), test.wantDegraded)
+		}
+		if test.failures > 1 && !sawDegraded {
+			t.Errorf("TestWrite(%s): Vault was never degraded during the failures", test.name)
+		}
+		if test.wantErr {
+			continue
+		}
+		if len(fake.actions) != 1 {
+			t.Fatalf("TestWrite(%s): got %d writes, want 1", test.name, len(fake.actions))
+		}
+		if fake.actions[0].ID != action.ID || fake.actions[0].State.Status != workflow.Running {
+			t.Errorf("TestWrite(%s): did not write the expected Action", test.name)
+		}
+	}
+}
+
+// TestRecoverAfterWindow tests that giving up on an outage only fails the writes made during it. Once storage
+// comes back, later writes succeed.
+func TestRecoverAfterWindow(t *testing.T) {
+	t.Parallel()
+
+	fake := &fakeVault{failures: -1}
+	v, err := New(fake, WithPolicy(testPolicy), WithRecovery(50*time.Millisecond))
+	if err != nil {
+		panic(err)
+	}
+
+	lost := &workflow.Action{ID: uuid.New(), Name: "lost", State: &workflow.State{Status: workflow.Running}}
+	if err := v.UpdateAction(context.Background(), lost); !errors.Is(err, ErrUnrecoverable) {
+		t.Fatalf("TestRecoverAfterWindow(outage): got err == %v, want err wrapping ErrUnrecoverable", err)
+	}
+
+	fake.mu.Lock()
+	fake.failures = 0
+	fake.mu.Unlock()
+
+	if v.Degraded() {
+		t.Errorf("TestRecoverAfterWindow: got Degraded() == true after giving up, want false")
+	}
+	if err := v.Wait(context.Background()); err != nil {
+		t.Errorf("TestRecoverAfterWindow(Wait): got err == %s, want err == nil", err)
+	}
+	next := &workflow.Action{ID: uuid.New(), Name: "next", State: &workflow.State{Status: workflow.Running}}
+	if err := v.UpdateAction(context.Background(), next); err != nil {
+		t.Fatalf("TestRecoverAfterWindow(after outage): got err == %s, want err == nil", err)
+	}
+
+	// The write made during the outage was dropped, it is not written once storage is back.
+	if len(fake.actions) != 1 || fake.actions[0].ID != next.ID {
+		t.Errorf("TestRecoverAfterWindow: got %d writes, want only the write made after the outage", len(fake.actions))
+	}
+}
+
+func TestBuffer(t *testing.T) {
+	t.Parallel()
+
+	v := &Vault{pending: map[key]write{}}
+
+	k1 := key{id: uuid.New(), op: "UpdateAction"}
+	k2 := key{id: uuid.New(), op: "UpdateAction"}
+
+	v.buffer(k1, nil)
+	v.buffer(k2, nil)
+	v.buffer(k1, nil)
+
+	if len(v.order) != 2 || v.order[0] != k1 || v.order[1] != k2 {
+		t.Errorf("TestBuffer: got order %v, want [%v %v]", v.order, k1, k2)
+	}
+	if v.pending[k1].gen != 3 {
+		t.Errorf("TestBuffer: got gen %d for the replaced write, want 3", v.pending[k1].gen)
+	}
+}
@@ -0,0 +1,150 @@
+// Package health provides a Monitor that keeps the health of the plugins in a registry.Register.
+// A plugin is Unavailable until its Init() succeeds, which the Monitor retries on an interval. Once
+// initialized, a plugin is Healthy unless it implements plugins.HealthChecker, which the Monitor then
+// calls on the same interval to find if it is Healthy, Degraded or Unavailable.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+
+	"github.com/gostdlib/concurrency/prim/wait"
+)
+
+// DefaultInterval is the default time between checks of the plugins.
+const DefaultInterval = 30 * time.Second
+
+// checkTimeout is the longest a plugins.HealthChecker.Check() call can take.
+const checkTimeout = 30 * time.Second
+
+// key identifies a version of a plugin.
+type key struct {
+	name    string
+	version string
+}
+
+// Monitor checks the health of plugins and records it in the registry.Register.
+type Monitor struct {
+	reg      *registry.Register
+	interval time.Duration
+
+	// mu makes sure only one Check() runs at a time, so Init() is never called concurrently for a plugin.
+	mu sync.Mutex
+	// initialized holds the plugins whose Init() has succeeded.
+	initialized map[key]bool
+}
+
+// New creates a new Monitor for reg that checks the plugins every interval. If interval <= 0,
+// DefaultInterval is used.
+func New(reg *registry.Register, interval time.Duration) (*Monitor, error) {
+	if reg == nil {
+		return nil, fmt.Errorf("registry is nil")
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Monitor{reg: reg, interval: interval, initialized: map[key]bool{}}, nil
+}
+
+// Run checks the plugins every interval until ctx is cancelled. This blocks.
+func (m *Monitor) Run(ctx context.Context) {
+	t := time.NewTicker(m.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check checks every plugin that is not initialized or implements plugins.HealthChecker and records the
+// result in the registry.Register. It returns when all the checks are done.
+func (m *Monitor) Check(ctx context.Context) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var (
+		g  = wait.Group{}
+		mu sync.Mutex
+		// inited holds the plugins whose Init() succeeded in this Check().
+		inited []key
+	)
+	for plugin := range m.reg.Plugins() {
+		plugin := plugin
+		k := key{name: plugin.Name(), version: plugins.VersionOf(plugin)}
+		initialized := m.initialized[k]
+		if _, ok := plugin.(plugins.HealthChecker); initialized && !ok {
+			continue
+		}
+
+		g.Go(ctx, func(ctx context.Context) error {
+			if !initialized {
+				if err := m.init(plugin); err != nil {
+					m.reg.SetHealth(plugin, registry.Unavailable, err.Error())
+					return nil
+				}
+				mu.Lock()
+				inited = append(inited, k)
+				mu.Unlock()
+				// Init() is expected to have checked what Check() would have.
+				m.reg.SetHealth(plugin, registry.Healthy, "")
+				return nil
+			}
+			m.check(ctx, plugin.(plugins.HealthChecker), plugin)
+			return nil
+		})
+	}
+	g.Wait(ctx)
+
+	for _, k := range inited {
+		m.initialized[k] = true
+	}
+}
+
+// init runs plugin.Init(). A panic is returned as an error.
+func (m *Monitor) init(plugin plugins.Plugin) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin(%s) panicked in Init(): %v", plugin.Name(), r)
+		}
+	}()
+
+	if err := plugin.Init(); err != nil {
+		return fmt.Errorf("plugin(%s) failed to initialize: %w", plugin.Name(), err)
+	}
+	return nil
+}
+
+// check runs hc.Check() and records the health of plugin.
+func (m *Monitor) check(ctx context.Context, hc plugins.HealthChecker, plugin plugins.Plugin) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panicked in Check(): %v", r)
+			}
+		}()
+		return hc.Check(ctx)
+	}()
+
+	switch {
+	case err == nil:
+		m.reg.SetHealth(plugin, registry.Healthy, "")
+	case errors.Is(err, plugins.ErrDegraded):
+		m.reg.SetHealth(plugin, registry.Degraded, fmt.Sprintf("plugin(%s): %s", plugin.Name(), err))
+	default:
+		m.reg.SetHealth(plugin, registry.Unavailable, fmt.Sprintf("plugin(%s) failed its health check: %s", plugin.Name(), err))
+	}
+}
@@ -0,0 +1,173 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+// fakePlugin fails Init() for the first failInits calls. Wrap it in checkingPlugin to implement
+// plugins.HealthChecker.
+type fakePlugin struct {
+	plugins.Plugin
+
+	name      string
+	failInits int64
+	panics    bool
+
+	inits atomic.Int64
+	check atomic.Value // checkResult
+}
+
+func (f *fakePlugin) Name() string                    { return f.name }
+func (f *fakePlugin) Request() any                    { return struct{}{} }
+func (f *fakePlugin) Response() any                   { return struct{}{} }
+func (f *fakePlugin) RetryPolicy() exponential.Policy { return plugins.FastRetryPolicy() }
+
+func (f *fakePlugin) Init() error {
+	if f.panics {
+		panic("boom")
+	}
+	if f.inits.Add(1) <= f.failInits {
+		return fmt.Errorf("not ready")
+	}
+	return nil
+}
+
+type checkingPlugin struct {
+	*fakePlugin
+}
+
+// checkResult is what Check() returns. atomic.Value cannot store a nil error.
+type checkResult struct {
+	err error
+}
+
+func (c checkingPlugin) Check(ctx context.Context) error {
+	r, _ := c.check.Load().(checkResult)
+	return r.err
+}
+
+// setCheck sets what Check() returns.
+func (f *fakePlugin) setCheck(err error) {
+	f.check.Store(checkResult{err: err})
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	ready := &fakePlugin{name: "ready"}
+	flaky := &fakePlugin{name: "flaky", failInits: 1}
+	panics := &fakePlugin{name: "panics", panics: true}
+	remote := &fakePlugin{name: "remote"}
+	remote.setCheck(nil)
+
+	reg := registry.New()
+	reg.MustRegister(ready)
+	reg.MustRegister(flaky)
+	reg.MustRegister(panics)
+	reg.MustRegister(checkingPlugin{remote})
+
+	m, err := New(reg, time.Hour)
+	if err != nil {
+		t.Fatalf("TestCheck(New): got err == %s, want err == nil", err)
+	}
+
+	type want struct {
+		ready, flaky, panics, remote registry.Health
+	}
+	steps := []struct {
+		name   string
+		before func()
+		want   want
+	}{
+		{
+			name: "First check",
+			want: want{registry.Healthy, registry.Unavailable, registry.Unavailable, registry.Healthy},
+		},
+		{
+			name:   "Init is retried and remote is degraded",
+			before: func() { remote.setCheck(fmt.Errorf("lost a backend: %w", plugins.ErrDegraded)) },
+			want:   want{registry.Healthy, registry.Healthy, registry.Unavailable, registry.Degraded},
+		},
+		{
+			name:   "Remote is unavailable",
+			before: func() { remote.setCheck(errors.New("agent is down")) },
+			want:   want{registry.Healthy, registry.Healthy, registry.Unavailable, registry.Unavailable},
+		},
+		{
+			name:   "Remote recovers",
+			before: func() { remote.setCheck(nil) },
+			want:   want{registry.Healthy, registry.Healthy, registry.Unavailable, registry.Healthy},
+		},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+		m.Check(context.Background())
+
+		got := want{
+			ready:  reg.Health(ready).Health,
+			flaky:  reg.Health(flaky).Health,
+			panics: reg.Health(panics).Health,
+			remote: reg.Health(checkingPlugin{remote}).Health,
+		}
+		if got != step.want {
+			t.Errorf("TestCheck(%s): got %+v, want %+v", step.name, got, step.want)
+		}
+	}
+
+	// Init() is only called until it succeeds.
+	if got := ready.inits.Load(); got != 1 {
+		t.Errorf("TestCheck: ready.Init() called %d times, want 1", got)
+	}
+	if got := flaky.inits.Load(); got != 2 {
+		t.Errorf("TestCheck: flaky.Init() called %d times, want 2", got)
+	}
+	if got := reg.Health(panics).Reason; got != "plugin(panics) panicked in Init(): boom" {
+		t.Errorf("TestCheck: got reason %q for panics, want the panic", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	flaky := &fakePlugin{name: "flaky", failInits: 1}
+	reg := registry.New()
+	reg.MustRegister(flaky)
+
+	m, err := New(reg, time.Millisecond)
+	if err != nil {
+		t.Fatalf("TestRun(New): got err == %s, want err == nil", err)
+	}
+	m.Check(context.Background())
+	if got := reg.Health(flaky).Health; got != registry.Unavailable {
+		t.Fatalf("TestRun(first check): got %v, want %v", got, registry.Unavailable)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(ctx)
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for reg.Health(flaky).Health != registry.Healthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("TestRun: plugin did not become %v in the background", registry.Healthy)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
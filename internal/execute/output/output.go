@@ -0,0 +1,224 @@
+// Package output records what plugins write with execinfo.Log() and execinfo.Progress() during an
+// Attempt. A Buffer holds a bounded amount of an Attempt's output to store with the Attempt. A Hub holds
+// the Actions that are running so that their output can be watched live.
+package output
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxLines is the number of lines a Buffer keeps. Older lines are dropped.
+	MaxLines = 200
+	// MaxLineLen is the length in bytes a line is truncated to. A line is cut at the last character
+	// that fits, so it may be shorter.
+	MaxLineLen = 1024
+	// watchBuffer is the number of Events a watcher can fall behind before Events are dropped.
+	watchBuffer = 100
+)
+
+// Event is a line or progress update from a plugin that is executing an Action.
+type Event struct {
+	// Attempt is the number of the attempt, starting at 1.
+	Attempt int
+	// Line is a line the plugin logged. This is the zero value if the Event is a progress update.
+	Line workflow.LogLine
+	// Progress is the last progress percentage the plugin reported.
+	Progress int
+}
+
+// Hub holds the Actions that are running. A nil *Hub is valid and does nothing.
+type Hub struct {
+	mu      sync.Mutex
+	actions map[uuid.UUID]*Action
+}
+
+// NewHub creates a new Hub.
+func NewHub() *Hub {
+	return &Hub{actions: map[uuid.UUID]*Action{}}
+}
+
+// Start registers the Action with id as running and returns it. Call End() when the Action is done.
+func (h *Hub) Start(id uuid.UUID) *Action {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	a := &Action{}
+	h.actions[id] = a
+	return a
+}
+
+// End removes the Action with id and closes all channels watching it.
+func (h *Hub) End(id uuid.UUID) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	a, ok := h.actions[id]
+	delete(h.actions, id)
+	h.mu.Unlock()
+
+	if ok {
+		a.close()
+	}
+}
+
+// Watch returns a channel that receives the Events of the running Action with id. The channel is closed
+// when the Action ends or the Context is cancelled. If the receiver falls behind, Events are dropped.
+// An error is returned if the Action is not running.
+func (h *Hub) Watch(ctx context.Context, id uuid.UUID) (chan Event, error) {
+	if h == nil {
+		return nil, fmt.Errorf("Action(%s) is not running", id)
+	}
+
+	h.mu.Lock()
+	a, ok := h.actions[id]
+	h.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Action(%s) is not running", id)
+	}
+	return a.watch(ctx)
+}
+
+// Action is a running Action. A nil *Action is valid and has no watchers.
+type Action struct {
+	mu       sync.Mutex
+	watchers []watcher
+	closed   bool
+}
+
+type watcher struct {
+	ch chan Event
+	// stop stops the watcher from being removed when its Context is cancelled.
+	stop func() bool
+}
+
+// Attempt returns a Buffer for the attempt with the number n.
+func (a *Action) Attempt(n int) *Buffer {
+	return &Buffer{action: a, attempt: n}
+}
+
+func (a *Action) watch(ctx context.Context) (chan Event, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil, fmt.Errorf("Action is not running")
+	}
+	ch := make(chan Event, watchBuffer)
+	stop := context.AfterFunc(ctx, func() { a.unwatch(ch) })
+	a.watchers = append(a.watchers, watcher{ch: ch, stop: stop})
+	return ch, nil
+}
+
+func (a *Action) unwatch(ch chan Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i, w := range a.watchers {
+		if w.ch == ch {
+			a.watchers = append(a.watchers[:i], a.watchers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+func (a *Action) publish(e Event) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, w := range a.watchers {
+		select {
+		case w.ch <- e:
+		default:
+		}
+	}
+}
+
+func (a *Action) close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.closed = true
+	for _, w := range a.watchers {
+		w.stop()
+		close(w.ch)
+	}
+	a.watchers = nil
+}
+
+// Buffer holds the output of an attempt. It implements execinfo.Output.
+type Buffer struct {
+	action  *Action
+	attempt int
+
+	mu       sync.Mutex
+	lines    []workflow.LogLine
+	dropped  int
+	progress int
+}
+
+var _ execinfo.Output = (*Buffer)(nil)
+
+// Log implements execinfo.Output.Log().
+func (b *Buffer) Log(line string) {
+	if len(line) > MaxLineLen {
+		i := MaxLineLen
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		line = line[:i]
+	}
+	l := workflow.LogLine{Time: time.Now().UTC(), Text: line}
+
+	b.mu.Lock()
+	if len(b.lines) == MaxLines {
+		b.lines = append(b.lines[:0], b.lines[1:]...)
+		b.dropped++
+	}
+	b.lines = append(b.lines, l)
+	progress := b.progress
+	b.mu.Unlock()
+
+	b.action.publish(Event{Attempt: b.attempt, Line: l, Progress: progress})
+}
+
+// Progress implements execinfo.Output.Progress().
+func (b *Buffer) Progress(percent int) {
+	b.mu.Lock()
+	b.progress = percent
+	b.mu.Unlock()
+
+	b.action.publish(Event{Attempt: b.attempt, Progress: percent})
+}
+
+// Record copies the output to the attempt.
+func (b *Buffer) Record(attempt *workflow.Attempt) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.lines) > 0 {
+		attempt.Log = make([]workflow.LogLine, len(b.lines))
+		copy(attempt.Log, b.lines)
+	}
+	attempt.LogDropped = b.dropped
+	attempt.Progress = b.progress
+}
@@ -0,0 +1,132 @@
+package output
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/google/uuid"
+)
+
+func TestBuffer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		lines       []string
+		wantLen     int
+		wantFirst   string
+		wantDropped int
+	}{
+		{
+			name:      "Success: under the bounds",
+			lines:     []string{"a", "b"},
+			wantLen:   2,
+			wantFirst: "a",
+		},
+		{
+			name: "Success: too many lines",
+			lines: func() []string {
+				l := make([]string, MaxLines+5)
+				for i := range l {
+					l[i] = fmt.Sprint(i)
+				}
+				return l
+			}(),
+			wantLen:     MaxLines,
+			wantFirst:   "5",
+			wantDropped: 5,
+		},
+		{
+			name:      "Success: line too long",
+			lines:     []string{strings.Repeat("a", MaxLineLen+1)},
+			wantLen:   1,
+			wantFirst: strings.Repeat("a", MaxLineLen),
+		},
+		{
+			name:      "Success: line too long is not cut inside a character",
+			lines:     []string{strings.Repeat("a", MaxLineLen-1) + "é"},
+			wantLen:   1,
+			wantFirst: strings.Repeat("a", MaxLineLen-1),
+		},
+	}
+
+	for _, test := range tests {
+		// A nil *Action is valid.
+		b := (*Action)(nil).Attempt(1)
+		for _, l := range test.lines {
+			b.Log(l)
+		}
+		b.Progress(50)
+
+		attempt := &workflow.Attempt{}
+		b.Record(attempt)
+
+		if len(attempt.Log) != test.wantLen {
+			t.Errorf("TestBuffer(%s): got %d lines, want %d", test.name, len(attempt.Log), test.wantLen)
+			continue
+		}
+		if attempt.Log[0].Text != test.wantFirst {
+			t.Errorf("TestBuffer(%s): got first line %q, want %q", test.name, attempt.Log[0].Text, test.wantFirst)
+		}
+		if attempt.LogDropped != test.wantDropped {
+			t.Errorf("TestBuffer(%s): got LogDropped %d, want %d", test.name, attempt.LogDropped, test.wantDropped)
+		}
+		if attempt.Progress != 50 {
+			t.Errorf("TestBuffer(%s): got Progress %d, want 50", test.name, attempt.Progress)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	h := NewHub()
+	id := uuid.New()
+
+	if _, err := h.Watch(context.Background(), id); err == nil {
+		t.Errorf("TestWatch: got err == nil watching an Action that is not running, want err != nil")
+	}
+
+	a := h.Start(id)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, err := h.Watch(ctx, id)
+	if err != nil {
+		t.Fatalf("TestWatch: got err == %s, want err == nil", err)
+	}
+	events, err := h.Watch(context.Background(), id)
+	if err != nil {
+		t.Fatalf("TestWatch: got err == %s, want err == nil", err)
+	}
+
+	cancel()
+	// The channel is closed once the Context is cancelled.
+	for range cancelled {
+	}
+
+	b := a.Attempt(2)
+	b.Log("hello")
+	b.Progress(10)
+	h.End(id)
+
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 2 {
+		t.Fatalf("TestWatch: got %d events, want 2", len(got))
+	}
+	if got[0].Attempt != 2 || got[0].Line.Text != "hello" {
+		t.Errorf("TestWatch: got first event %+v, want line 'hello' for attempt 2", got[0])
+	}
+	if got[1].Progress != 10 || got[1].Line.Text != "" {
+		t.Errorf("TestWatch: got second event %+v, want progress 10", got[1])
+	}
+
+	if _, err := h.Watch(context.Background(), id); err == nil {
+		t.Errorf("TestWatch: got err == nil watching an Action that ended, want err != nil")
+	}
+}
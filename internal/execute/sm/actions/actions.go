@@ -2,15 +2,23 @@ package actions
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
+	"runtime/debug"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/element-of-surprise/coercion/interceptors"
+	"github.com/element-of-surprise/coercion/internal/execute/output"
 	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/element-of-surprise/coercion/workflow/utils/clone"
 
 	"github.com/gostdlib/ops/retry/exponential"
 	"github.com/gostdlib/ops/statemachine"
@@ -24,14 +32,31 @@ type Data struct {
 	Updater storage.ActionUpdater
 	// Registry is the registry to get the plugin from.
 	Registry *registry.Register
+	// Chain is the chain of objects that led to the Action. This is passed to Interceptors.
+	Chain []workflow.Object
+	// Interceptors wrap every execution of the plugin. The first is the outermost.
+	Interceptors []interceptors.ExecuteFunc
+	// Outputs is where the Action is registered while it runs, so that its output can be watched.
+	// This may be nil.
+	Outputs *output.Hub
 
 	// plugin is the plugin to run. This is set by the GetPlugin state.
 	plugin plugins.Plugin
+	// live is the Action in Outputs. This is set by the Execute state.
+	live *output.Action
 	// err is the error that occurred during the state machine. As all states must
 	// call the End state, this is the error that will be returned.
 	err error
 }
 
+// ErrStorage is wrapped by errors caused by a write to storage that failed.
+var ErrStorage = errors.New("storage failure")
+
+// storageErr returns an error wrapping ErrStorage for a failed write of an Action.
+func storageErr(err error) error {
+	return fmt.Errorf("%w: failed to write Action: %w", ErrStorage, err)
+}
+
 type nower func() time.Time
 
 // Runner is a state machine that runs a workflow.Action.
@@ -48,7 +73,9 @@ func (r Runner) Start(req statemachine.Request[Data]) statemachine.Request[Data]
 	action.State.Status = workflow.Running
 
 	if err := updater.UpdateAction(req.Ctx, action); err != nil {
-		log.Fatalf("failed to write Action: %v", err)
+		req.Data.err = storageErr(err)
+		req.Next = r.End
+		return req
 	}
 
 	req.Next = r.GetPlugin
@@ -65,13 +92,18 @@ func pluginNotFoundErr(name string) error {
 func (r Runner) GetPlugin(req statemachine.Request[Data]) statemachine.Request[Data] {
 	action := req.Data.Action
 
-	p := req.Data.Registry.Plugin(action.Plugin)
+	p := req.Data.Registry.PluginVersion(action.Plugin, action.Version)
 	// This is defense in depth. The plugin should be checked when the Plan is created.
 	if p == nil {
 		req.Data.err = pluginNotFoundErr(action.Plugin)
 		req.Next = r.End
 		return req
 	}
+	if reason, ok := req.Data.Registry.Quarantined(p); ok {
+		req.Data.err = errPermanent(&plugins.Error{Message: quarantinedMsg(p, reason), Permanent: true})
+		req.Next = r.End
+		return req
+	}
 
 	req.Data.plugin = p
 	req.Next = r.Execute
@@ -79,26 +111,53 @@ func (r Runner) GetPlugin(req statemachine.Request[Data]) statemachine.Request[D
 }
 
 // Execute runs the action using the plugin and writes the result to the store. This
-// function will retry the action based on the plugin's retry policy.
+// function will retry the action based on the action's retry policy if set, otherwise the plugin's. If the action has a
+// MaxDuration, retries will stop when the next attempt would start after the MaxDuration.
 func (r Runner) Execute(req statemachine.Request[Data]) statemachine.Request[Data] {
 	action := req.Data.Action
 	plugin := req.Data.plugin
-	writer := req.Data.Updater
+
+	policy := plugin.RetryPolicy()
+	if action.RetryPolicy != nil {
+		policy = *action.RetryPolicy
+	}
 
 	backoff, err := exponential.New(
-		exponential.WithPolicy(req.Data.plugin.RetryPolicy()),
+		exponential.WithPolicy(policy),
 	)
 	// This should be protected by upper level code. If it fails, we should panic.
 	if err != nil {
 		log.Fatalf("failed to create backoff policy: %v", err)
 	}
 
-	req.Data.err = backoff.Retry(
-		req.Ctx,
+	req.Data.live = req.Data.Outputs.Start(action.ID)
+	defer req.Data.Outputs.End(action.ID)
+
+	ctx := req.Ctx
+	if action.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, action.State.Start.Add(action.MaxDuration))
+		defer cancel()
+	}
+
+	err = backoff.Retry(
+		ctx,
 		func(ctx context.Context, record exponential.Record) error {
-			return r.exec(ctx, action, plugin, writer)
+			return r.exec(ctx, req.Data)
 		},
 	)
+	// If our budget ran out before the parent Context was cancelled, say so instead of just
+	// saying the retries were cancelled.
+	if err != nil && action.MaxDuration > 0 && errors.Is(err, exponential.ErrRetryCanceled) && req.Ctx.Err() == nil {
+		err = fmt.Errorf("%s(%v): %w", budgetExceededMsg, action.MaxDuration, err)
+	}
+	if req.Data.Registry.QuarantineOnPanic() && panicked(action) {
+		last := action.Attempts[len(action.Attempts)-1]
+		if qErr := req.Data.Registry.Quarantine(plugin, last.Err.Message); qErr != nil {
+			log.Printf("failed to quarantine plugin(%s): %v", plugin.Name(), qErr)
+		}
+	}
+	req.Data.err = err
 	req.Next = r.End
 	return req
 }
@@ -116,10 +175,10 @@ func (r Runner) End(req statemachine.Request[Data]) statemachine.Request[Data] {
 
 	action.State.End = r.now()
 
-	if err := updater.UpdateAction(req.Ctx, action); err != nil {
-		log.Fatalf("failed to write Action: %v", err)
-	}
 	req.Err = req.Data.err
+	if err := updater.UpdateAction(req.Ctx, action); err != nil && !errors.Is(req.Err, ErrStorage) {
+		req.Err = errors.Join(req.Err, storageErr(err))
+	}
 	return req
 }
 
@@ -127,38 +186,103 @@ func (r Runner) End(req statemachine.Request[Data]) statemachine.Request[Data] {
 // to syncronize changes with test code.
 const pluginTimeoutMsg = "plugin execution timed out"
 
+// budgetExceededMsg is the message returned when an action has used all of its MaxDuration. Set here
+// to syncronize changes with test code.
+const budgetExceededMsg = "action exceeded its max duration"
+
+// panicMsg returns the message for when a plugin panics. This is used to syncronize changes with test code.
+func panicMsg(plugin plugins.Plugin, v any) string {
+	return fmt.Sprintf("plugin(%s) panicked: %v", plugin.Name(), v)
+}
+
+// quarantinedMsg returns the message for when an Action uses a quarantined plugin. This is used to syncronize
+// changes with test code.
+func quarantinedMsg(plugin plugins.Plugin, reason string) string {
+	if v := plugins.VersionOf(plugin); v != "" {
+		return fmt.Sprintf("plugin(%s) version %s is quarantined: %s", plugin.Name(), v, reason)
+	}
+	return fmt.Sprintf("plugin(%s) is quarantined: %s", plugin.Name(), reason)
+}
+
+// abandonedLimitMsg returns the message for when a plugin refuses to execute because it has too many
+// abandoned executions. This is used to syncronize changes with test code.
+func abandonedLimitMsg(plugin plugins.Plugin, n int64, max int) string {
+	return fmt.Sprintf("plugin(%s) has %d abandoned executions, which is more than its limit of %d", plugin.Name(), n, max)
+}
+
 // unexpectedTypeMsg returns a message for when a plugin returns an unexpected response type.
 // This is used to syncronize changes with test code.
 func unexpectedTypeMsg(plugin plugins.Plugin, got, want any) string {
 	return fmt.Sprintf("plugin(%s) returned a type %T but expected %T", plugin.Name(), got, want)
 }
 
-// exec runs the action once using the plugin and writes the result to the store, unless the action
-// has exceeded the maximum number of retries. In that case, it returns a permanent error.
-func (r Runner) exec(ctx context.Context, action *workflow.Action, plugin plugins.Plugin, updater storage.ActionUpdater) error {
+// exec runs the data.Action once using the plugin and writes the result to the store, unless the action
+// has exceeded the maximum number of retries or its MaxDuration. In that case, it returns a permanent error.
+// If the action has a MaxDuration, the attempt's timeout is reduced to what remains of it.
+// If the plugin has more abandoned executions in the registry than it allows, the attempt fails without running the plugin.
+func (r Runner) exec(ctx context.Context, data Data) (err error) {
+	action, plugin, reg, updater := data.Action, data.plugin, data.Registry, data.Updater
+
 	if len(action.Attempts) > action.Retries {
 		return exponential.ErrPermanent
 	}
 
+	timeout, budgeted := r.attemptTimeout(action)
+	if timeout <= 0 {
+		return errPermanent(&plugins.Error{Message: budgetExceededMsg, Permanent: true})
+	}
+
 	defer func() {
-		if err := updater.UpdateAction(ctx, action); err != nil {
-			log.Fatalf("failed to write Action: %v", err)
+		action.Spent = r.now().Sub(action.State.Start)
+		// ctx ends with the MaxDuration of the action, but an attempt that used it up must still be recorded.
+		if werr := updater.UpdateAction(context.WithoutCancel(ctx), action); werr != nil {
+			// We can't retry if we can't record the attempt.
+			err = fmt.Errorf("%w: %w", exponential.ErrPermanent, storageErr(werr))
 		}
 	}()
 
 	attempt := &workflow.Attempt{
-		Start: r.now(),
+		PluginVersion: plugins.VersionOf(plugin),
+		Start:         r.now(),
 	}
 	defer func() {
 		action.Attempts = append(action.Attempts, attempt)
 	}()
 
-	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), action.Timeout)
-	plugResp := run(runCtx, plugin, action.Req)
+	if msg := abandonedLimit(plugin, reg); msg != "" {
+		attempt.End = r.now()
+		attempt.Err = &plugins.Error{Message: msg}
+		return attempt.Err
+	}
+
+	call := interceptors.Call{
+		Action:  action,
+		Chain:   data.Chain,
+		Attempt: len(action.Attempts) + 1,
+		Req:     action.Req,
+	}
+
+	out := data.live.Attempt(call.Attempt)
+	cp := &checkpointer{action: action, updater: updater}
+
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	runCtx = execinfo.NewContext(runCtx, execInfo(runCtx, call, out, cp))
+	plugResp := run(runCtx, reg, plugin, data.Interceptors, call)
 	cancel()
+	// An abandoned plugin must not change the Action after this point.
+	cp.close()
 	attempt.End = r.now()
+	out.Record(attempt)
 
 	if plugResp.timeout {
+		// If we timed out because the MaxDuration was reached, there is no time for another attempt.
+		if budgeted {
+			attempt.Err = &plugins.Error{
+				Message:   budgetExceededMsg,
+				Permanent: true,
+			}
+			return errPermanent(attempt.Err)
+		}
 		attempt.Err = &plugins.Error{
 			Message:   pluginTimeoutMsg,
 			Permanent: false,
@@ -167,6 +291,7 @@ func (r Runner) exec(ctx context.Context, action *workflow.Action, plugin plugin
 	} else {
 		attempt.Resp = plugResp.Resp
 		attempt.Err = plugResp.Err
+		attempt.Stack = plugResp.stack
 	}
 
 	// We make sure the response is the expected type. If not, we return a permanent error.
@@ -183,12 +308,125 @@ func (r Runner) exec(ctx context.Context, action *workflow.Action, plugin plugin
 		}
 		attempt.Resp = nil
 	}
-	if attempt.Err.Permanent {
+	if attempt.Err.Permanent || !retryable(action, attempt.Err) {
 		return errPermanent(attempt.Err)
 	}
 	return attempt.Err
 }
 
+// execInfo returns the execinfo.Info that is passed to the plugin for call. ctx must have the attempt's deadline.
+func execInfo(ctx context.Context, call interceptors.Call, out execinfo.Output, cp execinfo.Checkpointer) execinfo.Info {
+	info := execinfo.Info{
+		ActionID:     call.Action.ID,
+		ActionName:   call.Action.Name,
+		Chain:        call.Chain,
+		Attempt:      call.Attempt,
+		Output:       out,
+		Checkpointer: cp,
+	}
+	info.Deadline, _ = ctx.Deadline()
+	if len(call.Chain) > 0 {
+		if plan, ok := call.Chain[0].(*workflow.Plan); ok {
+			info.PlanID, info.PlanName = plan.ID, plan.Name
+		}
+	}
+	info.Logger = execinfo.NewLogger(nil, info)
+	return info
+}
+
+// checkpointer implements execinfo.Checkpointer for an attempt of an Action. Saving a checkpoint writes the
+// Action to storage. Once the attempt is over, close() must be called and further saves return an error.
+type checkpointer struct {
+	action  *workflow.Action
+	updater storage.ActionUpdater
+
+	mu     sync.Mutex
+	closed bool
+}
+
+var _ execinfo.Checkpointer = (*checkpointer)(nil)
+
+// Checkpoint implements execinfo.Checkpointer.Checkpoint().
+func (c *checkpointer) Checkpoint() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.action.Checkpoint
+}
+
+// SaveCheckpoint implements execinfo.Checkpointer.SaveCheckpoint().
+func (c *checkpointer) SaveCheckpoint(ctx context.Context, data []byte) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return errors.New("cannot save a checkpoint after the attempt has ended")
+	}
+	c.action.Checkpoint = slices.Clone(data)
+	// A copy is written so the lock isn't held while storage is slow, which would hold up the end of the attempt.
+	action := clone.Action(ctx, c.action, clone.WithKeepSecrets(), clone.WithKeepState())
+	c.mu.Unlock()
+
+	if err := c.updater.UpdateAction(context.WithoutCancel(ctx), action); err != nil {
+		return fmt.Errorf("couldn't save checkpoint: %w", storageErr(err))
+	}
+	return nil
+}
+
+// close stops the checkpointer from changing the Action. It does not wait for a save in progress to be written.
+func (c *checkpointer) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+}
+
+// abandonedLimit returns a message if the plugin implements plugins.AbandonLimiter and has more abandoned
+// executions in reg than it allows. Otherwise it returns an empty string.
+func abandonedLimit(plugin plugins.Plugin, reg *registry.Register) string {
+	limiter, ok := plugin.(plugins.AbandonLimiter)
+	if !ok || limiter.MaxAbandoned() <= 0 {
+		return ""
+	}
+	if n := reg.Abandoned(plugin); n > int64(limiter.MaxAbandoned()) {
+		return abandonedLimitMsg(plugin, n, limiter.MaxAbandoned())
+	}
+	return ""
+}
+
+// panicked returns true if the last attempt of the action ended in a plugin panic.
+func panicked(action *workflow.Action) bool {
+	if len(action.Attempts) == 0 {
+		return false
+	}
+	return action.Attempts[len(action.Attempts)-1].Stack != ""
+}
+
+// retryable returns true if an error returned by the plugin may be retried according to
+// the action's RetryOn and PermanentOn rules.
+func retryable(action *workflow.Action, err *plugins.Error) bool {
+	if slices.Contains(action.PermanentOn, err.Code) {
+		return false
+	}
+	if len(action.RetryOn) > 0 && !slices.Contains(action.RetryOn, err.Code) {
+		return false
+	}
+	return true
+}
+
+// attemptTimeout returns the timeout for the next attempt of the action. This is the action's Timeout,
+// unless the action has a MaxDuration that would end before it. In that case, it returns what remains
+// of the MaxDuration and budgeted is set to true. A timeout <= 0 means the MaxDuration has been used up.
+func (r Runner) attemptTimeout(action *workflow.Action) (timeout time.Duration, budgeted bool) {
+	if action.MaxDuration <= 0 {
+		return action.Timeout, false
+	}
+	remaining := action.State.Start.Add(action.MaxDuration).Sub(r.now())
+	if remaining < action.Timeout {
+		return remaining, true
+	}
+	return action.Timeout, false
+}
+
 func errPermanent(err *plugins.Error) error {
 	return fmt.Errorf("%w: %w", exponential.ErrPermanent, err)
 }
@@ -204,27 +442,61 @@ type plugResp struct {
 	Resp    any
 	Err     *plugins.Error
 	timeout bool
+	// stack is the stack trace if the plugin panicked.
+	stack string
 }
 
-// run executes the plugin in a goroutine and returns the response or an error if the context is done.
-func run(ctx context.Context, plugin plugins.Plugin, req any) plugResp {
+// run executes the plugin through the interceptors in a goroutine and returns the response or an error if
+// the context is done. If the plugin or an interceptor panics, the panic is recovered and returned as a
+// permanent error with the stack trace. If the context is done before the plugin returns, the execution is
+// recorded as abandoned in reg until the plugin returns.
+func run(ctx context.Context, reg *registry.Register, plugin plugins.Plugin, ics []interceptors.ExecuteFunc, call interceptors.Call) plugResp {
 	ch := make(chan plugResp, 1)
 	go func() {
 		defer close(ch)
+		defer func() {
+			if v := recover(); v != nil {
+				ch <- plugResp{
+					Err:   &plugins.Error{Message: panicMsg(plugin, v), Permanent: true},
+					stack: string(debug.Stack()),
+				}
+			}
+		}()
 
 		plugResp := plugResp{}
-		plugResp.Resp, plugResp.Err = plugin.Execute(ctx, req)
+		plugResp.Resp, plugResp.Err = intercept(plugin, ics)(ctx, call)
 		ch <- plugResp
 	}()
 
 	select {
 	case <-ctx.Done():
+		returned := reg.Abandon(plugin)
+		go func() {
+			for range ch {
+			}
+			returned()
+		}()
 		return plugResp{timeout: true}
 	case resp := <-ch:
 		return resp
 	}
 }
 
+// intercept returns an interceptors.Next that executes the plugin through ics. The first interceptor
+// is the outermost.
+func intercept(plugin plugins.Plugin, ics []interceptors.ExecuteFunc) interceptors.Next {
+	next := func(ctx context.Context, call interceptors.Call) (any, *plugins.Error) {
+		return plugin.Execute(ctx, call.Req)
+	}
+	for i := len(ics) - 1; i >= 0; i-- {
+		ic, n := ics[i], next
+		next = func(ctx context.Context, call interceptors.Call) (any, *plugins.Error) {
+			return ic(ctx, call, n)
+		}
+	}
+	return next
+}
+
 func isType(a, b interface{}) bool {
 	return reflect.TypeOf(a) == reflect.TypeOf(b)
 }
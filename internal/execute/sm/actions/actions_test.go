@@ -9,12 +9,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/element-of-surprise/coercion/interceptors"
+	"github.com/element-of-surprise/coercion/internal/execute/output"
 	testplugin "github.com/element-of-surprise/coercion/internal/execute/sm/testing/plugins"
 	"github.com/element-of-surprise/coercion/internal/private"
 	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage/sqlite"
+	"github.com/google/uuid"
 
 	"github.com/gostdlib/ops/retry/exponential"
 	"github.com/gostdlib/ops/statemachine"
@@ -25,6 +28,9 @@ type fakeUpdater struct {
 	updates  []*workflow.Action
 	index    int
 	retErrOn int
+	// degraded makes writes with a Context that has a deadline wait for it and fail with its error, like
+	// degraded storage that does not recover in time.
+	degraded bool
 
 	private.Storage
 }
@@ -46,6 +52,12 @@ func (f *fakeUpdater) UpdateAction(ctx context.Context, action *workflow.Action)
 	if f.index == f.retErrOn {
 		return errors.New("fake error")
 	}
+	if f.degraded {
+		if _, ok := ctx.Deadline(); ok {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+	}
 	f.updates = append(f.updates, action)
 	return nil
 }
@@ -91,6 +103,27 @@ func TestStart(t *testing.T) {
 	}
 }
 
+func TestStartStorage(t *testing.T) {
+	t.Parallel()
+
+	data := Data{
+		Action: &workflow.Action{
+			State: &workflow.State{},
+		},
+		Updater: newFakeUpdater().SetRetErrOn(0),
+	}
+
+	sm := Runner{nower: time.Now}
+	req := sm.Start(statemachine.Request[Data]{Ctx: context.Background(), Data: data, Next: sm.Start})
+
+	if !errors.Is(req.Data.err, ErrStorage) {
+		t.Errorf("TestStartStorage: got Data.err == %v, want err wrapping ErrStorage", req.Data.err)
+	}
+	if methodName(req.Next) != methodName(sm.End) {
+		t.Errorf("TestStartStorage: got Request.Next %s, want %s", methodName(req.Next), methodName(sm.End))
+	}
+}
+
 func TestGetPlugin(t *testing.T) {
 	t.Parallel()
 
@@ -104,6 +137,25 @@ func TestGetPlugin(t *testing.T) {
 	reg := registry.New()
 	reg.Register(&testplugin.Plugin{})
 
+	vReg := registry.New()
+	vReg.Register(&testplugin.Plugin{PlugVersion: "1.0.0"})
+	vReg.Register(&testplugin.Plugin{PlugVersion: "2.0.0"})
+
+	// Only version 1.0.0 is quarantined.
+	qvReg := registry.New()
+	qvReg.Register(&testplugin.Plugin{PlugVersion: "1.0.0"})
+	qvReg.Register(&testplugin.Plugin{PlugVersion: "2.0.0"})
+	if err := qvReg.Quarantine(qvReg.PluginVersion(testplugin.Name, "1.0.0"), "panicked"); err != nil {
+		panic(err)
+	}
+
+	qPlugin := &testplugin.Plugin{PlugName: "quarantined"}
+	qReg := registry.New()
+	qReg.Register(qPlugin)
+	if err := qReg.Quarantine(qPlugin, "panicked"); err != nil {
+		panic(err)
+	}
+
 	tests := []struct {
 		name     string
 		data     Data
@@ -126,6 +178,22 @@ func TestGetPlugin(t *testing.T) {
 			},
 			wantNext: methodName(sm.End),
 		},
+		{
+			name: "Plugin quarantined",
+			data: Data{
+				Action: &workflow.Action{
+					Plugin: "quarantined",
+				},
+				Registry: qReg,
+			},
+			wantData: Data{
+				Action: &workflow.Action{
+					Plugin: "quarantined",
+				},
+				err: errPermanent(&plugins.Error{Message: quarantinedMsg(qPlugin, "panicked"), Permanent: true}),
+			},
+			wantNext: methodName(sm.End),
+		},
 		{
 			name: "Plugin found",
 			data: Data{
@@ -142,6 +210,78 @@ func TestGetPlugin(t *testing.T) {
 			},
 			wantNext: methodName(sm.Execute),
 		},
+		{
+			name: "Plugin version not found",
+			data: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "^3.0.0",
+				},
+				Registry: vReg,
+			},
+			wantData: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "^3.0.0",
+				},
+				err: pluginNotFoundErr(testplugin.Name),
+			},
+			wantNext: methodName(sm.End),
+		},
+		{
+			name: "Plugin version found",
+			data: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "^1.0.0",
+				},
+				Registry: vReg,
+			},
+			wantData: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "^1.0.0",
+				},
+				plugin: vReg.PluginVersion(testplugin.Name, "1.0.0"),
+			},
+			wantNext: methodName(sm.Execute),
+		},
+		{
+			name: "Plugin version quarantined",
+			data: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "1.0.0",
+				},
+				Registry: qvReg,
+			},
+			wantData: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "1.0.0",
+				},
+				err: errPermanent(&plugins.Error{Message: quarantinedMsg(qvReg.PluginVersion(testplugin.Name, "1.0.0"), "panicked"), Permanent: true}),
+			},
+			wantNext: methodName(sm.End),
+		},
+		{
+			name: "Other plugin version not quarantined",
+			data: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "2.0.0",
+				},
+				Registry: qvReg,
+			},
+			wantData: Data{
+				Action: &workflow.Action{
+					Plugin:  testplugin.Name,
+					Version: "2.0.0",
+				},
+				plugin: qvReg.PluginVersion(testplugin.Name, "2.0.0"),
+			},
+			wantNext: methodName(sm.Execute),
+		},
 	}
 	for _, test := range tests {
 		req := sm.GetPlugin(statemachine.Request[Data]{Ctx: context.Background(), Data: test.data, Next: sm.GetPlugin})
@@ -177,7 +317,7 @@ func TestExecute(t *testing.T) {
 			name: "Failed after a retry",
 			data: Data{
 				Action: &workflow.Action{
-					State:   &workflow.State{},
+					State:   &workflow.State{Start: now},
 					Plugin:  testplugin.Name,
 					Timeout: 1 * time.Second,
 					Retries: 1,
@@ -189,7 +329,7 @@ func TestExecute(t *testing.T) {
 			},
 			wantData: Data{
 				Action: &workflow.Action{
-					State:   &workflow.State{},
+					State:   &workflow.State{Start: now},
 					Plugin:  testplugin.Name,
 					Timeout: 1 * time.Second,
 					Retries: 1,
@@ -214,33 +354,36 @@ func TestExecute(t *testing.T) {
 			name: "Success after retry",
 			data: Data{
 				Action: &workflow.Action{
-					State:   &workflow.State{},
+					State:   &workflow.State{Start: now},
 					Plugin:  testplugin.Name,
 					Timeout: 1 * time.Second,
 					Retries: 1,
 					Req:     testplugin.Req{},
 				},
 				plugin: &testplugin.Plugin{
-					Responses: []any{pluginErr, testplugin.Resp{Arg: "ok"}},
+					PlugVersion: "1.2.0",
+					Responses:   []any{pluginErr, testplugin.Resp{Arg: "ok"}},
 				},
 			},
 			wantData: Data{
 				Action: &workflow.Action{
-					State:   &workflow.State{},
+					State:   &workflow.State{Start: now},
 					Plugin:  testplugin.Name,
 					Timeout: 1 * time.Second,
 					Retries: 1,
 					Req:     testplugin.Req{},
 					Attempts: []*workflow.Attempt{
 						{
-							Err:   &plugins.Error{Message: pluginErr.Error()},
-							Start: now,
-							End:   now,
+							Err:           &plugins.Error{Message: pluginErr.Error()},
+							PluginVersion: "1.2.0",
+							Start:         now,
+							End:           now,
 						},
 						{
-							Resp:  testplugin.Resp{Arg: "ok"},
-							Start: now,
-							End:   now,
+							Resp:          testplugin.Resp{Arg: "ok"},
+							PluginVersion: "1.2.0",
+							Start:         now,
+							End:           now,
 						},
 					},
 				},
@@ -269,6 +412,103 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+// TestExecuteBudgetStorage tests that an attempt that uses up the MaxDuration of its Action is still
+// written to storage, instead of failing to write with the expired Context of the budget.
+func TestExecuteBudgetStorage(t *testing.T) {
+	t.Parallel()
+
+	action := &workflow.Action{
+		State:       &workflow.State{Start: time.Now().UTC()},
+		Plugin:      testplugin.Name,
+		Timeout:     time.Second,
+		MaxDuration: 50 * time.Millisecond,
+		Retries:     1,
+		Req:         testplugin.Req{Sleep: time.Second},
+	}
+	updater := newFakeUpdater()
+	updater.degraded = true
+	data := Data{
+		Action:  action,
+		Updater: updater,
+		plugin:  &testplugin.Plugin{AlwaysRespond: true},
+	}
+
+	req := Runner{}.Execute(statemachine.Request[Data]{Ctx: context.Background(), Data: data})
+	if req.Data.err == nil {
+		t.Fatalf("TestExecuteBudgetStorage: got err == nil, want err != nil")
+	}
+	if errors.Is(req.Data.err, ErrStorage) {
+		t.Errorf("TestExecuteBudgetStorage: got err == %s, want it to not be a storage error", req.Data.err)
+	}
+	if len(updater.updates) == 0 {
+		t.Errorf("TestExecuteBudgetStorage: the attempt was not written")
+	}
+}
+
+func TestExecutePanic(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		options        []registry.Option
+		wantQuarantine bool
+	}{
+		{
+			name: "Panic without quarantine",
+		},
+		{
+			name:           "Panic with quarantine",
+			options:        []registry.Option{registry.WithQuarantineOnPanic()},
+			wantQuarantine: true,
+		},
+	}
+
+	for _, test := range tests {
+		plugin := &testplugin.Plugin{AlwaysRespond: true}
+		reg := registry.New(test.options...)
+		reg.MustRegister(plugin)
+
+		data := Data{
+			Action: &workflow.Action{
+				State:   &workflow.State{Start: time.Now()},
+				Plugin:  testplugin.Name,
+				Timeout: 1 * time.Second,
+				Retries: 2,
+				Req:     testplugin.Req{Panic: true},
+			},
+			Updater:  newFakeUpdater(),
+			Registry: reg,
+			plugin:   plugin,
+		}
+
+		sm := Runner{}
+		req := sm.Execute(statemachine.Request[Data]{Ctx: context.Background(), Data: data})
+
+		if req.Data.err == nil {
+			t.Errorf("TestExecutePanic(%s): got err == nil, want err != nil", test.name)
+		}
+		// A panic is a permanent error, so there should not be any retries.
+		if len(req.Data.Action.Attempts) != 1 {
+			t.Fatalf("TestExecutePanic(%s): got %d attempts, want 1", test.name, len(req.Data.Action.Attempts))
+		}
+		attempt := req.Data.Action.Attempts[0]
+		if attempt.Err == nil || !attempt.Err.Permanent {
+			t.Errorf("TestExecutePanic(%s): got attempt.Err == %v, want permanent error", test.name, attempt.Err)
+		}
+		if !strings.Contains(attempt.Stack, "goroutine") {
+			t.Errorf("TestExecutePanic(%s): got attempt.Stack == %q, want stack trace", test.name, attempt.Stack)
+		}
+
+		reason, ok := reg.Quarantined(plugin)
+		if ok != test.wantQuarantine {
+			t.Errorf("TestExecutePanic(%s): got quarantined == %v, want %v", test.name, ok, test.wantQuarantine)
+		}
+		if ok && reason != attempt.Err.Message {
+			t.Errorf("TestExecutePanic(%s): got quarantine reason %q, want %q", test.name, reason, attempt.Err.Message)
+		}
+	}
+}
+
 func TestEnd(t *testing.T) {
 	t.Parallel()
 
@@ -285,6 +525,7 @@ func TestEnd(t *testing.T) {
 		data         Data
 		wantDBAction *workflow.Action
 		wantErr      bool
+		wantStorage  bool
 	}{
 		{
 			name: "Data had error, so action should be marked as failed",
@@ -318,6 +559,23 @@ func TestEnd(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Action could not be written",
+			data: Data{
+				Action: &workflow.Action{
+					State: &workflow.State{},
+				},
+				Updater: newFakeUpdater().SetRetErrOn(0),
+			},
+			wantDBAction: &workflow.Action{
+				State: &workflow.State{
+					Status: workflow.Completed,
+					End:    now,
+				},
+			},
+			wantErr:     true,
+			wantStorage: true,
+		},
 	}
 
 	sm := Runner{nower: nower}
@@ -331,6 +589,9 @@ func TestEnd(t *testing.T) {
 		if test.wantErr != (req.Err != nil) {
 			t.Errorf("TestEnd(%s): gotErr=%v, wantErr=%v", test.name, test.wantErr, req.Err)
 		}
+		if test.wantStorage != errors.Is(req.Err, ErrStorage) {
+			t.Errorf("TestEnd(%s): got err == %v, want storage error == %v", test.name, req.Err, test.wantStorage)
+		}
 	}
 }
 
@@ -350,6 +611,8 @@ func TestExec(t *testing.T) {
 		ctx    context.Context
 		plugin plugins.Plugin
 		action *workflow.Action
+		// abandoned is the number of abandoned executions of the plugin before exec is called.
+		abandoned int
 
 		wantAttempts []*workflow.Attempt
 		wantErr      bool
@@ -394,6 +657,119 @@ func TestExec(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Too many abandoned executions",
+			ctx:  context.Background(),
+			plugin: &testplugin.Plugin{
+				AlwaysRespond: true,
+				MaxAbandon:    1,
+			},
+			action: &workflow.Action{
+				Req:     testplugin.Req{Arg: "ok"},
+				Timeout: 100 * time.Millisecond,
+				State:   &workflow.State{},
+			},
+			abandoned: 2,
+			wantAttempts: []*workflow.Attempt{
+				{
+					Err: &plugins.Error{
+						Message: abandonedLimitMsg(&testplugin.Plugin{}, 2, 1),
+					},
+					Start: now,
+					End:   now,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Abandoned executions within limit",
+			ctx:  context.Background(),
+			plugin: &testplugin.Plugin{
+				Responses: []any{
+					testplugin.Resp{Arg: "ok"},
+				},
+				MaxAbandon: 1,
+			},
+			action: &workflow.Action{
+				Req:     testplugin.Req{Arg: "ok"},
+				Timeout: 100 * time.Millisecond,
+				State:   &workflow.State{},
+			},
+			abandoned: 1,
+			wantAttempts: []*workflow.Attempt{
+				{
+					Resp:  &testplugin.Resp{Arg: "ok"},
+					Start: now,
+					End:   now,
+				},
+			},
+		},
+		{
+			name: "MaxDuration already used",
+			ctx:  context.Background(),
+			plugin: &testplugin.Plugin{
+				AlwaysRespond: true,
+			},
+			action: &workflow.Action{
+				Req:         testplugin.Req{Arg: "ok"},
+				Timeout:     10 * time.Second,
+				MaxDuration: time.Minute,
+				State:       &workflow.State{Start: now.Add(-2 * time.Minute)},
+			},
+			wantErr:      true,
+			errPermanent: true,
+		},
+		{
+			name: "Timeout reduced to fit MaxDuration",
+			ctx:  context.Background(),
+			plugin: &testplugin.Plugin{
+				Responses: []any{
+					testplugin.Resp{Arg: "ok"},
+				},
+			},
+			action: &workflow.Action{
+				Req:         testplugin.Req{Arg: "error", Sleep: time.Second},
+				Timeout:     10 * time.Second,
+				MaxDuration: 10 * time.Millisecond,
+				State:       &workflow.State{Start: now},
+			},
+			wantAttempts: []*workflow.Attempt{
+				{
+					Err: &plugins.Error{
+						Message:   budgetExceededMsg,
+						Permanent: true,
+					},
+					Start: now,
+					End:   now,
+				},
+			},
+			wantErr:      true,
+			errPermanent: true,
+		},
+		{
+			name: "Error code is in PermanentOn",
+			ctx:  context.Background(),
+			plugin: &testplugin.Plugin{
+				Responses: []any{
+					&plugins.Error{Code: 2, Message: "error"},
+				},
+			},
+			action: &workflow.Action{
+				Req:         testplugin.Req{Arg: "ok"},
+				Timeout:     100 * time.Millisecond,
+				PermanentOn: []plugins.ErrCode{2},
+				State:       &workflow.State{},
+			},
+			wantAttempts: []*workflow.Attempt{
+				{
+					Err:   &plugins.Error{Code: 2, Message: "error"},
+					Start: now,
+					End:   now,
+				},
+			},
+			wantErr:      true,
+			errPermanent: true,
+		},
 		{
 			name: "Unexpected response type",
 			ctx:  context.Background(),
@@ -451,7 +827,12 @@ func TestExec(t *testing.T) {
 		}
 		defer rw.Close(context.Background())
 
-		err = sm.exec(test.ctx, test.action, test.plugin, rw)
+		execReg := registry.New()
+		for i := 0; i < test.abandoned; i++ {
+			execReg.Abandon(test.plugin)
+		}
+
+		err = sm.exec(test.ctx, Data{Action: test.action, Updater: rw, Registry: execReg, plugin: test.plugin})
 
 		switch {
 		case err == nil && test.wantErr:
@@ -472,6 +853,164 @@ func TestExec(t *testing.T) {
 	}
 }
 
+func TestExecOutput(t *testing.T) {
+	t.Parallel()
+
+	rw, err := sqlite.New(context.Background(), "", registry.New(), sqlite.WithInMemory())
+	if err != nil {
+		t.Fatalf("TestExecOutput: failed to create writer: %v", err)
+	}
+	defer rw.Close(context.Background())
+
+	action := &workflow.Action{
+		ID:      uuid.New(),
+		Req:     testplugin.Req{Log: "hello"},
+		Timeout: 10 * time.Second,
+		State:   &workflow.State{},
+	}
+
+	hub := output.NewHub()
+	data := Data{
+		Action:   action,
+		Updater:  rw,
+		Registry: registry.New(),
+		Outputs:  hub,
+		plugin:   &testplugin.Plugin{AlwaysRespond: true},
+		live:     hub.Start(action.ID),
+	}
+	events, err := hub.Watch(context.Background(), action.ID)
+	if err != nil {
+		t.Fatalf("TestExecOutput: Watch() error: %v", err)
+	}
+
+	sm := Runner{}
+	if err := sm.exec(context.Background(), data); err != nil {
+		t.Fatalf("TestExecOutput: got err == %v, want err == nil", err)
+	}
+	hub.End(action.ID)
+
+	attempt := action.Attempts[0]
+	if len(attempt.Log) != 1 || attempt.Log[0].Text != "hello" {
+		t.Errorf("TestExecOutput: got Attempt.Log %v, want a single line of 'hello'", attempt.Log)
+	}
+	if attempt.Progress != 100 {
+		t.Errorf("TestExecOutput: got Attempt.Progress %d, want 100", attempt.Progress)
+	}
+
+	var got []output.Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 2 || got[0].Line.Text != "hello" || got[1].Progress != 100 || got[0].Attempt != 1 {
+		t.Errorf("TestExecOutput: got events %+v, want a line and a progress update for attempt 1", got)
+	}
+}
+
+func TestExecCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	plugin := &testplugin.Plugin{
+		Responses: []any{
+			&plugins.Error{Message: "error"},
+			testplugin.Resp{Arg: "ok"},
+		},
+	}
+	action := &workflow.Action{
+		ID:      uuid.New(),
+		Req:     testplugin.Req{Checkpoint: "step"},
+		Timeout: 10 * time.Second,
+		Retries: 1,
+		State:   &workflow.State{},
+	}
+	updater := newFakeUpdater()
+	data := Data{Action: action, Updater: updater, Registry: registry.New(), plugin: plugin}
+
+	sm := Runner{}
+	if err := sm.exec(context.Background(), data); err == nil {
+		t.Fatalf("TestExecCheckpoint: got err == nil on the first attempt, want err != nil")
+	}
+	if string(action.Checkpoint) != "step" {
+		t.Fatalf("TestExecCheckpoint: got Action.Checkpoint %q after the first attempt, want 'step'", action.Checkpoint)
+	}
+	if err := sm.exec(context.Background(), data); err != nil {
+		t.Fatalf("TestExecCheckpoint: got err == %s on the second attempt, want err == nil", err)
+	}
+
+	// The first attempt had no checkpoint, the second got the one saved by the first.
+	if diff := pretty.Compare([]string{"", "step"}, plugin.Checkpoints); diff != "" {
+		t.Errorf("TestExecCheckpoint: checkpoints given to the plugin: -want/+got:\n%s", diff)
+	}
+	// Each attempt writes the Action when the checkpoint is saved and when the attempt ends.
+	if len(updater.updates) != 4 {
+		t.Errorf("TestExecCheckpoint: got %d writes of the Action, want 4", len(updater.updates))
+	}
+}
+
+func TestCheckpointer(t *testing.T) {
+	t.Parallel()
+
+	action := &workflow.Action{ID: uuid.New(), State: &workflow.State{}}
+	cp := &checkpointer{action: action, updater: newFakeUpdater().SetRetErrOn(0)}
+
+	if err := cp.SaveCheckpoint(context.Background(), []byte("a")); !errors.Is(err, ErrStorage) {
+		t.Errorf("TestCheckpointer: got err == %v on a failed write, want ErrStorage", err)
+	}
+	if err := cp.SaveCheckpoint(context.Background(), []byte("b")); err != nil {
+		t.Errorf("TestCheckpointer: got err == %s, want err == nil", err)
+	}
+	if string(cp.Checkpoint()) != "b" {
+		t.Errorf("TestCheckpointer: got checkpoint %q, want 'b'", cp.Checkpoint())
+	}
+
+	cp.close()
+	if err := cp.SaveCheckpoint(context.Background(), []byte("c")); err == nil {
+		t.Errorf("TestCheckpointer: got err == nil saving after close(), want err != nil")
+	}
+	if string(action.Checkpoint) != "b" {
+		t.Errorf("TestCheckpointer: got Action.Checkpoint %q after close(), want 'b'", action.Checkpoint)
+	}
+}
+
+// blockingUpdater is an ActionUpdater whose writes wait until release is closed.
+type blockingUpdater struct {
+	started chan struct{}
+	release chan struct{}
+
+	private.Storage
+}
+
+func (b *blockingUpdater) UpdateAction(ctx context.Context, action *workflow.Action) error {
+	close(b.started)
+	<-b.release
+	return nil
+}
+
+func TestCheckpointerSlowWrite(t *testing.T) {
+	t.Parallel()
+
+	action := &workflow.Action{ID: uuid.New(), State: &workflow.State{}}
+	updater := &blockingUpdater{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(updater.release)
+	cp := &checkpointer{action: action, updater: updater}
+
+	go cp.SaveCheckpoint(context.Background(), []byte("a"))
+	<-updater.started
+
+	closed := make(chan struct{})
+	go func() {
+		cp.close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatalf("TestCheckpointerSlowWrite: close() waited for the write of a checkpoint")
+	}
+	if string(action.Checkpoint) != "a" {
+		t.Errorf("TestCheckpointerSlowWrite: got Action.Checkpoint %q, want 'a'", action.Checkpoint)
+	}
+}
+
 func TestRun(t *testing.T) {
 	t.Parallel()
 
@@ -498,6 +1037,13 @@ func TestRun(t *testing.T) {
 			wantErr:     true,
 			wantTimeout: false,
 		},
+		{
+			name:        "plugin panics",
+			req:         testplugin.Req{Panic: true},
+			timeout:     100 * time.Millisecond,
+			wantErr:     true,
+			wantTimeout: false,
+		},
 		{
 			name:        "context timeout",
 			req:         testplugin.Req{Sleep: 200 * time.Millisecond},
@@ -511,7 +1057,13 @@ func TestRun(t *testing.T) {
 		ctx, cancel := context.WithTimeout(context.Background(), test.timeout)
 		defer cancel()
 
-		resp := run(ctx, &testplugin.Plugin{AlwaysRespond: true}, test.req)
+		runReg := registry.New()
+		plugin := &testplugin.Plugin{AlwaysRespond: true}
+
+		resp := run(ctx, runReg, plugin, nil, interceptors.Call{Req: test.req})
+		if got := runReg.Abandoned(plugin); resp.timeout && got != 1 {
+			t.Errorf("TestRun(%s): got %d abandoned executions, want 1", test.name, got)
+		}
 		switch {
 		case test.wantErr && resp.Err == nil:
 			t.Errorf("TestRun(%s): got err == nil, want error != nil", test.name)
@@ -531,6 +1083,174 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestIntercept(t *testing.T) {
+	t.Parallel()
+
+	var order []string
+	record := func(name string) interceptors.ExecuteFunc {
+		return func(ctx context.Context, call interceptors.Call, next interceptors.Next) (any, *plugins.Error) {
+			order = append(order, name)
+			return next(ctx, call)
+		}
+	}
+	mutate := func(ctx context.Context, call interceptors.Call, next interceptors.Next) (any, *plugins.Error) {
+		call.Req = testplugin.Req{Arg: "error"}
+		return next(ctx, call)
+	}
+	veto := func(ctx context.Context, call interceptors.Call, next interceptors.Next) (any, *plugins.Error) {
+		return nil, &plugins.Error{Message: "vetoed", Permanent: true}
+	}
+
+	tests := []struct {
+		name      string
+		ics       []interceptors.ExecuteFunc
+		wantOrder []string
+		wantResp  any
+		wantErr   string
+	}{
+		{
+			name:     "Success: no interceptors",
+			wantResp: testplugin.Resp{Arg: "ok"},
+		},
+		{
+			name:      "Success: interceptors called in order",
+			ics:       []interceptors.ExecuteFunc{record("first"), record("second")},
+			wantOrder: []string{"first", "second"},
+			wantResp:  testplugin.Resp{Arg: "ok"},
+		},
+		{
+			name:    "Error: interceptor changed the request",
+			ics:     []interceptors.ExecuteFunc{mutate},
+			wantErr: "error",
+		},
+		{
+			name:      "Error: interceptor vetoed the execution",
+			ics:       []interceptors.ExecuteFunc{record("first"), veto, record("never")},
+			wantOrder: []string{"first"},
+			wantErr:   "vetoed",
+		},
+	}
+
+	for _, test := range tests {
+		order = nil
+		plugin := &testplugin.Plugin{AlwaysRespond: true}
+
+		resp, err := intercept(plugin, test.ics)(context.Background(), interceptors.Call{Req: testplugin.Req{}})
+		switch {
+		case err == nil && test.wantErr != "":
+			t.Errorf("TestIntercept(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && test.wantErr == "":
+			t.Errorf("TestIntercept(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil && err.Message != test.wantErr:
+			t.Errorf("TestIntercept(%s): got err == %s, want %s", test.name, err.Message, test.wantErr)
+		}
+
+		if diff := pretty.Compare(test.wantOrder, order); diff != "" {
+			t.Errorf("TestIntercept(%s): interceptor order: -want/+got:\n%s", test.name, diff)
+		}
+		if diff := pretty.Compare(test.wantResp, resp); diff != "" {
+			t.Errorf("TestIntercept(%s): response: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name         string
+		action       *workflow.Action
+		wantTimeout  time.Duration
+		wantBudgeted bool
+	}{
+		{
+			name:        "No MaxDuration",
+			action:      &workflow.Action{Timeout: time.Minute, State: &workflow.State{Start: now}},
+			wantTimeout: time.Minute,
+		},
+		{
+			name:        "MaxDuration has more time than Timeout",
+			action:      &workflow.Action{Timeout: time.Minute, MaxDuration: time.Hour, State: &workflow.State{Start: now}},
+			wantTimeout: time.Minute,
+		},
+		{
+			name:         "MaxDuration has less time than Timeout",
+			action:       &workflow.Action{Timeout: time.Minute, MaxDuration: time.Hour, State: &workflow.State{Start: now.Add(-59*time.Minute - 30*time.Second)}},
+			wantTimeout:  30 * time.Second,
+			wantBudgeted: true,
+		},
+		{
+			name:         "MaxDuration used up",
+			action:       &workflow.Action{Timeout: time.Minute, MaxDuration: time.Hour, State: &workflow.State{Start: now.Add(-2 * time.Hour)}},
+			wantTimeout:  -time.Hour,
+			wantBudgeted: true,
+		},
+	}
+
+	r := Runner{nower: func() time.Time { return now }}
+	for _, test := range tests {
+		timeout, budgeted := r.attemptTimeout(test.action)
+		if timeout != test.wantTimeout {
+			t.Errorf("TestAttemptTimeout(%s): got timeout == %v, want == %v", test.name, timeout, test.wantTimeout)
+		}
+		if budgeted != test.wantBudgeted {
+			t.Errorf("TestAttemptTimeout(%s): got budgeted == %v, want == %v", test.name, budgeted, test.wantBudgeted)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		action *workflow.Action
+		err    *plugins.Error
+		want   bool
+	}{
+		{
+			name:   "No rules",
+			action: &workflow.Action{},
+			err:    &plugins.Error{Code: 1},
+			want:   true,
+		},
+		{
+			name:   "Code in PermanentOn",
+			action: &workflow.Action{PermanentOn: []plugins.ErrCode{1}},
+			err:    &plugins.Error{Code: 1},
+			want:   false,
+		},
+		{
+			name:   "Code not in PermanentOn",
+			action: &workflow.Action{PermanentOn: []plugins.ErrCode{1}},
+			err:    &plugins.Error{Code: 2},
+			want:   true,
+		},
+		{
+			name:   "Code in RetryOn",
+			action: &workflow.Action{RetryOn: []plugins.ErrCode{1, 2}},
+			err:    &plugins.Error{Code: 2},
+			want:   true,
+		},
+		{
+			name:   "Code not in RetryOn",
+			action: &workflow.Action{RetryOn: []plugins.ErrCode{1, 2}},
+			err:    &plugins.Error{Code: 3},
+			want:   false,
+		},
+	}
+
+	for _, test := range tests {
+		if got := retryable(test.action, test.err); got != test.want {
+			t.Errorf("TestRetryable(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
 func TestIsType(t *testing.T) {
 	t.Parallel()
 
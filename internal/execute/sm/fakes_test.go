@@ -37,7 +37,10 @@ type fakeUpdater struct {
 	seqs    []*workflow.Sequence
 	actions []*workflow.Action
 	checks  []*workflow.Checks
+	runs    []*workflow.CheckRun
 	calls   atomic.Int32
+	// err, if set, is returned by all Update methods instead of writing.
+	err error
 
 	storage.Vault
 }
@@ -55,6 +58,9 @@ func (f *fakeUpdater) Create(ctx context.Context, plan *workflow.Plan) error {
 
 func (f *fakeUpdater) UpdatePlan(ctx context.Context, plan *workflow.Plan) error {
 	f.calls.Add(1)
+	if f.err != nil {
+		return f.err
+	}
 
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -66,6 +72,9 @@ func (f *fakeUpdater) UpdatePlan(ctx context.Context, plan *workflow.Plan) error
 
 func (f *fakeUpdater) UpdateBlock(ctx context.Context, block *workflow.Block) error {
 	f.calls.Add(1)
+	if f.err != nil {
+		return f.err
+	}
 
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -77,6 +86,9 @@ func (f *fakeUpdater) UpdateBlock(ctx context.Context, block *workflow.Block) er
 
 func (f *fakeUpdater) UpdateChecks(ctx context.Context, checks *workflow.Checks) error {
 	f.calls.Add(1)
+	if f.err != nil {
+		return f.err
+	}
 
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -86,8 +98,25 @@ func (f *fakeUpdater) UpdateChecks(ctx context.Context, checks *workflow.Checks)
 	return nil
 }
 
+func (f *fakeUpdater) RecordCheckRun(ctx context.Context, checks *workflow.Checks, run *workflow.CheckRun) error {
+	f.calls.Add(1)
+	if f.err != nil {
+		return f.err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	r := *run
+	f.runs = append(f.runs, &r)
+	return nil
+}
+
 func (f *fakeUpdater) UpdateAction(ctx context.Context, action *workflow.Action) error {
 	f.calls.Add(1)
+	if f.err != nil {
+		return f.err
+	}
 
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -99,6 +128,9 @@ func (f *fakeUpdater) UpdateAction(ctx context.Context, action *workflow.Action)
 
 func (f *fakeUpdater) UpdateSequence(ctx context.Context, seq *workflow.Sequence) error {
 	f.calls.Add(1)
+	if f.err != nil {
+		return f.err
+	}
 
 	f.lock.Lock()
 	defer f.lock.Unlock()
@@ -1,6 +1,7 @@
 package sm
 
 import (
+	"errors"
 	"fmt"
 	"log"
 
@@ -13,7 +14,35 @@ type finalStates struct{}
 
 // start is simply the starting place for the statemachine. It does nothing.
 func (f finalStates) start(req statemachine.Request[Data]) statemachine.Request[Data] {
-	req.Next = f.planChecks
+	req.Next = f.storage
+	return req
+}
+
+// storage fails the Plan with FRStorage if writes to storage could not be recovered. This takes precedence
+// over other failures, as the Plan stops where it is when this happens and objects are not in a final state.
+func (f finalStates) storage(req statemachine.Request[Data]) statemachine.Request[Data] {
+	if !errors.Is(req.Data.err, ErrStorage) {
+		req.Next = f.deadline
+		return req
+	}
+	plan := req.Data.Plan
+	plan.State.Status = workflow.Failed
+	plan.Reason = workflow.FRStorage
+	req.Err = req.Data.err
+	return req
+}
+
+// deadline fails the Plan with FRTimeout if the Plan exceeded its MaxDuration. This takes precedence
+// over other failures, as Blocks that were never started are expected when the Plan runs out of time.
+func (f finalStates) deadline(req statemachine.Request[Data]) statemachine.Request[Data] {
+	if !req.Data.planExpired {
+		req.Next = f.planChecks
+		return req
+	}
+	plan := req.Data.Plan
+	plan.State.Status = workflow.Failed
+	plan.Reason = workflow.FRTimeout
+	req.Err = fmt.Errorf("plan exceeded its max duration(%v)", plan.MaxDuration)
 	return req
 }
 
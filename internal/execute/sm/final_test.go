@@ -8,6 +8,112 @@ import (
 	"github.com/gostdlib/ops/statemachine"
 )
 
+func TestDeadline(t *testing.T) {
+	t.Parallel()
+
+	finals := finalStates{}
+
+	tests := []struct {
+		name        string
+		planExpired bool
+		wantNext    statemachine.State[Data]
+		wantStatus  workflow.Status
+		wantReason  workflow.FailureReason
+		wantErr     bool
+	}{
+		{
+			name:       "plan did not expire",
+			wantNext:   finals.planChecks,
+			wantStatus: workflow.Running,
+		},
+		{
+			name:        "plan expired",
+			planExpired: true,
+			wantStatus:  workflow.Failed,
+			wantReason:  workflow.FRTimeout,
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		plan := &workflow.Plan{State: &workflow.State{Status: workflow.Running}}
+
+		req := finals.deadline(statemachine.Request[Data]{Data: Data{Plan: plan, planExpired: test.planExpired}})
+		switch {
+		case req.Err == nil && test.wantErr:
+			t.Errorf("TestDeadline(%s): got err == nil, want err != nil", test.name)
+		case req.Err != nil && !test.wantErr:
+			t.Errorf("TestDeadline(%s): got err == %v, want err == nil", test.name, req.Err)
+		}
+
+		if methodName(req.Next) != methodName(test.wantNext) {
+			t.Errorf("TestDeadline(%s): got next == %v, want next == %v", test.name, methodName(req.Next), methodName(test.wantNext))
+		}
+		if plan.State.Status != test.wantStatus {
+			t.Errorf("TestDeadline(%s): got status == %v, want status == %v", test.name, plan.State.Status, test.wantStatus)
+		}
+		if plan.Reason != test.wantReason {
+			t.Errorf("TestDeadline(%s): got reason == %v, want reason == %v", test.name, plan.Reason, test.wantReason)
+		}
+	}
+}
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	finals := finalStates{}
+
+	tests := []struct {
+		name       string
+		err        error
+		wantNext   statemachine.State[Data]
+		wantStatus workflow.Status
+		wantReason workflow.FailureReason
+		wantErr    bool
+	}{
+		{
+			name:       "no error",
+			wantNext:   finals.deadline,
+			wantStatus: workflow.Running,
+		},
+		{
+			name:       "error that is not a storage error",
+			err:        errors.New("error"),
+			wantNext:   finals.deadline,
+			wantStatus: workflow.Running,
+		},
+		{
+			name:       "storage error",
+			err:        errors.Join(errors.New("error"), storageErr("Block", errors.New("disk full"))),
+			wantStatus: workflow.Failed,
+			wantReason: workflow.FRStorage,
+			wantErr:    true,
+		},
+	}
+
+	for _, test := range tests {
+		plan := &workflow.Plan{State: &workflow.State{Status: workflow.Running}}
+
+		req := finals.storage(statemachine.Request[Data]{Data: Data{Plan: plan, err: test.err}})
+		switch {
+		case req.Err == nil && test.wantErr:
+			t.Errorf("TestStorage(%s): got err == nil, want err != nil", test.name)
+		case req.Err != nil && !test.wantErr:
+			t.Errorf("TestStorage(%s): got err == %v, want err == nil", test.name, req.Err)
+		}
+
+		if methodName(req.Next) != methodName(test.wantNext) {
+			t.Errorf("TestStorage(%s): got next == %v, want next == %v", test.name, methodName(req.Next), methodName(test.wantNext))
+		}
+		if plan.State.Status != test.wantStatus {
+			t.Errorf("TestStorage(%s): got status == %v, want status == %v", test.name, plan.State.Status, test.wantStatus)
+		}
+		if plan.Reason != test.wantReason {
+			t.Errorf("TestStorage(%s): got reason == %v, want reason == %v", test.name, plan.Reason, test.wantReason)
+		}
+	}
+}
+
 func TestPlanChecks(t *testing.T) {
 	t.Parallel()
 
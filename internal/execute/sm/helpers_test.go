@@ -2,10 +2,18 @@ package sm
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+
+	"github.com/google/uuid"
+	"github.com/gostdlib/ops/retry/exponential"
 	"github.com/kylelemons/godebug/pretty"
 )
 
@@ -51,6 +59,7 @@ func TestRunPreChecks(t *testing.T) {
 
 	for _, test := range tests {
 		states := &States{
+			store:        &fakeUpdater{},
 			checksRunner: fakeRunChecksOnce,
 		}
 
@@ -61,6 +70,317 @@ func TestRunPreChecks(t *testing.T) {
 	}
 }
 
+func TestRunContChecks(t *testing.T) {
+	t.Parallel()
+
+	checkErr := errors.New("error")
+
+	tests := []struct {
+		name        string
+		tolerance   *workflow.Tolerance
+		results     []error
+		wantResults []error
+		wantRuns    []workflow.Status
+	}{
+		{
+			name:        "No tolerance fails on first failure",
+			results:     []error{nil, checkErr, nil},
+			wantResults: []error{nil, checkErr},
+			wantRuns:    []workflow.Status{workflow.Completed, workflow.Failed},
+		},
+		{
+			name:        "Consecutive failures",
+			tolerance:   &workflow.Tolerance{Consecutive: 2},
+			results:     []error{checkErr, nil, checkErr, checkErr, nil},
+			wantResults: []error{nil, nil, nil, checkErr},
+			wantRuns:    []workflow.Status{workflow.Failed, workflow.Completed, workflow.Failed, workflow.Failed},
+		},
+		{
+			name:        "Failures in Window",
+			tolerance:   &workflow.Tolerance{Failures: 2, Window: 3},
+			results:     []error{checkErr, nil, nil, checkErr, nil, checkErr, nil},
+			wantResults: []error{nil, nil, nil, nil, nil, checkErr},
+			wantRuns: []workflow.Status{
+				workflow.Failed, workflow.Completed, workflow.Completed, workflow.Failed, workflow.Completed, workflow.Failed,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		calls := 0
+		states := &States{
+			store: &fakeUpdater{},
+			checksRunner: func(ctx context.Context, checks *workflow.Checks) error {
+				defer func() { calls++ }()
+				checks.State.Status = workflow.Completed
+				if test.results[calls] != nil {
+					checks.State.Status = workflow.Failed
+				}
+				return test.results[calls]
+			},
+		}
+		checks := &workflow.Checks{
+			Delay:     time.Nanosecond,
+			Tolerance: test.tolerance,
+			State:     &workflow.State{},
+		}
+
+		// runContChecks stops on the first error that isn't tolerated, which closes the channel.
+		resultCh := make(chan error, 1)
+		go states.runContChecks(context.Background(), checks, resultCh)
+
+		var results []error
+		for err := range resultCh {
+			results = append(results, err)
+		}
+		if diff := pretty.Compare(test.wantResults, results); diff != "" {
+			t.Errorf("TestRunContChecks(%s): results: -want/+got:\n%s", test.name, diff)
+		}
+
+		var runs []workflow.Status
+		for _, run := range checks.Runs {
+			runs = append(runs, run.Status)
+		}
+		if diff := pretty.Compare(test.wantRuns, runs); diff != "" {
+			t.Errorf("TestRunContChecks(%s): runs: -want/+got:\n%s", test.name, diff)
+		}
+		if checks.State.Status != workflow.Failed {
+			t.Errorf("TestRunContChecks(%s): got checks status %v, want %v", test.name, checks.State.Status, workflow.Failed)
+		}
+	}
+}
+
+func TestToleranceExceeded(t *testing.T) {
+	t.Parallel()
+
+	runs := func(statuses ...workflow.Status) []*workflow.CheckRun {
+		r := make([]*workflow.CheckRun, 0, len(statuses))
+		for _, s := range statuses {
+			r = append(r, &workflow.CheckRun{Status: s})
+		}
+		return r
+	}
+	f, c := workflow.Failed, workflow.Completed
+
+	tests := []struct {
+		name      string
+		tolerance *workflow.Tolerance
+		runs      []*workflow.CheckRun
+		want      bool
+	}{
+		{name: "No runs", want: false},
+		{name: "Last run passed", runs: runs(f, c), want: false},
+		{name: "No tolerance, last run failed", runs: runs(c, f), want: true},
+		{name: "Consecutive not reached", tolerance: &workflow.Tolerance{Consecutive: 3}, runs: runs(f, c, f, f), want: false},
+		{name: "Consecutive reached", tolerance: &workflow.Tolerance{Consecutive: 3}, runs: runs(c, f, f, f), want: true},
+		{name: "Window not reached", tolerance: &workflow.Tolerance{Failures: 2, Window: 3}, runs: runs(f, c, c, f), want: false},
+		{name: "Window reached", tolerance: &workflow.Tolerance{Failures: 2, Window: 3}, runs: runs(c, f, c, f), want: true},
+		{name: "Window larger than runs", tolerance: &workflow.Tolerance{Failures: 2, Window: 10}, runs: runs(f, f), want: true},
+		{
+			name:      "Window reached, Consecutive not",
+			tolerance: &workflow.Tolerance{Consecutive: 3, Failures: 2, Window: 3},
+			runs:      runs(f, c, f),
+			want:      true,
+		},
+	}
+
+	for _, test := range tests {
+		if got := toleranceExceeded(test.tolerance, test.runs); got != test.want {
+			t.Errorf("TestToleranceExceeded(%s): got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestTolerate(t *testing.T) {
+	t.Parallel()
+
+	states := &States{store: &fakeUpdater{}}
+	checks := &workflow.Checks{
+		Tolerance: &workflow.Tolerance{Consecutive: 2},
+		State:     &workflow.State{Status: workflow.Failed},
+	}
+
+	if err := states.tolerate(context.Background(), checks, errors.New("error")); err != nil {
+		t.Fatalf("TestTolerate: first failure: got err == %v, want err == nil", err)
+	}
+	if checks.State.Status != workflow.Completed {
+		t.Errorf("TestTolerate: first failure: got status %v, want %v", checks.State.Status, workflow.Completed)
+	}
+
+	checks.State.Status = workflow.Failed
+	if err := states.tolerate(context.Background(), checks, errors.New("error")); err == nil {
+		t.Fatalf("TestTolerate: second failure: got err == nil, want err != nil")
+	}
+	if checks.State.Status != workflow.Failed {
+		t.Errorf("TestTolerate: second failure: got status %v, want %v", checks.State.Status, workflow.Failed)
+	}
+}
+
+func TestRecordRunLimit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		retention int
+		want      int
+	}{
+		{name: "Default retention", want: workflow.MaxCheckRuns},
+		{name: "Retention set", retention: 5, want: 5},
+	}
+
+	for _, test := range tests {
+		store := &fakeUpdater{}
+		states := &States{store: store}
+		checks := &workflow.Checks{State: &workflow.State{}, Retention: test.retention}
+
+		for i := 0; i < test.want+10; i++ {
+			var err error
+			if i == test.want+9 {
+				err = errors.New("error")
+			}
+			states.recordRun(context.Background(), checks, err)
+		}
+
+		if len(checks.Runs) != test.want {
+			t.Errorf("TestRecordRunLimit(%s): got %d runs, want %d", test.name, len(checks.Runs), test.want)
+			continue
+		}
+		last := checks.Runs[len(checks.Runs)-1]
+		if last.Status != workflow.Failed || last.Iteration != test.want+10 {
+			t.Errorf("TestRecordRunLimit(%s): last run should be the newest run", test.name)
+		}
+		if len(store.runs) != test.want+10 {
+			t.Errorf("TestRecordRunLimit(%s): got %d runs written to the store, want %d", test.name, len(store.runs), test.want+10)
+		}
+	}
+}
+
+func TestRecordRunActions(t *testing.T) {
+	t.Parallel()
+
+	store := &fakeUpdater{}
+	states := &States{store: store}
+	attempts := []*workflow.Attempt{{Err: &plugins.Error{Message: "error"}}}
+	checks := &workflow.Checks{
+		Actions: []*workflow.Action{
+			{ID: uuid.New(), State: &workflow.State{Status: workflow.Failed}, Attempts: attempts},
+		},
+		State: &workflow.State{},
+	}
+
+	states.recordRun(context.Background(), checks, errors.New("error"))
+
+	want := []*workflow.ActionRun{{ID: checks.Actions[0].ID, Status: workflow.Failed, Attempts: attempts}}
+	if diff := pretty.Compare(want, store.runs[0].Actions); diff != "" {
+		t.Errorf("TestRecordRunActions: stored actions: -want/+got:\n%s", diff)
+	}
+	if checks.Runs[0].Actions != nil {
+		t.Errorf("TestRecordRunActions: Checks.Runs should not hold the Actions of the run")
+	}
+}
+
+func TestRunGate(t *testing.T) {
+	t.Parallel()
+
+	checkErr := errors.New("error")
+	policy := &exponential.Policy{InitialInterval: time.Millisecond, Multiplier: 2, MaxInterval: 5 * time.Millisecond}
+
+	tests := []struct {
+		name        string
+		gate        *workflow.Gate
+		policy      *exponential.Policy
+		results     []error
+		wantCalls   int
+		wantStorage bool
+		wantErr     bool
+	}{
+		{
+			name:      "Success: Interval, passes on third run",
+			gate:      &workflow.Gate{Interval: time.Millisecond, Timeout: 10 * time.Second},
+			results:   []error{checkErr, checkErr, nil},
+			wantCalls: 3,
+		},
+		{
+			name:      "Success: retry policy, passes after a permanent error",
+			gate:      &workflow.Gate{Timeout: 10 * time.Second},
+			policy:    policy,
+			results:   []error{fmt.Errorf("%w: %w", exponential.ErrPermanent, checkErr), checkErr, nil},
+			wantCalls: 3,
+		},
+		{
+			name:    "Error: Interval, Timeout reached",
+			gate:    &workflow.Gate{Interval: time.Millisecond, Timeout: 20 * time.Millisecond},
+			wantErr: true,
+		},
+		{
+			name:    "Error: retry policy, Timeout reached",
+			gate:    &workflow.Gate{Timeout: 20 * time.Millisecond},
+			policy:  policy,
+			wantErr: true,
+		},
+		{
+			name:        "Error: Interval, storage fails",
+			gate:        &workflow.Gate{Interval: time.Millisecond, Timeout: 10 * time.Second},
+			results:     []error{storageErr("Action", checkErr)},
+			wantCalls:   1,
+			wantStorage: true,
+			wantErr:     true,
+		},
+		{
+			name:        "Error: retry policy, storage fails",
+			gate:        &workflow.Gate{Timeout: 10 * time.Second},
+			policy:      policy,
+			results:     []error{storageErr("Action", checkErr)},
+			wantCalls:   1,
+			wantStorage: true,
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		calls := 0
+		states := &States{
+			checksRunner: func(ctx context.Context, checks *workflow.Checks) error {
+				defer func() { calls++ }()
+				if calls < len(test.results) {
+					return test.results[calls]
+				}
+				return checkErr
+			},
+		}
+		checks := &workflow.Checks{
+			Gate:    test.gate,
+			Actions: []*workflow.Action{{RetryPolicy: test.policy}},
+		}
+
+		err := states.runChecks(context.Background(), checks)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestRunGate(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestRunGate(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			if !errors.Is(err, checkErr) {
+				t.Errorf("TestRunGate(%s): got err == %s, want it to wrap the last check error", test.name, err)
+			}
+			if test.wantStorage {
+				if !errors.Is(err, ErrStorage) {
+					t.Errorf("TestRunGate(%s): got err == %s, want it to wrap ErrStorage", test.name, err)
+				}
+				if calls != test.wantCalls {
+					t.Errorf("TestRunGate(%s): got %d runs, want %d", test.name, calls, test.wantCalls)
+				}
+			}
+			continue
+		}
+		if calls != test.wantCalls {
+			t.Errorf("TestRunGate(%s): got %d runs, want %d", test.name, calls, test.wantCalls)
+		}
+	}
+}
+
 func TestRunChecksOnce(t *testing.T) {
 	t.Parallel()
 
@@ -138,6 +458,65 @@ func TestRunChecksOnce(t *testing.T) {
 	}
 }
 
+func TestRunActionsQuorum(t *testing.T) {
+	t.Parallel()
+
+	actions := func(names ...string) []*workflow.Action {
+		a := make([]*workflow.Action, 0, len(names))
+		for _, n := range names {
+			a = append(a, &workflow.Action{Name: n, State: &workflow.State{}})
+		}
+		return a
+	}
+
+	tests := []struct {
+		name    string
+		actions []*workflow.Action
+		quorum  *workflow.Quorum
+		wantErr bool
+	}{
+		{
+			name:    "Success: Count met",
+			actions: actions("action1", "error", "action3"),
+			quorum:  &workflow.Quorum{Count: 2},
+		},
+		{
+			name:    "Success: Percent met",
+			actions: actions("action1", "action2", "action3", "error"),
+			quorum:  &workflow.Quorum{Percent: 75},
+		},
+		{
+			name:    "Error: Count not met",
+			actions: actions("action1", "error", "error"),
+			quorum:  &workflow.Quorum{Count: 2},
+			wantErr: true,
+		},
+		{
+			name:    "Error: Percent not met",
+			actions: actions("action1", "action2", "error", "error"),
+			quorum:  &workflow.Quorum{Percent: 75},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		states := &States{
+			store:        &fakeUpdater{},
+			actionRunner: fakeActionRunner,
+		}
+
+		err := states.runActionsQuorum(context.Background(), test.actions, test.quorum)
+		if test.wantErr != (err != nil) {
+			t.Errorf("TestRunActionsQuorum(%s): got err == %v, want err == %v", test.name, err, test.wantErr)
+		}
+		for _, a := range test.actions {
+			if a.State.Status != workflow.Running {
+				t.Errorf("TestRunActionsQuorum(%s): action(%s) was not marked Running", test.name, a.Name)
+			}
+		}
+	}
+}
+
 func TestParallelActionsRunner(t *testing.T) {
 	t.Parallel()
 
@@ -205,8 +584,11 @@ func TestParallelActionsRunner(t *testing.T) {
 func TestExecSeq(t *testing.T) {
 	t.Parallel()
 
+	now := time.Now().UTC()
+
 	tests := []struct {
 		name      string
+		advance   time.Duration
 		seq       *workflow.Sequence
 		wantSeq   *workflow.Sequence
 		dbUpdates []*workflow.Sequence
@@ -224,6 +606,8 @@ func TestExecSeq(t *testing.T) {
 				Actions: []*workflow.Action{{Name: "action"}, {Name: "error"}},
 				State: &workflow.State{
 					Status: workflow.Failed,
+					Start:  now,
+					End:    now,
 				},
 			},
 			dbUpdates: []*workflow.Sequence{
@@ -232,6 +616,7 @@ func TestExecSeq(t *testing.T) {
 					Actions: []*workflow.Action{{Name: "action"}, {Name: "error"}},
 					State: &workflow.State{
 						Status: workflow.Running,
+						Start:  now,
 					},
 				},
 				{
@@ -239,6 +624,8 @@ func TestExecSeq(t *testing.T) {
 					Actions: []*workflow.Action{{Name: "action"}, {Name: "error"}},
 					State: &workflow.State{
 						Status: workflow.Failed,
+						Start:  now,
+						End:    now,
 					},
 				},
 			},
@@ -256,6 +643,8 @@ func TestExecSeq(t *testing.T) {
 				Actions: []*workflow.Action{{Name: "action1"}, {Name: "action2"}},
 				State: &workflow.State{
 					Status: workflow.Completed,
+					Start:  now,
+					End:    now,
 				},
 			},
 			dbUpdates: []*workflow.Sequence{
@@ -264,6 +653,7 @@ func TestExecSeq(t *testing.T) {
 					Actions: []*workflow.Action{{Name: "action1"}, {Name: "action2"}},
 					State: &workflow.State{
 						Status: workflow.Running,
+						Start:  now,
 					},
 				},
 				{
@@ -271,17 +661,70 @@ func TestExecSeq(t *testing.T) {
 					Actions: []*workflow.Action{{Name: "action1"}, {Name: "action2"}},
 					State: &workflow.State{
 						Status: workflow.Completed,
+						Start:  now,
+						End:    now,
+					},
+				},
+			},
+		},
+		{
+			name:    "seq exceeded max duration",
+			advance: 2 * time.Minute,
+			seq: &workflow.Sequence{
+				Name:        "seq",
+				Actions:     []*workflow.Action{{Name: "action1"}},
+				MaxDuration: time.Minute,
+				State:       &workflow.State{},
+			},
+			wantSeq: &workflow.Sequence{
+				Name:        "seq",
+				Actions:     []*workflow.Action{{Name: "action1"}},
+				MaxDuration: time.Minute,
+				State: &workflow.State{
+					Status: workflow.Failed,
+					Start:  now,
+					End:    now.Add(2 * time.Minute),
+				},
+			},
+			dbUpdates: []*workflow.Sequence{
+				{
+					Name:        "seq",
+					Actions:     []*workflow.Action{{Name: "action1"}},
+					MaxDuration: time.Minute,
+					State: &workflow.State{
+						Status: workflow.Running,
+						Start:  now,
+					},
+				},
+				{
+					Name:        "seq",
+					Actions:     []*workflow.Action{{Name: "action1"}},
+					MaxDuration: time.Minute,
+					State: &workflow.State{
+						Status: workflow.Failed,
+						Start:  now,
+						End:    now.Add(2 * time.Minute),
 					},
 				},
 			},
+			wantErr: true,
 		},
 	}
 
 	for _, test := range tests {
 		updater := &fakeUpdater{}
+		// The first call to nower sets the Start time, all later calls have advanced the clock.
+		calls := 0
 		states := &States{
 			store:        updater,
 			actionRunner: fakeActionRunner,
+			nower: func() time.Time {
+				calls++
+				if calls == 1 {
+					return now
+				}
+				return now.Add(test.advance)
+			},
 		}
 
 		err := states.execSeq(context.Background(), test.seq)
@@ -298,6 +741,207 @@ func TestExecSeq(t *testing.T) {
 	}
 }
 
+func TestExecSeqChecks(t *testing.T) {
+	t.Parallel()
+
+	success := func() *workflow.Checks {
+		return &workflow.Checks{Delay: time.Millisecond, Actions: []*workflow.Action{{Name: "success"}}, State: &workflow.State{}}
+	}
+	failure := func() *workflow.Checks {
+		return &workflow.Checks{Delay: time.Millisecond, Actions: []*workflow.Action{{Name: "error"}}, State: &workflow.State{}}
+	}
+
+	tests := []struct {
+		name string
+		pre  *workflow.Checks
+		cont *workflow.Checks
+		post *workflow.Checks
+		// contFailAfter causes the ContChecks to fail after this many successful runs.
+		contFailAfter int
+		maxDuration   time.Duration
+		wantStatus    workflow.Status
+		wantPostRan   bool
+		wantErr       bool
+	}{
+		{
+			name:        "Success: all checks pass",
+			pre:         success(),
+			cont:        success(),
+			post:        success(),
+			wantStatus:  workflow.Completed,
+			wantPostRan: true,
+		},
+		{
+			name:       "Error: PreChecks fail",
+			pre:        failure(),
+			post:       success(),
+			wantStatus: workflow.Failed,
+			wantErr:    true,
+		},
+		{
+			name:       "Error: first ContChecks run fails",
+			cont:       failure(),
+			wantStatus: workflow.Failed,
+			wantErr:    true,
+		},
+		{
+			name:          "Error: ContChecks fail while Actions run",
+			cont:          success(),
+			contFailAfter: 1,
+			wantStatus:    workflow.Failed,
+			wantErr:       true,
+		},
+		{
+			name:        "Error: PostChecks fail",
+			pre:         success(),
+			post:        failure(),
+			wantStatus:  workflow.Failed,
+			wantPostRan: true,
+			wantErr:     true,
+		},
+		{
+			name:        "Error: max duration exceeded still runs PostChecks",
+			post:        success(),
+			maxDuration: time.Nanosecond,
+			wantStatus:  workflow.Failed,
+			wantPostRan: true,
+			wantErr:     true,
+		},
+		{
+			name:        "Error: ContChecks fail does not run PostChecks",
+			cont:        failure(),
+			post:        success(),
+			wantStatus:  workflow.Failed,
+			wantPostRan: false,
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		var contRuns atomic.Int32
+		var postRan atomic.Bool
+		states := &States{
+			store: &fakeUpdater{},
+			checksRunner: func(ctx context.Context, checks *workflow.Checks) error {
+				if checks == test.post {
+					postRan.Store(true)
+				}
+				if checks == test.cont && test.contFailAfter > 0 {
+					if int(contRuns.Add(1)) > test.contFailAfter {
+						return fmt.Errorf("error")
+					}
+				}
+				return fakeRunChecksOnce(ctx, checks)
+			},
+			actionRunner: func(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
+				// Give the ContChecks time to run between the Actions.
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			},
+		}
+
+		seq := &workflow.Sequence{
+			Name:        "seq",
+			PreChecks:   test.pre,
+			ContChecks:  test.cont,
+			PostChecks:  test.post,
+			Actions:     []*workflow.Action{{Name: "action1"}, {Name: "action2"}},
+			MaxDuration: test.maxDuration,
+			State:       &workflow.State{},
+		}
+
+		err := states.execSeq(context.Background(), seq)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestExecSeqChecks(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestExecSeqChecks(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if seq.State.Status != test.wantStatus {
+			t.Errorf("TestExecSeqChecks(%s): got status %v, want %v", test.name, seq.State.Status, test.wantStatus)
+		}
+		if test.post != nil && postRan.Load() != test.wantPostRan {
+			t.Errorf("TestExecSeqChecks(%s): got PostChecks ran == %v, want %v", test.name, postRan.Load(), test.wantPostRan)
+		}
+	}
+}
+
+func TestExecSeqStorage(t *testing.T) {
+	t.Parallel()
+
+	states := &States{
+		store:        &fakeUpdater{err: errors.New("disk full")},
+		actionRunner: fakeActionRunner,
+	}
+
+	seq := &workflow.Sequence{
+		Name:    "seq",
+		Actions: []*workflow.Action{{Name: "action"}},
+		State:   &workflow.State{},
+	}
+
+	err := states.execSeq(context.Background(), seq)
+	if !errors.Is(err, ErrStorage) {
+		t.Fatalf("TestExecSeqStorage: got err == %v, want err wrapping ErrStorage", err)
+	}
+	if seq.State.Status != workflow.Failed {
+		t.Errorf("TestExecSeqStorage: got status %v, want %v", seq.State.Status, workflow.Failed)
+	}
+	if seq.Actions[0].State != nil {
+		t.Errorf("TestExecSeqStorage: Action was run after the Sequence could not be written")
+	}
+}
+
+type fakeWaiter struct {
+	err error
+
+	*fakeUpdater
+}
+
+func (f fakeWaiter) Wait(ctx context.Context) error {
+	return f.err
+}
+
+func TestWaitStorage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		store   storage.Vault
+		wantErr bool
+	}{
+		{
+			name:  "Success: store can't be degraded",
+			store: &fakeUpdater{},
+		},
+		{
+			name:  "Success: store is healthy",
+			store: fakeWaiter{fakeUpdater: &fakeUpdater{}},
+		},
+		{
+			name:    "Error: store could not recover",
+			store:   fakeWaiter{err: errors.New("gave up"), fakeUpdater: &fakeUpdater{}},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		states := &States{store: test.store}
+
+		err := states.waitStorage(context.Background())
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestWaitStorage(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestWaitStorage(%s): got err == %s, want err == nil", test.name, err)
+		case err != nil && !errors.Is(err, ErrStorage):
+			t.Errorf("TestWaitStorage(%s): got err == %s, want err wrapping ErrStorage", test.name, err)
+		}
+	}
+}
+
 func TestResetActions(t *testing.T) {
 	t.Parallel()
 
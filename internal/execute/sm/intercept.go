@@ -0,0 +1,147 @@
+package sm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/element-of-surprise/coercion/interceptors"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/element-of-surprise/coercion/workflow/utils/walk"
+	"github.com/google/uuid"
+)
+
+// tracked is an object that is tracked by a tracker.
+type tracked struct {
+	chain []workflow.Object
+
+	mu sync.Mutex
+	// status is the last status of the object that was written to storage.
+	status workflow.Status
+}
+
+// tracker wraps a storage.Vault to find state transitions of objects when they are written.
+// It also holds the chain of every object in the running Plans for the interceptors.
+type tracker struct {
+	storage.Vault
+
+	transitions []interceptors.TransitionFunc
+
+	// objects holds a *tracked for every object in the running Plans by ID.
+	objects sync.Map
+}
+
+// track starts tracking all objects in the Plan. This must be called before the Plan is written.
+func (t *tracker) track(ctx context.Context, plan *workflow.Plan) {
+	for item := range walk.Plan(context.WithoutCancel(ctx), plan) {
+		o := item.Value.(ider)
+		t.objects.Store(o.GetID(), &tracked{chain: item.Chain, status: status(o)})
+	}
+}
+
+// untrack stops tracking all objects in the Plan.
+func (t *tracker) untrack(ctx context.Context, plan *workflow.Plan) {
+	for item := range walk.Plan(context.WithoutCancel(ctx), plan) {
+		t.objects.Delete(item.Value.(ider).GetID())
+	}
+}
+
+// chain returns the chain of objects that led to the object with id. t may be nil.
+func (t *tracker) chain(id uuid.UUID) []workflow.Object {
+	if t == nil {
+		return nil
+	}
+	v, ok := t.objects.Load(id)
+	if !ok {
+		return nil
+	}
+	return v.(*tracked).chain
+}
+
+// transition calls the TransitionFuncs if the status of o has changed since it was last written.
+func (t *tracker) transition(ctx context.Context, o ider) {
+	v, ok := t.objects.Load(o.GetID())
+	if !ok {
+		return
+	}
+	tr := v.(*tracked)
+
+	tr.mu.Lock()
+	from, to := tr.status, status(o)
+	tr.status = to
+	tr.mu.Unlock()
+
+	if from == to {
+		return
+	}
+	for _, f := range t.transitions {
+		f(ctx, interceptors.Transition{Object: o.(workflow.Object), Chain: tr.chain, From: from, To: to})
+	}
+}
+
+// UpdatePlan implements storage.PlanUpdater.UpdatePlan().
+func (t *tracker) UpdatePlan(ctx context.Context, plan *workflow.Plan) error {
+	if err := t.Vault.UpdatePlan(ctx, plan); err != nil {
+		return err
+	}
+	t.transition(ctx, plan)
+	return nil
+}
+
+// UpdateBlock implements storage.BlockUpdater.UpdateBlock().
+func (t *tracker) UpdateBlock(ctx context.Context, block *workflow.Block) error {
+	if err := t.Vault.UpdateBlock(ctx, block); err != nil {
+		return err
+	}
+	t.transition(ctx, block)
+	return nil
+}
+
+// UpdateChecks implements storage.ChecksUpdater.UpdateChecks().
+func (t *tracker) UpdateChecks(ctx context.Context, checks *workflow.Checks) error {
+	if err := t.Vault.UpdateChecks(ctx, checks); err != nil {
+		return err
+	}
+	t.transition(ctx, checks)
+	return nil
+}
+
+// UpdateSequence implements storage.SequenceUpdater.UpdateSequence().
+func (t *tracker) UpdateSequence(ctx context.Context, seq *workflow.Sequence) error {
+	if err := t.Vault.UpdateSequence(ctx, seq); err != nil {
+		return err
+	}
+	t.transition(ctx, seq)
+	return nil
+}
+
+// UpdateAction implements storage.ActionUpdater.UpdateAction().
+func (t *tracker) UpdateAction(ctx context.Context, action *workflow.Action) error {
+	if err := t.Vault.UpdateAction(ctx, action); err != nil {
+		return err
+	}
+	t.transition(ctx, action)
+	return nil
+}
+
+// Wait implements storageWaiter.Wait() if the wrapped storage.Vault does.
+func (t *tracker) Wait(ctx context.Context) error {
+	if w, ok := t.Vault.(storageWaiter); ok {
+		return w.Wait(ctx)
+	}
+	return nil
+}
+
+// status returns the status of o. An object without a State has not started.
+func status(o ider) workflow.Status {
+	if o.GetState() == nil {
+		return workflow.NotStarted
+	}
+	return o.GetState().Status
+}
+
+// ider is implemented by all workflow objects that have an ID and a State.
+type ider interface {
+	GetID() uuid.UUID
+	GetState() *workflow.State
+}
@@ -0,0 +1,137 @@
+package sm
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/interceptors"
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/google/uuid"
+	"github.com/kylelemons/godebug/pretty"
+
+	testplugin "github.com/element-of-surprise/coercion/internal/execute/sm/testing/plugins"
+)
+
+func TestTrackerTransitions(t *testing.T) {
+	t.Parallel()
+
+	action := &workflow.Action{ID: uuid.New(), Name: "action", State: &workflow.State{}}
+	seq := &workflow.Sequence{ID: uuid.New(), Actions: []*workflow.Action{action}, State: &workflow.State{}}
+	block := &workflow.Block{ID: uuid.New(), Sequences: []*workflow.Sequence{seq}, State: &workflow.State{}}
+	plan := &workflow.Plan{ID: uuid.New(), Blocks: []*workflow.Block{block}, State: &workflow.State{}}
+
+	type got struct {
+		id    uuid.UUID
+		chain int
+		from  workflow.Status
+		to    workflow.Status
+	}
+	var (
+		mu   sync.Mutex
+		gots []got
+	)
+	tr := &tracker{
+		Vault: &fakeUpdater{},
+		transitions: []interceptors.TransitionFunc{
+			func(ctx context.Context, t interceptors.Transition) {
+				mu.Lock()
+				defer mu.Unlock()
+				gots = append(gots, got{id: t.Object.(ider).GetID(), chain: len(t.Chain), from: t.From, to: t.To})
+			},
+		},
+	}
+
+	ctx := context.Background()
+	tr.track(ctx, plan)
+
+	plan.State.Status = workflow.Running
+	tr.UpdatePlan(ctx, plan)
+	action.State.Status = workflow.Running
+	tr.UpdateAction(ctx, action)
+	// No change in status, so no transition.
+	tr.UpdateAction(ctx, action)
+	action.State.Status = workflow.Completed
+	tr.UpdateAction(ctx, action)
+
+	want := []got{
+		{id: plan.ID, chain: 0, from: workflow.NotStarted, to: workflow.Running},
+		{id: action.ID, chain: 3, from: workflow.NotStarted, to: workflow.Running},
+		{id: action.ID, chain: 3, from: workflow.Running, to: workflow.Completed},
+	}
+	if diff := pretty.Compare(want, gots); diff != "" {
+		t.Errorf("TestTrackerTransitions: -want/+got:\n%s", diff)
+	}
+
+	if got := tr.chain(action.ID); len(got) != 3 || got[0] != plan || got[1] != block || got[2] != seq {
+		t.Errorf("TestTrackerTransitions: got chain %v, want [plan block seq]", got)
+	}
+
+	tr.untrack(ctx, plan)
+	if got := tr.chain(action.ID); got != nil {
+		t.Errorf("TestTrackerTransitions: got chain after untrack, want nil")
+	}
+}
+
+func TestRunActionInterceptors(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.Register(&testplugin.Plugin{AlwaysRespond: true})
+
+	var (
+		calls []interceptors.Call
+		infos []execinfo.Info
+	)
+	ic := func(ctx context.Context, call interceptors.Call, next interceptors.Next) (any, *plugins.Error) {
+		calls = append(calls, call)
+		if info, ok := execinfo.From(ctx); ok {
+			infos = append(infos, info)
+		}
+		return next(ctx, call)
+	}
+
+	states, err := New(&fakeUpdater{}, reg, WithExecuteInterceptors(ic))
+	if err != nil {
+		panic(err)
+	}
+
+	action := &workflow.Action{
+		ID:      uuid.New(),
+		Name:    "action",
+		Plugin:  testplugin.Name,
+		Timeout: 10 * time.Second,
+		Req:     testplugin.Req{},
+		State:   &workflow.State{},
+	}
+	seq := &workflow.Sequence{ID: uuid.New(), Actions: []*workflow.Action{action}, State: &workflow.State{}}
+	block := &workflow.Block{ID: uuid.New(), Sequences: []*workflow.Sequence{seq}, State: &workflow.State{}}
+	plan := &workflow.Plan{ID: uuid.New(), Name: "plan", Blocks: []*workflow.Block{block}, State: &workflow.State{}}
+	states.tracker.track(context.Background(), plan)
+
+	if err := states.runAction(context.Background(), action, states.store); err != nil {
+		t.Fatalf("TestRunActionInterceptors: got err == %s, want err == nil", err)
+	}
+
+	if len(calls) != 1 {
+		t.Fatalf("TestRunActionInterceptors: got %d calls to the interceptor, want 1", len(calls))
+	}
+	if calls[0].Action != action || calls[0].Attempt != 1 || len(calls[0].Chain) != 3 {
+		t.Errorf("TestRunActionInterceptors: got Call{Action: %p, Attempt: %d, Chain: %d objects}, want Call{Action: %p, Attempt: 1, Chain: 3 objects}", calls[0].Action, calls[0].Attempt, len(calls[0].Chain), action)
+	}
+
+	if len(infos) != 1 {
+		t.Fatalf("TestRunActionInterceptors: Context did not have an execinfo.Info")
+	}
+	info := infos[0]
+	if info.PlanID != plan.ID || info.PlanName != "plan" || info.ActionID != action.ID || info.Attempt != 1 {
+		t.Errorf("TestRunActionInterceptors: got execinfo.Info %+v, want Info for plan(%s) and action(%s)", info, plan.ID, action.ID)
+	}
+	if info.Deadline.IsZero() || info.Logger == nil {
+		t.Errorf("TestRunActionInterceptors: execinfo.Info did not have a Deadline and Logger")
+	}
+}
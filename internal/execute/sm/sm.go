@@ -10,6 +10,8 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/element-of-surprise/coercion/interceptors"
+	"github.com/element-of-surprise/coercion/internal/execute/output"
 	"github.com/element-of-surprise/coercion/internal/execute/sm/actions"
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
@@ -17,11 +19,21 @@ import (
 
 	"github.com/gostdlib/concurrency/goroutines/pooled"
 	"github.com/gostdlib/concurrency/prim/wait"
+	"github.com/gostdlib/ops/retry/exponential"
 	"github.com/gostdlib/ops/statemachine"
 )
 
 var ErrInternalFailure = errors.New("internal failure")
 
+// ErrStorage is wrapped by errors caused by a write to storage that failed. When this happens the Plan fails
+// with workflow.FRStorage, as its state can no longer be recorded.
+var ErrStorage = actions.ErrStorage
+
+// storageErr returns an error wrapping ErrStorage for a failed write of what.
+func storageErr(what string, err error) error {
+	return fmt.Errorf("%w: failed to write %s: %w", ErrStorage, what, err)
+}
+
 // block is a wrapper around a workflow.Block that contains additional information for the statemachine.
 type block struct {
 	block *workflow.Block
@@ -41,6 +53,8 @@ type Data struct {
 	contCancel context.CancelFunc
 	// contCheckResult is the channel that will receive the result of the continuous check for the Plan.
 	contCheckResult chan error
+	// planExpired is set when the Plan has exceeded its MaxDuration.
+	planExpired bool
 
 	err error
 }
@@ -95,10 +109,46 @@ type States struct {
 	// actionRunner is the function that runs an action. If set, runAction calls this and returns.
 	// We use this to fake out the action runner in tests.
 	actionRunner actionRunner
+
+	// interceptors wrap every plugin execution.
+	interceptors []interceptors.ExecuteFunc
+	// transitions are called on every state transition.
+	transitions []interceptors.TransitionFunc
+	// outputs holds the running Actions so their output can be watched. This may be nil.
+	outputs *output.Hub
+	// tracker wraps the store to find state transitions and the chain of each object. This is set by New().
+	tracker *tracker
+}
+
+// Option is an optional argument for New().
+type Option func(*States) error
+
+// WithExecuteInterceptors adds interceptors that wrap every plugin execution.
+func WithExecuteInterceptors(ics ...interceptors.ExecuteFunc) Option {
+	return func(s *States) error {
+		s.interceptors = append(s.interceptors, ics...)
+		return nil
+	}
+}
+
+// WithTransitionInterceptors adds interceptors that are called on every state transition.
+func WithTransitionInterceptors(ts ...interceptors.TransitionFunc) Option {
+	return func(s *States) error {
+		s.transitions = append(s.transitions, ts...)
+		return nil
+	}
+}
+
+// WithOutputs sets the Hub that running Actions are registered in, so that their output can be watched.
+func WithOutputs(h *output.Hub) Option {
+	return func(s *States) error {
+		s.outputs = h
+		return nil
+	}
 }
 
 // New creates a new States statemachine.
-func New(store storage.Vault, registry *registry.Register) (*States, error) {
+func New(store storage.Vault, registry *registry.Register, options ...Option) (*States, error) {
 	if store == nil {
 		return nil, fmt.Errorf("store is required")
 	}
@@ -106,6 +156,13 @@ func New(store storage.Vault, registry *registry.Register) (*States, error) {
 		store:    store,
 		registry: registry,
 	}
+	for _, o := range options {
+		if err := o(s); err != nil {
+			return nil, err
+		}
+	}
+	s.tracker = &tracker{Vault: store, transitions: s.transitions}
+	s.store = s.tracker
 	return s, nil
 }
 
@@ -118,11 +175,15 @@ func (s *States) Start(req statemachine.Request[Data]) statemachine.Request[Data
 	}
 	req.Data.contCheckResult = make(chan error, 1)
 
+	if s.tracker != nil {
+		s.tracker.track(req.Ctx, plan)
+	}
+
 	plan.State.Status = workflow.Running
 	plan.State.Start = s.now()
 
 	if err := s.store.UpdatePlan(req.Ctx, plan); err != nil {
-		log.Fatalf("failed to write Plan: %v", err)
+		return s.storageFailed(req, "Plan", err)
 	}
 
 	req.Next = s.PlanPreChecks
@@ -131,13 +192,10 @@ func (s *States) Start(req statemachine.Request[Data]) statemachine.Request[Data
 
 // PlanPreChecks runs all PreChecks and ContChecks on the Plan before proceeding.
 func (s *States) PlanPreChecks(req statemachine.Request[Data]) statemachine.Request[Data] {
-	defer func() {
-		if err := s.store.UpdatePlan(req.Ctx, req.Data.Plan); err != nil {
-			log.Fatalf("failed to write Plan: %v", err)
-		}
-	}()
-
 	err := s.runPreChecks(req.Ctx, req.Data.Plan.PreChecks, req.Data.Plan.ContChecks)
+	if werr := s.store.UpdatePlan(req.Ctx, req.Data.Plan); werr != nil {
+		return s.storageFailed(req, "Plan", werr)
+	}
 	if err != nil {
 		req.Data.err = err
 		req.Next = s.End
@@ -165,7 +223,7 @@ func (s *States) PlanStartContChecks(req statemachine.Request[Data]) statemachin
 }
 
 // ExecuteBlock executes the current block.
-func (s *States) ExecuteBlock(req statemachine.Request[Data]) statemachine.Request[Data] {
+func (s *States) ExecuteBlock(req statemachine.Request[Data]) (out statemachine.Request[Data]) {
 	// No more blocks, the Plan is done.
 	if len(req.Data.blocks) == 0 {
 		req.Next = s.PlanPostChecks
@@ -174,9 +232,16 @@ func (s *States) ExecuteBlock(req statemachine.Request[Data]) statemachine.Reque
 
 	h := req.Data.blocks[0]
 
+	// The Plan has run out of time, we don't start any more blocks.
+	if err := s.deadlineExceeded(&req.Data, nil); err != nil {
+		req.Data.err = err
+		req.Next = s.PlanPostChecks
+		return req
+	}
+
 	defer func() {
 		if err := s.store.UpdateBlock(req.Ctx, h.block); err != nil {
-			log.Fatalf("failed to write Block: %v", err)
+			out = s.storageFailed(out, "Block", err)
 		}
 	}()
 
@@ -187,7 +252,15 @@ func (s *States) ExecuteBlock(req statemachine.Request[Data]) statemachine.Reque
 		return req
 	}
 
+	// The Plan may have run out of time while we waited for the EntranceDelay.
+	if err := s.deadlineExceeded(&req.Data, nil); err != nil {
+		req.Data.err = err
+		req.Next = s.PlanPostChecks
+		return req
+	}
+
 	h.block.State.Status = workflow.Running
+	h.block.State.Start = s.now()
 	req.Next = s.BlockPreChecks
 	return req
 }
@@ -260,6 +333,13 @@ func (s *States) ExecuteSequences(req statemachine.Request[Data]) statemachine.R
 		Name: "ExecuteSequences",
 	}
 
+	// deadlineErr is set if the Plan or Block exceeds its MaxDuration. We stop dispatching
+	// Sequences, but let the ones that are running finish.
+	var deadlineErr error
+	// storeErr is set if a Sequence could not record its state. Like deadlineErr, we stop dispatching
+	// and let the running Sequences finish.
+	storeErr := atomic.Pointer[error]{}
+
 	for i := 0; i < len(h.block.Sequences); i++ {
 		seq := h.block.Sequences[i]
 
@@ -278,6 +358,19 @@ func (s *States) ExecuteSequences(req statemachine.Request[Data]) statemachine.R
 		}
 
 		limiter <- struct{}{}
+		// We check after getting a slot in the limiter, as we may have waited for some time.
+		if deadlineErr = s.deadlineExceeded(&req.Data, h.block); deadlineErr != nil {
+			<-limiter
+			break
+		}
+		// Don't start new work while storage is degraded.
+		if err := s.waitStorage(req.Ctx); err != nil {
+			storeErr.CompareAndSwap(nil, &err)
+		}
+		if storeErr.Load() != nil {
+			<-limiter
+			break
+		}
 		g.Go(
 			req.Ctx,
 			func(ctx context.Context) error {
@@ -290,6 +383,9 @@ func (s *States) ExecuteSequences(req statemachine.Request[Data]) statemachine.R
 
 				err := s.execSeq(ctx, seq)
 				if err != nil {
+					if errors.Is(err, ErrStorage) {
+						storeErr.CompareAndSwap(nil, &err)
+					}
 					failures.Add(1)
 				}
 				return err
@@ -300,6 +396,20 @@ func (s *States) ExecuteSequences(req statemachine.Request[Data]) statemachine.R
 	waitCtx := context.WithoutCancel(req.Ctx)
 	g.Wait(waitCtx) // We don't care about the error here, we just want to wait for all sequences to finish.'
 
+	if errp := storeErr.Load(); errp != nil {
+		h.block.State.Status = workflow.Failed
+		req.Data.err = *errp
+		req.Next = s.BlockEnd
+		return req
+	}
+
+	if deadlineErr != nil {
+		h.block.State.Status = workflow.Failed
+		req.Data.err = deadlineErr
+		req.Next = s.BlockPostChecks
+		return req
+	}
+
 	// Need to recheck in case the last sequence failed and sent us over the edge.
 	if h.block.ToleratedFailures >= 0 && failures.Load() > int64(h.block.ToleratedFailures) {
 		h.block.State.Status = workflow.Failed
@@ -320,7 +430,7 @@ func (s *States) BlockPostChecks(req statemachine.Request[Data]) statemachine.Re
 		return req
 	}
 
-	err := s.runChecksOnce(req.Ctx, h.block.PostChecks)
+	err := s.runChecks(req.Ctx, h.block.PostChecks)
 	if err != nil {
 		h.block.State.Status = workflow.Failed
 		req.Data.err = err
@@ -333,12 +443,13 @@ func (s *States) BlockPostChecks(req statemachine.Request[Data]) statemachine.Re
 }
 
 // BlockEnd ends the current block and moves to the next block.
-func (s *States) BlockEnd(req statemachine.Request[Data]) statemachine.Request[Data] {
+func (s *States) BlockEnd(req statemachine.Request[Data]) (out statemachine.Request[Data]) {
 	h := req.Data.blocks[0]
 
 	defer func() {
+		h.block.State.End = s.now()
 		if err := s.store.UpdateBlock(req.Ctx, h.block); err != nil {
-			log.Fatalf("failed to write Block: %v", err)
+			out = s.storageFailed(out, "Block", err)
 		}
 	}()
 
@@ -368,6 +479,10 @@ func (s *States) BlockEnd(req statemachine.Request[Data]) statemachine.Request[D
 	} else {
 		h.block.State.Status = workflow.Failed
 		req.Next = s.End
+		// If the Plan ran out of time, we still want to stop the ContChecks and run the PostChecks.
+		if req.Data.planExpired {
+			req.Next = s.PlanPostChecks
+		}
 		return req
 	}
 
@@ -408,7 +523,7 @@ func (s *States) PlanPostChecks(req statemachine.Request[Data]) statemachine.Req
 	}
 
 	if req.Data.Plan.PostChecks != nil {
-		if err := s.runChecksOnce(req.Ctx, req.Data.Plan.PostChecks); err != nil {
+		if err := s.runChecks(req.Ctx, req.Data.Plan.PostChecks); err != nil {
 			req.Data.err = err
 			return req
 		}
@@ -418,10 +533,17 @@ func (s *States) PlanPostChecks(req statemachine.Request[Data]) statemachine.Req
 
 // End is the final state of the state machine. This is always the last state, regardless of errors.
 // This will do the calculations of the final state of the Plan.
-func (s *States) End(req statemachine.Request[Data]) statemachine.Request[Data] {
+func (s *States) End(req statemachine.Request[Data]) (out statemachine.Request[Data]) {
 	defer func() {
+		// If storage has already failed, this write will fail too. There is nowhere left to record it.
 		if err := s.store.UpdatePlan(req.Ctx, req.Data.Plan); err != nil {
-			log.Fatalf("failed to write Plan: %v", err)
+			log.Printf("failed to write Plan(%s) final state: %v", req.Data.Plan.ID, err)
+			if !errors.Is(out.Err, ErrStorage) {
+				out.Err = errors.Join(out.Err, storageErr("Plan", err))
+			}
+		}
+		if s.tracker != nil {
+			s.tracker.untrack(req.Ctx, req.Data.Plan)
 		}
 	}()
 
@@ -453,6 +575,36 @@ func (s *States) End(req statemachine.Request[Data]) statemachine.Request[Data]
 	return req
 }
 
+// storageFailed records a failed write to storage of what in req.Data.err and moves to the End state,
+// which fails the Plan.
+func (s *States) storageFailed(req statemachine.Request[Data], what string, err error) statemachine.Request[Data] {
+	req.Data.err = errors.Join(req.Data.err, storageErr(what, err))
+	req.Next = s.End
+	return req
+}
+
+// storageWaiter is implemented by a storage.Vault that can be degraded, such as guard.Vault.
+type storageWaiter interface {
+	// Wait blocks until the storage is no longer degraded.
+	Wait(ctx context.Context) error
+}
+
+// waitStorage blocks until storage is healthy if the store supports it. This keeps us from starting new
+// work while writes are failing. An error is returned if the storage could not be recovered.
+func (s *States) waitStorage(ctx context.Context) error {
+	w, ok := s.store.(storageWaiter)
+	if !ok {
+		return nil
+	}
+	if err := w.Wait(ctx); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrStorage, err)
+	}
+	return nil
+}
+
 // runPreChecks runs all PreChecks and ContChecks. This is a helper function for PlanPreChecks and BlockPreChecks.
 func (s *States) runPreChecks(ctx context.Context, preChecks *workflow.Checks, contChecks *workflow.Checks) error {
 	if preChecks == nil && contChecks == nil {
@@ -463,13 +615,19 @@ func (s *States) runPreChecks(ctx context.Context, preChecks *workflow.Checks, c
 
 	if preChecks != nil {
 		g.Go(ctx, func(ctx context.Context) error {
-			return s.runChecksOnce(ctx, preChecks)
+			return s.runChecks(ctx, preChecks)
 		})
 	}
 
 	if contChecks != nil {
 		g.Go(ctx, func(ctx context.Context) error {
-			return s.runChecksOnce(ctx, contChecks)
+			// The first run of the ContChecks is recorded, but it must pass. Tolerance only applies
+			// once the ContChecks are running in the background.
+			err := s.runChecksOnce(ctx, contChecks)
+			if rerr := s.recordRun(ctx, contChecks, err); rerr != nil {
+				return rerr
+			}
+			return err
 		})
 	}
 
@@ -499,6 +657,7 @@ func (s *States) runContChecks(ctx context.Context, checks *workflow.Checks, res
 			return
 		case <-t.C:
 			err := s.runChecksOnce(ctx, checks)
+			err = s.tolerate(ctx, checks, err)
 			resultCh <- err
 			if err != nil {
 				return
@@ -507,8 +666,194 @@ func (s *States) runContChecks(ctx context.Context, checks *workflow.Checks, res
 	}
 }
 
+// tolerate records the last run of the checks and returns err if the checks' Tolerance has been exceeded.
+// If the run failed, but the failure is tolerated, the checks are marked Completed and nil is returned.
+// Storage failures are never tolerated.
+func (s *States) tolerate(ctx context.Context, checks *workflow.Checks, err error) error {
+	if rerr := s.recordRun(ctx, checks, err); rerr != nil {
+		return rerr
+	}
+
+	if err == nil || errors.Is(err, ErrStorage) || toleranceExceeded(checks.Tolerance, checks.Runs) {
+		return err
+	}
+
+	if checks.State != nil {
+		checks.State.Status = workflow.Completed
+	}
+	if err := s.store.UpdateChecks(ctx, checks); err != nil {
+		return storageErr("ContChecks", err)
+	}
+	return nil
+}
+
+// recordRun adds the result of the last run of the checks to checks.Runs and writes the run, with the
+// results of its Actions, to the store. err is the result of the run. Only the last checks.RunRetention()
+// runs are kept in checks.Runs and they do not hold the results of the Actions. An error is only returned
+// if the run could not be written.
+func (s *States) recordRun(ctx context.Context, checks *workflow.Checks, err error) error {
+	run := &workflow.CheckRun{Iteration: 1, Status: workflow.Completed}
+	if err != nil {
+		run.Status = workflow.Failed
+	}
+	if len(checks.Runs) > 0 {
+		run.Iteration = checks.Runs[len(checks.Runs)-1].Iteration + 1
+	}
+	if checks.State != nil {
+		run.Start = checks.State.Start
+		run.End = checks.State.End
+	}
+	run.Actions = make([]*workflow.ActionRun, 0, len(checks.Actions))
+	for _, a := range checks.Actions {
+		ar := &workflow.ActionRun{ID: a.ID, Attempts: a.Attempts}
+		if a.State != nil {
+			ar.Status = a.State.Status
+		}
+		run.Actions = append(run.Actions, ar)
+	}
+
+	if err := s.store.RecordCheckRun(ctx, checks, run); err != nil {
+		return storageErr("ContChecks run", err)
+	}
+
+	summary := *run
+	summary.Actions = nil
+
+	if keep := checks.RunRetention(); len(checks.Runs) >= keep {
+		n := copy(checks.Runs, checks.Runs[len(checks.Runs)-keep+1:])
+		checks.Runs = checks.Runs[:n]
+	}
+	checks.Runs = append(checks.Runs, &summary)
+	return nil
+}
+
+// toleranceExceeded returns true if the failed runs exceed the Tolerance. A nil Tolerance
+// is exceeded by a single failed run.
+func toleranceExceeded(t *workflow.Tolerance, runs []*workflow.CheckRun) bool {
+	if len(runs) == 0 || runs[len(runs)-1].Status != workflow.Failed {
+		return false
+	}
+	if t == nil {
+		return true
+	}
+
+	if t.Consecutive > 0 {
+		n := 0
+		for i := len(runs) - 1; i >= 0 && runs[i].Status == workflow.Failed; i-- {
+			n++
+		}
+		if n >= t.Consecutive {
+			return true
+		}
+	}
+
+	if t.Window > 0 {
+		n := 0
+		for _, run := range runs[max(0, len(runs)-t.Window):] {
+			if run.Status == workflow.Failed {
+				n++
+			}
+		}
+		if n >= t.Failures {
+			return true
+		}
+	}
+	return false
+}
+
+// runChecks runs PreChecks or PostChecks. If the checks have a Gate, they are run until they pass or
+// the Gate's Timeout is reached. Otherwise they are run once.
+func (s *States) runChecks(ctx context.Context, checks *workflow.Checks) error {
+	if checks.Gate == nil {
+		return s.runChecksOnce(ctx, checks)
+	}
+	return s.runGate(ctx, checks)
+}
+
+// runGate runs the checks until all Actions pass or the Gate's Timeout is reached. Between runs it waits
+// the Gate's Interval or, if that is not set, what the retry policy of the first Action says.
+func (s *States) runGate(ctx context.Context, checks *workflow.Checks) error {
+	gate := checks.Gate
+
+	gateCtx, cancel := context.WithTimeout(ctx, gate.Timeout)
+	defer cancel()
+
+	var lastErr error
+	op := func(ctx context.Context, r exponential.Record) error {
+		lastErr = s.runChecksOnce(ctx, checks)
+		switch {
+		case lastErr == nil:
+			return nil
+		case errors.Is(lastErr, ErrStorage):
+			// Storage failing is not a check failing, so the gate stops right away.
+			return fmt.Errorf("%w: %w", exponential.ErrPermanent, lastErr)
+		}
+		// An Action that used up its retries returns a permanent error, which would stop the backoff.
+		// A gate retries every check failure until its Timeout.
+		return errors.New(lastErr.Error())
+	}
+
+	var err error
+	if gate.Interval > 0 {
+		for {
+			if err = op(gateCtx, exponential.Record{}); err == nil || errors.Is(err, exponential.ErrPermanent) {
+				break
+			}
+			if err = after(gateCtx, gate.Interval); err != nil {
+				break
+			}
+		}
+	} else {
+		var backoff *exponential.Backoff
+		backoff, err = exponential.New(exponential.WithPolicy(s.gatePolicy(checks)))
+		if err != nil {
+			return fmt.Errorf("gate could not create backoff from retry policy: %w", err)
+		}
+		err = backoff.Retry(gateCtx, op)
+	}
+
+	if err == nil {
+		return nil
+	}
+	if errors.Is(lastErr, ErrStorage) {
+		return lastErr
+	}
+	// The parent Context was cancelled, so this isn't a gate failure.
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if lastErr == nil {
+		lastErr = err
+	}
+	return fmt.Errorf("gate did not pass within %v: %w", gate.Timeout, lastErr)
+}
+
+// gatePolicy returns the retry policy that is used between runs of a Gate without an Interval. This is
+// the first Action's RetryPolicy or, if not set, its plugin's.
+func (s *States) gatePolicy(checks *workflow.Checks) exponential.Policy {
+	a := checks.Actions[0]
+	if a.RetryPolicy != nil {
+		return *a.RetryPolicy
+	}
+	if s.registry != nil {
+		if p := s.registry.PluginVersion(a.Plugin, a.Version); p != nil {
+			return p.RetryPolicy()
+		}
+	}
+	return defaultGatePolicy
+}
+
+// defaultGatePolicy is used by gatePolicy when the Action's plugin cannot be found. This should only happen in tests,
+// as plugins are checked when the Plan is submitted.
+var defaultGatePolicy = exponential.Policy{
+	InitialInterval:     time.Second,
+	Multiplier:          2,
+	RandomizationFactor: 0.2,
+	MaxInterval:         30 * time.Second,
+}
+
 // runContChecksOnce runs the ContChecks once and writes the result to the store.
-func (s *States) runChecksOnce(ctx context.Context, checks *workflow.Checks) error {
+func (s *States) runChecksOnce(ctx context.Context, checks *workflow.Checks) (err error) {
 	if s.checksRunner != nil {
 		return s.checksRunner(ctx, checks)
 	}
@@ -518,18 +863,23 @@ func (s *States) runChecksOnce(ctx context.Context, checks *workflow.Checks) err
 	checks.State.Start = s.now()
 
 	if err := s.store.UpdateChecks(ctx, checks); err != nil {
-		log.Fatalf("failed to write ContChecks: %v", err)
+		return storageErr("Checks", err)
 	}
 	defer func() {
-		if err := s.store.UpdateChecks(ctx, checks); err != nil {
-			log.Fatalf("failed to write ContChecks: %v", err)
+		if werr := s.store.UpdateChecks(ctx, checks); werr != nil && !errors.Is(err, ErrStorage) {
+			err = errors.Join(err, storageErr("Checks", werr))
 		}
 	}()
 	defer func() {
 		checks.State.End = s.now()
 	}()
 
-	if err := s.runActionsParallel(ctx, checks.Actions); err != nil {
+	if checks.Quorum != nil {
+		err = s.runActionsQuorum(ctx, checks.Actions, checks.Quorum)
+	} else {
+		err = s.runActionsParallel(ctx, checks.Actions)
+	}
+	if err != nil {
 		checks.State.Status = workflow.Failed
 		return err
 	}
@@ -542,13 +892,8 @@ func (s *States) runActionsParallel(ctx context.Context, actions []*workflow.Act
 	if s.actionsParallelRunner != nil {
 		return s.actionsParallelRunner(ctx, actions)
 	}
-	// Yes, we loop twice, but actions is small and we only want to write to the store once.
-	for _, action := range actions {
-		action.State.Status = workflow.Running
-		action.State.Start = s.now()
-		if err := s.store.UpdateAction(ctx, action); err != nil {
-			log.Fatalf("failed to write Action: %v", err)
-		}
+	if err := s.markRunning(ctx, actions); err != nil {
+		return err
 	}
 
 	g := wait.Group{}
@@ -564,30 +909,160 @@ func (s *States) runActionsParallel(ctx context.Context, actions []*workflow.Act
 	return g.Wait(ctx)
 }
 
+// runActionsQuorum runs a list of actions in parallel like runActionsParallel, but only returns an error
+// if fewer actions passed than the Quorum requires. The error holds the error of every action that failed.
+func (s *States) runActionsQuorum(ctx context.Context, actions []*workflow.Action, quorum *workflow.Quorum) error {
+	if err := s.markRunning(ctx, actions); err != nil {
+		return err
+	}
+
+	errs := make([]error, len(actions))
+	g := wait.Group{}
+
+	for i, action := range actions {
+		i, action := i, action
+
+		g.Go(ctx, func(ctx context.Context) error {
+			errs[i] = s.runAction(ctx, action, s.store)
+			return nil
+		})
+	}
+	g.Wait(ctx)
+
+	var failed []error
+	for i, err := range errs {
+		// A quorum can't make up for state that could not be recorded.
+		if errors.Is(err, ErrStorage) {
+			return err
+		}
+		if err != nil {
+			failed = append(failed, fmt.Errorf("action(%s): %w", actions[i].Name, err))
+		}
+	}
+
+	passed := len(actions) - len(failed)
+	required := quorum.Required(len(actions))
+	if passed >= required {
+		return nil
+	}
+	return fmt.Errorf("quorum not met, %d of %d actions passed but %d are required: %w", passed, len(actions), required, errors.Join(failed...))
+}
+
+// markRunning marks a list of actions as running and writes them to the store.
+func (s *States) markRunning(ctx context.Context, actions []*workflow.Action) error {
+	// Yes, we loop twice, but actions is small and we only want to write to the store once.
+	for _, action := range actions {
+		action.State.Status = workflow.Running
+		action.State.Start = s.now()
+		if err := s.store.UpdateAction(ctx, action); err != nil {
+			return storageErr("Action", err)
+		}
+	}
+	return nil
+}
+
 // execSeq executes a sequence of actions. Any Job failures fail the Sequnence. The Job may retry
 // based on the retry policy.
-func (s *States) execSeq(ctx context.Context, seq *workflow.Sequence) error {
+func (s *States) execSeq(ctx context.Context, seq *workflow.Sequence) (err error) {
 	seq.State.Status = workflow.Running
+	seq.State.Start = s.now()
 	if err := s.store.UpdateSequence(ctx, seq); err != nil {
-		log.Fatalf("failed to write Sequence: %v", err)
+		seq.State.Status = workflow.Failed
+		return storageErr("Sequence", err)
 	}
 	defer func() {
-		if err := s.store.UpdateSequence(ctx, seq); err != nil {
-			log.Fatalf("failed to write Sequence: %v", err)
+		seq.State.End = s.now()
+		if werr := s.store.UpdateSequence(ctx, seq); werr != nil && !errors.Is(err, ErrStorage) {
+			err = errors.Join(err, storageErr("Sequence", werr))
 		}
 	}()
 
-	for _, action := range seq.Actions {
-		if err := s.runAction(ctx, action, s.store); err != nil {
-			seq.State.Status = workflow.Failed
-			return err
+	if err := s.runPreChecks(ctx, seq.PreChecks, seq.ContChecks); err != nil {
+		seq.State.Status = workflow.Failed
+		return fmt.Errorf("sequence(%s) prechecks failed: %w", seq.Name, err)
+	}
+
+	passing, stop := s.startContChecks(ctx, seq.ContChecks)
+
+	expired, err := s.execSeqActions(ctx, seq, passing)
+	// If the Sequence ran out of time, we still want to run the PostChecks.
+	if (err == nil || expired) && seq.PostChecks != nil {
+		if postErr := s.runChecks(ctx, seq.PostChecks); postErr != nil {
+			err = errors.Join(err, fmt.Errorf("sequence(%s) postchecks failed: %w", seq.Name, postErr))
 		}
 	}
+	// The ContChecks must always be stopped, even if the Sequence has already failed.
+	if contErr := stop(); err == nil && contErr != nil {
+		err = fmt.Errorf("sequence(%s) contchecks failed: %w", seq.Name, contErr)
+	}
+	if err != nil {
+		seq.State.Status = workflow.Failed
+		return err
+	}
 
 	seq.State.Status = workflow.Completed
 	return nil
 }
 
+// execSeqActions runs the Actions of a Sequence in order. Before each Action it checks that the Sequence
+// has not exceeded its MaxDuration and that passing() does not return an error. expired is true if
+// the error is because the Sequence exceeded its MaxDuration.
+func (s *States) execSeqActions(ctx context.Context, seq *workflow.Sequence, passing func() error) (expired bool, err error) {
+	for _, action := range seq.Actions {
+		if s.expired(seq.State, seq.MaxDuration) {
+			return true, fmt.Errorf("sequence(%s) exceeded its max duration(%v)", seq.Name, seq.MaxDuration)
+		}
+		if err := passing(); err != nil {
+			return false, fmt.Errorf("sequence(%s) contchecks failed: %w", seq.Name, err)
+		}
+		if err := s.runAction(ctx, action, s.store); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// startContChecks starts the ContChecks in the background. passing returns the error of the ContChecks if
+// they have failed without blocking. stop cancels the ContChecks and returns their final result.
+// If checks is nil, both functions always return nil.
+func (s *States) startContChecks(ctx context.Context, checks *workflow.Checks) (passing func() error, stop func() error) {
+	if checks == nil {
+		noop := func() error { return nil }
+		return noop, noop
+	}
+
+	resultCh := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go s.runContChecks(ctx, checks, resultCh)
+
+	// failed is only accessed by the goroutine that calls passing and stop.
+	var failed error
+	passing = func() error {
+		if failed != nil {
+			return failed
+		}
+		select {
+		case err := <-resultCh:
+			failed = err
+		default:
+		}
+		return failed
+	}
+	stop = func() error {
+		cancel()
+		if failed != nil {
+			return failed
+		}
+		for err := range resultCh {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return passing, stop
+}
+
 // runAction runs an action and returns the response or an error. If the response is not the expected
 // type, it returns a permanent error that prevents retries.
 func (s *States) runAction(ctx context.Context, action *workflow.Action, updater storage.ActionUpdater) error {
@@ -598,9 +1073,12 @@ func (s *States) runAction(ctx context.Context, action *workflow.Action, updater
 	req := statemachine.Request[actions.Data]{
 		Ctx: ctx,
 		Data: actions.Data{
-			Action:   action,
-			Updater:  updater,
-			Registry: s.registry,
+			Action:       action,
+			Updater:      updater,
+			Registry:     s.registry,
+			Chain:        s.tracker.chain(action.ID),
+			Interceptors: s.interceptors,
+			Outputs:      s.outputs,
 		},
 		Next: s.actionsSM.Start,
 	}
@@ -619,6 +1097,29 @@ func (s *States) now() time.Time {
 	return s.nower().UTC()
 }
 
+// deadlineExceeded returns an error if the Plan or the Block (if not nil) has exceeded its MaxDuration.
+// If the Plan has exceeded its MaxDuration, Data.planExpired is set.
+func (s *States) deadlineExceeded(data *Data, b *workflow.Block) error {
+	plan := data.Plan
+	if s.expired(plan.State, plan.MaxDuration) {
+		data.planExpired = true
+		return fmt.Errorf("plan(%s) exceeded its max duration(%v)", plan.Name, plan.MaxDuration)
+	}
+	if b != nil && s.expired(b.State, b.MaxDuration) {
+		return fmt.Errorf("block(%s) exceeded its max duration(%v)", b.Name, b.MaxDuration)
+	}
+	return nil
+}
+
+// expired returns true if an object with State has been running longer than max. A max <= 0
+// means there is no limit.
+func (s *States) expired(state *workflow.State, max time.Duration) bool {
+	if max <= 0 || state.Start.IsZero() {
+		return false
+	}
+	return s.now().Sub(state.Start) >= max
+}
+
 // resetActions adjusts all the actions to their initial un-started state.
 // This is used by the ContChecks to reset the actions before each run.
 func resetActions(actions []*workflow.Action) {
@@ -627,6 +1128,8 @@ func resetActions(actions []*workflow.Action) {
 		action.State.Start = time.Time{}
 		action.State.End = time.Time{}
 		action.Attempts = nil
+		action.Spent = 0
+		action.Checkpoint = nil
 	}
 }
 
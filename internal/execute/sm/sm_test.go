@@ -2,6 +2,7 @@ package sm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"runtime"
@@ -13,6 +14,7 @@ import (
 	"github.com/element-of-surprise/coercion/plugins/registry"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/builder"
+	"github.com/element-of-surprise/coercion/workflow/storage"
 	"github.com/element-of-surprise/coercion/workflow/utils/clone"
 	"github.com/gostdlib/ops/statemachine"
 )
@@ -192,12 +194,21 @@ func TestExecuteBlocks(t *testing.T) {
 	tests := []struct {
 		name          string
 		block         block
+		planExpired   bool
 		wantNextState statemachine.State[Data]
 	}{
 		{
 			name:          "No more blocks",
 			wantNextState: states.PlanPostChecks,
 		},
+		{
+			name: "Plan exceeded max duration",
+			block: block{
+				block: &workflow.Block{State: &workflow.State{}},
+			},
+			planExpired:   true,
+			wantNextState: states.PlanPostChecks,
+		},
 		{
 			name: "Have a block",
 			block: block{
@@ -213,9 +224,15 @@ func TestExecuteBlocks(t *testing.T) {
 		if test.block.block != nil {
 			blocks = append(blocks, test.block)
 		}
+		plan := &workflow.Plan{State: &workflow.State{Start: time.Now()}}
+		if test.planExpired {
+			plan.MaxDuration = time.Minute
+			plan.State.Start = time.Now().Add(-time.Hour)
+		}
 		req := statemachine.Request[Data]{
 			Ctx: context.Background(),
 			Data: Data{
+				Plan:   plan,
 				blocks: blocks,
 			},
 		}
@@ -223,6 +240,15 @@ func TestExecuteBlocks(t *testing.T) {
 		if methodName(req.Next) != methodName(test.wantNextState) {
 			t.Errorf("TestExecuteBlocks(%s): got next state = %v, want %v", test.name, methodName(req.Next), methodName(test.wantNextState))
 		}
+		if req.Data.planExpired != test.planExpired {
+			t.Errorf("TestExecuteBlocks(%s): got planExpired = %v, want %v", test.name, req.Data.planExpired, test.planExpired)
+		}
+		if test.planExpired {
+			if req.Data.blocks[0].block.State.Status != workflow.NotStarted {
+				t.Errorf("TestExecuteBlocks(%s): got block state = %v, want %v", test.name, req.Data.blocks[0].block.State.Status, workflow.NotStarted)
+			}
+			continue
+		}
 		if len(req.Data.blocks) != 0 {
 			if req.Data.blocks[0].block.State.Status != workflow.Running {
 				t.Errorf("TestExecuteBlocks(%s): got block state = %v, want %v", test.name, req.Data.blocks[0].block.State.Status, workflow.Running)
@@ -376,9 +402,11 @@ func TestExecuteSequences(t *testing.T) {
 	tests := []struct {
 		name            string
 		block           *workflow.Block
+		planMaxDuration time.Duration
 		contCheckFail   bool
 		wantPluginCalls int
 		wantStatus      workflow.Status
+		wantPlanExpired bool
 		wantErr         bool
 	}{
 		{
@@ -437,6 +465,33 @@ func TestExecuteSequences(t *testing.T) {
 			wantStatus:    workflow.Failed,
 			wantErr:       true,
 		},
+		{
+			name: "Error: Block exceeded max duration",
+			block: &workflow.Block{
+				ToleratedFailures: 0,
+				Concurrency:       1,
+				MaxDuration:       time.Minute,
+				Sequences: []*workflow.Sequence{
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+				},
+			},
+			wantStatus: workflow.Failed,
+			wantErr:    true,
+		},
+		{
+			name: "Error: Plan exceeded max duration",
+			block: &workflow.Block{
+				ToleratedFailures: 0,
+				Concurrency:       1,
+				Sequences: []*workflow.Sequence{
+					clone.Sequence(ctx, sequenceWithSuccess, cloneOpts...), // Never should be called.
+				},
+			},
+			planMaxDuration: time.Minute,
+			wantStatus:      workflow.Failed,
+			wantPlanExpired: true,
+			wantErr:         true,
+		},
 		{
 			name: "Success",
 			block: &workflow.Block{
@@ -464,11 +519,16 @@ func TestExecuteSequences(t *testing.T) {
 			store:    &fakeUpdater{},
 		}
 
+		// Both the Plan and Block started an hour ago, so any MaxDuration set has expired.
+		started := time.Now().Add(-time.Hour)
 		req := statemachine.Request[Data]{
 			Ctx: context.Background(),
+			Data: Data{
+				Plan: &workflow.Plan{MaxDuration: test.planMaxDuration, State: &workflow.State{Start: started}},
+			},
 		}
 		req.Data.blocks = []block{{block: test.block}}
-		test.block.State = &workflow.State{}
+		test.block.State = &workflow.State{Start: started}
 		if test.contCheckFail {
 			req.Data.contCheckResult = make(chan error, 1)
 			req.Data.contCheckResult <- fmt.Errorf("error")
@@ -491,6 +551,9 @@ func TestExecuteSequences(t *testing.T) {
 		if plug.Calls.Load() != int64(test.wantPluginCalls) {
 			t.Errorf("TestExecuteSequences(%s): got plugin calls == %v, want == %v", test.name, plug.Calls.Load(), test.wantPluginCalls)
 		}
+		if req.Data.planExpired != test.wantPlanExpired {
+			t.Errorf("TestExecuteSequences(%s): got planExpired == %v, want == %v", test.name, req.Data.planExpired, test.wantPlanExpired)
+		}
 	}
 }
 
@@ -565,6 +628,66 @@ func TestExecuteSequencesConcurrency(t *testing.T) {
 	}
 }
 
+func TestExecuteSequencesStorage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		store storage.Vault
+	}{
+		{
+			name:  "Sequence could not be written",
+			store: &fakeUpdater{err: errors.New("disk full")},
+		},
+		{
+			name:  "storage could not recover",
+			store: fakeWaiter{err: errors.New("gave up"), fakeUpdater: &fakeUpdater{}},
+		},
+	}
+
+	for _, test := range tests {
+		seqs := []*workflow.Sequence{
+			{Name: "seq0", Actions: []*workflow.Action{{Name: "action"}}, State: &workflow.State{}},
+			{Name: "seq1", Actions: []*workflow.Action{{Name: "action"}}, State: &workflow.State{}},
+		}
+		b := &workflow.Block{
+			Name:              "block",
+			Concurrency:       1,
+			ToleratedFailures: -1,
+			Sequences:         seqs,
+			State:             &workflow.State{Status: workflow.Running},
+		}
+
+		states := States{
+			store:        test.store,
+			actionRunner: fakeActionRunner,
+		}
+
+		req := statemachine.Request[Data]{
+			Ctx: context.Background(),
+			Data: Data{
+				Plan:   &workflow.Plan{Blocks: []*workflow.Block{b}},
+				blocks: []block{{block: b}},
+			},
+		}
+		req = states.ExecuteSequences(req)
+
+		if !errors.Is(req.Data.err, ErrStorage) {
+			t.Errorf("TestExecuteSequencesStorage(%s): got err == %v, want err wrapping ErrStorage", test.name, req.Data.err)
+		}
+		if methodName(req.Next) != methodName(states.BlockEnd) {
+			t.Errorf("TestExecuteSequencesStorage(%s): got next == %v, want next == %v", test.name, methodName(req.Next), methodName(states.BlockEnd))
+		}
+		if b.State.Status != workflow.Failed {
+			t.Errorf("TestExecuteSequencesStorage(%s): got block status %v, want %v", test.name, b.State.Status, workflow.Failed)
+		}
+		// We stop dispatching once storage fails, so the second Sequence never starts.
+		if seqs[1].State.Status != workflow.NotStarted {
+			t.Errorf("TestExecuteSequencesStorage(%s): second Sequence was started", test.name)
+		}
+	}
+}
+
 func TestBlockPostChecks(t *testing.T) {
 	t.Parallel()
 
@@ -639,6 +762,7 @@ func TestBlockEnd(t *testing.T) {
 	tests := []struct {
 		name            string
 		data            Data
+		blockStatus     workflow.Status
 		contCheckResult error
 		wantErr         bool
 		wantBlockStatus workflow.Status
@@ -656,6 +780,27 @@ func TestBlockEnd(t *testing.T) {
 			wantNextState:   states.End,
 			wantBlocksLen:   1,
 		},
+		{
+			name: "Block failed",
+			data: Data{
+				blocks: []block{{}, {}},
+			},
+			blockStatus:     workflow.Failed,
+			wantBlockStatus: workflow.Failed,
+			wantNextState:   states.End,
+			wantBlocksLen:   2,
+		},
+		{
+			name: "Block failed, Plan expired",
+			data: Data{
+				blocks:      []block{{}, {}},
+				planExpired: true,
+			},
+			blockStatus:     workflow.Failed,
+			wantBlockStatus: workflow.Failed,
+			wantNextState:   states.PlanPostChecks,
+			wantBlocksLen:   2,
+		},
 		{
 			name: "Success: no more blocks",
 			data: Data{
@@ -680,11 +825,14 @@ func TestBlockEnd(t *testing.T) {
 		states := &States{
 			store: &fakeUpdater{},
 		}
+		if test.blockStatus == workflow.NotStarted {
+			test.blockStatus = workflow.Running
+		}
 		for i, block := range test.data.blocks {
 			if block.block == nil {
-				block.block = &workflow.Block{State: &workflow.State{Status: workflow.Running}}
+				block.block = &workflow.Block{State: &workflow.State{Status: test.blockStatus}}
 			} else {
-				block.block.State = &workflow.State{Status: workflow.Running}
+				block.block.State = &workflow.State{Status: test.blockStatus}
 			}
 			test.data.blocks[i] = block
 		}
@@ -724,7 +872,7 @@ func TestBlockEnd(t *testing.T) {
 			t.Errorf("TestBlockEnd(%s): context for continuous checks should have been cancelled", test.name)
 		}
 		if states.store.(*fakeUpdater).calls.Load() != 1 {
-			t.Errorf("TestBlockEnd(%s): got store calls == %v, want store calls == 1", test.name, states.store.(*fakeUpdater).calls)
+			t.Errorf("TestBlockEnd(%s): got store calls == %v, want store calls == 1", test.name, states.store.(*fakeUpdater).calls.Load())
 		}
 	}
 }
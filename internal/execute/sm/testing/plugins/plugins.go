@@ -3,10 +3,12 @@ package plugins
 import (
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
 	"github.com/gostdlib/ops/retry/exponential"
 )
 
@@ -24,6 +26,12 @@ type Req struct {
 	Started chan struct{} `json:"-"`
 	// PauseUntil is a channel that Execute() will block on until closed.
 	PauseUntil chan struct{} `json:"-"`
+	// Panic causes Execute() to panic.
+	Panic bool
+	// Log is written with execinfo.Log() and the progress is set to 100 when Execute() is called.
+	Log string
+	// Checkpoint is saved with execinfo.SaveCheckpoint() when Execute() is called.
+	Checkpoint string
 }
 
 type Resp struct {
@@ -31,17 +39,27 @@ type Resp struct {
 	Arg string
 }
 
-var _ plugins.Plugin = &Plugin{}
+var (
+	_ plugins.Plugin         = &Plugin{}
+	_ plugins.AbandonLimiter = &Plugin{}
+	_ plugins.Versioner      = &Plugin{}
+)
 
 type Plugin struct {
 	// PlugName overrides the plugin name. If empty, the default name is used.
 	PlugName string
+	// PlugVersion is returned by Version().
+	PlugVersion string
 	// IsCheckPlugin is a flag to indicate if the plugin is a check plugin.
 	IsCheckPlugin bool
 	// Responses is a list of responses to return.
 	Responses []any // This is a list of responses, if *plugins.Error, will be returned as an error
 	// AlwaysRespond indicates to ignore Responses and always return a non-error response.
 	AlwaysRespond bool
+	// MaxAbandon is returned by MaxAbandoned().
+	MaxAbandon int
+	// InitFails is the number of calls to Init() that fail before it succeeds.
+	InitFails int64
 
 	// MaxCount is a count of the maximum concurrecy this Plugin was called with.
 	// You should not set this.
@@ -53,8 +71,16 @@ type Plugin struct {
 	// You should not set this.
 	Calls atomic.Int64
 
+	// mu protects Checkpoints.
+	mu sync.Mutex
+	// Checkpoints are the checkpoints given to each Execute() call by execinfo.Checkpoint().
+	// You should not set this.
+	Checkpoints []string
+
 	// at is the current index of the response.
 	at atomic.Int64
+	// inits is the number of calls to Init().
+	inits atomic.Int64
 }
 
 func (h *Plugin) ResetCounts() {
@@ -94,6 +120,25 @@ func (h *Plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
 		<-r.PauseUntil
 	}
 
+	if r.Panic {
+		panic("plugin told to panic")
+	}
+
+	if r.Log != "" {
+		execinfo.Log(ctx, "%s", r.Log)
+		execinfo.Progress(ctx, 100)
+	}
+
+	h.mu.Lock()
+	h.Checkpoints = append(h.Checkpoints, string(execinfo.Checkpoint(ctx)))
+	h.mu.Unlock()
+
+	if r.Checkpoint != "" {
+		if err := execinfo.SaveCheckpoint(ctx, []byte(r.Checkpoint)); err != nil {
+			return nil, &plugins.Error{Message: err.Error()}
+		}
+	}
+
 	at := h.at.Add(1) - 1
 
 	time.Sleep(r.Sleep)
@@ -110,6 +155,16 @@ func (h *Plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
 	return h.Responses[at], nil
 }
 
+// Version implements plugins.Versioner.
+func (h *Plugin) Version() string {
+	return h.PlugVersion
+}
+
+// MaxAbandoned implements plugins.AbandonLimiter.
+func (h *Plugin) MaxAbandoned() int {
+	return h.MaxAbandon
+}
+
 // ValidateReq validates the request object.
 func (h *Plugin) ValidateReq(a any) error {
 	if _, ok := a.(Req); !ok {
@@ -152,5 +207,8 @@ func (h *Plugin) RetryPolicy() exponential.Policy {
 // This is useful for plugins that require local resources like a command line application to
 // be installed.
 func (h *Plugin) Init() error {
+	if h.inits.Add(1) <= h.InitFails {
+		return fmt.Errorf("plugin told to fail Init()")
+	}
 	return nil
 }
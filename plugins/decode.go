@@ -0,0 +1,24 @@
+package plugins
+
+import (
+	"reflect"
+
+	"github.com/go-json-experiment/json"
+)
+
+// DecodeInto decodes b, the JSON of a request or response, into v, which is a value returned by a Plugin's
+// Request() or Response(). If v is a pointer, b is decoded into what it points to. Otherwise, b is decoded into
+// an interface holding v, so a value type stays a value type. The decoded value is returned. This is how storage
+// and anything that reads stored requests and responses decodes them.
+func DecodeInto(b []byte, v any) (any, error) {
+	if v != nil && reflect.TypeOf(v).Kind() == reflect.Pointer {
+		if err := json.Unmarshal(b, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,51 @@
+package plugins
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type decodeReq struct {
+	Say string
+}
+
+func TestDecodeInto(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		b       string
+		v       any
+		want    any
+		wantErr bool
+	}{
+		{name: "Error: bad JSON", b: `{"Say":`, v: &decodeReq{}, wantErr: true},
+		{name: "Pointer", b: `{"Say":"hello"}`, v: &decodeReq{}, want: &decodeReq{Say: "hello"}},
+		{name: "Value", b: `{"Say":"hello"}`, v: decodeReq{}, want: decodeReq{Say: "hello"}},
+		{name: "Nil", b: `{"Say":"hello"}`, v: nil, want: map[string]any{"Say": "hello"}},
+	}
+
+	for _, test := range tests {
+		got, err := DecodeInto([]byte(test.b), test.v)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestDecodeInto(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestDecodeInto(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		// pretty.Compare doesn't compare types, so a value and a pointer to it would be equal.
+		if reflect.TypeOf(got) != reflect.TypeOf(test.want) {
+			t.Errorf("TestDecodeInto(%s): got type %T, want type %T", test.name, got, test.want)
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestDecodeInto(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
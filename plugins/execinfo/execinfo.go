@@ -0,0 +1,184 @@
+/*
+Package execinfo provides information about the execution a plugin is running for. The Context passed
+to Plugin.Execute() holds an Info that can be retrieved with From().
+
+This allows plugins to correlate their logs with the Plan:
+
+	func (p *Plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
+		execinfo.Logger(ctx).Info("doing the thing")
+		...
+	}
+
+Plugins can also record what they are doing with the Attempt. Lines written with Log() and
+the percentage given to Progress() are stored in the workflow.Attempt and can be watched live
+with Workstream.Output():
+
+	execinfo.Log(ctx, "copying %d files", len(files))
+	for i, f := range files {
+		...
+		execinfo.Progress(ctx, (i+1)*100/len(files))
+	}
+
+Plugins that run for a long time can save a checkpoint with SaveCheckpoint(). The checkpoint is stored
+on the workflow.Action and returned by Checkpoint() on the next attempt, so the plugin can continue
+where it stopped instead of starting over:
+
+	var done int
+	if cp := execinfo.Checkpoint(ctx); cp != nil {
+		done, _ = strconv.Atoi(string(cp))
+	}
+	for i := done; i < len(files); i++ {
+		...
+		if err := execinfo.SaveCheckpoint(ctx, []byte(strconv.Itoa(i+1))); err != nil {
+			return nil, &plugins.Error{Message: err.Error()}
+		}
+	}
+*/
+package execinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/google/uuid"
+)
+
+// Info holds information about the Action a plugin is executing for.
+type Info struct {
+	// PlanID is the ID of the Plan.
+	PlanID uuid.UUID
+	// PlanName is the name of the Plan.
+	PlanName string
+	// ActionID is the ID of the Action.
+	ActionID uuid.UUID
+	// ActionName is the name of the Action.
+	ActionName string
+	// Chain is the chain of objects that led to the Action, starting with the Plan.
+	// The objects must not be modified.
+	Chain []workflow.Object
+	// Attempt is the number of this attempt, starting at 1.
+	Attempt int
+	// Deadline is when this attempt times out.
+	Deadline time.Time
+	// Logger is a logger with attributes for the Plan, Action and attempt.
+	Logger *slog.Logger
+	// Output receives the lines and progress that are recorded with the Attempt. Use Log() and
+	// Progress() instead of calling this directly.
+	Output Output
+	// Checkpointer holds the checkpoint of the Action. Use Checkpoint() and SaveCheckpoint() instead
+	// of calling this directly.
+	Checkpointer Checkpointer
+}
+
+// MaxCheckpointSize is the largest checkpoint in bytes that can be saved.
+const MaxCheckpointSize = 1 << 20
+
+// Checkpointer holds the checkpoint of an Action. It must be safe for concurrent use.
+type Checkpointer interface {
+	// Checkpoint returns the last checkpoint that was saved. This is nil if none was.
+	Checkpoint() []byte
+	// SaveCheckpoint stores data as the checkpoint of the Action. This returns once it has
+	// been written to storage.
+	SaveCheckpoint(ctx context.Context, data []byte) error
+}
+
+// Output receives the output of a plugin that is recorded with the Attempt.
+// It must be safe for concurrent use.
+type Output interface {
+	// Log records a line.
+	Log(line string)
+	// Progress records the progress percentage, from 0 to 100.
+	Progress(percent int)
+}
+
+type key struct{}
+
+// NewContext returns a new Context that holds info. This is used by the engine and should
+// only be used by plugin authors in tests.
+func NewContext(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, key{}, info)
+}
+
+// From returns the Info in the Context. ok is false if the Context does not hold one, which
+// happens if the plugin is not being executed by the engine.
+func From(ctx context.Context) (info Info, ok bool) {
+	info, ok = ctx.Value(key{}).(Info)
+	return info, ok
+}
+
+// Logger returns the Logger in the Context's Info. If there isn't one, slog.Default() is returned.
+func Logger(ctx context.Context) *slog.Logger {
+	info, ok := From(ctx)
+	if !ok || info.Logger == nil {
+		return slog.Default()
+	}
+	return info.Logger
+}
+
+// Log records a line with the Attempt the Context is for. Lines are bounded in length and only
+// the last lines are kept, so this is not a replacement for Logger(). If the Context has no
+// Info, this does nothing.
+func Log(ctx context.Context, format string, args ...any) {
+	info, ok := From(ctx)
+	if !ok || info.Output == nil {
+		return
+	}
+	info.Output.Log(fmt.Sprintf(format, args...))
+}
+
+// Progress records the progress percentage of the Attempt the Context is for. percent is clamped
+// between 0 and 100. If the Context has no Info, this does nothing.
+func Progress(ctx context.Context, percent int) {
+	info, ok := From(ctx)
+	if !ok || info.Output == nil {
+		return
+	}
+	info.Output.Progress(min(max(percent, 0), 100))
+}
+
+// Checkpoint returns the last checkpoint saved for the Action the Context is for, which may
+// have been saved by an earlier attempt. This is nil if there is no checkpoint or the Context
+// has no Info. The returned slice must not be modified.
+func Checkpoint(ctx context.Context) []byte {
+	info, ok := From(ctx)
+	if !ok || info.Checkpointer == nil {
+		return nil
+	}
+	return info.Checkpointer.Checkpoint()
+}
+
+// SaveCheckpoint saves data as the checkpoint of the Action the Context is for. data is copied and
+// replaces any earlier checkpoint. An error is returned if the Context has no Info, data is larger than
+// MaxCheckpointSize, the attempt is over or the checkpoint could not be written to storage.
+func SaveCheckpoint(ctx context.Context, data []byte) error {
+	info, ok := From(ctx)
+	if !ok || info.Checkpointer == nil {
+		return errors.New("the Context is not for an Action that is executing")
+	}
+	if len(data) > MaxCheckpointSize {
+		return fmt.Errorf("checkpoint is %d bytes, which is more than the maximum of %d", len(data), MaxCheckpointSize)
+	}
+	return info.Checkpointer.SaveCheckpoint(ctx, data)
+}
+
+// NewLogger returns a logger derived from base that has attributes for the Plan, Action and attempt in info.
+// If base is nil, slog.Default() is used.
+func NewLogger(base *slog.Logger, info Info) *slog.Logger {
+	if base == nil {
+		base = slog.Default()
+	}
+	return base.With(
+		slog.Group(
+			"coercion",
+			slog.String("plan_id", info.PlanID.String()),
+			slog.String("plan_name", info.PlanName),
+			slog.String("action_id", info.ActionID.String()),
+			slog.String("action_name", info.ActionName),
+			slog.Int("attempt", info.Attempt),
+		),
+	)
+}
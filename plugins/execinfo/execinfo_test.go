@@ -0,0 +1,112 @@
+package execinfo
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestFrom(t *testing.T) {
+	t.Parallel()
+
+	if _, ok := From(context.Background()); ok {
+		t.Errorf("TestFrom: got ok == true for a Context without Info, want false")
+	}
+	if Logger(context.Background()) != slog.Default() {
+		t.Errorf("TestFrom: Logger() for a Context without Info did not return slog.Default()")
+	}
+
+	want := Info{PlanID: uuid.New(), PlanName: "plan", ActionID: uuid.New(), ActionName: "action", Attempt: 2}
+	ctx := NewContext(context.Background(), want)
+
+	got, ok := From(ctx)
+	if !ok {
+		t.Fatalf("TestFrom: got ok == false, want true")
+	}
+	if got.PlanID != want.PlanID || got.ActionID != want.ActionID || got.Attempt != want.Attempt {
+		t.Errorf("TestFrom: got %+v, want %+v", got, want)
+	}
+}
+
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	buff := &bytes.Buffer{}
+	base := slog.New(slog.NewTextHandler(buff, nil))
+
+	info := Info{PlanID: uuid.New(), PlanName: "plan", ActionID: uuid.New(), ActionName: "action", Attempt: 2}
+	info.Logger = NewLogger(base, info)
+	ctx := NewContext(context.Background(), info)
+
+	Logger(ctx).Info("hello")
+
+	for _, want := range []string{info.PlanID.String(), "plan_name=plan", info.ActionID.String(), "action_name=action", "attempt=2"} {
+		if !strings.Contains(buff.String(), want) {
+			t.Errorf("TestNewLogger: log line %q does not contain %q", buff.String(), want)
+		}
+	}
+}
+
+type fakeCheckpointer struct {
+	data []byte
+}
+
+func (f *fakeCheckpointer) Checkpoint() []byte {
+	return f.data
+}
+
+func (f *fakeCheckpointer) SaveCheckpoint(ctx context.Context, data []byte) error {
+	f.data = data
+	return nil
+}
+
+func TestSaveCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		data    []byte
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:    "Error: Context has no Info",
+			ctx:     context.Background(),
+			data:    []byte("hello"),
+			wantErr: true,
+		},
+		{
+			name:    "Error: checkpoint too large",
+			ctx:     NewContext(context.Background(), Info{Checkpointer: &fakeCheckpointer{}}),
+			data:    make([]byte, MaxCheckpointSize+1),
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			ctx:  NewContext(context.Background(), Info{Checkpointer: &fakeCheckpointer{}}),
+			data: []byte("hello"),
+			want: []byte("hello"),
+		},
+	}
+
+	for _, test := range tests {
+		err := SaveCheckpoint(test.ctx, test.data)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestSaveCheckpoint(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestSaveCheckpoint(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+
+		if got := Checkpoint(test.ctx); !bytes.Equal(got, test.want) {
+			t.Errorf("TestSaveCheckpoint(%s): got checkpoint %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,571 @@
+/*
+Package external provides a plugins.Plugin that runs the plugin in another process. This allows plugins
+to be written in any language and shipped as their own binary. If the process crashes or hangs, the
+executions running in it fail with a *plugins.Error and the process is started again on the next execution.
+
+The Plugin talks to the process over its stdin and stdout with JSON Messages, one per line. The protocol is
+described by the Message type. A plugin written in Go can be served by calling Serve() from main():
+
+	func main() {
+		if err := external.Serve(context.Background(), &MyPlugin{}, os.Stdin, os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+The Plugin is then registered like any other:
+
+	p, err := external.New(ctx, "/usr/local/bin/myplugin")
+	if err != nil {
+		// Do something
+	}
+	defer p.Close()
+
+	if err := reg.Register(p); err != nil {
+		// Do something
+	}
+
+Requests and responses are JSON objects. Actions using the Plugin must use a Req, which is converted to JSON
+and sent to the process. Responses are returned as a Resp.
+*/
+package external
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+// Req is the request for a Plugin. It is sent to the process as a JSON object.
+type Req map[string]any
+
+// Resp is the response of a Plugin. It is the JSON object the process returned.
+type Resp map[string]any
+
+const (
+	defaultHeartbeat        = 5 * time.Second
+	defaultHeartbeatTimeout = 30 * time.Second
+	defaultStartTimeout     = 30 * time.Second
+	// closeTimeout is how long Close() waits for the process to exit after its stdin is closed.
+	closeTimeout = 5 * time.Second
+)
+
+// Option is an optional argument for New().
+type Option func(*Plugin) error
+
+// WithArgs sets the arguments the process is started with.
+func WithArgs(args ...string) Option {
+	return func(p *Plugin) error {
+		p.args = args
+		return nil
+	}
+}
+
+// WithEnv adds environment variables in the form "key=value" to the environment of the process.
+// The process inherits the environment of the current process.
+func WithEnv(env ...string) Option {
+	return func(p *Plugin) error {
+		p.env = env
+		return nil
+	}
+}
+
+// WithStderr sets where the stderr of the process is written. Defaults to os.Stderr.
+func WithStderr(w io.Writer) Option {
+	return func(p *Plugin) error {
+		if w == nil {
+			return errors.New("stderr cannot be nil")
+		}
+		p.stderr = w
+		return nil
+	}
+}
+
+// WithHeartbeat sets how often a heartbeat is sent to the process and how long the process can go without
+// answering before it is killed. Defaults to every 5 seconds with a timeout of 30 seconds.
+func WithHeartbeat(interval, timeout time.Duration) Option {
+	return func(p *Plugin) error {
+		if interval <= 0 {
+			return errors.New("heartbeat interval must be greater than 0")
+		}
+		if timeout <= interval {
+			return errors.New("heartbeat timeout must be greater than the interval")
+		}
+		p.heartbeat, p.heartbeatTimeout = interval, timeout
+		return nil
+	}
+}
+
+// WithStartTimeout sets how long the process has to answer the describe and init messages. Defaults to 30 seconds.
+func WithStartTimeout(d time.Duration) Option {
+	return func(p *Plugin) error {
+		if d <= 0 {
+			return errors.New("start timeout must be greater than 0")
+		}
+		p.startTimeout = d
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the retry policy of the Plugin. Defaults to plugins.FastRetryPolicy().
+func WithRetryPolicy(policy exponential.Policy) Option {
+	return func(p *Plugin) error {
+		p.policy = policy
+		return nil
+	}
+}
+
+// Plugin is a plugins.Plugin that runs the plugin in another process.
+type Plugin struct {
+	path   string
+	args   []string
+	env    []string
+	stderr io.Writer
+
+	heartbeat        time.Duration
+	heartbeatTimeout time.Duration
+	startTimeout     time.Duration
+	policy           exponential.Policy
+
+	// desc is the answer to the describe message of the first process.
+	desc Message
+
+	mu     sync.Mutex
+	proc   *process
+	inited bool
+	closed bool
+}
+
+var (
+	_ plugins.Plugin    = (*Plugin)(nil)
+	_ plugins.Versioner = (*Plugin)(nil)
+)
+
+// New starts the executable at path and returns a Plugin for it. The process must answer the describe
+// message before the start timeout. Call Close() when the Plugin is no longer needed.
+func New(ctx context.Context, path string, options ...Option) (*Plugin, error) {
+	p := &Plugin{
+		path:             path,
+		stderr:           os.Stderr,
+		heartbeat:        defaultHeartbeat,
+		heartbeatTimeout: defaultHeartbeatTimeout,
+		startTimeout:     defaultStartTimeout,
+		policy:           plugins.FastRetryPolicy(),
+	}
+	for _, o := range options {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
+
+	proc, desc, err := p.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.proc = proc
+	p.desc = desc
+	return p, nil
+}
+
+// Name implements plugins.Plugin.Name(). This is the name the process gave in its describe answer.
+func (p *Plugin) Name() string {
+	return p.desc.Name
+}
+
+// Execute implements plugins.Plugin.Execute(). req is sent to the process, which is started again if it
+// is not running. If ctx is cancelled, a cancel message is sent to the process.
+func (p *Plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
+	msg, err := ExecuteMessage(ctx, req)
+	if err != nil {
+		return nil, &plugins.Error{Message: err.Error(), Permanent: true}
+	}
+
+	proc, err := p.process(ctx)
+	if err != nil {
+		return nil, &plugins.Error{Message: fmt.Sprintf("plugin(%s): %v", p.Name(), err)}
+	}
+
+	info, _ := execinfo.From(ctx)
+	result, err := proc.call(ctx, msg, info.Output)
+	if err != nil {
+		return nil, &plugins.Error{Message: fmt.Sprintf("plugin(%s): %v", p.Name(), err)}
+	}
+	resp, pErr := result.Result()
+	if pErr != nil {
+		return nil, pErr
+	}
+	return resp, nil
+}
+
+// ValidateReq implements plugins.Plugin.ValidateReq(). It checks that req is a Req that can be encoded.
+func (p *Plugin) ValidateReq(req any) error {
+	_, err := ExecuteMessage(context.Background(), req)
+	return err
+}
+
+// Request implements plugins.Plugin.Request().
+func (p *Plugin) Request() any {
+	return Req{}
+}
+
+// Response implements plugins.Plugin.Response().
+func (p *Plugin) Response() any {
+	return Resp{}
+}
+
+// Version implements plugins.Versioner.Version(). This is what the process gave in its describe answer.
+func (p *Plugin) Version() string {
+	return p.desc.PluginVersion
+}
+
+// IsCheck implements plugins.Plugin.IsCheck(). This is what the process gave in its describe answer.
+func (p *Plugin) IsCheck() bool {
+	return p.desc.IsCheck
+}
+
+// RetryPolicy implements plugins.Plugin.RetryPolicy().
+func (p *Plugin) RetryPolicy() exponential.Policy {
+	return p.policy
+}
+
+// Init implements plugins.Plugin.Init(). It sends an init message to the process. If the process is
+// started again later, it is sent an init message before any execution.
+func (p *Plugin) Init() error {
+	proc, err := p.process(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := p.init(proc); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inited = true
+	return nil
+}
+
+// Schemas returns the JSON Schemas of the request and response that the process gave in its describe
+// answer. Either may be nil.
+func (p *Plugin) Schemas() (req, resp []byte) {
+	return p.desc.RequestSchema.Clone(), p.desc.ResponseSchema.Clone()
+}
+
+// Close stops the process. It closes the process's stdin and kills it if it has not exited within 5 seconds.
+// After Close(), executions fail.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	proc := p.proc
+	p.proc = nil
+	p.mu.Unlock()
+
+	if proc != nil {
+		proc.close(closeTimeout)
+	}
+	return nil
+}
+
+// process returns the running process. If it has exited, a new one is started.
+func (p *Plugin) process(ctx context.Context) (*process, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, errors.New("plugin is closed")
+	}
+	if p.proc != nil && !p.proc.exited() {
+		return p.proc, nil
+	}
+
+	proc, desc, err := p.start(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if desc.Name != p.desc.Name {
+		err := fmt.Errorf("process changed its name from %q to %q", p.desc.Name, desc.Name)
+		proc.kill(err)
+		return nil, err
+	}
+	if p.inited {
+		if err := p.init(proc); err != nil {
+			proc.kill(err)
+			return nil, err
+		}
+	}
+	p.proc = proc
+	return proc, nil
+}
+
+// start starts the process and sends it the describe message.
+func (p *Plugin) start(ctx context.Context) (*process, Message, error) {
+	cmd := exec.Command(p.path, p.args...)
+	cmd.Env = append(os.Environ(), p.env...)
+	cmd.Stderr = p.stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, Message{}, fmt.Errorf("couldn't get stdin of %s: %w", p.path, err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, Message{}, fmt.Errorf("couldn't get stdout of %s: %w", p.path, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, Message{}, fmt.Errorf("couldn't start %s: %w", p.path, err)
+	}
+	proc := newProcess(cmd, stdin, stdout, p.heartbeat, p.heartbeatTimeout)
+
+	ctx, cancel := context.WithTimeout(ctx, p.startTimeout)
+	defer cancel()
+
+	desc, err := proc.call(ctx, Message{Type: MTDescribe, Version: ProtocolVersion}, nil)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("%s did not answer the describe message: %w", p.path, err)
+	case desc.Version != ProtocolVersion:
+		err = fmt.Errorf("%s speaks protocol version %d, want %d", p.path, desc.Version, ProtocolVersion)
+	case desc.Name == "":
+		err = fmt.Errorf("%s did not give a name in its describe answer", p.path)
+	}
+	if err != nil {
+		proc.kill(err)
+		return nil, Message{}, err
+	}
+	return proc, desc, nil
+}
+
+// init sends the init message to proc.
+func (p *Plugin) init(proc *process) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.startTimeout)
+	defer cancel()
+
+	result, err := proc.call(ctx, Message{Type: MTInit}, nil)
+	if err != nil {
+		return fmt.Errorf("plugin(%s) did not answer the init message: %w", p.desc.Name, err)
+	}
+	if result.Err != nil {
+		return fmt.Errorf("plugin(%s) failed to init: %w", p.desc.Name, result.Err.PluginError())
+	}
+	return nil
+}
+
+// call is a message sent to the process that is waiting for an answer.
+type call struct {
+	answer chan Message
+	// out receives the log and progress messages of an execution. This may be nil.
+	out execinfo.Output
+}
+
+// process is a running process of the plugin.
+type process struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	wmu sync.Mutex
+	enc *jsontext.Encoder
+
+	mu    sync.Mutex
+	id    uint64
+	calls map[uint64]*call
+	// killErr is why the process was killed.
+	killErr error
+
+	// lastBeat is when the last heartbeat was answered in Unix nanoseconds.
+	lastBeat atomic.Int64
+	killOnce sync.Once
+
+	// done is closed when the process has exited. err is why and is set before done is closed.
+	done chan struct{}
+	err  error
+}
+
+func newProcess(cmd *exec.Cmd, stdin io.WriteCloser, stdout io.Reader, interval, timeout time.Duration) *process {
+	p := &process{
+		cmd:   cmd,
+		stdin: stdin,
+		enc:   jsontext.NewEncoder(stdin),
+		calls: map[uint64]*call{},
+		done:  make(chan struct{}),
+	}
+	p.lastBeat.Store(time.Now().UnixNano())
+
+	go p.read(stdout)
+	go p.heartbeats(interval, timeout)
+	return p
+}
+
+// call sends msg to the process and waits for the answer with the same ID. If ctx is done first
+// and msg is an execute message, a cancel message is sent. out receives log and progress messages.
+func (p *process) call(ctx context.Context, msg Message, out execinfo.Output) (Message, error) {
+	c := &call{answer: make(chan Message, 1), out: out}
+
+	p.mu.Lock()
+	p.id++
+	msg.ID = p.id
+	p.calls[msg.ID] = c
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		delete(p.calls, msg.ID)
+		p.mu.Unlock()
+	}()
+
+	if err := p.send(msg); err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case answer := <-c.answer:
+		return answer, nil
+	case <-p.done:
+		return Message{}, p.err
+	case <-ctx.Done():
+		if msg.Type == MTExecute {
+			// The answer to the execute message is ignored, as the call is removed.
+			go p.send(Message{Type: MTCancel, ID: msg.ID})
+		}
+		return Message{}, ctx.Err()
+	}
+}
+
+// send writes msg to the stdin of the process.
+func (p *process) send(msg Message) error {
+	p.wmu.Lock()
+	defer p.wmu.Unlock()
+
+	if err := json.MarshalEncode(p.enc, msg); err != nil {
+		return fmt.Errorf("couldn't write to the process: %w", err)
+	}
+	return nil
+}
+
+// read reads the messages from the stdout of the process until it is closed, then waits for the process to exit.
+func (p *process) read(stdout io.Reader) {
+	dec := jsontext.NewDecoder(stdout)
+	for {
+		v, err := dec.ReadValue()
+		if err != nil {
+			if err != io.EOF {
+				p.kill(fmt.Errorf("couldn't read from the process: %w", err))
+			}
+			break
+		}
+		var msg Message
+		if err := json.Unmarshal(v, &msg); err != nil {
+			p.kill(fmt.Errorf("process sent a bad message: %w", err))
+			break
+		}
+		p.handle(msg)
+	}
+
+	waitErr := p.cmd.Wait()
+
+	p.mu.Lock()
+	switch {
+	case p.killErr != nil:
+		p.err = fmt.Errorf("process was killed: %w", p.killErr)
+	case waitErr != nil:
+		p.err = fmt.Errorf("process exited: %w", waitErr)
+	default:
+		p.err = errors.New("process exited")
+	}
+	p.mu.Unlock()
+	close(p.done)
+}
+
+// handle handles a message from the process.
+func (p *process) handle(msg Message) {
+	switch msg.Type {
+	case MTHeartbeat:
+		p.lastBeat.Store(time.Now().UnixNano())
+	case MTLog, MTProgress:
+		p.mu.Lock()
+		c := p.calls[msg.ID]
+		p.mu.Unlock()
+		if c != nil {
+			msg.Forward(c.out)
+		}
+	case MTDescribe, MTResult:
+		p.mu.Lock()
+		c := p.calls[msg.ID]
+		delete(p.calls, msg.ID)
+		p.mu.Unlock()
+		if c != nil {
+			c.answer <- msg
+		}
+	}
+}
+
+// heartbeats sends heartbeats to the process every interval and kills it if it has not answered in timeout.
+func (p *process) heartbeats(interval, timeout time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+		}
+
+		if time.Since(time.Unix(0, p.lastBeat.Load())) > timeout {
+			p.kill(fmt.Errorf("process did not answer heartbeats for %v", timeout))
+			return
+		}
+
+		p.mu.Lock()
+		p.id++
+		id := p.id
+		p.mu.Unlock()
+		// This is done in a goroutine because a hung process might not be reading its stdin.
+		go p.send(Message{Type: MTHeartbeat, ID: id})
+	}
+}
+
+// kill kills the process because of err.
+func (p *process) kill(err error) {
+	p.killOnce.Do(func() {
+		p.mu.Lock()
+		p.killErr = err
+		p.mu.Unlock()
+		p.cmd.Process.Kill()
+	})
+}
+
+// close closes the stdin of the process and kills it if it has not exited within timeout.
+func (p *process) close(timeout time.Duration) {
+	p.wmu.Lock()
+	p.stdin.Close()
+	p.wmu.Unlock()
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-p.done:
+	case <-t.C:
+		p.kill(errors.New("process did not exit after its stdin was closed"))
+		<-p.done
+	}
+}
+
+// exited returns true if the process has exited.
+func (p *process) exited() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
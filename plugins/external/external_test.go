@@ -0,0 +1,286 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	testplugin "github.com/element-of-surprise/coercion/plugins/external/testing/plugins"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// childEnv is set to the mode the test binary runs in when it is started as a plugin process.
+const childEnv = "COERCION_EXTERNAL_TEST_CHILD"
+
+func TestMain(m *testing.M) {
+	switch os.Getenv(childEnv) {
+	case "":
+		os.Exit(m.Run())
+	case "serve":
+		if err := Serve(context.Background(), testplugin.New(), os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case "hang":
+		// Answer the describe message and then stop answering anything.
+		dec := jsontext.NewDecoder(os.Stdin)
+		v, err := dec.ReadValue()
+		if err != nil {
+			os.Exit(1)
+		}
+		var msg Message
+		if err := json.Unmarshal(v, &msg); err != nil {
+			os.Exit(1)
+		}
+		json.MarshalWrite(os.Stdout, Message{Type: MTDescribe, ID: msg.ID, Version: ProtocolVersion, Name: testplugin.Name})
+		os.Stdout.Write([]byte("\n"))
+		select {}
+	case "version":
+		dec := jsontext.NewDecoder(os.Stdin)
+		v, _ := dec.ReadValue()
+		var msg Message
+		json.Unmarshal(v, &msg)
+		json.MarshalWrite(os.Stdout, Message{Type: MTDescribe, ID: msg.ID, Version: ProtocolVersion + 1, Name: testplugin.Name})
+		os.Stdout.Write([]byte("\n"))
+		io.Copy(io.Discard, os.Stdin)
+		os.Exit(0)
+	}
+}
+
+// newChild starts the test binary as a plugin process in mode.
+func newChild(t *testing.T, mode string, options ...Option) (*Plugin, error) {
+	options = append([]Option{WithEnv(childEnv + "=" + mode), WithArgs("-test.run=^$")}, options...)
+	return New(context.Background(), os.Args[0], options...)
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		mode    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name:    "Error: executable does not exist",
+			path:    "/does/not/exist",
+			wantErr: true,
+		},
+		{
+			name:    "Error: wrong protocol version",
+			mode:    "version",
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			mode: "serve",
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			p   *Plugin
+			err error
+		)
+		if test.path != "" {
+			p, err = New(context.Background(), test.path)
+		} else {
+			p, err = newChild(t, test.mode)
+		}
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNew(%s): got err == nil, want err != nil", test.name)
+			p.Close()
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNew(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if p.Name() != testplugin.Name {
+			t.Errorf("TestNew(%s): got Name() == %q, want %q", test.name, p.Name(), testplugin.Name)
+		}
+		if err := p.Init(); err != nil {
+			t.Errorf("TestNew(%s): got Init() err == %s, want err == nil", test.name, err)
+		}
+		p.Close()
+	}
+}
+
+func TestExecute(t *testing.T) {
+	t.Parallel()
+
+	p, err := newChild(t, "serve")
+	if err != nil {
+		t.Fatalf("TestExecute: couldn't start the plugin: %s", err)
+	}
+	defer p.Close()
+	if err := p.Init(); err != nil {
+		t.Fatalf("TestExecute: Init() failed: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		req     any
+		timeout time.Duration
+		want    any
+		wantErr *plugins.Error
+		// anyErr is set if any retryable error is expected.
+		anyErr bool
+	}{
+		{
+			name:    "Error: wrong request type",
+			req:     testplugin.Req{Say: "hello"},
+			wantErr: &plugins.Error{Message: "invalid request object(plugins.Req), want external.Req", Permanent: true},
+		},
+		{
+			name:    "Error: plugin returns an error",
+			req:     Req{"Fail": true},
+			wantErr: &plugins.Error{Code: 2, Message: "failed", Permanent: true},
+		},
+		{
+			name: "Error: cancelled",
+			req:  Req{"Wait": true},
+			// The process gets the same deadline, so either it or the Plugin may see it first.
+			timeout: 100 * time.Millisecond,
+			anyErr:  true,
+		},
+		{
+			name: "Success",
+			req:  Req{"Say": "hello"},
+			want: Resp{"Said": "hello"},
+		},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, test.timeout)
+			defer cancel()
+		}
+		out := &testplugin.Output{}
+		ctx = execinfo.NewContext(ctx, execinfo.Info{Output: out})
+
+		got, err := p.Execute(ctx, test.req)
+		if test.anyErr {
+			if err == nil || err.Permanent {
+				t.Errorf("TestExecute(%s): got err == %v, want a retryable error", test.name, err)
+			}
+			continue
+		}
+		if diff := pretty.Compare(test.wantErr, err); diff != "" {
+			t.Errorf("TestExecute(%s): error: -want/+got:\n%s", test.name, diff)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestExecute(%s): response: -want/+got:\n%s", test.name, diff)
+		}
+		lines, progress := out.Got()
+		if len(lines) != 1 || lines[0] != "saying hello" || progress != 100 {
+			t.Errorf("TestExecute(%s): got output %v with progress %d, want the line 'saying hello' with progress 100", test.name, lines, progress)
+		}
+	}
+}
+
+func TestCrash(t *testing.T) {
+	t.Parallel()
+
+	p, err := newChild(t, "serve")
+	if err != nil {
+		t.Fatalf("TestCrash: couldn't start the plugin: %s", err)
+	}
+	defer p.Close()
+
+	_, pErr := p.Execute(context.Background(), Req{"Crash": true})
+	if pErr == nil || pErr.Permanent || !strings.Contains(pErr.Message, "process exited") {
+		t.Fatalf("TestCrash: got err == %v, want a retryable error saying the process exited", pErr)
+	}
+
+	// The process is started again.
+	got, pErr := p.Execute(context.Background(), Req{"Say": "hello"})
+	if pErr != nil {
+		t.Fatalf("TestCrash: got err == %s after the crash, want err == nil", pErr)
+	}
+	if diff := pretty.Compare(Resp{"Said": "hello"}, got); diff != "" {
+		t.Errorf("TestCrash: -want/+got:\n%s", diff)
+	}
+}
+
+func TestHang(t *testing.T) {
+	t.Parallel()
+
+	p, err := newChild(t, "hang", WithHeartbeat(10*time.Millisecond, 200*time.Millisecond))
+	if err != nil {
+		t.Fatalf("TestHang: couldn't start the plugin: %s", err)
+	}
+	defer p.Close()
+
+	_, pErr := p.Execute(context.Background(), Req{"Say": "hello"})
+	if pErr == nil || !strings.Contains(pErr.Message, "did not answer heartbeats") {
+		t.Fatalf("TestHang: got err == %v, want an error saying the process did not answer heartbeats", pErr)
+	}
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+
+	p, err := newChild(t, "serve")
+	if err != nil {
+		t.Fatalf("TestClose: couldn't start the plugin: %s", err)
+	}
+	p.Close()
+
+	if _, pErr := p.Execute(context.Background(), Req{"Say": "hello"}); pErr == nil {
+		t.Errorf("TestClose: got err == nil executing after Close(), want err != nil")
+	}
+}
+
+func TestToReq(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		req     any
+		want    Req
+		wantErr bool
+	}{
+		{name: "Error: wrong type", req: "hello", wantErr: true},
+		{name: "Error: nil *Req", req: (*Req)(nil), wantErr: true},
+		{name: "Success: Req", req: Req{"a": 1}, want: Req{"a": 1}},
+		{name: "Success: map", req: map[string]any{"a": 1}, want: Req{"a": 1}},
+		{name: "Success: *Req", req: &Req{"a": 1}, want: Req{"a": 1}},
+	}
+
+	for _, test := range tests {
+		got, err := toReq(test.req)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestToReq(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestToReq(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestToReq(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/google/uuid"
+)
+
+// ProtocolVersion is the version of the protocol spoken between a Plugin and the process.
+// The process must answer a describe message with the same version.
+const ProtocolVersion = 1
+
+// MsgType is the type of a Message.
+type MsgType string
+
+const (
+	// MTDescribe is sent to the process when it starts. The process answers with a describe message
+	// that has the Version, Name and IsCheck set and optionally the schemas.
+	MTDescribe MsgType = "describe"
+	// MTInit is sent to the process to run the plugin's Init(). The process answers with a result.
+	MTInit MsgType = "init"
+	// MTExecute is sent to the process to execute the plugin. The process answers with a result.
+	MTExecute MsgType = "execute"
+	// MTCancel is sent to the process to cancel the execute message with the same ID. The process
+	// still answers the execute message with a result.
+	MTCancel MsgType = "cancel"
+	// MTHeartbeat is sent to the process periodically. The process must answer with a heartbeat
+	// with the same ID. A process that stops answering is killed.
+	MTHeartbeat MsgType = "heartbeat"
+	// MTResult is sent by the process to answer an init or execute message with the same ID.
+	MTResult MsgType = "result"
+	// MTLog is sent by the process to record a line with the execute message with the same ID.
+	MTLog MsgType = "log"
+	// MTProgress is sent by the process to record the progress of the execute message with the same ID.
+	MTProgress MsgType = "progress"
+)
+
+// Message is a message of the protocol. Messages are JSON objects, one per line, written to the
+// stdin and read from the stdout of the process. Which fields are set depends on the Type.
+type Message struct {
+	// Type is the type of the message.
+	Type MsgType `json:"type"`
+	// ID correlates messages. It is set by the Plugin on every message it sends. Answers and
+	// messages about an execution use the ID of the message they are for.
+	ID uint64 `json:"id,omitempty"`
+
+	// Version is the protocol version. Set in describe messages.
+	Version int `json:"version,omitempty"`
+	// Name is the name of the plugin. Set in the describe answer.
+	Name string `json:"name,omitempty"`
+	// IsCheck is true if the plugin is a check plugin. Set in the describe answer.
+	IsCheck bool `json:"is_check,omitempty"`
+	// PluginVersion is the version of the plugin, if it implements plugins.Versioner. Set in the describe answer.
+	PluginVersion string `json:"plugin_version,omitempty"`
+	// RequestSchema is a JSON Schema of the request. Optionally set in the describe answer.
+	RequestSchema jsontext.Value `json:"request_schema,omitempty"`
+	// ResponseSchema is a JSON Schema of the response. Optionally set in the describe answer.
+	ResponseSchema jsontext.Value `json:"response_schema,omitempty"`
+
+	// Exec holds information about what is being executed. Set in execute messages.
+	Exec *Exec `json:"exec,omitempty"`
+	// Req is the request. Set in execute messages.
+	Req jsontext.Value `json:"req,omitempty"`
+	// Resp is the response. Set in the result of an execute message.
+	Resp jsontext.Value `json:"resp,omitempty"`
+	// Err is the error. Set in results when the init or execute failed.
+	Err *Error `json:"err,omitempty"`
+
+	// Line is the line to record. Set in log messages.
+	Line string `json:"line,omitempty"`
+	// Progress is the progress percentage. Set in progress messages.
+	Progress int `json:"progress,omitempty"`
+}
+
+// Exec holds information about an execution. This is the part of the execinfo.Info that
+// can be sent to the process.
+type Exec struct {
+	PlanID     string    `json:"plan_id,omitempty"`
+	PlanName   string    `json:"plan_name,omitempty"`
+	ActionID   string    `json:"action_id,omitempty"`
+	ActionName string    `json:"action_name,omitempty"`
+	Attempt    int       `json:"attempt,omitempty"`
+	Deadline   time.Time `json:"deadline,omitzero"`
+}
+
+// Error is a plugins.Error in the protocol.
+type Error struct {
+	Code      uint   `json:"code,omitempty"`
+	Message   string `json:"message"`
+	Permanent bool   `json:"permanent,omitempty"`
+}
+
+// NewError converts a *plugins.Error to an *Error. Wrapped errors are not sent.
+func NewError(err *plugins.Error) *Error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: uint(err.Code), Message: err.Message, Permanent: err.Permanent}
+}
+
+// PluginError converts the *Error to a *plugins.Error.
+func (e *Error) PluginError() *plugins.Error {
+	if e == nil {
+		return nil
+	}
+	return &plugins.Error{Code: plugins.ErrCode(e.Code), Message: e.Message, Permanent: e.Permanent}
+}
+
+// ExecuteMessage returns the execute message for req. The Exec is filled in from the deadline
+// of ctx and its execinfo.Info. req must be a Req.
+func ExecuteMessage(ctx context.Context, req any) (Message, error) {
+	r, err := toReq(req)
+	if err != nil {
+		return Message{}, err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return Message{}, fmt.Errorf("couldn't encode request: %w", err)
+	}
+	info, _ := execinfo.From(ctx)
+	return Message{Type: MTExecute, Exec: toExec(ctx, info), Req: b}, nil
+}
+
+// Result returns the response or error in a result message.
+func (m Message) Result() (Resp, *plugins.Error) {
+	if m.Err != nil {
+		return nil, m.Err.PluginError()
+	}
+	resp := Resp{}
+	if len(m.Resp) > 0 {
+		if err := json.Unmarshal(m.Resp, &resp); err != nil {
+			return nil, &plugins.Error{Message: fmt.Sprintf("bad response: %v", err), Permanent: true}
+		}
+	}
+	return resp, nil
+}
+
+// Forward sends the log or progress in m to out. It returns false if m is not a log or progress message.
+func (m Message) Forward(out execinfo.Output) bool {
+	switch m.Type {
+	case MTLog:
+		if out != nil {
+			out.Log(m.Line)
+		}
+	case MTProgress:
+		if out != nil {
+			out.Progress(min(max(m.Progress, 0), 100))
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// toReq converts req to a Req.
+func toReq(req any) (Req, error) {
+	switch r := req.(type) {
+	case Req:
+		return r, nil
+	case map[string]any:
+		return r, nil
+	case *Req:
+		if r != nil {
+			return *r, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid request object(%T), want external.Req", req)
+}
+
+// toExec returns the Exec for an execution with ctx and info.
+func toExec(ctx context.Context, info execinfo.Info) *Exec {
+	e := &Exec{
+		PlanName:   info.PlanName,
+		ActionName: info.ActionName,
+		Attempt:    info.Attempt,
+	}
+	if info.PlanID != uuid.Nil {
+		e.PlanID = info.PlanID.String()
+	}
+	if info.ActionID != uuid.Nil {
+		e.ActionID = info.ActionID.String()
+	}
+	e.Deadline, _ = ctx.Deadline()
+	return e
+}
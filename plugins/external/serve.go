@@ -0,0 +1,230 @@
+package external
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/plugins/schema"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/google/uuid"
+)
+
+// Schemer can be implemented by a plugin given to Serve() to send its own JSON Schemas of its request and
+// response in the describe answer. Otherwise they are generated, see schema.ForPlugin().
+type Schemer = plugins.Schemer
+
+// Serve serves plugin over the protocol, reading Messages from in and writing them to out. This is
+// called by the main() of a plugin's process with os.Stdin and os.Stdout. Executions are run concurrently
+// with a Context derived from ctx. Serve returns when in is closed and all executions have returned.
+func Serve(ctx context.Context, plugin plugins.Plugin, in io.Reader, out io.Writer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &server{
+		plugin:  plugin,
+		enc:     jsontext.NewEncoder(out),
+		running: map[uint64]context.CancelFunc{},
+	}
+
+	err := s.serve(ctx, in)
+	cancel()
+	s.wg.Wait()
+	return err
+}
+
+// server serves a plugin over the protocol.
+type server struct {
+	plugin plugins.Plugin
+
+	wmu sync.Mutex
+	enc *jsontext.Encoder
+
+	mu sync.Mutex
+	// running holds the cancel functions of the running executions by message ID.
+	running map[uint64]context.CancelFunc
+
+	wg sync.WaitGroup
+}
+
+func (s *server) serve(ctx context.Context, in io.Reader) error {
+	dec := jsontext.NewDecoder(in)
+	for {
+		v, err := dec.ReadValue()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("couldn't read a message: %w", err)
+		}
+		var msg Message
+		if err := json.Unmarshal(v, &msg); err != nil {
+			return fmt.Errorf("bad message: %w", err)
+		}
+
+		switch msg.Type {
+		case MTDescribe:
+			answer := Describe(s.plugin)
+			answer.ID = msg.ID
+			s.send(answer)
+		case MTHeartbeat:
+			s.send(Message{Type: MTHeartbeat, ID: msg.ID})
+		case MTInit:
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.send(Message{Type: MTResult, ID: msg.ID, Err: s.init()})
+			}()
+		case MTExecute:
+			ctx, cancel := context.WithCancel(ctx)
+			s.mu.Lock()
+			s.running[msg.ID] = cancel
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer func() {
+					s.mu.Lock()
+					delete(s.running, msg.ID)
+					s.mu.Unlock()
+					cancel()
+				}()
+				s.send(Execute(ctx, s.plugin, msg, output{s: s, id: msg.ID}))
+			}()
+		case MTCancel:
+			s.mu.Lock()
+			if cancel, ok := s.running[msg.ID]; ok {
+				cancel()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// init runs the plugin's Init().
+func (s *server) init() *Error {
+	if err := s.plugin.Init(); err != nil {
+		return &Error{Message: err.Error(), Permanent: true}
+	}
+	return nil
+}
+
+// send writes msg to the Plugin. Errors are ignored, as the Plugin will kill the process
+// if it stops answering.
+func (s *server) send(msg Message) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	json.MarshalEncode(s.enc, msg)
+}
+
+// Describe returns the answer to a describe message for plugin. The ID is not set.
+func Describe(plugin plugins.Plugin) Message {
+	answer := Message{
+		Type:          MTDescribe,
+		Version:       ProtocolVersion,
+		Name:          plugin.Name(),
+		IsCheck:       plugin.IsCheck(),
+		PluginVersion: plugins.VersionOf(plugin),
+	}
+	// A plugin whose types can't be described is still served, just without schemas.
+	if req, resp, err := schema.ForPlugin(plugin); err == nil {
+		answer.RequestSchema, answer.ResponseSchema = jsontext.Value(req), jsontext.Value(resp)
+	}
+	return answer
+}
+
+// Execute runs plugin for the execute message msg and returns the result. The Context passed to the plugin
+// is derived from ctx and has the deadline and execinfo.Info from msg. The log and progress of the plugin are
+// sent to out, which may be nil. This is used by Serve() and allows the protocol to be served over other transports.
+func Execute(ctx context.Context, plugin plugins.Plugin, msg Message, out execinfo.Output) (result Message) {
+	result = Message{Type: MTResult, ID: msg.ID}
+	defer func() {
+		if v := recover(); v != nil {
+			result.Resp = nil
+			result.Err = &Error{Message: fmt.Sprintf("plugin(%s) panicked: %v\n%s", plugin.Name(), v, debug.Stack()), Permanent: true}
+		}
+	}()
+
+	ctx, cancel := execCtx(ctx, msg, out)
+	defer cancel()
+
+	req, err := decodeReq(plugin, msg.Req)
+	if err != nil {
+		result.Err = &Error{Message: err.Error(), Permanent: true}
+		return result
+	}
+	if err := plugin.ValidateReq(req); err != nil {
+		result.Err = &Error{Message: err.Error(), Permanent: true}
+		return result
+	}
+
+	resp, pErr := plugin.Execute(ctx, req)
+	if pErr != nil {
+		result.Err = NewError(pErr)
+		return result
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		result.Err = &Error{Message: fmt.Sprintf("couldn't encode response: %v", err), Permanent: true}
+		return result
+	}
+	result.Resp = b
+	return result
+}
+
+// execCtx returns the Context for the execute message. It holds an execinfo.Info that sends the log and
+// progress of the plugin to out.
+func execCtx(ctx context.Context, msg Message, out execinfo.Output) (context.Context, context.CancelFunc) {
+	info := execinfo.Info{Output: out}
+	if e := msg.Exec; e != nil {
+		info.PlanID, _ = uuid.Parse(e.PlanID)
+		info.PlanName = e.PlanName
+		info.ActionID, _ = uuid.Parse(e.ActionID)
+		info.ActionName = e.ActionName
+		info.Attempt = e.Attempt
+		info.Deadline = e.Deadline
+	}
+	info.Logger = execinfo.NewLogger(nil, info)
+
+	var cancel context.CancelFunc
+	if info.Deadline.IsZero() {
+		ctx, cancel = context.WithCancel(ctx)
+	} else {
+		ctx, cancel = context.WithDeadline(ctx, info.Deadline)
+	}
+	return execinfo.NewContext(ctx, info), cancel
+}
+
+// decodeReq decodes b into the request type of plugin the way storage does.
+func decodeReq(plugin plugins.Plugin, b []byte) (any, error) {
+	req := plugin.Request()
+	if req == nil || len(b) == 0 {
+		return req, nil
+	}
+	req, err := plugins.DecodeInto(b, req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode request: %w", err)
+	}
+	return req, nil
+}
+
+// output sends the log and progress of an execution to the Plugin. It implements execinfo.Output.
+type output struct {
+	s  *server
+	id uint64
+}
+
+// Log implements execinfo.Output.Log().
+func (o output) Log(line string) {
+	o.s.send(Message{Type: MTLog, ID: o.id, Line: line})
+}
+
+// Progress implements execinfo.Output.Progress().
+func (o output) Progress(percent int) {
+	o.s.send(Message{Type: MTProgress, ID: o.id, Progress: percent})
+}
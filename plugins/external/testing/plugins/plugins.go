@@ -0,0 +1,85 @@
+// Package plugins provides a plugin and an execinfo.Output for the tests of the external and remote packages.
+package plugins
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+)
+
+// Name is the name of the plugin returned by New().
+const Name = "github.com/element-of-surprise/coercion/plugins/external/testing/plugins.Test"
+
+// Req is the request of the plugin returned by New().
+type Req struct {
+	Say string
+	// Crash causes the process to exit.
+	Crash bool
+	// Wait causes the execution to wait until its Context is cancelled.
+	Wait bool
+	// Fail causes the execution to return an error.
+	Fail bool
+}
+
+// Resp is the response of the plugin returned by New().
+type Resp struct {
+	Said string
+}
+
+// New returns a plugin that logs and responds with what a Req says.
+func New() plugins.Plugin {
+	p, err := plugins.NewTyped(
+		plugins.TypedArgs[Req, Resp]{
+			Name: Name,
+			Execute: func(ctx context.Context, req Req) (Resp, *plugins.Error) {
+				switch {
+				case req.Crash:
+					os.Exit(3)
+				case req.Wait:
+					<-ctx.Done()
+					return Resp{}, &plugins.Error{Message: "cancelled"}
+				case req.Fail:
+					return Resp{}, &plugins.Error{Code: 2, Message: "failed", Permanent: true}
+				}
+				execinfo.Log(ctx, "saying %s", req.Say)
+				execinfo.Progress(ctx, 100)
+				return Resp{Said: req.Say}, nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Output records what an execution sends to its execinfo.Output.
+type Output struct {
+	mu       sync.Mutex
+	lines    []string
+	progress int
+}
+
+// Log implements execinfo.Output.Log().
+func (o *Output) Log(line string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.lines = append(o.lines, line)
+}
+
+// Progress implements execinfo.Output.Progress().
+func (o *Output) Progress(percent int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.progress = percent
+}
+
+// Got returns the logged lines and the last progress.
+func (o *Output) Got() (lines []string, progress int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.lines...), o.progress
+}
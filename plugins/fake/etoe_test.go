@@ -0,0 +1,81 @@
+package fake_test
+
+import (
+	"testing"
+
+	"github.com/element-of-surprise/coercion/internal/etoe"
+	"github.com/element-of-surprise/coercion/plugins/fake"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+)
+
+type Req struct {
+	Target string
+}
+
+type Resp struct {
+	Result string
+}
+
+// TestPlan tests that a Plan retries an Action that fails, and fails when an Action fails permanently.
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		behavior   fake.Behavior
+		retries    int
+		wantStatus workflow.Status
+		wantCalls  int
+	}{
+		{
+			name:       "Retries until it succeeds",
+			behavior:   fake.FailTimes(2, 1, fake.Succeed(Resp{Result: "ok"})),
+			retries:    2,
+			wantStatus: workflow.Completed,
+			wantCalls:  3,
+		},
+		{
+			name:       "Runs out of retries",
+			behavior:   fake.FailTimes(2, 1, fake.Succeed(Resp{Result: "ok"})),
+			retries:    1,
+			wantStatus: workflow.Failed,
+			wantCalls:  2,
+		},
+		{
+			name:       "Permanent failure is not retried",
+			behavior:   fake.FailPermanent(2),
+			retries:    3,
+			wantStatus: workflow.Failed,
+			wantCalls:  1,
+		},
+	}
+
+	for _, test := range tests {
+		plugin := fake.MustNew(fake.Args[Req, Resp]{Name: "fake/deploy", Behavior: test.behavior})
+		reg := registry.New()
+		reg.MustRegister(plugin)
+
+		plan := etoe.Run(
+			t,
+			reg,
+			etoe.Plan(
+				t,
+				&workflow.Action{
+					Name:    "deploy",
+					Descr:   "deploy",
+					Plugin:  "fake/deploy",
+					Req:     Req{Target: "a"},
+					Retries: test.retries,
+				},
+			),
+		)
+
+		if got := plan.State.Status; got != test.wantStatus {
+			t.Errorf("TestPlan(%s): got status %v, want %v", test.name, got, test.wantStatus)
+		}
+		if got := len(plugin.Calls()); got != test.wantCalls {
+			t.Errorf("TestPlan(%s): got %d calls, want %d", test.name, got, test.wantCalls)
+		}
+	}
+}
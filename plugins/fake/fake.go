@@ -0,0 +1,283 @@
+/*
+Package fake provides fake plugins for testing how Plans behave, such as on failure, without real plugins.
+
+A Plugin takes on any name and request and response types. What it does for each call is scripted
+with Behaviors:
+
+	plugin, err := fake.New(
+		fake.Args[deploy.Req, deploy.Resp]{
+			Name: deploy.Name,
+			// The first two calls fail with a retryable error, then the calls succeed.
+			Behavior: fake.FailTimes(2, deploy.ECUnavailable, fake.Succeed(deploy.Resp{Version: "1.2.0"})),
+			// Requests for the canary fail and are not retried.
+			ByRequest: func(req deploy.Req) fake.Behavior {
+				if req.Cluster == "canary" {
+					return fake.FailPermanent(deploy.ECRejected)
+				}
+				return nil
+			},
+		},
+	)
+
+Every call is recorded and can be inspected with Calls() after the Plan is run.
+
+Behaviors that keep state, such as FailTimes() and Sequence(), count the calls made with them. To script
+each request on its own, return the same Behavior for a request from ByRequest each time, such as from a map.
+*/
+package fake
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+// Behavior is what a Plugin does for a call. resp is the response for a call that succeeds. A nil resp is
+// replaced with the zero value of the response type.
+type Behavior func(ctx context.Context) (resp any, err *plugins.Error)
+
+// Succeed returns a Behavior that returns resp.
+func Succeed(resp any) Behavior {
+	return func(ctx context.Context) (any, *plugins.Error) {
+		return resp, nil
+	}
+}
+
+// Fail returns a Behavior that fails with a retryable error with code.
+func Fail(code plugins.ErrCode) Behavior {
+	return func(ctx context.Context) (any, *plugins.Error) {
+		return nil, &plugins.Error{Code: code, Message: fmt.Sprintf("fake failure with code %d", code)}
+	}
+}
+
+// FailPermanent returns a Behavior that fails with a permanent error with code.
+func FailPermanent(code plugins.ErrCode) Behavior {
+	return func(ctx context.Context) (any, *plugins.Error) {
+		return nil, &plugins.Error{Code: code, Message: fmt.Sprintf("fake permanent failure with code %d", code), Permanent: true}
+	}
+}
+
+// FailTimes returns a Behavior that fails with a retryable error with code for the first n calls made with it,
+// and then does then.
+func FailTimes(n int, code plugins.ErrCode, then Behavior) Behavior {
+	fail := Fail(code)
+	calls := atomic.Int64{}
+	return func(ctx context.Context) (any, *plugins.Error) {
+		if calls.Add(1) <= int64(n) {
+			return fail(ctx)
+		}
+		return then(ctx)
+	}
+}
+
+// Sequence returns a Behavior that does the Behavior at the index of the call made with it, starting at 0.
+// Calls after the last Behavior repeat the last one. It panics if behaviors is empty.
+func Sequence(behaviors ...Behavior) Behavior {
+	if len(behaviors) == 0 {
+		panic("fake.Sequence() called without Behaviors")
+	}
+	calls := atomic.Int64{}
+	return func(ctx context.Context) (any, *plugins.Error) {
+		i := int(calls.Add(1) - 1)
+		if i >= len(behaviors) {
+			i = len(behaviors) - 1
+		}
+		return behaviors[i](ctx)
+	}
+}
+
+// Sleep returns a Behavior that sleeps for d and then does then. If the Context is done before d has passed,
+// it fails with a retryable error, like a plugin that honors cancellation.
+func Sleep(d time.Duration, then Behavior) Behavior {
+	return func(ctx context.Context) (any, *plugins.Error) {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-ctx.Done():
+			return nil, &plugins.Error{Message: fmt.Sprintf("fake sleep interrupted: %v", ctx.Err())}
+		case <-timer.C:
+			return then(ctx)
+		}
+	}
+}
+
+// Hang returns a Behavior that blocks until until is closed, ignoring the Context, like a plugin that
+// does not honor cancellation. It then fails with a retryable error. A nil until blocks forever.
+// Close until when the test ends so the call can return.
+func Hang(until <-chan struct{}) Behavior {
+	return func(ctx context.Context) (any, *plugins.Error) {
+		<-until
+		return nil, &plugins.Error{Message: "fake hang released"}
+	}
+}
+
+// Panic returns a Behavior that panics with v.
+func Panic(v any) Behavior {
+	return func(ctx context.Context) (any, *plugins.Error) {
+		panic(v)
+	}
+}
+
+// Args are the arguments to New().
+type Args[Req, Resp any] struct {
+	// Name is the name of the plugin. Required.
+	Name string
+	// Version is the version of the plugin. See plugins.Versioner. Optional.
+	Version string
+	// IsCheck indicates the plugin is a check plugin.
+	IsCheck bool
+	// RetryPolicy is the retry policy of the plugin. If not set, a policy that retries every millisecond
+	// is used so that tests of retries are fast.
+	RetryPolicy exponential.Policy
+	// Validate validates the request. Optional.
+	Validate func(req Req) error
+	// Behavior is what the plugin does for a call that ByRequest does not have a Behavior for.
+	// If nil, calls succeed with the zero value of Resp.
+	Behavior Behavior
+	// ByRequest returns the Behavior for a request, or nil to use Behavior. Optional.
+	ByRequest func(req Req) Behavior
+}
+
+// Call is a record of a call to a Plugin.
+type Call[Req any] struct {
+	// Req is the request.
+	Req Req
+	// Info is the execinfo.Info the call was made with. It is the zero value if there was none.
+	Info execinfo.Info
+	// Start is when the call started.
+	Start time.Time
+	// End is when the call returned. It is the zero value while the call is running or if it panicked.
+	End time.Time
+	// Resp is the response of the call.
+	Resp any
+	// Err is the error of the call.
+	Err *plugins.Error
+}
+
+// Plugin is a fake plugins.Plugin. It is built on plugins.Typed, so requests are converted and validated
+// the same way. Calls are recorded once the request is validated. Safe for concurrent use.
+type Plugin[Req, Resp any] struct {
+	*plugins.Typed[Req, Resp]
+
+	behavior  Behavior
+	byRequest func(req Req) Behavior
+
+	mu    sync.Mutex
+	calls []*Call[Req]
+}
+
+var _ plugins.Plugin = (*Plugin[struct{}, struct{}])(nil)
+
+// New creates a new Plugin.
+func New[Req, Resp any](args Args[Req, Resp]) (*Plugin[Req, Resp], error) {
+	if args.RetryPolicy == (exponential.Policy{}) {
+		args.RetryPolicy = exponential.Policy{
+			InitialInterval:     time.Millisecond,
+			Multiplier:          1.1,
+			RandomizationFactor: 0,
+			MaxInterval:         time.Millisecond,
+		}
+	}
+	if args.Behavior == nil {
+		args.Behavior = Succeed(nil)
+	}
+
+	p := &Plugin[Req, Resp]{behavior: args.Behavior, byRequest: args.ByRequest}
+	typed, err := plugins.NewTyped(
+		plugins.TypedArgs[Req, Resp]{
+			Name:        args.Name,
+			Version:     args.Version,
+			Execute:     p.execute,
+			Validate:    args.Validate,
+			IsCheck:     args.IsCheck,
+			RetryPolicy: args.RetryPolicy,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.Typed = typed
+	return p, nil
+}
+
+// MustNew is like New, but panics on an error.
+func MustNew[Req, Resp any](args Args[Req, Resp]) *Plugin[Req, Resp] {
+	p, err := New(args)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p *Plugin[Req, Resp]) execute(ctx context.Context, req Req) (Resp, *plugins.Error) {
+	call := &Call[Req]{Req: req, Start: time.Now()}
+	call.Info, _ = execinfo.From(ctx)
+	p.mu.Lock()
+	p.calls = append(p.calls, call)
+	p.mu.Unlock()
+
+	b := p.behavior
+	if p.byRequest != nil {
+		if rb := p.byRequest(req); rb != nil {
+			b = rb
+		}
+	}
+	resp, err := b(ctx)
+
+	var r Resp
+	if err == nil {
+		if resp == nil {
+			resp = zero[Resp]()
+		}
+		var ok bool
+		r, ok = resp.(Resp)
+		if !ok {
+			err = &plugins.Error{Message: fmt.Sprintf("fake Behavior returned a response of type %T, want %T", resp, r), Permanent: true}
+			resp = nil
+		}
+	}
+
+	p.mu.Lock()
+	call.End, call.Resp, call.Err = time.Now(), resp, err
+	p.mu.Unlock()
+	return r, err
+}
+
+// Calls returns a copy of the records of the calls to the Plugin, in the order they started.
+func (p *Plugin[Req, Resp]) Calls() []Call[Req] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	out := make([]Call[Req], 0, len(p.calls))
+	for _, c := range p.calls {
+		out = append(out, *c)
+	}
+	return out
+}
+
+// Reset removes the records of the calls. It does not reset the state of Behaviors.
+func (p *Plugin[Req, Resp]) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls = nil
+}
+
+// zero returns the zero value of T. If T is a pointer, it returns a pointer to the zero value of what T points to,
+// which is what plugins.Typed returns from Response().
+func zero[T any]() any {
+	var v T
+	rt := reflect.TypeFor[T]()
+	if rt.Kind() == reflect.Pointer {
+		return reflect.New(rt.Elem()).Interface()
+	}
+	return v
+}
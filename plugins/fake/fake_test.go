@@ -0,0 +1,245 @@
+package fake
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/google/uuid"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type Req struct {
+	Target string
+}
+
+type Resp struct {
+	Result string
+}
+
+func TestBehaviors(t *testing.T) {
+	t.Parallel()
+
+	release := make(chan struct{})
+	close(release)
+
+	tests := []struct {
+		name     string
+		behavior Behavior
+		// calls is the number of calls to make. The last call's result is checked.
+		calls     int
+		ctx       func() context.Context
+		wantResp  any
+		wantErr   *plugins.Error
+		wantPanic bool
+	}{
+		{
+			name:     "Success: Default",
+			calls:    1,
+			wantResp: Resp{},
+		},
+		{
+			name:     "Success: Succeed",
+			behavior: Succeed(Resp{Result: "ok"}),
+			calls:    1,
+			wantResp: Resp{Result: "ok"},
+		},
+		{
+			name:     "Fail",
+			behavior: Fail(3),
+			calls:    1,
+			wantErr:  &plugins.Error{Code: 3, Message: "fake failure with code 3"},
+		},
+		{
+			name:     "FailPermanent",
+			behavior: FailPermanent(4),
+			calls:    1,
+			wantErr:  &plugins.Error{Code: 4, Message: "fake permanent failure with code 4", Permanent: true},
+		},
+		{
+			name:     "FailTimes: still failing",
+			behavior: FailTimes(2, 5, Succeed(Resp{Result: "ok"})),
+			calls:    2,
+			wantErr:  &plugins.Error{Code: 5, Message: "fake failure with code 5"},
+		},
+		{
+			name:     "FailTimes: then succeeds",
+			behavior: FailTimes(2, 5, Succeed(Resp{Result: "ok"})),
+			calls:    3,
+			wantResp: Resp{Result: "ok"},
+		},
+		{
+			name:     "Sequence: repeats the last",
+			behavior: Sequence(Fail(1), Succeed(Resp{Result: "2"}), Succeed(Resp{Result: "3"})),
+			calls:    5,
+			wantResp: Resp{Result: "3"},
+		},
+		{
+			name:     "Sleep",
+			behavior: Sleep(time.Millisecond, Succeed(Resp{Result: "ok"})),
+			calls:    1,
+			wantResp: Resp{Result: "ok"},
+		},
+		{
+			name:     "Sleep: Context canceled",
+			behavior: Sleep(time.Hour, Succeed(Resp{Result: "ok"})),
+			calls:    1,
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			wantErr: &plugins.Error{Message: "fake sleep interrupted: context canceled"},
+		},
+		{
+			name:     "Hang: released",
+			behavior: Hang(release),
+			calls:    1,
+			wantErr:  &plugins.Error{Message: "fake hang released"},
+		},
+		{
+			name:     "Error: wrong response type",
+			behavior: Succeed("not a Resp"),
+			calls:    1,
+			wantErr:  &plugins.Error{Message: "fake Behavior returned a response of type string, want fake.Resp", Permanent: true},
+		},
+		{
+			name:      "Panic",
+			behavior:  Panic("boom"),
+			calls:     1,
+			wantPanic: true,
+		},
+	}
+
+	for _, test := range tests {
+		p := MustNew(Args[Req, Resp]{Name: "fake", Behavior: test.behavior})
+		ctx := context.Background()
+		if test.ctx != nil {
+			ctx = test.ctx()
+		}
+
+		var (
+			resp     any
+			err      *plugins.Error
+			panicked bool
+		)
+		for i := 0; i < test.calls; i++ {
+			func() {
+				defer func() {
+					if recover() != nil {
+						panicked = true
+					}
+				}()
+				resp, err = p.Execute(ctx, Req{Target: "a"})
+			}()
+		}
+
+		if panicked != test.wantPanic {
+			t.Errorf("TestBehaviors(%s): got panic == %v, want %v", test.name, panicked, test.wantPanic)
+			continue
+		}
+		if test.wantPanic {
+			continue
+		}
+		if diff := pretty.Compare(test.wantErr, err); diff != "" {
+			t.Errorf("TestBehaviors(%s): error: -want/+got:\n%s", test.name, diff)
+		}
+		if diff := pretty.Compare(test.wantResp, resp); diff != "" {
+			t.Errorf("TestBehaviors(%s): response: -want/+got:\n%s", test.name, diff)
+		}
+		if got := len(p.Calls()); got != test.calls {
+			t.Errorf("TestBehaviors(%s): got %d calls recorded, want %d", test.name, got, test.calls)
+		}
+	}
+}
+
+func TestByRequest(t *testing.T) {
+	t.Parallel()
+
+	perTarget := map[string]Behavior{
+		"a": FailTimes(1, 1, Succeed(Resp{Result: "a"})),
+		"b": FailPermanent(2),
+	}
+	p := MustNew(
+		Args[Req, Resp]{
+			Name:      "fake",
+			Behavior:  Succeed(Resp{Result: "default"}),
+			ByRequest: func(req Req) Behavior { return perTarget[req.Target] },
+		},
+	)
+
+	info := execinfo.Info{PlanID: uuid.New(), ActionName: "action", Attempt: 1}
+	ctx := execinfo.NewContext(context.Background(), info)
+	for _, target := range []string{"a", "b", "a", "c"} {
+		p.Execute(ctx, Req{Target: target})
+	}
+
+	calls := p.Calls()
+	want := []struct {
+		target string
+		result string
+		code   plugins.ErrCode
+	}{
+		{target: "a", code: 1},
+		{target: "b", code: 2},
+		{target: "a", result: "a"},
+		{target: "c", result: "default"},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("TestByRequest: got %d calls, want %d", len(calls), len(want))
+	}
+	for i, w := range want {
+		c := calls[i]
+		if c.Req.Target != w.target {
+			t.Errorf("TestByRequest(call %d): got target %q, want %q", i, c.Req.Target, w.target)
+		}
+		if c.Info.PlanID != info.PlanID {
+			t.Errorf("TestByRequest(call %d): got PlanID %s, want %s", i, c.Info.PlanID, info.PlanID)
+		}
+		if c.Start.IsZero() || c.End.IsZero() {
+			t.Errorf("TestByRequest(call %d): got Start %v and End %v, want both set", i, c.Start, c.End)
+		}
+		switch {
+		case w.code != 0:
+			if c.Err == nil || c.Err.Code != w.code {
+				t.Errorf("TestByRequest(call %d): got err %v, want code %d", i, c.Err, w.code)
+			}
+		case c.Err != nil:
+			t.Errorf("TestByRequest(call %d): got err == %s, want err == nil", i, c.Err)
+		case c.Resp.(Resp).Result != w.result:
+			t.Errorf("TestByRequest(call %d): got result %q, want %q", i, c.Resp.(Resp).Result, w.result)
+		}
+	}
+
+	p.Reset()
+	if got := len(p.Calls()); got != 0 {
+		t.Errorf("TestByRequest(Reset): got %d calls, want 0", got)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(Args[Req, Resp]{}); err == nil {
+		t.Errorf("TestRegister(no name): got err == nil, want err != nil")
+	}
+
+	p := MustNew(Args[*Req, *Resp]{Name: "fake", Version: "1.0.0", IsCheck: true})
+	if err := registry.New().Register(p); err != nil {
+		t.Fatalf("TestRegister: got err == %s, want err == nil", err)
+	}
+	if !p.IsCheck() || plugins.VersionOf(p) != "1.0.0" {
+		t.Errorf("TestRegister: got IsCheck %v and version %q, want true and %q", p.IsCheck(), plugins.VersionOf(p), "1.0.0")
+	}
+
+	resp, err := p.Execute(context.Background(), Req{})
+	if err != nil {
+		t.Fatalf("TestRegister(Execute): got err == %s, want err == nil", err)
+	}
+	if _, ok := resp.(*Resp); !ok {
+		t.Errorf("TestRegister(Execute): got response of type %T, want %T", resp, &Resp{})
+	}
+}
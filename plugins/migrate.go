@@ -0,0 +1,79 @@
+package plugins
+
+import (
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/plugins/version"
+)
+
+// Migration converts requests and responses stored by older versions of a plugin to the types of the current
+// version, so that Plans in storage can still be read after the types change.
+type Migration struct {
+	// From is a version.Constraint that matches the versions whose data this converts. Data stored by an
+	// unversioned plugin has the version "0.0.0".
+	From string
+	// Req converts the JSON of a stored request to the JSON of the current request. Optional.
+	Req func(b []byte) ([]byte, error)
+	// Resp converts the JSON of a stored response to the JSON of the current response. Optional.
+	Resp func(b []byte) ([]byte, error)
+}
+
+// Migrator can be implemented by a Plugin whose request or response changed between versions. See Versioner.
+type Migrator interface {
+	// Migrations returns the Migrations of the plugin. For stored data, the first Migration whose From
+	// matches the version it was stored with is used.
+	Migrations() []Migration
+}
+
+// MigrateReq converts b, the JSON of a request stored by version from of the plugin, to the JSON of the request
+// of p. b is returned as is if from is the version of p or p has no Migration for it.
+func MigrateReq(p Plugin, from string, b []byte) ([]byte, error) {
+	m, err := findMigration(p, from)
+	if err != nil || m.Req == nil {
+		return b, err
+	}
+	b, err = m.Req(b)
+	if err != nil {
+		return nil, fmt.Errorf("plugin(%s) couldn't migrate request from version %q: %w", p.Name(), from, err)
+	}
+	return b, nil
+}
+
+// MigrateResp is like MigrateReq, but for a response.
+func MigrateResp(p Plugin, from string, b []byte) ([]byte, error) {
+	m, err := findMigration(p, from)
+	if err != nil || m.Resp == nil {
+		return b, err
+	}
+	b, err = m.Resp(b)
+	if err != nil {
+		return nil, fmt.Errorf("plugin(%s) couldn't migrate response from version %q: %w", p.Name(), from, err)
+	}
+	return b, nil
+}
+
+// findMigration returns the Migration of p for data stored by version from. The zero value is returned if there isn't one.
+func findMigration(p Plugin, from string) (Migration, error) {
+	migrator, ok := p.(Migrator)
+	if !ok || from == VersionOf(p) {
+		return Migration{}, nil
+	}
+	if from == "" {
+		from = "0.0.0"
+	}
+	v, err := version.Parse(from)
+	if err != nil {
+		return Migration{}, fmt.Errorf("plugin(%s) data has invalid version: %w", p.Name(), err)
+	}
+
+	for _, m := range migrator.Migrations() {
+		c, err := version.ParseConstraint(m.From)
+		if err != nil {
+			return Migration{}, fmt.Errorf("plugin(%s) has a Migration with an invalid From: %w", p.Name(), err)
+		}
+		if c.Check(v) {
+			return m, nil
+		}
+	}
+	return Migration{}, nil
+}
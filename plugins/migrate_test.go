@@ -0,0 +1,87 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type migratingPlugin struct {
+	*Typed[struct{}, struct{}]
+	migrations []Migration
+}
+
+func (m migratingPlugin) Migrations() []Migration {
+	return m.migrations
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+
+	typed, err := NewTyped(
+		TypedArgs[struct{}, struct{}]{
+			Name:    "migrating",
+			Version: "2.0.0",
+			Execute: func(ctx context.Context, req struct{}) (struct{}, *Error) { return struct{}{}, nil },
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	tag := func(s string) func(b []byte) ([]byte, error) {
+		return func(b []byte) ([]byte, error) { return append(b, s...), nil }
+	}
+	p := migratingPlugin{
+		Typed: typed,
+		migrations: []Migration{
+			{From: "0.0.0", Req: tag(" unversioned")},
+			{From: "^1.0.0", Req: tag(" req v1"), Resp: tag(" resp v1")},
+			{From: "^1.5.0", Req: tag(" never used")},
+			{From: "0.5.0", Req: func(b []byte) ([]byte, error) { return nil, errors.New("broken") }},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		plugin  Plugin
+		from    string
+		resp    bool
+		want    string
+		wantErr bool
+	}{
+		{name: "Error: invalid version", plugin: p, from: "1", wantErr: true},
+		{name: "Error: migration fails", plugin: p, from: "0.5.0", wantErr: true},
+		{name: "Not a Migrator", plugin: typed, from: "1.0.0", want: "data"},
+		{name: "Same version", plugin: p, from: "2.0.0", want: "data"},
+		{name: "No matching migration", plugin: p, from: "3.0.0", want: "data"},
+		{name: "Unversioned", plugin: p, from: "", want: "data unversioned"},
+		{name: "First match is used", plugin: p, from: "1.5.0", want: "data req v1"},
+		{name: "Response", plugin: p, from: "1.0.0", resp: true, want: "data resp v1"},
+		{name: "Response without a migration", plugin: p, from: "", resp: true, want: "data"},
+	}
+
+	for _, test := range tests {
+		var (
+			got []byte
+			err error
+		)
+		if test.resp {
+			got, err = MigrateResp(test.plugin, test.from, []byte("data"))
+		} else {
+			got, err = MigrateReq(test.plugin, test.from, []byte("data"))
+		}
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestMigrate(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestMigrate(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if string(got) != test.want {
+			t.Errorf("TestMigrate(%s): got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
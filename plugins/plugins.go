@@ -3,6 +3,7 @@ package plugins
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/gostdlib/ops/retry/exponential"
@@ -77,6 +78,53 @@ type Plugin interface {
 	Init() error
 }
 
+// AbandonLimiter can be implemented by a Plugin to refuse new executions when too many of its
+// executions were abandoned. An execution is abandoned when it does not return before its timeout.
+// Because Context cancellation is only advisory, an abandoned execution may keep running and holding
+// resources. While the limit is exceeded, attempts to execute the plugin fail with a retryable error.
+type AbandonLimiter interface {
+	// MaxAbandoned is the number of abandoned executions that have not returned that the plugin
+	// tolerates. Once there are more than this, new executions are refused. Each version of a plugin
+	// is counted on its own. A value <= 0 means no limit.
+	MaxAbandoned() int
+}
+
+// Versioner can be implemented by a Plugin to declare its semantic version, such as "1.2.0". The registry
+// can hold several versions of a plugin with the same name, and an Action can select them with
+// workflow.Action.Version. A Plugin that does not implement this, or returns "", is unversioned.
+// See the version package for the format.
+type Versioner interface {
+	// Version returns the semantic version of the plugin.
+	Version() string
+}
+
+// VersionOf returns the version of p or "" if p is unversioned.
+func VersionOf(p Plugin) string {
+	if v, ok := p.(Versioner); ok {
+		return v.Version()
+	}
+	return ""
+}
+
+// Schemer can be implemented by a Plugin that has JSON Schemas for its request and response, such as a Plugin whose
+// types are not Go structs. Otherwise the registry generates them from Request() and Response(). See the schema package.
+type Schemer interface {
+	// Schemas returns the JSON Schemas of the request and response. Either may be nil.
+	Schemas() (req, resp []byte)
+}
+
+// ErrDegraded is wrapped by an error from HealthChecker.Check() when a plugin can still be used, but not as well
+// as it should, such as a plugin that has lost one of several backends.
+var ErrDegraded = errors.New("plugin is degraded")
+
+// HealthChecker can be implemented by a Plugin whose health can change after Init(), such as a plugin that
+// depends on a remote service. Check is called periodically once Init() has succeeded. Returning an error that
+// wraps ErrDegraded marks the plugin degraded, any other error marks it unavailable.
+type HealthChecker interface {
+	// Check checks the health of the plugin. It should honor the Context's deadline.
+	Check(ctx context.Context) error
+}
+
 // FastRetryPolicy returns a retry plan that is fast at first and then slows down.
 //
 // progression will be:
@@ -0,0 +1,363 @@
+/*
+Package plugintest provides a conformance test for plugins. It checks the parts of the plugins.Plugin contract
+that the compiler can't and that otherwise only fail once the plugin is used in a Plan:
+
+  - Register: the plugin can be registered with a registry.Register.
+  - Secrets: fields that may hold secrets have the coerce:"secure" or coerce:"ignore" tag. Unlike the registry,
+    this looks in slices, arrays, maps and nil pointers of the request and response types.
+  - ValidateReq: ValidateReq() accepts the request and rejects a request of another type.
+  - RequestRoundTrip: the request is the same after it is stored as JSON and read back into a value
+    from Request(), the way storage does, and ValidateReq() accepts what was read back.
+  - Execute: Execute() succeeds for the request and returns a response of the type of Response(). The engine fails
+    an Action with a permanent error if the type is different.
+  - ResponseRoundTrip: the response is the same after it is stored as JSON and read back into a value from Response().
+  - Cancel: Execute() returns soon after its Context is canceled.
+
+Run() runs each check as a subtest of a test in the plugin's package:
+
+	func TestConformance(t *testing.T) {
+		plugintest.Run(t, myplugin.New(), myplugin.Req{Host: "localhost"})
+	}
+
+The Execute and Cancel checks run the plugin, so the request should be one the plugin can execute in a test.
+If it can't be, pass a nil request to skip the checks that need one, or skip checks with WithSkip().
+*/
+package plugintest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/token"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/go-json-experiment/json"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/google/uuid"
+)
+
+// The names of the checks, for use with WithSkip().
+const (
+	CheckRegister          = "Register"
+	CheckSecrets           = "Secrets"
+	CheckValidateReq       = "ValidateReq"
+	CheckRequestRoundTrip  = "RequestRoundTrip"
+	CheckExecute           = "Execute"
+	CheckResponseRoundTrip = "ResponseRoundTrip"
+	CheckCancel            = "Cancel"
+)
+
+type config struct {
+	skip          []string
+	timeout       time.Duration
+	cancelTimeout time.Duration
+}
+
+// Option is an optional argument for Run().
+type Option func(*config)
+
+// WithSkip skips the checks with names. The ResponseRoundTrip check is also skipped if Execute is.
+func WithSkip(names ...string) Option {
+	return func(c *config) {
+		c.skip = append(c.skip, names...)
+	}
+}
+
+// WithTimeout sets the timeout of the Context passed to Execute() in the Execute check. The default is 30 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.timeout = d
+	}
+}
+
+// WithCancelTimeout sets how long Execute() has to return after its Context is canceled. This applies to
+// the Execute check after its timeout and to the Cancel check. The default is 5 seconds.
+func WithCancelTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.cancelTimeout = d
+	}
+}
+
+// Run runs the checks on p as subtests of t. req is a request that p can execute. If req is nil,
+// the checks that need a request are skipped.
+func Run(t *testing.T, p plugins.Plugin, req any, options ...Option) {
+	t.Helper()
+
+	if p == nil {
+		t.Fatalf("plugintest.Run: plugin is nil")
+	}
+	cfg := config{timeout: 30 * time.Second, cancelTimeout: 5 * time.Second}
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	r := &runner{plugin: p, req: req, cfg: cfg}
+	for _, c := range r.checks() {
+		t.Run(c.name, func(t *testing.T) {
+			switch {
+			case slices.Contains(cfg.skip, c.name):
+				t.Skip("skipped with WithSkip()")
+			case c.needsReq && req == nil:
+				t.Skip("no request was given")
+			case c.needsResp && !r.executed:
+				t.Skip("the Execute check did not succeed")
+			}
+			if err := c.fn(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// check is a check on a plugin.
+type check struct {
+	name string
+	fn   func() error
+	// needsReq is set if the check needs a request.
+	needsReq bool
+	// needsResp is set if the check needs the response from the Execute check.
+	needsResp bool
+}
+
+// runner runs the checks on a plugin.
+type runner struct {
+	plugin plugins.Plugin
+	req    any
+	cfg    config
+
+	// executed is set if the Execute check succeeded, with the response in resp.
+	executed bool
+	resp     any
+}
+
+func (r *runner) checks() []check {
+	return []check{
+		{name: CheckRegister, fn: r.register},
+		{name: CheckSecrets, fn: r.secrets},
+		{name: CheckValidateReq, fn: r.validateReq, needsReq: true},
+		{name: CheckRequestRoundTrip, fn: r.requestRoundTrip, needsReq: true},
+		{name: CheckExecute, fn: r.execute, needsReq: true},
+		{name: CheckResponseRoundTrip, fn: r.responseRoundTrip, needsResp: true},
+		{name: CheckCancel, fn: r.cancel, needsReq: true},
+	}
+}
+
+func (r *runner) register() error {
+	if err := registry.New().Register(r.plugin); err != nil {
+		return fmt.Errorf("plugin couldn't be registered: %w", err)
+	}
+	return nil
+}
+
+func (r *runner) secrets() error {
+	var errs []error
+	if err := findSecrets(reflect.TypeOf(r.plugin.Request()), "Request()"); err != nil {
+		errs = append(errs, err)
+	}
+	if err := findSecrets(reflect.TypeOf(r.plugin.Response()), "Response()"); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// wrongType is a request type that no plugin accepts.
+type wrongType struct{}
+
+func (r *runner) validateReq() error {
+	if err := r.plugin.ValidateReq(r.req); err != nil {
+		return fmt.Errorf("ValidateReq() rejected the request: %w", err)
+	}
+	if err := r.plugin.ValidateReq(wrongType{}); err == nil {
+		return fmt.Errorf("ValidateReq() accepted a request of type %T, it must reject requests that are not the type of Request()", wrongType{})
+	}
+	return nil
+}
+
+func (r *runner) requestRoundTrip() error {
+	got, err := roundTrip(r.req, r.plugin.Request, "Request()")
+	if err != nil {
+		return err
+	}
+	if err := r.plugin.ValidateReq(got); err != nil {
+		return fmt.Errorf("ValidateReq() rejected the request read back from storage as %T: %w", got, err)
+	}
+	return nil
+}
+
+func (r *runner) execute() error {
+	ctx, cancel := context.WithTimeout(r.context(), r.cfg.timeout)
+	defer cancel()
+
+	resp, perr, err := r.run(ctx, r.cfg.timeout+r.cfg.cancelTimeout)
+	if err != nil {
+		return err
+	}
+	if perr != nil {
+		return fmt.Errorf("Execute() returned an error: %w", perr)
+	}
+	if want := r.plugin.Response(); reflect.TypeOf(resp) != reflect.TypeOf(want) {
+		return fmt.Errorf("Execute() returned a response of type %T, but Response() returns %T: the engine fails the Action with a permanent error", resp, want)
+	}
+	r.executed, r.resp = true, resp
+	return nil
+}
+
+func (r *runner) responseRoundTrip() error {
+	_, err := roundTrip(r.resp, r.plugin.Response, "Response()")
+	return err
+}
+
+func (r *runner) cancel() error {
+	ctx, cancel := context.WithCancel(r.context())
+	cancel()
+
+	if _, _, err := r.run(ctx, r.cfg.cancelTimeout); err != nil {
+		return fmt.Errorf("with a canceled Context: %w", err)
+	}
+	return nil
+}
+
+// context returns a Context with an execinfo.Info like the one the engine passes to Execute().
+func (r *runner) context() context.Context {
+	info := execinfo.Info{
+		PlanID:     uuid.New(),
+		PlanName:   "plugintest",
+		ActionID:   uuid.New(),
+		ActionName: "plugintest",
+		Attempt:    1,
+		Deadline:   time.Now().Add(r.cfg.timeout),
+	}
+	info.Logger = execinfo.NewLogger(slog.Default(), info)
+	return execinfo.NewContext(context.Background(), info)
+}
+
+// run runs Execute() with the request. err is set if Execute() panics or does not return within wait.
+// Execute() keeps running in that case.
+func (r *runner) run(ctx context.Context, wait time.Duration) (resp any, perr *plugins.Error, err error) {
+	type result struct {
+		resp  any
+		perr  *plugins.Error
+		panic any
+	}
+	ch := make(chan result, 1)
+	go func() {
+		res := result{}
+		defer func() {
+			res.panic = recover()
+			ch <- res
+		}()
+		res.resp, res.perr = r.plugin.Execute(ctx, r.req)
+	}()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case res := <-ch:
+		if res.panic != nil {
+			return nil, nil, fmt.Errorf("Execute() panicked: %v", res.panic)
+		}
+		return res.resp, res.perr, nil
+	case <-timer.C:
+		return nil, nil, fmt.Errorf("Execute() did not return within %v, it must return soon after its Context is done", wait)
+	}
+}
+
+// roundTrip encodes v as JSON and decodes it into a value from newV, the way storage does. It returns the decoded
+// value or an error if it is different from v. Nil and empty slices and maps are the same, as storage encodes
+// both as empty. method is the name of newV, for errors.
+func roundTrip(v any, newV func() any, method string) (any, error) {
+	a, b := newV(), newV()
+	if a == nil {
+		return nil, fmt.Errorf("%s returned nil", method)
+	}
+	if reflect.TypeOf(a).Kind() == reflect.Pointer && reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer() {
+		return nil, fmt.Errorf("%s returned the same pointer twice, it must return a new value each time as it is decoded into", method)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode %T as JSON: %w", v, err)
+	}
+	got, err := plugins.DecodeInto(data, a)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't decode %s into the %T from %s: %w", data, a, method, err)
+	}
+
+	if diff := cmp.Diff(indirect(v), indirect(got), ignoreUnexported, cmpopts.EquateEmpty()); diff != "" {
+		return nil, fmt.Errorf("%T changed after it was stored as JSON and read back as %T (-want/+got):\n%s", v, got, diff)
+	}
+	return got, nil
+}
+
+// indirect returns what v points to if it is a non-nil pointer, otherwise v. This lets a request given as a value
+// be compared to one read back into a pointer.
+func indirect(v any) any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		return rv.Elem().Interface()
+	}
+	return v
+}
+
+// ignoreUnexported ignores unexported struct fields, which are not stored.
+var ignoreUnexported = cmp.FilterPath(
+	func(p cmp.Path) bool {
+		sf, ok := p.Last().(cmp.StructField)
+		return ok && !token.IsExported(sf.Name())
+	},
+	cmp.Ignore(),
+)
+
+// findSecrets returns an error for each field in t, or the types it holds, whose name may hold a secret and that
+// does not have the coerce:"secure" or coerce:"ignore" tag.
+func findSecrets(t reflect.Type, path string) error {
+	var errs []error
+	walkFields(t, path, map[reflect.Type]bool{}, func(f reflect.StructField, path string) {
+		if !registry.IsSecretName(f.Name) {
+			return
+		}
+		for _, tag := range strings.Split(f.Tag.Get("coerce"), ",") {
+			switch strings.TrimSpace(strings.ToLower(tag)) {
+			case "secure", "ignore":
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf(`field %s may hold a secret, it must have the coerce:"secure" or coerce:"ignore" tag`, path))
+	})
+	return errors.Join(errs...)
+}
+
+// walkFields calls fn for each exported struct field in t and the types it holds.
+func walkFields(t reflect.Type, path string, seen map[reflect.Type]bool, fn func(f reflect.StructField, path string)) {
+	if t == nil || seen[t] {
+		return
+	}
+	seen[t] = true
+	defer delete(seen, t)
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		walkFields(t.Elem(), path, seen, fn)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		walkFields(t.Elem(), path+"[]", seen, fn)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			fpath := path + "." + f.Name
+			fn(f, fpath)
+			walkFields(f.Type, fpath, seen, fn)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package plugintest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+type Req struct {
+	Host     string
+	Password string `coerce:"secure"`
+	Headers  map[string]string
+	When     time.Time
+	cache    int
+}
+
+type Resp struct {
+	Lines []string
+}
+
+func TestRunTyped(t *testing.T) {
+	p, err := plugins.NewTyped(plugins.TypedArgs[Req, Resp]{
+		Name: "plugintest/typed",
+		Execute: func(ctx context.Context, req Req) (Resp, *plugins.Error) {
+			if ctx.Err() != nil {
+				return Resp{}, &plugins.Error{Message: ctx.Err().Error()}
+			}
+			return Resp{Lines: []string{req.Host}}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("TestRunTyped: got err == %s, want err == nil", err)
+	}
+
+	Run(t, p, Req{Host: "localhost", Headers: map[string]string{"a": "b"}, When: time.Now(), cache: 1})
+}
+
+// fakePlugin is a plugin that can break the contract in ways a test sets.
+type fakePlugin struct {
+	req       func() any
+	resp      func() any
+	validate  func(req any) error
+	execute   func(ctx context.Context, req any) (any, *plugins.Error)
+	panicking bool
+}
+
+func newFakePlugin() *fakePlugin {
+	return &fakePlugin{
+		req:  func() any { return Req{} },
+		resp: func() any { return &Resp{} },
+		validate: func(req any) error {
+			switch req.(type) {
+			case Req, *Req:
+				return nil
+			}
+			return fmt.Errorf("wrong type %T", req)
+		},
+		execute: func(ctx context.Context, req any) (any, *plugins.Error) {
+			return &Resp{Lines: []string{"ok"}}, nil
+		},
+	}
+}
+
+func (f *fakePlugin) Name() string { return "plugintest/fake" }
+func (f *fakePlugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
+	if f.panicking {
+		panic("boom")
+	}
+	return f.execute(ctx, req)
+}
+func (f *fakePlugin) ValidateReq(req any) error       { return f.validate(req) }
+func (f *fakePlugin) Request() any                    { return f.req() }
+func (f *fakePlugin) Response() any                   { return f.resp() }
+func (f *fakePlugin) IsCheck() bool                   { return false }
+func (f *fakePlugin) RetryPolicy() exponential.Policy { return plugins.FastRetryPolicy() }
+func (f *fakePlugin) Init() error                     { return nil }
+
+type secretsReq struct {
+	Items []struct {
+		APIKey string
+	}
+	Nested *struct {
+		Token string `coerce:"ignore"`
+	}
+}
+
+type anyReq struct {
+	Value any
+}
+
+func TestChecks(t *testing.T) {
+	t.Parallel()
+
+	shared := &Resp{}
+
+	tests := []struct {
+		name    string
+		modify  func(f *fakePlugin)
+		req     any
+		check   string
+		wantErr bool
+	}{
+		{
+			name:  "Success: Register",
+			check: CheckRegister,
+		},
+		{
+			name:    "Error: Register with an untagged secret",
+			modify:  func(f *fakePlugin) { f.req = func() any { return struct{ Password string }{} } },
+			check:   CheckRegister,
+			wantErr: true,
+		},
+		{
+			name:    "Error: secret in a slice",
+			modify:  func(f *fakePlugin) { f.req = func() any { return secretsReq{} } },
+			check:   CheckSecrets,
+			wantErr: true,
+		},
+		{
+			name:  "Success: secrets",
+			check: CheckSecrets,
+		},
+		{
+			name:    "Error: ValidateReq accepts any type",
+			modify:  func(f *fakePlugin) { f.validate = func(any) error { return nil } },
+			check:   CheckValidateReq,
+			wantErr: true,
+		},
+		{
+			name:    "Error: ValidateReq rejects the request",
+			modify:  func(f *fakePlugin) { f.validate = func(any) error { return fmt.Errorf("no") } },
+			check:   CheckValidateReq,
+			wantErr: true,
+		},
+		{
+			name: "Error: request does not survive the round trip",
+			modify: func(f *fakePlugin) {
+				f.req = func() any { return &anyReq{} }
+				f.validate = func(any) error { return nil }
+			},
+			req:     anyReq{Value: Req{Host: "localhost"}},
+			check:   CheckRequestRoundTrip,
+			wantErr: true,
+		},
+		{
+			name:    "Error: Response() returns the same pointer",
+			modify:  func(f *fakePlugin) { f.resp = func() any { return shared } },
+			check:   CheckResponseRoundTrip,
+			wantErr: true,
+		},
+		{
+			name: "Error: ValidateReq rejects the request read back from storage",
+			modify: func(f *fakePlugin) {
+				f.req = func() any { return &Req{} }
+				f.validate = func(req any) error {
+					if _, ok := req.(Req); !ok {
+						return fmt.Errorf("wrong type %T", req)
+					}
+					return nil
+				}
+			},
+			check:   CheckRequestRoundTrip,
+			wantErr: true,
+		},
+		{
+			name:  "Success: request round trip",
+			check: CheckRequestRoundTrip,
+		},
+		{
+			name: "Error: Execute returns the wrong response type",
+			modify: func(f *fakePlugin) {
+				f.execute = func(context.Context, any) (any, *plugins.Error) { return Resp{}, nil }
+			},
+			check:   CheckExecute,
+			wantErr: true,
+		},
+		{
+			name: "Error: Execute returns an error",
+			modify: func(f *fakePlugin) {
+				f.execute = func(context.Context, any) (any, *plugins.Error) { return nil, &plugins.Error{Message: "failed"} }
+			},
+			check:   CheckExecute,
+			wantErr: true,
+		},
+		{
+			name:    "Error: Execute panics",
+			modify:  func(f *fakePlugin) { f.panicking = true },
+			check:   CheckExecute,
+			wantErr: true,
+		},
+		{
+			name:  "Success: Execute",
+			check: CheckExecute,
+		},
+		{
+			name: "Error: Execute ignores cancellation",
+			modify: func(f *fakePlugin) {
+				f.execute = func(context.Context, any) (any, *plugins.Error) {
+					time.Sleep(time.Second)
+					return &Resp{}, nil
+				}
+			},
+			check:   CheckCancel,
+			wantErr: true,
+		},
+		{
+			name: "Success: Cancel",
+			modify: func(f *fakePlugin) {
+				f.execute = func(ctx context.Context, _ any) (any, *plugins.Error) {
+					select {
+					case <-ctx.Done():
+						return nil, &plugins.Error{Message: ctx.Err().Error()}
+					case <-time.After(time.Second):
+						return &Resp{}, nil
+					}
+				}
+			},
+			check: CheckCancel,
+		},
+	}
+
+	for _, test := range tests {
+		f := newFakePlugin()
+		if test.modify != nil {
+			test.modify(f)
+		}
+		req := test.req
+		if req == nil {
+			req = Req{Host: "localhost"}
+		}
+		r := &runner{plugin: f, req: req, cfg: config{timeout: time.Second, cancelTimeout: 50 * time.Millisecond}}
+
+		var err error
+		for _, c := range r.checks() {
+			if c.name == test.check {
+				if c.needsResp {
+					r.resp = &Resp{Lines: []string{"ok"}}
+				}
+				err = c.fn()
+			}
+		}
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestChecks(%s): got err == nil, want err != nil", test.name)
+		case err != nil && !test.wantErr:
+			t.Errorf("TestChecks(%s): got err == %s, want err == nil", test.name, err)
+		}
+	}
+}
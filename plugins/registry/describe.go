@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/schema"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+// Description describes a plugin in a Register, such as for documentation or to validate Plans that
+// were not written in Go.
+type Description struct {
+	// Name is the name of the plugin.
+	Name string `json:"name"`
+	// Version is the version of the plugin. It is empty for an unversioned plugin.
+	Version string `json:"version,omitempty"`
+	// IsCheck is true if the plugin is a check plugin.
+	IsCheck bool `json:"is_check"`
+	// RetryPolicy is the retry policy of the plugin.
+	RetryPolicy exponential.Policy `json:"retry_policy"`
+	// Request is the JSON Schema of the request.
+	Request jsontext.Value `json:"request"`
+	// Response is the JSON Schema of the response.
+	Response jsontext.Value `json:"response"`
+}
+
+// Describe returns a Description of each plugin in the Register, sorted by name and then from the oldest
+// to the latest version. The JSON Schemas are from schema.ForPlugin(). It returns an error if a schema
+// could not be generated.
+func (r *Register) Describe() ([]Description, error) {
+	if r == nil || r.m == nil {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(r.m))
+	for name := range r.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var out []Description
+	for _, name := range names {
+		for _, p := range r.m[name] {
+			d, err := describe(p)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, d)
+		}
+	}
+	return out, nil
+}
+
+func describe(p plugins.Plugin) (Description, error) {
+	req, resp, err := schema.ForPlugin(p)
+	if err != nil {
+		return Description{}, fmt.Errorf("plugin(%s) couldn't be described: %w", p.Name(), err)
+	}
+	return Description{
+		Name:        p.Name(),
+		Version:     plugins.VersionOf(p),
+		IsCheck:     p.IsCheck(),
+		RetryPolicy: p.RetryPolicy(),
+		Request:     jsontext.Value(req),
+		Response:    jsontext.Value(resp),
+	}, nil
+}
+
+// Catalog is the document written by WriteCatalog().
+type Catalog struct {
+	// Plugins are the Descriptions from Describe().
+	Plugins []Description `json:"plugins"`
+}
+
+// WriteCatalog writes a Catalog of the plugins in the Register to w as indented JSON.
+func (r *Register) WriteCatalog(w io.Writer) error {
+	descs, err := r.Describe()
+	if err != nil {
+		return err
+	}
+	return json.MarshalWrite(w, Catalog{Plugins: descs}, json.Deterministic(true), jsontext.WithIndent("  "))
+}
@@ -0,0 +1,103 @@
+package registry
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/go-json-experiment/json"
+	"github.com/gostdlib/ops/retry/exponential"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type describeReq struct {
+	Host  string
+	Token string `coerce:"secure"`
+}
+
+type describedPlugin struct {
+	fakePlugin
+	name    string
+	version string
+	check   bool
+}
+
+func (d describedPlugin) Name() string    { return d.name }
+func (d describedPlugin) Version() string { return d.version }
+func (d describedPlugin) IsCheck() bool   { return d.check }
+func (d describedPlugin) Request() any    { return describeReq{} }
+
+type schemerPlugin struct {
+	describedPlugin
+}
+
+func (schemerPlugin) Schemas() (req, resp []byte) {
+	return []byte(`{"type":"object"}`), nil
+}
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	reg := New()
+	reg.MustRegister(describedPlugin{name: "b", version: "2.0.0", check: true})
+	reg.MustRegister(describedPlugin{name: "b", version: "1.0.0", check: true})
+	reg.MustRegister(schemerPlugin{describedPlugin{name: "a"}})
+
+	got, err := reg.Describe()
+	if err != nil {
+		t.Fatalf("TestDescribe: got err == %s, want err == nil", err)
+	}
+
+	reqSchema := `{"$schema":"https://json-schema.org/draft/2020-12/schema","title":"describeReq","type":"object",` +
+		`"properties":{"Host":{"type":"string"},"Token":{"type":"string","writeOnly":true}}}`
+	respSchema := `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"object"}`
+	want := []Description{
+		{Name: "a", RetryPolicy: plugins.FastRetryPolicy(), Request: []byte(`{"type":"object"}`), Response: []byte(respSchema)},
+		{Name: "b", Version: "1.0.0", IsCheck: true, RetryPolicy: plugins.FastRetryPolicy(), Request: []byte(reqSchema), Response: []byte(respSchema)},
+		{Name: "b", Version: "2.0.0", IsCheck: true, RetryPolicy: plugins.FastRetryPolicy(), Request: []byte(reqSchema), Response: []byte(respSchema)},
+	}
+
+	if diff := pretty.Compare(toStrings(want), toStrings(got)); diff != "" {
+		t.Errorf("TestDescribe: -want/+got:\n%s", diff)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := reg.WriteCatalog(buf); err != nil {
+		t.Fatalf("TestDescribe(WriteCatalog): got err == %s, want err == nil", err)
+	}
+	cat := Catalog{}
+	if err := json.Unmarshal(buf.Bytes(), &cat); err != nil {
+		t.Fatalf("TestDescribe(WriteCatalog): catalog is not valid JSON: %s", err)
+	}
+	if diff := pretty.Compare(toStrings(got), toStrings(cat.Plugins)); diff != "" {
+		t.Errorf("TestDescribe(WriteCatalog): -want/+got:\n%s", diff)
+	}
+}
+
+// describeStrings is a Description with the schemas as compact strings, for comparing.
+type describeStrings struct {
+	Name        string
+	Version     string
+	IsCheck     bool
+	RetryPolicy exponential.Policy
+	Request     string
+	Response    string
+}
+
+func toStrings(descs []Description) []describeStrings {
+	var out []describeStrings
+	for _, d := range descs {
+		req, resp := d.Request.Clone(), d.Response.Clone()
+		req.Compact()
+		resp.Compact()
+		out = append(out, describeStrings{
+			Name:        d.Name,
+			Version:     d.Version,
+			IsCheck:     d.IsCheck,
+			RetryPolicy: d.RetryPolicy,
+			Request:     string(req),
+			Response:    string(resp),
+		})
+	}
+	return out
+}
@@ -0,0 +1,120 @@
+package registry
+
+import (
+	"slices"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+)
+
+//go:generate stringer -type=Health
+
+// Health is the health of a plugin, as found by running its Init() and plugins.HealthChecker.Check().
+type Health uint8
+
+const (
+	// HealthUnknown is a plugin that has not been checked. This is the case for a Register that is not
+	// used by a Workstream.
+	HealthUnknown Health = 0 // HealthUnknown
+	// Healthy is a plugin that initialized and passes its health checks.
+	Healthy Health = 1 // Healthy
+	// Degraded is a plugin whose health check says it can be used, but not as well as it should.
+	// Plans that use it are accepted.
+	Degraded Health = 2 // Degraded
+	// Unavailable is a plugin that failed to initialize or failed its health check. Plans that
+	// use it are rejected when they are submitted.
+	Unavailable Health = 3 // Unavailable
+)
+
+// PluginHealth is the health of a version of a plugin.
+type PluginHealth struct {
+	// Name is the name of the plugin.
+	Name string
+	// Version is the version of the plugin, or "" if it is unversioned.
+	Version string
+	// Health is the health of the plugin.
+	Health Health
+	// Reason is why the plugin is not Healthy. It is empty when the plugin is Healthy.
+	Reason string
+	// Since is when the plugin changed to Health.
+	Since time.Time
+	// Checked is when the plugin was last checked.
+	Checked time.Time
+}
+
+// SetHealth records the health of p and why it is not Healthy. This is set by the Workstream, it is
+// not for use by the user. This is safe for concurrent use.
+func (r *Register) SetHealth(p plugins.Plugin, h Health, reason string) {
+	if r == nil || p == nil {
+		return
+	}
+	if h == Healthy {
+		reason = ""
+	}
+	now := time.Now().UTC()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.health == nil {
+		r.health = map[pluginKey]PluginHealth{}
+	}
+	k := keyOf(p)
+	ph, ok := r.health[k]
+	if !ok || ph.Health != h {
+		ph.Since = now
+	}
+	ph.Name, ph.Version, ph.Health, ph.Reason, ph.Checked = k.name, k.version, h, reason, now
+	r.health[k] = ph
+}
+
+// Health returns the health of p. A plugin that has not been checked has HealthUnknown.
+// This is safe for concurrent use.
+func (r *Register) Health(p plugins.Plugin) PluginHealth {
+	if p == nil {
+		return PluginHealth{}
+	}
+	k := keyOf(p)
+	if r == nil {
+		return PluginHealth{Name: k.name, Version: k.version}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if ph, ok := r.health[k]; ok {
+		return ph
+	}
+	return PluginHealth{Name: k.name, Version: k.version}
+}
+
+// AllHealth returns the health of every plugin in the Register, sorted by name and then from the oldest
+// to the latest version. This is safe for concurrent use.
+func (r *Register) AllHealth() []PluginHealth {
+	if r == nil || r.m == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.m))
+	for name := range r.m {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	out := make([]PluginHealth, 0, len(names))
+	for _, name := range names {
+		for _, p := range r.m[name] {
+			// This can't use Health(), as it takes the lock.
+			k := keyOf(p)
+			ph, ok := r.health[k]
+			if !ok {
+				ph = PluginHealth{Name: k.name, Version: k.version}
+			}
+			out = append(out, ph)
+		}
+	}
+	return out
+}
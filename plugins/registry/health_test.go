@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+
+	reg := New()
+	v1, v2 := versionedPlugin{version: "1.0.0"}, versionedPlugin{version: "2.0.0"}
+	reg.MustRegister(v1)
+	reg.MustRegister(v2)
+
+	if got := reg.Health(v1); got.Health != HealthUnknown || got.Name != "fake" || got.Version != "1.0.0" {
+		t.Errorf("TestHealth(before check): got %s, want HealthUnknown for fake 1.0.0", pretty.Sprint(got))
+	}
+
+	reg.SetHealth(v1, Unavailable, "Init() failed")
+	reg.SetHealth(v2, Healthy, "ignored")
+	down := reg.Health(v1)
+	if down.Health != Unavailable || down.Reason != "Init() failed" || down.Since.IsZero() || down.Checked.IsZero() {
+		t.Errorf("TestHealth(Unavailable): got %s, want Unavailable with a reason and times", pretty.Sprint(down))
+	}
+	if got := reg.Health(v2); got.Health != Healthy || got.Reason != "" {
+		t.Errorf("TestHealth(Healthy): got %s, want Healthy without a reason", pretty.Sprint(got))
+	}
+
+	// Since only changes when the Health changes.
+	reg.SetHealth(v1, Unavailable, "Init() failed again")
+	again := reg.Health(v1)
+	if !again.Since.Equal(down.Since) || again.Reason != "Init() failed again" {
+		t.Errorf("TestHealth(still Unavailable): got %s, want Since %v and the new reason", pretty.Sprint(again), down.Since)
+	}
+	reg.SetHealth(v1, Degraded, "one backend is down")
+
+	var got []string
+	for _, ph := range reg.AllHealth() {
+		got = append(got, ph.Version+" "+ph.Health.String())
+	}
+	want := []string{"1.0.0 Degraded", "2.0.0 Healthy"}
+	if diff := pretty.Compare(want, got); diff != "" {
+		t.Errorf("TestHealth(AllHealth): -want/+got:\n%s", diff)
+	}
+}
@@ -18,36 +18,98 @@ Usage:
 		}
 		...
 	}
+
+A Register can hold several versions of a plugin with the same name if the plugins implement
+plugins.Versioner. Plugin() returns the latest version and PluginVersion() selects one with a
+version.Constraint.
 */
 package registry
 
 import (
 	"errors"
+	"expvar"
 	"fmt"
 	"reflect"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 
 	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/version"
 	"github.com/gostdlib/ops/retry/exponential"
 )
 
 // Register provides a Register for plugins. This should not be used directly by the user,
 // but instead via the Registry variable. Use of this type directly is not supported.
 type Register struct {
-	m map[string]plugins.Plugin
+	// m is a map of plugin names to the versions of the plugin, from oldest to latest.
+	// An unversioned plugin is before all versions.
+	m map[string][]plugins.Plugin
+
+	// quarantineOnPanic is true if a plugin that panics should be quarantined.
+	quarantineOnPanic bool
+
+	mu sync.Mutex
+	// quarantined is a map of plugin versions to the reason they were quarantined.
+	quarantined map[pluginKey]string
+	// abandoned is a map of plugin versions to the number of abandoned executions that have not returned.
+	abandoned map[pluginKey]int64
+	// health is the health of each version of a plugin that has been checked.
+	health map[pluginKey]PluginHealth
+}
+
+// pluginKey identifies a version of a plugin. Versions of a plugin share a name, so state about a plugin
+// that comes from running it is kept by pluginKey.
+type pluginKey struct {
+	name    string
+	version string
+}
+
+func keyOf(p plugins.Plugin) pluginKey {
+	return pluginKey{name: p.Name(), version: plugins.VersionOf(p)}
+}
+
+// String returns the name of the plugin, followed by "@" and the version if it is versioned.
+func (k pluginKey) String() string {
+	if k.version == "" {
+		return k.name
+	}
+	return k.name + "@" + k.version
+}
+
+// abandonedVar exports the number of abandoned executions that have not returned per plugin version,
+// summed across all Registers. See AllAbandoned() for the keys.
+var abandonedVar = expvar.NewMap("coercion.plugins.abandoned")
+
+// Option is an optional argument for New().
+type Option func(*Register)
+
+// WithQuarantineOnPanic causes a plugin that panics while executing an Action to be quarantined.
+// A quarantined plugin fails any Action that uses it until it is released with Release().
+func WithQuarantineOnPanic() Option {
+	return func(r *Register) {
+		r.quarantineOnPanic = true
+	}
 }
 
 // New creates a new Register. Not for use by the user.
-func New() *Register {
-	return &Register{
-		m: map[string]plugins.Plugin{},
+func New(options ...Option) *Register {
+	r := &Register{
+		m:           map[string][]plugins.Plugin{},
+		quarantined: map[pluginKey]string{},
+		abandoned:   map[pluginKey]int64{},
+		health:      map[pluginKey]PluginHealth{},
 	}
+	for _, o := range options {
+		o(r)
+	}
+	return r
 }
 
-// Register registers a plugin by name. It panics if the name is empty, the plugin is nil,
-// or a plugin is already registered with the same name. This can only be called during
-// init, otherwise the behavior is undefined. Not safe for concurrent use.
+// Register registers a plugin by name and version. It returns an error if the name is empty, the plugin is nil,
+// the version is invalid or a plugin is already registered with the same name and version. This can only be called
+// during init, otherwise the behavior is undefined. Not safe for concurrent use.
 func (r *Register) Register(p plugins.Plugin) error {
 	if p == nil {
 		return fmt.Errorf("plugin is nil")
@@ -61,13 +123,31 @@ func (r *Register) Register(p plugins.Plugin) error {
 		return fmt.Errorf("bug: Registry not initialized")
 	}
 
-	if _, ok := r.m[p.Name()]; ok {
-		return fmt.Errorf("plugin(%s) already registered", p.Name())
+	ver := plugins.VersionOf(p)
+	if ver != "" {
+		if _, err := version.Parse(ver); err != nil {
+			return fmt.Errorf("plugin(%s) has invalid version: %v", p.Name(), err)
+		}
+	}
+	for _, rp := range r.m[p.Name()] {
+		if compareVersions(plugins.VersionOf(rp), ver) == 0 {
+			if ver == "" {
+				return fmt.Errorf("plugin(%s) already registered", p.Name())
+			}
+			return fmt.Errorf("plugin(%s) version %s already registered", p.Name(), ver)
+		}
 	}
 
-	if err := validatePolicy(p.RetryPolicy()); err != nil {
+	if err := ValidatePolicy(p.RetryPolicy()); err != nil {
 		return fmt.Errorf("plugin(%s) has invalid retry plan: %v", p.Name(), err)
 	}
+	if m, ok := p.(plugins.Migrator); ok {
+		for _, migration := range m.Migrations() {
+			if _, err := version.ParseConstraint(migration.From); err != nil {
+				return fmt.Errorf("plugin(%s) has invalid migration: %v", p.Name(), err)
+			}
+		}
+	}
 
 	req := p.Request()
 	if err := findSecrets(req, ""); err != nil {
@@ -78,10 +158,27 @@ func (r *Register) Register(p plugins.Plugin) error {
 		return fmt.Errorf("plugin(%s) has invalid response: %v", p.Name(), err)
 	}
 
-	r.m[p.Name()] = p
+	versions := append(r.m[p.Name()], p)
+	slices.SortFunc(versions, func(a, b plugins.Plugin) int {
+		return compareVersions(plugins.VersionOf(a), plugins.VersionOf(b))
+	})
+	r.m[p.Name()] = versions
 	return nil
 }
 
+// compareVersions compares two valid versions, where "" is before all versions.
+func compareVersions(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return -1
+	case b == "":
+		return 1
+	}
+	return version.MustParse(a).Compare(version.MustParse(b))
+}
+
 // MustRegister registers a plugin by name. It panics if their is an error
 // registering the plugin.
 func (r *Register) MustRegister(p plugins.Plugin) {
@@ -90,29 +187,208 @@ func (r *Register) MustRegister(p plugins.Plugin) {
 	}
 }
 
-// Plugins returns a channel of all the plugins in the registry.
+// Plugins returns a channel of all the plugins in the registry, including every version of a plugin.
 func (r *Register) Plugins() chan plugins.Plugin {
 	ch := make(chan plugins.Plugin, 1)
 	go func() {
-		for _, p := range r.m {
-			ch <- p
+		for _, versions := range r.m {
+			for _, p := range versions {
+				ch <- p
+			}
 		}
 		close(ch)
 	}()
 	return ch
 }
 
-// Plugin returns a plugin by name. It returns nil if the plugin is not found.
+// Plugin returns the latest version of a plugin by name. It returns nil if the plugin is not found.
 func (r *Register) Plugin(name string) plugins.Plugin {
 	if r == nil || r.m == nil {
 		return nil
 	}
-	return r.m[name]
+	versions := r.m[name]
+	if len(versions) == 0 {
+		return nil
+	}
+	return versions[len(versions)-1]
 }
 
-// validatePolicy validates the exponential policy. This is a copy of the exponential.Policy.validate method.
+// PluginVersion returns the latest version of a plugin by name that matches the version.Constraint in constraint.
+// An empty constraint is the same as calling Plugin(). An unversioned plugin only matches an empty constraint.
+// It returns nil if no plugin matches or constraint is invalid.
+func (r *Register) PluginVersion(name string, constraint string) plugins.Plugin {
+	if r == nil || r.m == nil {
+		return nil
+	}
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		return nil
+	}
+	if c.IsZero() {
+		return r.Plugin(name)
+	}
+
+	versions := r.m[name]
+	for i := len(versions) - 1; i >= 0; i-- {
+		ver := plugins.VersionOf(versions[i])
+		if ver == "" {
+			continue
+		}
+		if c.Check(version.MustParse(ver)) {
+			return versions[i]
+		}
+	}
+	return nil
+}
+
+// Versions returns the versions registered for a plugin by name, from oldest to latest.
+// An unversioned plugin is returned as "".
+func (r *Register) Versions(name string) []string {
+	if r == nil || r.m == nil {
+		return nil
+	}
+	var out []string
+	for _, p := range r.m[name] {
+		out = append(out, plugins.VersionOf(p))
+	}
+	return out
+}
+
+// QuarantineOnPanic returns true if a plugin that panics should be quarantined.
+func (r *Register) QuarantineOnPanic() bool {
+	if r == nil {
+		return false
+	}
+	return r.quarantineOnPanic
+}
+
+// registered returns true if p is the version of its plugin in the Register.
+func (r *Register) registered(p plugins.Plugin) bool {
+	if r == nil || p == nil {
+		return false
+	}
+	k := keyOf(p)
+	for _, rp := range r.m[k.name] {
+		if plugins.VersionOf(rp) == k.version {
+			return true
+		}
+	}
+	return false
+}
+
+// Quarantine quarantines the version of the plugin p for reason. Actions that use a quarantined plugin
+// fail without the plugin being executed. Other versions of the plugin are not affected. This is safe for concurrent use.
+func (r *Register) Quarantine(p plugins.Plugin, reason string) error {
+	if !r.registered(p) {
+		if p == nil {
+			return fmt.Errorf("plugin is nil")
+		}
+		return fmt.Errorf("plugin(%s) not found", keyOf(p))
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.quarantined == nil {
+		r.quarantined = map[pluginKey]string{}
+	}
+	r.quarantined[keyOf(p)] = reason
+	return nil
+}
+
+// Quarantined returns the reason the version of the plugin p was quarantined. ok is false if it
+// is not quarantined. This is safe for concurrent use.
+func (r *Register) Quarantined(p plugins.Plugin) (reason string, ok bool) {
+	if r == nil || p == nil {
+		return "", false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reason, ok = r.quarantined[keyOf(p)]
+	return reason, ok
+}
+
+// Release removes the version of the plugin p from quarantine. This is a no-op if it
+// is not quarantined. This is safe for concurrent use.
+func (r *Register) Release(p plugins.Plugin) {
+	if r == nil || p == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.quarantined, keyOf(p))
+}
+
+// Abandon records that an execution of the version of the plugin p was abandoned because it did not return
+// before its timeout. The returned function must be called when the execution returns. The count is also
+// exported with expvar as "coercion.plugins.abandoned". This is safe for concurrent use.
+func (r *Register) Abandon(p plugins.Plugin) (returned func()) {
+	if r == nil || p == nil {
+		return func() {}
+	}
+	k := keyOf(p)
+	r.addAbandoned(k, 1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { r.addAbandoned(k, -1) })
+	}
+}
+
+func (r *Register) addAbandoned(k pluginKey, n int64) {
+	abandonedVar.Add(k.String(), n)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.abandoned == nil {
+		r.abandoned = map[pluginKey]int64{}
+	}
+	r.abandoned[k] += n
+	if r.abandoned[k] <= 0 {
+		delete(r.abandoned, k)
+	}
+}
+
+// Abandoned returns the number of abandoned executions of the version of the plugin p that have not returned.
+// This is safe for concurrent use.
+func (r *Register) Abandoned(p plugins.Plugin) int64 {
+	if r == nil || p == nil {
+		return 0
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.abandoned[keyOf(p)]
+}
+
+// AllAbandoned returns the number of abandoned executions that have not returned for each plugin version that
+// has any. The keys are the plugin names, followed by "@" and the version for versioned plugins, such as
+// "deploy@1.2.0". This is safe for concurrent use.
+func (r *Register) AllAbandoned() map[string]int64 {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make(map[string]int64, len(r.abandoned))
+	for k, n := range r.abandoned {
+		out[k.String()] = n
+	}
+	return out
+}
+
+// ValidatePolicy validates the exponential policy. This is a copy of the exponential.Policy.validate method.
+// It is exported so that policies set outside of a Plugin, such as on a workflow.Action, get the same checks.
 // TODO(element-of-surprise): Remove this when the exponential package is updated to export the validate method.
-func validatePolicy(p exponential.Policy) error {
+func ValidatePolicy(p exponential.Policy) error {
 	if p.InitialInterval <= 0 {
 		return errors.New("Policy.InitialInterval must be greater than 0")
 	}
@@ -133,6 +409,12 @@ func validatePolicy(p exponential.Policy) error {
 
 var secretRE = regexp.MustCompile(`(?i)(token|pass|jwt|hash|secret|bearer|cred|secure|signing|cert|code|key)`)
 
+// IsSecretName returns true if a field with name may hold a secret. Fields with such names must have the
+// coerce:"secure" or coerce:"ignore" tag in the request or response of a plugin.
+func IsSecretName(name string) bool {
+	return secretRE.MatchString(name)
+}
+
 var explain = `field %q seems to be related to a secret (like a password). This must have a field tag of ` +
 	`coerce:"secure" or coerce:"ignore" in order to work. coerce:"secure" indicates that the field ` +
 	`will have its value set to the zero value of the type when being displayed to the web.` +
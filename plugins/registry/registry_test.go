@@ -6,6 +6,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/element-of-surprise/coercion/plugins"
+
 	"github.com/gostdlib/ops/retry/exponential"
 	"github.com/kylelemons/godebug/pretty"
 )
@@ -85,7 +87,7 @@ func TestValidatePolicy(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			got := validatePolicy(test.policy)
+			got := ValidatePolicy(test.policy)
 			if diff := pretty.Compare(got, test.want); diff != "" {
 				t.Errorf("Validate(): -got +want: %v", diff)
 			}
@@ -222,3 +224,184 @@ func TestGetTags(t *testing.T) {
 		}
 	}
 }
+
+// fakePlugin is a plugins.Plugin that can be registered. Calling any method not implemented here panics.
+// We can't use the testing plugin, as it imports packages that import this one.
+type fakePlugin struct {
+	plugins.Plugin
+}
+
+func (fakePlugin) Name() string                    { return "fake" }
+func (fakePlugin) Request() any                    { return struct{}{} }
+func (fakePlugin) Response() any                   { return struct{}{} }
+func (fakePlugin) RetryPolicy() exponential.Policy { return plugins.FastRetryPolicy() }
+
+func TestQuarantine(t *testing.T) {
+	t.Parallel()
+
+	v1, v2 := versionedPlugin{version: "1.0.0"}, versionedPlugin{version: "2.0.0"}
+	reg := New()
+	reg.MustRegister(v1)
+	reg.MustRegister(v2)
+
+	if err := reg.Quarantine(versionedPlugin{version: "3.0.0"}, "reason"); err == nil {
+		t.Errorf("TestQuarantine(version not found): got err == nil, want err != nil")
+	}
+	if err := reg.Quarantine(nil, "reason"); err == nil {
+		t.Errorf("TestQuarantine(nil plugin): got err == nil, want err != nil")
+	}
+
+	if _, ok := reg.Quarantined(v1); ok {
+		t.Errorf("TestQuarantine(before quarantine): got quarantined == true, want false")
+	}
+
+	if err := reg.Quarantine(v1, "panicked"); err != nil {
+		t.Fatalf("TestQuarantine(quarantine): got err == %s, want err == nil", err)
+	}
+	reason, ok := reg.Quarantined(v1)
+	if !ok || reason != "panicked" {
+		t.Errorf("TestQuarantine(after quarantine): got (%q, %v), want (%q, true)", reason, ok, "panicked")
+	}
+	// Quarantining a version does not quarantine the other versions.
+	if _, ok := reg.Quarantined(v2); ok {
+		t.Errorf("TestQuarantine(other version): got quarantined == true, want false")
+	}
+
+	reg.Release(v1)
+	if _, ok := reg.Quarantined(v1); ok {
+		t.Errorf("TestQuarantine(after release): got quarantined == true, want false")
+	}
+}
+
+func TestAbandon(t *testing.T) {
+	t.Parallel()
+
+	v1, v2 := versionedPlugin{version: "1.0.0"}, versionedPlugin{version: "2.0.0"}
+	reg := New()
+
+	returned1 := reg.Abandon(v1)
+	returned2 := reg.Abandon(v1)
+	returnedV2 := reg.Abandon(v2)
+	if got := reg.Abandoned(v1); got != 2 {
+		t.Errorf("TestAbandon(after abandon): got %d, want 2", got)
+	}
+	// Each version is counted on its own.
+	if got := reg.Abandoned(v2); got != 1 {
+		t.Errorf("TestAbandon(other version): got %d, want 1", got)
+	}
+
+	returned1()
+	// Calling the returned function more than once must not change the count.
+	returned1()
+	if got := reg.Abandoned(v1); got != 1 {
+		t.Errorf("TestAbandon(after first return): got %d, want 1", got)
+	}
+	if diff := pretty.Compare(map[string]int64{"fake@1.0.0": 1, "fake@2.0.0": 1}, reg.AllAbandoned()); diff != "" {
+		t.Errorf("TestAbandon(AllAbandoned): -want/+got:\n%s", diff)
+	}
+
+	returned2()
+	returnedV2()
+	if got := reg.Abandoned(v1); got != 0 {
+		t.Errorf("TestAbandon(after all returned): got %d, want 0", got)
+	}
+	if got := reg.AllAbandoned(); len(got) != 0 {
+		t.Errorf("TestAbandon(AllAbandoned after all returned): got %v, want empty", got)
+	}
+}
+
+type versionedPlugin struct {
+	fakePlugin
+	version string
+}
+
+func (v versionedPlugin) Version() string { return v.version }
+
+func TestVersions(t *testing.T) {
+	t.Parallel()
+
+	reg := New()
+	for _, v := range []string{"1.2.0", "2.0.0-beta.1", "1.10.0", "2.0.0"} {
+		reg.MustRegister(versionedPlugin{version: v})
+	}
+	reg.MustRegister(fakePlugin{})
+
+	if err := reg.Register(versionedPlugin{version: "1.2.0"}); err == nil {
+		t.Errorf("TestVersions(duplicate version): got err == nil, want err != nil")
+	}
+	if err := reg.Register(fakePlugin{}); err == nil {
+		t.Errorf("TestVersions(duplicate unversioned): got err == nil, want err != nil")
+	}
+	if err := reg.Register(versionedPlugin{version: "1.2"}); err == nil {
+		t.Errorf("TestVersions(bad version): got err == nil, want err != nil")
+	}
+
+	want := []string{"", "1.2.0", "1.10.0", "2.0.0-beta.1", "2.0.0"}
+	if diff := pretty.Compare(want, reg.Versions("fake")); diff != "" {
+		t.Errorf("TestVersions(Versions): -want/+got:\n%s", diff)
+	}
+	if got := plugins.VersionOf(reg.Plugin("fake")); got != "2.0.0" {
+		t.Errorf("TestVersions(Plugin): got version %q, want %q", got, "2.0.0")
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		want       string
+		wantNil    bool
+	}{
+		{name: "No constraint", constraint: "", want: "2.0.0"},
+		{name: "Exact", constraint: "1.2.0", want: "1.2.0"},
+		{name: "Caret", constraint: "^1.0.0", want: "1.10.0"},
+		{name: "Tilde", constraint: "~1.2.0", want: "1.2.0"},
+		{name: "Range", constraint: ">=1.0.0 <2.0.0-0", want: "1.10.0"},
+		{name: "Or", constraint: "^3.0.0 || 1.2.0", want: "1.2.0"},
+		{name: "No match", constraint: "^3.0.0", wantNil: true},
+		{name: "Bad constraint", constraint: ">>1", wantNil: true},
+	}
+
+	for _, test := range tests {
+		got := reg.PluginVersion("fake", test.constraint)
+		if test.wantNil {
+			if got != nil {
+				t.Errorf("TestVersions(%s): got version %q, want nil", test.name, plugins.VersionOf(got))
+			}
+			continue
+		}
+		if got == nil {
+			t.Errorf("TestVersions(%s): got nil, want version %q", test.name, test.want)
+			continue
+		}
+		if plugins.VersionOf(got) != test.want {
+			t.Errorf("TestVersions(%s): got version %q, want %q", test.name, plugins.VersionOf(got), test.want)
+		}
+	}
+
+	n := 0
+	for range reg.Plugins() {
+		n++
+	}
+	if n != 5 {
+		t.Errorf("TestVersions(Plugins): got %d plugins, want 5", n)
+	}
+}
+
+type migratingPlugin struct {
+	fakePlugin
+	from string
+}
+
+func (m migratingPlugin) Migrations() []plugins.Migration {
+	return []plugins.Migration{{From: m.from}}
+}
+
+func TestRegisterMigrations(t *testing.T) {
+	t.Parallel()
+
+	if err := New().Register(migratingPlugin{from: "^1"}); err == nil {
+		t.Errorf("TestRegisterMigrations(invalid From): got err == nil, want err != nil")
+	}
+	if err := New().Register(migratingPlugin{from: "^1.0.0"}); err != nil {
+		t.Errorf("TestRegisterMigrations(valid From): got err == %s, want err == nil", err)
+	}
+}
@@ -0,0 +1,233 @@
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/external"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// AgentOption is an optional argument for NewAgent().
+type AgentOption func(*Agent) error
+
+// WithAgentToken sets the bearer token that requests must have. Without this, requests are not authenticated,
+// which should only be done when something in front of the Agent does it.
+func WithAgentToken(token string) AgentOption {
+	return func(a *Agent) error {
+		if token == "" {
+			return errors.New("token cannot be empty")
+		}
+		a.token = token
+		return nil
+	}
+}
+
+// WithHeartbeat sets how often the Agent sends a heartbeat while an execution is running. This must
+// be shorter than the heartbeat timeout of the Plugin. Defaults to 5 seconds.
+func WithHeartbeat(d time.Duration) AgentOption {
+	return func(a *Agent) error {
+		if d <= 0 {
+			return errors.New("heartbeat must be greater than 0")
+		}
+		a.heartbeat = d
+		return nil
+	}
+}
+
+// Agent is an http.Handler that executes the plugins in a registry.Register for a Plugin.
+type Agent struct {
+	reg       *registry.Register
+	token     string
+	heartbeat time.Duration
+
+	mux *http.ServeMux
+}
+
+var _ http.Handler = (*Agent)(nil)
+
+// NewAgent creates a new Agent for the plugins in reg. The plugins should have been initialized.
+func NewAgent(reg *registry.Register, options ...AgentOption) (*Agent, error) {
+	if reg == nil {
+		return nil, errors.New("registry cannot be nil")
+	}
+	a := &Agent{reg: reg, heartbeat: defaultHeartbeat, mux: http.NewServeMux()}
+	for _, o := range options {
+		if err := o(a); err != nil {
+			return nil, err
+		}
+	}
+
+	a.mux.HandleFunc("GET "+healthPath, a.health)
+	a.mux.HandleFunc("GET "+describePath, a.describe)
+	a.mux.HandleFunc("POST "+executePath, a.execute)
+	return a, nil
+}
+
+// ServeHTTP implements http.Handler.ServeHTTP().
+func (a *Agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if a.token != "" {
+		want := []byte("Bearer " + a.token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// health answers with the plugins that can be executed. A plugin is not included if all its versions are quarantined.
+func (a *Agent) health(w http.ResponseWriter, r *http.Request) {
+	h := Health{Plugins: []string{}}
+	for p := range a.reg.Plugins() {
+		if _, ok := a.reg.Quarantined(p); ok {
+			continue
+		}
+		h.Plugins = append(h.Plugins, p.Name())
+	}
+	slices.Sort(h.Plugins)
+	// A Register can have several versions of a plugin.
+	h.Plugins = slices.Compact(h.Plugins)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.MarshalWrite(w, h)
+}
+
+// describe answers with the describe message of a plugin.
+func (a *Agent) describe(w http.ResponseWriter, r *http.Request) {
+	p, ok := a.plugin(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.MarshalWrite(w, external.Describe(p))
+}
+
+// execute executes a plugin for the execute message in the body. The answer is a stream of log, progress
+// and heartbeat messages followed by the result.
+func (a *Agent) execute(w http.ResponseWriter, r *http.Request) {
+	p, ok := a.plugin(w, r)
+	if !ok {
+		return
+	}
+	if reason, ok := a.reg.Quarantined(p); ok {
+		http.Error(w, fmt.Sprintf("plugin(%s) is quarantined: %s", p.Name(), reason), http.StatusServiceUnavailable)
+		return
+	}
+
+	var msg external.Message
+	if err := json.UnmarshalRead(r.Body, &msg); err != nil || msg.Type != external.MTExecute {
+		http.Error(w, "body must be an execute message", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	s := &stream{w: w, enc: jsontext.NewEncoder(w)}
+	s.flusher, _ = w.(http.Flusher)
+	s.flush()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.heartbeats(ctx, a.heartbeat)
+	}()
+
+	result := external.Execute(r.Context(), p, msg, s)
+	// Nothing can be written once the handler returns.
+	cancel()
+	<-done
+	s.end(result)
+}
+
+// plugin returns the latest version of the plugin named in the request that matches the version constraint
+// in the request. If there isn't one, an error is written and ok is false.
+func (a *Agent) plugin(w http.ResponseWriter, r *http.Request) (p plugins.Plugin, ok bool) {
+	name, constraint := r.URL.Query().Get(pluginParam), r.URL.Query().Get(versionParam)
+	p = a.reg.PluginVersion(name, constraint)
+	if p == nil {
+		if constraint != "" {
+			http.Error(w, fmt.Sprintf("plugin(%s) version %q not found", name, constraint), http.StatusNotFound)
+			return nil, false
+		}
+		http.Error(w, fmt.Sprintf("plugin(%s) not found", name), http.StatusNotFound)
+		return nil, false
+	}
+	return p, true
+}
+
+// stream writes the messages of an execution to the Plugin. It implements execinfo.Output.
+type stream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	enc     *jsontext.Encoder
+	flusher http.Flusher
+	// ended is set when the result is sent. Nothing is sent after that.
+	ended bool
+}
+
+// Log implements execinfo.Output.Log().
+func (s *stream) Log(line string) {
+	s.send(external.Message{Type: external.MTLog, Line: line})
+}
+
+// Progress implements execinfo.Output.Progress().
+func (s *stream) Progress(percent int) {
+	s.send(external.Message{Type: external.MTProgress, Progress: percent})
+}
+
+// heartbeats sends a heartbeat every interval until ctx is done.
+func (s *stream) heartbeats(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.send(external.Message{Type: external.MTHeartbeat})
+		}
+	}
+}
+
+// send writes msg and flushes it to the Plugin. Errors are ignored, as they mean the Plugin went away
+// and the request's Context is cancelled.
+func (s *stream) send(msg external.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+	json.MarshalEncode(s.enc, msg)
+	s.flush()
+}
+
+// end sends the result. Messages sent after this, such as by a goroutine the plugin left running,
+// are dropped.
+func (s *stream) end(result external.Message) {
+	s.send(result)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+func (s *stream) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
@@ -0,0 +1,103 @@
+/*
+Agent is a worker agent that serves plugins to remote.Plugins in a Workstream on another host. The plugins
+are executables that speak the protocol of the external package.
+
+Usage:
+
+	COERCION_AGENT_TOKEN=secret agent -addr :8443 -cert cert.pem -key key.pem -plugin /usr/local/bin/myplugin
+
+-plugin can be given more than once. If COERCION_AGENT_TOKEN is set, requests must have it as a bearer token.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins/external"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/plugins/remote"
+)
+
+// tokenEnv is the environment variable that holds the bearer token.
+const tokenEnv = "COERCION_AGENT_TOKEN"
+
+// pluginPaths is a flag that can be given more than once.
+type pluginPaths []string
+
+func (p *pluginPaths) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *pluginPaths) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+var (
+	addr = flag.String("addr", ":8443", "The address to listen on")
+	cert = flag.String("cert", "", "The TLS certificate file. If not set, the agent does not use TLS")
+	key  = flag.String("key", "", "The TLS key file")
+)
+
+func main() {
+	var paths pluginPaths
+	flag.Var(&paths, "plugin", "The path to a plugin executable. Can be given more than once")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	reg := registry.New()
+	for _, path := range paths {
+		p, err := external.New(ctx, path)
+		if err != nil {
+			log.Fatalf("couldn't start plugin %s: %v", path, err)
+		}
+		defer p.Close()
+
+		if err := p.Init(); err != nil {
+			log.Fatalf("plugin(%s) failed to init: %v", p.Name(), err)
+		}
+		if err := reg.Register(p); err != nil {
+			log.Fatalf("couldn't register plugin(%s): %v", p.Name(), err)
+		}
+		log.Printf("serving plugin(%s) from %s", p.Name(), path)
+	}
+
+	var options []remote.AgentOption
+	if token := os.Getenv(tokenEnv); token != "" {
+		options = append(options, remote.WithAgentToken(token))
+	} else {
+		log.Printf("%s is not set, requests are not authenticated", tokenEnv)
+	}
+	agent, err := remote.NewAgent(reg, options...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: agent}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+
+	if *cert != "" {
+		err = srv.ListenAndServeTLS(*cert, *key)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,376 @@
+/*
+Package remote provides a plugins.Plugin that executes a plugin on a worker agent running on another host,
+and the Agent that serves the plugins in a registry.Register.
+
+The Plugin and the Agent talk over HTTP with the Messages of the external package. An execution is a POST
+of the execute message. The Agent answers with a stream of messages, one per line, that holds the log,
+progress and heartbeats of the execution followed by the result. Cancelling the execution's Context closes
+the request, which cancels the Context of the plugin on the Agent.
+
+On the worker:
+
+	agent, err := remote.NewAgent(reg, remote.WithAgentToken(token))
+	if err != nil {
+		// Do something
+	}
+	log.Fatal(http.ListenAndServeTLS(":8443", certFile, keyFile, agent))
+
+In the Workstream:
+
+	p, err := remote.New(ctx, "https://worker1:8443", "github.com/me/myplugins.Hello", remote.WithToken(token))
+	if err != nil {
+		// Do something
+	}
+	reg.MustRegister(p)
+
+If the Agent has several versions of a plugin, use WithVersion() to pick one. Register a Plugin for each
+version that Actions select with workflow.Action.Version.
+
+Actions using the Plugin must use an external.Req and get an external.Resp as the response.
+The agent subdirectory has a binary that serves plugins in other processes with the external package.
+*/
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/plugins/external"
+	"github.com/element-of-surprise/coercion/plugins/version"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+const (
+	healthPath   = "/v1/health"
+	describePath = "/v1/describe"
+	executePath  = "/v1/execute"
+	// pluginParam is the query parameter that holds the name of the plugin.
+	pluginParam = "plugin"
+	// versionParam is the query parameter that holds the version.Constraint of the plugin. If it is not set,
+	// the latest version is used.
+	versionParam = "version"
+
+	defaultHeartbeat        = 5 * time.Second
+	defaultHeartbeatTimeout = 30 * time.Second
+	// checkTimeout is how long Init() waits for the Agent.
+	checkTimeout = 30 * time.Second
+)
+
+// Health is the answer of the Agent to a health request.
+type Health struct {
+	// Plugins are the names of the plugins the Agent can execute.
+	Plugins []string `json:"plugins"`
+}
+
+// Option is an optional argument for New().
+type Option func(*Plugin) error
+
+// WithClient sets the http.Client used to talk to the Agent. Use this to set up TLS.
+// Defaults to http.DefaultClient.
+func WithClient(client *http.Client) Option {
+	return func(p *Plugin) error {
+		if client == nil {
+			return errors.New("client cannot be nil")
+		}
+		p.client = client
+		return nil
+	}
+}
+
+// WithToken sets the bearer token sent to the Agent.
+func WithToken(token string) Option {
+	return func(p *Plugin) error {
+		p.token = token
+		return nil
+	}
+}
+
+// WithHeartbeatTimeout sets how long an execution can go without a message from the Agent before it
+// fails. This must be longer than the heartbeat interval of the Agent. Defaults to 30 seconds.
+func WithHeartbeatTimeout(d time.Duration) Option {
+	return func(p *Plugin) error {
+		if d <= 0 {
+			return errors.New("heartbeat timeout must be greater than 0")
+		}
+		p.heartbeatTimeout = d
+		return nil
+	}
+}
+
+// WithRetryPolicy sets the retry policy of the Plugin. Defaults to plugins.FastRetryPolicy().
+func WithRetryPolicy(policy exponential.Policy) Option {
+	return func(p *Plugin) error {
+		p.policy = policy
+		return nil
+	}
+}
+
+// WithVersion sets the version.Constraint of the plugin on the Agent, for an Agent that has several versions
+// of it. The Plugin uses the latest version that matches when it is created. Defaults to the latest version.
+func WithVersion(constraint string) Option {
+	return func(p *Plugin) error {
+		if _, err := version.ParseConstraint(constraint); err != nil {
+			return err
+		}
+		p.constraint = constraint
+		return nil
+	}
+}
+
+// Plugin is a plugins.Plugin that executes a plugin on an Agent.
+type Plugin struct {
+	base       *url.URL
+	name       string
+	constraint string
+	client     *http.Client
+	token      string
+
+	heartbeatTimeout time.Duration
+	policy           exponential.Policy
+
+	// desc is the Agent's answer to the describe request.
+	desc external.Message
+}
+
+var (
+	_ plugins.Plugin        = (*Plugin)(nil)
+	_ plugins.Versioner     = (*Plugin)(nil)
+	_ plugins.HealthChecker = (*Plugin)(nil)
+)
+
+// New returns a Plugin for the plugin with name on the Agent at agentURL. The Agent must have the plugin.
+func New(ctx context.Context, agentURL, name string, options ...Option) (*Plugin, error) {
+	base, err := url.Parse(agentURL)
+	if err != nil {
+		return nil, fmt.Errorf("bad agent URL: %w", err)
+	}
+	p := &Plugin{
+		base:             base,
+		name:             name,
+		client:           http.DefaultClient,
+		heartbeatTimeout: defaultHeartbeatTimeout,
+		policy:           plugins.FastRetryPolicy(),
+	}
+	for _, o := range options {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := p.do(ctx, http.MethodGet, describePath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.UnmarshalRead(resp.Body, &p.desc); err != nil {
+		return nil, fmt.Errorf("bad describe answer from agent(%s): %w", base, err)
+	}
+	switch {
+	case p.desc.Version != external.ProtocolVersion:
+		return nil, fmt.Errorf("agent(%s) speaks protocol version %d, want %d", base, p.desc.Version, external.ProtocolVersion)
+	case p.desc.Name != name:
+		return nil, fmt.Errorf("agent(%s) described plugin %q, want %q", base, p.desc.Name, name)
+	}
+	return p, nil
+}
+
+// Name implements plugins.Plugin.Name().
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Execute implements plugins.Plugin.Execute(). req is sent to the Agent. Errors talking to the Agent
+// are retryable, except when the Agent rejects the request.
+func (p *Plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
+	msg, err := external.ExecuteMessage(ctx, req)
+	if err != nil {
+		return nil, &plugins.Error{Message: err.Error(), Permanent: true}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, &plugins.Error{Message: fmt.Sprintf("couldn't encode request: %v", err), Permanent: true}
+	}
+
+	// The request is cancelled if the Agent stops sending messages.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	timer := time.AfterFunc(p.heartbeatTimeout, func() {
+		cancel(fmt.Errorf("agent(%s) sent nothing for %v", p.base, p.heartbeatTimeout))
+	})
+	defer timer.Stop()
+
+	resp, err := p.do(ctx, http.MethodPost, executePath, body)
+	if err != nil {
+		var sErr statusErr
+		if errors.As(err, &sErr) && sErr.permanent() {
+			return nil, &plugins.Error{Message: err.Error(), Permanent: true}
+		}
+		return nil, &plugins.Error{Message: p.failMsg(ctx, err)}
+	}
+	defer resp.Body.Close()
+
+	info, _ := execinfo.From(ctx)
+	dec := jsontext.NewDecoder(resp.Body)
+	for {
+		v, err := dec.ReadValue()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("agent closed the stream without a result")
+			}
+			return nil, &plugins.Error{Message: p.failMsg(ctx, err)}
+		}
+		timer.Reset(p.heartbeatTimeout)
+
+		var m external.Message
+		if err := json.Unmarshal(v, &m); err != nil {
+			return nil, &plugins.Error{Message: p.failMsg(ctx, fmt.Errorf("bad message: %w", err))}
+		}
+		if m.Forward(info.Output) {
+			continue
+		}
+		if m.Type == external.MTResult {
+			resp, pErr := m.Result()
+			if pErr != nil {
+				return nil, pErr
+			}
+			return resp, nil
+		}
+	}
+}
+
+// failMsg returns the message for an execution that failed with err. If ctx was cancelled, the cause is used.
+func (p *Plugin) failMsg(ctx context.Context, err error) string {
+	if cause := context.Cause(ctx); cause != nil {
+		err = cause
+	}
+	return fmt.Sprintf("plugin(%s) on agent(%s): %v", p.name, p.base, err)
+}
+
+// ValidateReq implements plugins.Plugin.ValidateReq(). It checks that req is an external.Req that can be encoded.
+func (p *Plugin) ValidateReq(req any) error {
+	_, err := external.ExecuteMessage(context.Background(), req)
+	return err
+}
+
+// Request implements plugins.Plugin.Request().
+func (p *Plugin) Request() any {
+	return external.Req{}
+}
+
+// Response implements plugins.Plugin.Response().
+func (p *Plugin) Response() any {
+	return external.Resp{}
+}
+
+// Version implements plugins.Versioner.Version(). This is what the Agent described.
+func (p *Plugin) Version() string {
+	return p.desc.PluginVersion
+}
+
+// IsCheck implements plugins.Plugin.IsCheck(). This is what the Agent described.
+func (p *Plugin) IsCheck() bool {
+	return p.desc.IsCheck
+}
+
+// RetryPolicy implements plugins.Plugin.RetryPolicy().
+func (p *Plugin) RetryPolicy() exponential.Policy {
+	return p.policy
+}
+
+// Init implements plugins.Plugin.Init(). It returns an error if Check() does.
+func (p *Plugin) Init() error {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	return p.Check(ctx)
+}
+
+// Check returns an error if the Agent is not available or does not have the plugin. This implements
+// plugins.HealthChecker, so the plugin is Unavailable to new Plans while the Agent is down.
+func (p *Plugin) Check(ctx context.Context) error {
+	resp, err := p.do(ctx, http.MethodGet, healthPath, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	h := Health{}
+	if err := json.UnmarshalRead(resp.Body, &h); err != nil {
+		return fmt.Errorf("bad health answer from agent(%s): %w", p.base, err)
+	}
+	if !slices.Contains(h.Plugins, p.name) {
+		return fmt.Errorf("agent(%s) does not have plugin(%s)", p.base, p.name)
+	}
+	return nil
+}
+
+// Schemas returns the JSON Schemas of the request and response that the Agent described. Either may be nil.
+func (p *Plugin) Schemas() (req, resp []byte) {
+	return p.desc.RequestSchema.Clone(), p.desc.ResponseSchema.Clone()
+}
+
+// statusErr is returned by do() when the Agent answers with a status other than 200.
+type statusErr struct {
+	code int
+	msg  string
+}
+
+func (s statusErr) Error() string {
+	return fmt.Sprintf("agent answered with %d: %s", s.code, s.msg)
+}
+
+// permanent returns true if retrying the request will not help.
+func (s statusErr) permanent() bool {
+	switch s.code {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+// do sends a request for the plugin to the Agent. An error is returned if the Agent does not answer with http.StatusOK.
+func (p *Plugin) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	u := p.base.JoinPath(path)
+	q := url.Values{pluginParam: []string{p.name}}
+	// Once the Agent has described the plugin, only that version of it is used.
+	switch {
+	case p.desc.PluginVersion != "":
+		q.Set(versionParam, "="+p.desc.PluginVersion)
+	case p.constraint != "":
+		q.Set(versionParam, p.constraint)
+	}
+	u.RawQuery = q.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't reach agent(%s): %w", p.base, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("agent(%s): %w", p.base, statusErr{code: resp.StatusCode, msg: string(bytes.TrimSpace(b))})
+	}
+	return resp, nil
+}
@@ -0,0 +1,310 @@
+package remote
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/plugins/external"
+	testplugin "github.com/element-of-surprise/coercion/plugins/external/testing/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+const testToken = "secret"
+
+// newAgent starts an Agent on localhost serving the test plugin. The server must be closed.
+func newAgent(t *testing.T, options ...AgentOption) (*httptest.Server, *registry.Register) {
+	reg := registry.New()
+	reg.MustRegister(testplugin.New())
+
+	options = append([]AgentOption{WithAgentToken(testToken)}, options...)
+	agent, err := NewAgent(reg, options...)
+	if err != nil {
+		t.Fatalf("couldn't create the Agent: %s", err)
+	}
+	return httptest.NewServer(agent), reg
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newAgent(t)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		url     string
+		plugin  string
+		token   string
+		wantErr bool
+	}{
+		{
+			name:    "Error: bad token",
+			url:     srv.URL,
+			plugin:  testplugin.Name,
+			token:   "bad",
+			wantErr: true,
+		},
+		{
+			name:    "Error: agent does not have the plugin",
+			url:     srv.URL,
+			plugin:  "unknown",
+			token:   testToken,
+			wantErr: true,
+		},
+		{
+			name:    "Error: no agent",
+			url:     "http://127.0.0.1:1",
+			plugin:  testplugin.Name,
+			token:   testToken,
+			wantErr: true,
+		},
+		{
+			name:   "Success",
+			url:    srv.URL,
+			plugin: testplugin.Name,
+			token:  testToken,
+		},
+	}
+
+	for _, test := range tests {
+		p, err := New(context.Background(), test.url, test.plugin, WithToken(test.token))
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNew(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNew(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if p.Name() != testplugin.Name {
+			t.Errorf("TestNew(%s): got Name() == %q, want %q", test.name, p.Name(), testplugin.Name)
+		}
+		if p.IsCheck() {
+			t.Errorf("TestNew(%s): got IsCheck() == true, want false", test.name)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newAgent(t, WithHeartbeat(10*time.Millisecond))
+	defer srv.Close()
+
+	p, err := New(context.Background(), srv.URL, testplugin.Name, WithToken(testToken))
+	if err != nil {
+		t.Fatalf("TestExecute: couldn't create the Plugin: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		req     any
+		token   string
+		timeout time.Duration
+		want    any
+		wantErr *plugins.Error
+		// wantMsg is set if the error message is not known exactly and must contain this.
+		wantMsg string
+		// wantPermanent is the Permanent value when wantMsg is set.
+		wantPermanent bool
+	}{
+		{
+			name:    "Error: wrong request type",
+			req:     testplugin.Req{Say: "hello"},
+			wantErr: &plugins.Error{Message: "invalid request object(plugins.Req), want external.Req", Permanent: true},
+		},
+		{
+			name:          "Error: bad token",
+			req:           external.Req{"Say": "hello"},
+			token:         "bad",
+			wantMsg:       "401",
+			wantPermanent: true,
+		},
+		{
+			name:    "Error: plugin returns an error",
+			req:     external.Req{"Fail": true},
+			wantErr: &plugins.Error{Code: 2, Message: "failed", Permanent: true},
+		},
+		{
+			name:    "Error: cancelled",
+			req:     external.Req{"Wait": true},
+			timeout: 100 * time.Millisecond,
+			wantMsg: "context deadline exceeded",
+		},
+		{
+			name: "Success",
+			req:  external.Req{"Say": "hello"},
+			want: external.Resp{"Said": "hello"},
+		},
+	}
+
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, test.timeout)
+			defer cancel()
+		}
+		out := &testplugin.Output{}
+		ctx = execinfo.NewContext(ctx, execinfo.Info{Output: out})
+
+		tp := p
+		if test.token != "" {
+			c := *p
+			c.token = test.token
+			tp = &c
+		}
+
+		got, err := tp.Execute(ctx, test.req)
+		if test.wantMsg != "" {
+			if err == nil || err.Permanent != test.wantPermanent || !strings.Contains(err.Message, test.wantMsg) {
+				t.Errorf("TestExecute(%s): got err == %v, want an error containing %q with Permanent == %v", test.name, err, test.wantMsg, test.wantPermanent)
+			}
+			continue
+		}
+		if diff := pretty.Compare(test.wantErr, err); diff != "" {
+			t.Errorf("TestExecute(%s): error: -want/+got:\n%s", test.name, diff)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestExecute(%s): response: -want/+got:\n%s", test.name, diff)
+		}
+		lines, progress := out.Got()
+		if len(lines) != 1 || lines[0] != "saying hello" || progress != 100 {
+			t.Errorf("TestExecute(%s): got output %v with progress %d, want the line 'saying hello' with progress 100", test.name, lines, progress)
+		}
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	t.Parallel()
+
+	srv, _ := newAgent(t, WithHeartbeat(time.Hour))
+	defer srv.Close()
+
+	p, err := New(context.Background(), srv.URL, testplugin.Name, WithToken(testToken), WithHeartbeatTimeout(100*time.Millisecond))
+	if err != nil {
+		t.Fatalf("TestHeartbeatTimeout: couldn't create the Plugin: %s", err)
+	}
+
+	_, pErr := p.Execute(context.Background(), external.Req{"Wait": true})
+	if pErr == nil || pErr.Permanent || !strings.Contains(pErr.Message, "sent nothing") {
+		t.Fatalf("TestHeartbeatTimeout: got err == %v, want a retryable error saying the agent sent nothing", pErr)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	srv, reg := newAgent(t)
+	defer srv.Close()
+
+	p, err := New(context.Background(), srv.URL, testplugin.Name, WithToken(testToken))
+	if err != nil {
+		t.Fatalf("TestCheck: couldn't create the Plugin: %s", err)
+	}
+
+	if err := p.Init(); err != nil {
+		t.Errorf("TestCheck: got Init() err == %s, want err == nil", err)
+	}
+
+	if err := reg.Quarantine(reg.Plugin(testplugin.Name), "broken"); err != nil {
+		t.Fatalf("TestCheck: couldn't quarantine the plugin: %s", err)
+	}
+	if err := p.Check(context.Background()); err == nil {
+		t.Errorf("TestCheck: got Check() err == nil with the plugin quarantined, want err != nil")
+	}
+	if _, pErr := p.Execute(context.Background(), external.Req{"Say": "hello"}); pErr == nil || pErr.Permanent {
+		t.Errorf("TestCheck: got Execute() err == %v with the plugin quarantined, want a retryable error", pErr)
+	}
+	reg.Release(reg.Plugin(testplugin.Name))
+
+	srv.Close()
+	if err := p.Check(context.Background()); err == nil {
+		t.Errorf("TestCheck: got Check() err == nil with the agent down, want err != nil")
+	}
+}
+
+// newVersionedPlugin returns version v of a plugin that responds with its version.
+func newVersionedPlugin(v string) plugins.Plugin {
+	p, err := plugins.NewTyped(
+		plugins.TypedArgs[testplugin.Req, testplugin.Resp]{
+			Name:    "versioned",
+			Version: v,
+			Execute: func(ctx context.Context, req testplugin.Req) (testplugin.Resp, *plugins.Error) {
+				return testplugin.Resp{Said: v}, nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func TestVersion(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.MustRegister(newVersionedPlugin("1.0.0"))
+	reg.MustRegister(newVersionedPlugin("2.0.0"))
+	agent, err := NewAgent(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(agent)
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{name: "Error: no version matches", constraint: "^3.0.0", wantErr: true},
+		{name: "Latest", want: "2.0.0"},
+		{name: "Older version", constraint: "^1.0.0", want: "1.0.0"},
+	}
+
+	for _, test := range tests {
+		var options []Option
+		if test.constraint != "" {
+			options = append(options, WithVersion(test.constraint))
+		}
+		p, err := New(context.Background(), srv.URL, "versioned", options...)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestVersion(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestVersion(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if p.Version() != test.want {
+			t.Errorf("TestVersion(%s): got Version() == %q, want %q", test.name, p.Version(), test.want)
+		}
+		got, pErr := p.Execute(context.Background(), external.Req{})
+		if pErr != nil {
+			t.Errorf("TestVersion(%s): got err == %s, want err == nil", test.name, pErr)
+			continue
+		}
+		if diff := pretty.Compare(external.Resp{"Said": test.want}, got); diff != "" {
+			t.Errorf("TestVersion(%s): executed the wrong version: -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
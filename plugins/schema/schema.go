@@ -0,0 +1,326 @@
+/*
+Package schema generates JSON Schemas for the request and response types of plugins by reflection.
+
+The Schema follows how github.com/go-json-experiment/json encodes a type, which is how coercion stores
+and reads requests and responses:
+
+  - A field is named by its json tag, or by its Go name if the tag has none. Fields tagged json:"-"
+    and unexported fields are left out.
+  - Embedded structs without a json name have their fields inlined.
+  - Numbers and bools with the json:",string" option are strings.
+  - []byte is a base64 string, time.Time is an RFC 3339 string and time.Duration is a string
+    such as "1m30s".
+  - Types that implement encoding.TextMarshaler are strings. Types with their own JSON marshaling
+    can be any value.
+
+A field with the coerce:"secure" tag is marked writeOnly, as its value is hidden when a Plan is read back.
+Fields are not marked as required, because a missing field decodes to its zero value.
+
+A struct type that contains itself is put in the $defs of the root Schema and referred to with a $ref.
+*/
+package schema
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/go-json-experiment/json"
+)
+
+// Draft is the JSON Schema dialect of the Schemas from Generate().
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema. It only has the keywords that Generate() uses.
+type Schema struct {
+	// Schema is the dialect. It is only set on the root Schema.
+	Schema string `json:"$schema,omitempty"`
+	// Ref refers to a Schema in the Defs of the root Schema.
+	Ref string `json:"$ref,omitempty"`
+	// Defs holds the Schemas of struct types that contain themselves. It is only set on the root Schema.
+	Defs map[string]*Schema `json:"$defs,omitempty"`
+
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// Type is the JSON type. It is empty for any value.
+	Type            string `json:"type,omitempty"`
+	Format          string `json:"format,omitempty"`
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	Minimum         *int64 `json:"minimum,omitempty"`
+	// WriteOnly is set for fields with the coerce:"secure" tag.
+	WriteOnly bool `json:"writeOnly,omitzero"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+}
+
+// Generate returns the Schema of the type of v. v is usually the result of a plugin's Request() or Response().
+// It returns an error if v is nil or has a type that cannot be encoded as JSON, such as a func or chan.
+func Generate(v any) (*Schema, error) {
+	if v == nil {
+		return nil, fmt.Errorf("cannot generate a schema for nil")
+	}
+
+	g := &generator{inProgress: map[reflect.Type]bool{}, defs: map[string]*Schema{}}
+	t := reflect.TypeOf(v)
+	s, err := g.schema(t)
+	if err != nil {
+		return nil, err
+	}
+
+	root := *s
+	root.Schema = Draft
+	if len(g.defs) > 0 {
+		root.Defs = g.defs
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct && t.Name() != "" {
+		root.Title = t.Name()
+	}
+	return &root, nil
+}
+
+// ForPlugin returns the JSON Schemas of the request and response of p. If p implements plugins.Schemer, its
+// Schemas are used. Schemas it does not have are generated from p.Request() and p.Response().
+func ForPlugin(p plugins.Plugin) (req, resp []byte, err error) {
+	var schemerReq, schemerResp []byte
+	if schemer, ok := p.(plugins.Schemer); ok {
+		schemerReq, schemerResp = schemer.Schemas()
+	}
+
+	req, err = orGenerate(schemerReq, p.Request())
+	if err != nil {
+		return nil, nil, fmt.Errorf("plugin(%s) request: %w", p.Name(), err)
+	}
+	resp, err = orGenerate(schemerResp, p.Response())
+	if err != nil {
+		return nil, nil, fmt.Errorf("plugin(%s) response: %w", p.Name(), err)
+	}
+	return req, resp, nil
+}
+
+// orGenerate returns b if it is set, otherwise the Schema of v encoded as JSON.
+func orGenerate(b []byte, v any) ([]byte, error) {
+	if len(b) > 0 {
+		return b, nil
+	}
+	s, err := Generate(v)
+	if err != nil {
+		return nil, err
+	}
+	return s.JSON()
+}
+
+// JSON returns the Schema encoded as JSON. The encoding is deterministic.
+func (s *Schema) JSON() ([]byte, error) {
+	return json.Marshal(s, json.Deterministic(true))
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	bytesType    = reflect.TypeOf([]byte(nil))
+
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	marshalerV1   = reflect.TypeOf((*json.MarshalerV1)(nil)).Elem()
+	marshalerV2   = reflect.TypeOf((*json.MarshalerV2)(nil)).Elem()
+)
+
+// generator generates the Schema of a type. It is used once.
+type generator struct {
+	// inProgress holds the struct types whose Schema is being generated.
+	inProgress map[reflect.Type]bool
+	// defs holds the Schemas of struct types that contain themselves.
+	defs map[string]*Schema
+}
+
+func (g *generator) schema(t reflect.Type) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}, nil
+	case durationType:
+		return &Schema{Type: "string", Description: `A duration, such as "1m30s".`}, nil
+	}
+	if implements(t, marshalerV1) || implements(t, marshalerV2) {
+		return &Schema{}, nil
+	}
+	if implements(t, textMarshaler) {
+		return &Schema{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var zero int64
+		return &Schema{Type: "integer", Minimum: &zero}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}, nil
+	case reflect.String:
+		return &Schema{Type: "string"}, nil
+	case reflect.Interface:
+		return &Schema{}, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 && t.ConvertibleTo(bytesType) {
+			return &Schema{Type: "string", ContentEncoding: "base64"}, nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "array", Items: items}, nil
+	case reflect.Array:
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		n := t.Len()
+		return &Schema{Type: "array", Items: items, MinItems: &n, MaxItems: &n}, nil
+	case reflect.Map:
+		values, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: "object", AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return g.structSchema(t)
+	}
+	return nil, fmt.Errorf("type %s cannot be encoded as JSON", t)
+}
+
+// structSchema returns the Schema of a struct type. If t contains itself, a $ref to its Schema in the defs is returned
+// where it is contained.
+func (g *generator) structSchema(t reflect.Type) (*Schema, error) {
+	name := t.String()
+	if g.inProgress[t] {
+		if _, ok := g.defs[name]; !ok {
+			// Set when t is done, this reserves the name.
+			g.defs[name] = nil
+		}
+		return &Schema{Ref: "#/$defs/" + escapeRef(name)}, nil
+	}
+	g.inProgress[t] = true
+	defer delete(g.inProgress, t)
+
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	if err := g.addFields(s, t); err != nil {
+		return nil, err
+	}
+	if len(s.Properties) == 0 {
+		s.Properties = nil
+	}
+
+	if def, ok := g.defs[name]; ok && def == nil {
+		g.defs[name] = s
+	}
+	return s, nil
+}
+
+// addFields adds the fields of the struct type t to the Properties of s. The fields of embedded structs are added
+// after the fields of t, so a field of t wins over a field with the same name in an embedded struct.
+func (g *generator) addFields(s *Schema, t reflect.Type) error {
+	var inline []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && (name == "" || hasOpt(opts, "inline")) {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				inline = append(inline, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if !hasTag || name == "" {
+			name = f.Name
+		}
+		if _, ok := s.Properties[name]; ok {
+			continue
+		}
+
+		fs, err := g.schema(f.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if hasOpt(opts, "string") {
+			switch fs.Type {
+			case "integer", "number", "boolean":
+				fs = &Schema{Type: "string"}
+			}
+		}
+		if isSecure(f) {
+			fs = withWriteOnly(fs)
+		}
+		s.Properties[name] = fs
+	}
+
+	for _, it := range inline {
+		if err := g.addFields(s, it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withWriteOnly returns s marked writeOnly. A $ref is wrapped so that the Schema in the defs is not changed.
+func withWriteOnly(s *Schema) *Schema {
+	if s.Ref != "" {
+		return &Schema{Ref: s.Ref, WriteOnly: true}
+	}
+	c := *s
+	c.WriteOnly = true
+	return &c
+}
+
+// isSecure returns true if f has the coerce:"secure" tag.
+func isSecure(f reflect.StructField) bool {
+	for _, tag := range strings.Split(f.Tag.Get("coerce"), ",") {
+		if strings.TrimSpace(strings.ToLower(tag)) == "secure" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOpt(opts string, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
+
+// escapeRef escapes name for use in a JSON Pointer.
+func escapeRef(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+type Base struct {
+	ID    string
+	Owner string
+}
+
+type Node struct {
+	Name     string
+	Children []*Node
+}
+
+type Req struct {
+	Base
+	ID       int
+	Owner    string `json:"owner"`
+	Count    int    `json:"count,omitempty"`
+	Size     uint16
+	Ratio    float64
+	Enabled  bool
+	Quoted   int64  `json:",string"`
+	Password string `coerce:"secure"`
+	Skipped  string `json:"-"`
+	private  string
+	Data     []byte
+	When     time.Time
+	Wait     time.Duration
+	Addr     netip.Addr
+	Tags     []string
+	Pair     [2]int
+	Labels   map[string]int
+	Any      any
+	Ptr      *Base
+	Raw      jsontext.Value
+}
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		v       any
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "Error: nil",
+			v:       nil,
+			wantErr: true,
+		},
+		{
+			name:    "Error: func field",
+			v:       struct{ F func() }{},
+			wantErr: true,
+		},
+		{
+			name: "Success: string",
+			v:    "",
+			want: `{"$schema":"https://json-schema.org/draft/2020-12/schema","type":"string"}`,
+		},
+		{
+			name: "Success: struct",
+			v:    &Req{},
+			want: `{
+				"$schema":"https://json-schema.org/draft/2020-12/schema",
+				"title":"Req",
+				"type":"object",
+				"properties":{
+					"ID":{"type":"integer"},
+					"Owner":{"type":"string"},
+					"owner":{"type":"string"},
+					"count":{"type":"integer"},
+					"Size":{"type":"integer","minimum":0},
+					"Ratio":{"type":"number"},
+					"Enabled":{"type":"boolean"},
+					"Quoted":{"type":"string"},
+					"Password":{"type":"string","writeOnly":true},
+					"Data":{"type":"string","contentEncoding":"base64"},
+					"When":{"type":"string","format":"date-time"},
+					"Wait":{"type":"string","description":"A duration, such as \"1m30s\"."},
+					"Addr":{"type":"string"},
+					"Tags":{"type":"array","items":{"type":"string"}},
+					"Pair":{"type":"array","items":{"type":"integer"},"minItems":2,"maxItems":2},
+					"Labels":{"type":"object","additionalProperties":{"type":"integer"}},
+					"Any":{},
+					"Ptr":{"type":"object","properties":{"ID":{"type":"string"},"Owner":{"type":"string"}}},
+					"Raw":{}
+				}
+			}`,
+		},
+		{
+			name: "Success: recursive struct",
+			v:    Node{},
+			want: `{
+				"$schema":"https://json-schema.org/draft/2020-12/schema",
+				"$defs":{
+					"schema.Node":{
+						"type":"object",
+						"properties":{
+							"Name":{"type":"string"},
+							"Children":{"type":"array","items":{"$ref":"#/$defs/schema.Node"}}
+						}
+					}
+				},
+				"title":"Node",
+				"type":"object",
+				"properties":{
+					"Name":{"type":"string"},
+					"Children":{"type":"array","items":{"$ref":"#/$defs/schema.Node"}}
+				}
+			}`,
+		},
+	}
+
+	for _, test := range tests {
+		s, err := Generate(test.v)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestGenerate(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestGenerate(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		got, err := s.JSON()
+		if err != nil {
+			t.Fatalf("TestGenerate(%s): got err == %s, want err == nil", test.name, err)
+		}
+		if !equalJSON(t, got, []byte(test.want)) {
+			t.Errorf("TestGenerate(%s): got %s, want %s", test.name, got, compact(t, []byte(test.want)))
+		}
+	}
+}
+
+// equalJSON returns true if a and b are the same JSON, ignoring whitespace and the order of object members.
+func equalJSON(t *testing.T, a, b []byte) bool {
+	return compact(t, a) == compact(t, b)
+}
+
+// compact returns b without whitespace and with sorted object members.
+func compact(t *testing.T, b []byte) string {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatalf("json.Unmarshal(%s): %s", b, err)
+	}
+	out, err := json.Marshal(v, json.Deterministic(true))
+	if err != nil {
+		t.Fatalf("json.Marshal(): %s", err)
+	}
+	return string(out)
+}
@@ -0,0 +1,172 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/gostdlib/ops/retry/exponential"
+)
+
+// TypedArgs are the arguments to NewTyped().
+type TypedArgs[Req, Resp any] struct {
+	// Name is the name of the plugin. This must be unique in the registry.
+	// The name should include the package path to avoid name collisions.
+	Name string
+	// Version is the semantic version of the plugin. See Versioner. Optional.
+	Version string
+	// Execute executes the plugin. Required.
+	Execute func(ctx context.Context, req Req) (Resp, *Error)
+	// Validate validates the request. Optional.
+	Validate func(req Req) error
+	// IsCheck indicates the plugin is a check plugin. See Plugin.IsCheck().
+	IsCheck bool
+	// RetryPolicy is the retry policy for the plugin. If not set, FastRetryPolicy() is used.
+	RetryPolicy exponential.Policy
+	// Init is run after the registry is loaded. See Plugin.Init(). Optional.
+	Init func() error
+}
+
+// Typed is a Plugin built from a typed Execute function with NewTyped(). It handles the conversion
+// of the request to Req, so the function never deals with an any.
+//
+// Req and Resp may be a struct or a pointer to a struct. If either is a pointer, Request() and Response()
+// return a pointer to a new zero value, which is what storage decodes into. Execute() and ValidateReq()
+// accept a request that is either Req or the value or pointer form of it.
+type Typed[Req, Resp any] struct {
+	args TypedArgs[Req, Resp]
+}
+
+var (
+	_ Plugin    = (*Typed[struct{}, struct{}])(nil)
+	_ Versioner = (*Typed[struct{}, struct{}])(nil)
+)
+
+// NewTyped creates a new Typed plugin.
+func NewTyped[Req, Resp any](args TypedArgs[Req, Resp]) (*Typed[Req, Resp], error) {
+	if args.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if args.Execute == nil {
+		return nil, errors.New("execute is required")
+	}
+	if reflect.TypeFor[Req]().Kind() == reflect.Interface {
+		return nil, errors.New("request type cannot be an interface")
+	}
+	if reflect.TypeFor[Resp]().Kind() == reflect.Interface {
+		return nil, errors.New("response type cannot be an interface")
+	}
+	if args.RetryPolicy == (exponential.Policy{}) {
+		args.RetryPolicy = FastRetryPolicy()
+	}
+	return &Typed[Req, Resp]{args: args}, nil
+}
+
+// Name implements Plugin.Name().
+func (t *Typed[Req, Resp]) Name() string {
+	return t.args.Name
+}
+
+// Version implements Versioner.Version().
+func (t *Typed[Req, Resp]) Version() string {
+	return t.args.Version
+}
+
+// Execute implements Plugin.Execute(). The request is converted to Req and validated before
+// the Execute function is called.
+func (t *Typed[Req, Resp]) Execute(ctx context.Context, req any) (any, *Error) {
+	r, err := t.convert(req)
+	if err != nil {
+		return nil, &Error{Message: err.Error(), Permanent: true}
+	}
+	if t.args.Validate != nil {
+		if err := t.args.Validate(r); err != nil {
+			return nil, &Error{Message: err.Error(), Permanent: true}
+		}
+	}
+
+	resp, pErr := t.args.Execute(ctx, r)
+	if pErr != nil {
+		return nil, pErr
+	}
+	return resp, nil
+}
+
+// ValidateReq implements Plugin.ValidateReq().
+func (t *Typed[Req, Resp]) ValidateReq(req any) error {
+	r, err := t.convert(req)
+	if err != nil {
+		return err
+	}
+	if t.args.Validate != nil {
+		return t.args.Validate(r)
+	}
+	return nil
+}
+
+// Request implements Plugin.Request().
+func (t *Typed[Req, Resp]) Request() any {
+	return zero[Req]()
+}
+
+// Response implements Plugin.Response().
+func (t *Typed[Req, Resp]) Response() any {
+	return zero[Resp]()
+}
+
+// IsCheck implements Plugin.IsCheck().
+func (t *Typed[Req, Resp]) IsCheck() bool {
+	return t.args.IsCheck
+}
+
+// RetryPolicy implements Plugin.RetryPolicy().
+func (t *Typed[Req, Resp]) RetryPolicy() exponential.Policy {
+	return t.args.RetryPolicy
+}
+
+// Init implements Plugin.Init().
+func (t *Typed[Req, Resp]) Init() error {
+	if t.args.Init != nil {
+		return t.args.Init()
+	}
+	return nil
+}
+
+// convert converts req to Req. req can be a Req or, if Req is a pointer, the value it points to.
+// If Req is not a pointer, req can be a pointer to a Req.
+func (t *Typed[Req, Resp]) convert(req any) (Req, error) {
+	var r Req
+	want := reflect.TypeFor[Req]()
+
+	v := reflect.ValueOf(req)
+	switch {
+	case !v.IsValid():
+	case v.Type() == want:
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return r, fmt.Errorf("request is a nil %T", req)
+		}
+		return req.(Req), nil
+	case want.Kind() == reflect.Pointer && v.Type() == want.Elem():
+		p := reflect.New(want.Elem())
+		p.Elem().Set(v)
+		return p.Interface().(Req), nil
+	case v.Kind() == reflect.Pointer && v.Type().Elem() == want:
+		if v.IsNil() {
+			return r, fmt.Errorf("request is a nil %T", req)
+		}
+		return v.Elem().Interface().(Req), nil
+	}
+	return r, fmt.Errorf("invalid request object(%T), want %T", req, zero[Req]())
+}
+
+// zero returns the zero value of T. If T is a pointer, it returns a pointer to the zero value
+// of what T points to, so that it can be decoded into.
+func zero[T any]() any {
+	var v T
+	rt := reflect.TypeFor[T]()
+	if rt.Kind() == reflect.Pointer {
+		return reflect.New(rt.Elem()).Interface()
+	}
+	return v
+}
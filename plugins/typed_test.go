@@ -0,0 +1,242 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type typedReq struct {
+	Say string
+}
+
+type typedResp struct {
+	Said string
+}
+
+func validateTypedReq(r typedReq) error {
+	if r.Say == "" {
+		return errors.New("Say is empty")
+	}
+	return nil
+}
+
+func TestNewTyped(t *testing.T) {
+	t.Parallel()
+
+	exec := func(ctx context.Context, req typedReq) (typedResp, *Error) {
+		return typedResp{}, nil
+	}
+
+	tests := []struct {
+		name    string
+		args    TypedArgs[typedReq, typedResp]
+		wantErr bool
+	}{
+		{
+			name:    "Error: no name",
+			args:    TypedArgs[typedReq, typedResp]{Execute: exec},
+			wantErr: true,
+		},
+		{
+			name:    "Error: no Execute",
+			args:    TypedArgs[typedReq, typedResp]{Name: "typed"},
+			wantErr: true,
+		},
+		{
+			name: "Success",
+			args: TypedArgs[typedReq, typedResp]{Name: "typed", Execute: exec},
+		},
+	}
+
+	for _, test := range tests {
+		p, err := NewTyped(test.args)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestNewTyped(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestNewTyped(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+
+		if diff := pretty.Compare(FastRetryPolicy(), p.RetryPolicy()); diff != "" {
+			t.Errorf("TestNewTyped(%s): RetryPolicy() did not default to FastRetryPolicy(): -want/+got:\n%s", test.name, diff)
+		}
+	}
+
+	if _, err := NewTyped(TypedArgs[any, typedResp]{Name: "typed", Execute: func(context.Context, any) (typedResp, *Error) { return typedResp{}, nil }}); err == nil {
+		t.Errorf("TestNewTyped(interface request): got err == nil, want err != nil")
+	}
+}
+
+func TestTypedValue(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewTyped(
+		TypedArgs[typedReq, typedResp]{
+			Name: "typed",
+			Execute: func(ctx context.Context, req typedReq) (typedResp, *Error) {
+				return typedResp{Said: req.Say}, nil
+			},
+			Validate: validateTypedReq,
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, ok := p.Request().(typedReq); !ok {
+		t.Errorf("TestTypedValue: Request() returned %T, want typedReq", p.Request())
+	}
+	if _, ok := p.Response().(typedResp); !ok {
+		t.Errorf("TestTypedValue: Response() returned %T, want typedResp", p.Response())
+	}
+
+	tests := []struct {
+		name     string
+		req      any
+		want     any
+		wantErr  bool
+		validErr bool
+	}{
+		{
+			name:     "Error: wrong type",
+			req:      "hello",
+			wantErr:  true,
+			validErr: true,
+		},
+		{
+			name:     "Error: nil",
+			wantErr:  true,
+			validErr: true,
+		},
+		{
+			name:     "Error: nil pointer",
+			req:      (*typedReq)(nil),
+			wantErr:  true,
+			validErr: true,
+		},
+		{
+			name:     "Error: fails validation",
+			req:      typedReq{},
+			wantErr:  true,
+			validErr: true,
+		},
+		{
+			name: "Success: value",
+			req:  typedReq{Say: "hello"},
+			want: typedResp{Said: "hello"},
+		},
+		{
+			name: "Success: pointer",
+			req:  &typedReq{Say: "hello"},
+			want: typedResp{Said: "hello"},
+		},
+	}
+
+	for _, test := range tests {
+		if err := p.ValidateReq(test.req); (err != nil) != test.validErr {
+			t.Errorf("TestTypedValue(%s): got ValidateReq() err == %v, want err != nil == %v", test.name, err, test.validErr)
+		}
+
+		got, pErr := p.Execute(context.Background(), test.req)
+		switch {
+		case pErr == nil && test.wantErr:
+			t.Errorf("TestTypedValue(%s): got err == nil, want err != nil", test.name)
+			continue
+		case pErr != nil && !test.wantErr:
+			t.Errorf("TestTypedValue(%s): got err == %s, want err == nil", test.name, pErr)
+			continue
+		case pErr != nil:
+			if !pErr.Permanent {
+				t.Errorf("TestTypedValue(%s): got a non-permanent error, want permanent", test.name)
+			}
+			continue
+		}
+
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestTypedValue(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestTypedPointer(t *testing.T) {
+	t.Parallel()
+
+	p, err := NewTyped(
+		TypedArgs[*typedReq, *typedResp]{
+			Name: "typed",
+			Execute: func(ctx context.Context, req *typedReq) (*typedResp, *Error) {
+				if req.Say == "error" {
+					return nil, &Error{Message: "error"}
+				}
+				return &typedResp{Said: req.Say}, nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	// A pointer request or response must not be nil, as it is decoded into.
+	if r, ok := p.Request().(*typedReq); !ok || r == nil {
+		t.Errorf("TestTypedPointer: Request() returned %#v, want a non-nil *typedReq", p.Request())
+	}
+	if r, ok := p.Response().(*typedResp); !ok || r == nil {
+		t.Errorf("TestTypedPointer: Response() returned %#v, want a non-nil *typedResp", p.Response())
+	}
+
+	tests := []struct {
+		name    string
+		req     any
+		want    any
+		wantErr bool
+	}{
+		{
+			name:    "Error: nil pointer",
+			req:     (*typedReq)(nil),
+			wantErr: true,
+		},
+		{
+			name:    "Error: Execute returns an error",
+			req:     &typedReq{Say: "error"},
+			wantErr: true,
+		},
+		{
+			name: "Success: pointer",
+			req:  &typedReq{Say: "hello"},
+			want: &typedResp{Said: "hello"},
+		},
+		{
+			name: "Success: value",
+			req:  typedReq{Say: "hello"},
+			want: &typedResp{Said: "hello"},
+		},
+	}
+
+	for _, test := range tests {
+		got, pErr := p.Execute(context.Background(), test.req)
+		switch {
+		case pErr == nil && test.wantErr:
+			t.Errorf("TestTypedPointer(%s): got err == nil, want err != nil", test.name)
+			continue
+		case pErr != nil && !test.wantErr:
+			t.Errorf("TestTypedPointer(%s): got err == %s, want err == nil", test.name, pErr)
+			continue
+		case pErr != nil:
+			if got != nil {
+				t.Errorf("TestTypedPointer(%s): got response %#v with an error, want nil", test.name, got)
+			}
+			continue
+		}
+
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestTypedPointer(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
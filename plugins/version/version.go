@@ -0,0 +1,262 @@
+/*
+Package version provides semantic versions for plugins and constraints that select them.
+
+A Version is written as MAJOR.MINOR.PATCH with an optional pre-release, such as "1.2.0" or "2.0.0-beta.1".
+A leading "v" and build metadata after a "+" are accepted and ignored.
+
+A Constraint is a list of comparisons that must all be true, separated by spaces. Lists can be joined
+with "||", where any list being true is enough:
+
+	1.2.3            Exactly 1.2.3, the same as "=1.2.3".
+	>=1.2.0 <2.0.0   At least 1.2.0 and before 2.0.0.
+	^1.2.0           Compatible with 1.2.0, the same as ">=1.2.0 <2.0.0".
+	~1.2.0           Patches of 1.2.0, the same as ">=1.2.0 <1.3.0".
+	^1.0.0 || ^2.0.0 Either major version 1 or 2.
+
+The operators are =, !=, >, >=, <, <=, ^ and ~. A pre-release Version only matches a list that has a comparison
+with a pre-release of the same MAJOR.MINOR.PATCH, so "^1.0.0" does not match "2.0.0-beta.1" or "1.1.0-rc.1".
+*/
+package version
+
+import (
+	"cmp"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a semantic version.
+type Version struct {
+	Major, Minor, Patch uint64
+	// Pre is the pre-release, without the leading "-". A Version with a Pre is before the
+	// same Version without one.
+	Pre string
+}
+
+// Parse parses a Version from s.
+func Parse(s string) (Version, error) {
+	orig := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	v := Version{}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		s, v.Pre = s[:i], s[i+1:]
+		if v.Pre == "" {
+			return Version{}, fmt.Errorf("version %q has an empty pre-release", orig)
+		}
+		for _, id := range strings.Split(v.Pre, ".") {
+			if id == "" {
+				return Version{}, fmt.Errorf("version %q has an empty pre-release identifier", orig)
+			}
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("version %q must be MAJOR.MINOR.PATCH", orig)
+	}
+	nums := make([]uint64, 3)
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil || (len(p) > 1 && p[0] == '0') {
+			return Version{}, fmt.Errorf("version %q has a bad number %q", orig, p)
+		}
+		nums[i] = n
+	}
+	v.Major, v.Minor, v.Patch = nums[0], nums[1], nums[2]
+	return v, nil
+}
+
+// MustParse is like Parse, but panics on an error.
+func MustParse(s string) Version {
+	v, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String implements fmt.Stringer.
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	return s
+}
+
+// Compare returns -1 if v is before o, 0 if they are equal and 1 if v is after o.
+func (v Version) Compare(o Version) int {
+	if c := cmp.Compare(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+	return comparePre(v.Pre, o.Pre)
+}
+
+// comparePre compares pre-releases with the precedence rules of semantic versioning.
+func comparePre(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		var c int
+		switch {
+		case aErr == nil && bErr == nil:
+			c = cmp.Compare(an, bn)
+		case aErr == nil:
+			// Numeric identifiers are before alphanumeric ones.
+			c = -1
+		case bErr == nil:
+			c = 1
+		default:
+			c = strings.Compare(as[i], bs[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// Constraint selects Versions. The zero value matches all Versions.
+type Constraint struct {
+	s string
+	// any holds lists of comparisons. A Version matches if all comparisons in any list match.
+	any [][]comparison
+}
+
+type comparison struct {
+	op string
+	v  Version
+}
+
+// ParseConstraint parses a Constraint from s. An empty s matches all Versions.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{s: strings.TrimSpace(s)}
+	if c.s == "" {
+		return c, nil
+	}
+
+	for _, list := range strings.Split(c.s, "||") {
+		fields := strings.Fields(list)
+		if len(fields) == 0 {
+			return Constraint{}, fmt.Errorf("constraint %q has an empty list", s)
+		}
+		var all []comparison
+		for _, f := range fields {
+			comps, err := parseComparison(f)
+			if err != nil {
+				return Constraint{}, fmt.Errorf("constraint %q: %w", s, err)
+			}
+			all = append(all, comps...)
+		}
+		c.any = append(c.any, all)
+	}
+	return c, nil
+}
+
+// parseComparison parses a single comparison. ^ and ~ become two comparisons.
+func parseComparison(s string) ([]comparison, error) {
+	op := ""
+	for _, o := range []string{">=", "<=", "!=", "=", ">", "<", "^", "~"} {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	v, err := Parse(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "":
+		return []comparison{{op: "=", v: v}}, nil
+	case "^":
+		upper := Version{Major: v.Major + 1}
+		switch {
+		case v.Major == 0 && v.Minor == 0:
+			upper = Version{Patch: v.Patch + 1}
+		case v.Major == 0:
+			upper = Version{Minor: v.Minor + 1}
+		}
+		return []comparison{{op: ">=", v: v}, {op: "<", v: upper}}, nil
+	case "~":
+		return []comparison{{op: ">=", v: v}, {op: "<", v: Version{Major: v.Major, Minor: v.Minor + 1}}}, nil
+	}
+	return []comparison{{op: op, v: v}}, nil
+}
+
+// Check returns true if v matches the Constraint.
+func (c Constraint) Check(v Version) bool {
+	if len(c.any) == 0 {
+		return true
+	}
+	for _, all := range c.any {
+		if matchAll(all, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAll returns true if v matches all comparisons. See the package doc for pre-releases.
+func matchAll(all []comparison, v Version) bool {
+	ok := v.Pre == ""
+	for _, comp := range all {
+		if !comp.match(v) {
+			return false
+		}
+		if comp.v.Pre != "" && comp.v.Major == v.Major && comp.v.Minor == v.Minor && comp.v.Patch == v.Patch {
+			ok = true
+		}
+	}
+	return ok
+}
+
+func (c comparison) match(v Version) bool {
+	r := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return r == 0
+	case "!=":
+		return r != 0
+	case ">":
+		return r > 0
+	case ">=":
+		return r >= 0
+	case "<":
+		return r < 0
+	case "<=":
+		return r <= 0
+	}
+	return false
+}
+
+// IsZero returns true if the Constraint matches all Versions.
+func (c Constraint) IsZero() bool {
+	return len(c.any) == 0
+}
+
+// String implements fmt.Stringer. This is the string the Constraint was parsed from.
+func (c Constraint) String() string {
+	return c.s
+}
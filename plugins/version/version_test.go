@@ -0,0 +1,183 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/kylelemons/godebug/pretty"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		s       string
+		want    Version
+		wantErr bool
+	}{
+		{name: "Error: empty", s: "", wantErr: true},
+		{name: "Error: missing patch", s: "1.2", wantErr: true},
+		{name: "Error: not a number", s: "1.a.3", wantErr: true},
+		{name: "Error: leading zero", s: "1.02.3", wantErr: true},
+		{name: "Error: empty pre-release", s: "1.2.3-", wantErr: true},
+		{name: "Error: empty pre-release identifier", s: "1.2.3-beta..1", wantErr: true},
+		{name: "Success", s: "1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{name: "Success: leading v", s: "v1.2.3", want: Version{Major: 1, Minor: 2, Patch: 3}},
+		{name: "Success: pre-release", s: "1.2.3-beta.1", want: Version{Major: 1, Minor: 2, Patch: 3, Pre: "beta.1"}},
+		{name: "Success: build metadata", s: "1.2.3+abc", want: Version{Major: 1, Minor: 2, Patch: 3}},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.s)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestParse(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestParse(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestParse(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	// In order from first to last, as in the semantic versioning spec.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.2.0",
+		"1.10.0",
+		"2.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := MustParse(ordered[i]).Compare(MustParse(ordered[j])); got != want {
+				t.Errorf("TestCompare(%s, %s): got %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		constraint string
+		match      []string
+		noMatch    []string
+		wantErr    bool
+	}{
+		{name: "Error: bad version", constraint: ">=1.2", wantErr: true},
+		{name: "Error: bad operator", constraint: "=>1.2.0", wantErr: true},
+		{name: "Error: empty list", constraint: "1.2.0 ||", wantErr: true},
+		{
+			name:    "Empty",
+			match:   []string{"0.0.1", "1.2.0", "2.0.0-beta.1"},
+			noMatch: nil,
+		},
+		{
+			name:       "Exact",
+			constraint: "1.2.0",
+			match:      []string{"1.2.0"},
+			noMatch:    []string{"1.2.1", "1.2.0-rc.1"},
+		},
+		{
+			name:       "Not equal",
+			constraint: "!=1.2.0",
+			match:      []string{"1.2.1", "0.1.0"},
+			noMatch:    []string{"1.2.0"},
+		},
+		{
+			name:       "Range",
+			constraint: ">1.0.0 <=2.0.0",
+			match:      []string{"1.0.1", "2.0.0"},
+			noMatch:    []string{"1.0.0", "2.0.1", "2.0.0-rc.1"},
+		},
+		{
+			name:       "Caret",
+			constraint: "^1.2.0",
+			match:      []string{"1.2.0", "1.9.9"},
+			noMatch:    []string{"1.1.9", "2.0.0", "2.0.0-beta.1", "1.3.0-rc.1"},
+		},
+		{
+			name:       "Caret: major 0",
+			constraint: "^0.2.3",
+			match:      []string{"0.2.3", "0.2.9"},
+			noMatch:    []string{"0.3.0"},
+		},
+		{
+			name:       "Caret: major and minor 0",
+			constraint: "^0.0.3",
+			match:      []string{"0.0.3"},
+			noMatch:    []string{"0.0.4"},
+		},
+		{
+			name:       "Tilde",
+			constraint: "~1.2.0",
+			match:      []string{"1.2.0", "1.2.9"},
+			noMatch:    []string{"1.3.0"},
+		},
+		{
+			name:       "Pre-release",
+			constraint: ">=2.0.0-beta.1",
+			match:      []string{"2.0.0-beta.2", "2.0.0", "3.0.0"},
+			noMatch:    []string{"2.0.0-alpha", "3.0.0-beta.1"},
+		},
+		{
+			name:       "Or",
+			constraint: "^1.0.0 || ^3.0.0",
+			match:      []string{"1.5.0", "3.1.0"},
+			noMatch:    []string{"2.0.0"},
+		},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConstraint(test.constraint)
+		switch {
+		case err == nil && test.wantErr:
+			t.Errorf("TestConstraint(%s): got err == nil, want err != nil", test.name)
+			continue
+		case err != nil && !test.wantErr:
+			t.Errorf("TestConstraint(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		case err != nil:
+			continue
+		}
+		if c.String() != test.constraint {
+			t.Errorf("TestConstraint(%s): got String() == %q, want %q", test.name, c.String(), test.constraint)
+		}
+		for _, v := range test.match {
+			if !c.Check(MustParse(v)) {
+				t.Errorf("TestConstraint(%s): got Check(%s) == false, want true", test.name, v)
+			}
+		}
+		for _, v := range test.noMatch {
+			if c.Check(MustParse(v)) {
+				t.Errorf("TestConstraint(%s): got Check(%s) == true, want false", test.name, v)
+			}
+		}
+	}
+}
@@ -83,6 +83,22 @@ func WithGroupID(id uuid.UUID) Option {
 	}
 }
 
+// WithMaxDuration sets the maximum amount of time the Plan may run.
+func WithMaxDuration(d time.Duration) Option {
+	return func(b *BuildPlan) error {
+		if b.emitted {
+			return errors.New("cannot call WithMaxDuration() after Plan() has been called")
+		}
+
+		if d < 0 {
+			return errors.New("max duration must not be negative")
+		}
+
+		b.current().(*workflow.Plan).MaxDuration = d
+		return nil
+	}
+}
+
 // New creates a new BuildPlan with the internal Plan object having the given
 // name and description.
 func New(name, descr string, options ...Option) (*BuildPlan, error) {
@@ -178,7 +194,7 @@ func (b *BuildPlan) Up() *BuildPlan {
 	return b
 }
 
-// ChecksType is the check type you are adding to a Plan or Block.
+// ChecksType is the check type you are adding to a Plan, Block or Sequence.
 type ChecksType int
 
 const (
@@ -193,7 +209,7 @@ const (
 	PostChecks ChecksType = 3
 )
 
-// AddChecks adds a check to the current Plan or Block. This moves you into the check.
+// AddChecks adds a check to the current Plan, Block or Sequence. This moves you into the check.
 // If at any other level of the plan hierarchy, AddChecks will return an error.
 func (b *BuildPlan) AddChecks(cType ChecksType, check *workflow.Checks) *BuildPlan {
 	if b.emitted {
@@ -271,8 +287,35 @@ func (b *BuildPlan) AddChecks(cType ChecksType, check *workflow.Checks) *BuildPl
 			b.setErr(errors.New("unknown check type"))
 			return b
 		}
+	case *workflow.Sequence:
+		switch cType {
+		case PreChecks:
+			if t.PreChecks != nil {
+				b.setErr(errors.New("cannot add PreCheck to Sequence with existing PreChecks"))
+				return b
+			}
+			t.PreChecks = check
+			b.chain = append(b.chain, check)
+		case ContChecks:
+			if t.ContChecks != nil {
+				b.setErr(errors.New("cannot add ContCheck to Sequence with existing ContChecks"))
+				return b
+			}
+			t.ContChecks = check
+			b.chain = append(b.chain, check)
+		case PostChecks:
+			if t.PostChecks != nil {
+				b.setErr(errors.New("cannot add PostCheck to Sequence with existing PostChecks"))
+				return b
+			}
+			t.PostChecks = check
+			b.chain = append(b.chain, check)
+		default:
+			b.setErr(errors.New("unknown check type"))
+			return b
+		}
 	default:
-		b.setErr(fmt.Errorf("cannot add checks to a non-Plan or non-Block object(%T)", t))
+		b.setErr(fmt.Errorf("cannot add checks to a non-Plan, non-Block or non-Sequence object(%T)", t))
 		return b
 	}
 	return b
@@ -280,11 +323,11 @@ func (b *BuildPlan) AddChecks(cType ChecksType, check *workflow.Checks) *BuildPl
 
 // BlockArgs are arguments for AddBlock that define a Block in the Plan.
 type BlockArgs struct {
-	Name                     string
-	Descr                    string
-	EntranceDelay, ExitDelay time.Duration
-	Concurrency              int
-	ToleratedFailures        int
+	Name                                  string
+	Descr                                 string
+	EntranceDelay, ExitDelay, MaxDuration time.Duration
+	Concurrency                           int
+	ToleratedFailures                     int
 }
 
 // AddBlock adds a Block to the current workflow Plan. If at any other level of the plan hierarchy,
@@ -314,6 +357,7 @@ func (b *BuildPlan) AddBlock(args BlockArgs) *BuildPlan {
 			Descr:             args.Descr,
 			EntranceDelay:     args.EntranceDelay,
 			ExitDelay:         args.ExitDelay,
+			MaxDuration:       args.MaxDuration,
 			Concurrency:       args.Concurrency,
 			ToleratedFailures: args.ToleratedFailures,
 		}
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"testing"
+	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
 
@@ -153,6 +154,9 @@ func TestAddChecks(t *testing.T) {
 	wantBlock := &workflow.Block{
 		ContChecks: wantContChecks,
 	}
+	wantSeq := &workflow.Sequence{
+		ContChecks: wantContChecks,
+	}
 
 	tests := []struct {
 		name   string
@@ -173,7 +177,7 @@ func TestAddChecks(t *testing.T) {
 			err:  true,
 		},
 		{
-			name: "Error: current() is not a Plan or Block",
+			name: "Error: current() is not a Plan, Block or Sequence",
 			bp: func() *BuildPlan {
 				return &BuildPlan{chain: []any{&workflow.Action{}}}
 			},
@@ -217,6 +221,32 @@ func TestAddChecks(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Success: Sequence",
+			bp: func() *BuildPlan {
+				seq := &workflow.Sequence{}
+				block := &workflow.Block{Sequences: []*workflow.Sequence{seq}}
+				return &BuildPlan{
+					chain: []any{
+						&workflow.Plan{
+							Blocks: []*workflow.Block{block},
+						},
+						block,
+						seq,
+					},
+				}
+			},
+			checks: &workflow.Checks{Actions: []*workflow.Action{{}}},
+			want: &BuildPlan{
+				chain: []any{
+					&workflow.Plan{
+						Blocks: []*workflow.Block{{Sequences: []*workflow.Sequence{wantSeq}}},
+					},
+					&workflow.Block{Sequences: []*workflow.Sequence{wantSeq}},
+					wantSeq,
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -264,6 +294,10 @@ func TestAddPrePostChecks(t *testing.T) {
 	builder.AddBlock(BlockArgs{Name: "test", Descr: "test", Concurrency: 1})
 	builder.AddChecks(PreChecks, wantCheck0).Up()
 	builder.AddChecks(PostChecks, wantCheck1).Up()
+	builder.AddSequence(&workflow.Sequence{Name: "test", Descr: "test"})
+	builder.AddChecks(PreChecks, wantCheck0).Up()
+	builder.AddChecks(PostChecks, wantCheck1).Up()
+	builder.AddAction(&workflow.Action{Name: "action", Descr: "action", Plugin: "plugin"})
 
 	got, err := builder.Plan()
 	if err != nil {
@@ -282,17 +316,24 @@ func TestAddPrePostChecks(t *testing.T) {
 	if got.Blocks[0].PostChecks.Actions[0].Name != "check1" {
 		t.Errorf("TestAddPrePostChecks(Block.PostChecks): got %s, want check1", got.Blocks[0].PostChecks.Actions[0].Name)
 	}
+	if got.Blocks[0].Sequences[0].PreChecks.Actions[0].Name != "check0" {
+		t.Errorf("TestAddPrePostChecks(Sequence.PreChecks): got %s, want check0", got.Blocks[0].Sequences[0].PreChecks.Actions[0].Name)
+	}
+	if got.Blocks[0].Sequences[0].PostChecks.Actions[0].Name != "check1" {
+		t.Errorf("TestAddPrePostChecks(Sequence.PostChecks): got %s, want check1", got.Blocks[0].Sequences[0].PostChecks.Actions[0].Name)
+	}
 }
 
 func TestAddBlock(t *testing.T) {
 	t.Parallel()
 
 	goodArgs := BlockArgs{
-		Name:  "test",
-		Descr: "test",
+		Name:        "test",
+		Descr:       "test",
+		MaxDuration: time.Minute,
 	}
 
-	wantBlock := &workflow.Block{Name: "test", Descr: "test"}
+	wantBlock := &workflow.Block{Name: "test", Descr: "test", MaxDuration: time.Minute}
 
 	tests := []struct {
 		name string
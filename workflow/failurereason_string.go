@@ -14,6 +14,8 @@ func _() {
 	_ = x[FRPostCheck-300]
 	_ = x[FRContCheck-400]
 	_ = x[FRStopped-500]
+	_ = x[FRTimeout-600]
+	_ = x[FRStorage-700]
 }
 
 const (
@@ -23,6 +25,8 @@ const (
 	_FailureReason_name_3 = "FRPostCheck"
 	_FailureReason_name_4 = "FRContCheck"
 	_FailureReason_name_5 = "FRStopped"
+	_FailureReason_name_6 = "FRTimeout"
+	_FailureReason_name_7 = "FRStorage"
 )
 
 func (i FailureReason) String() string {
@@ -39,6 +43,10 @@ func (i FailureReason) String() string {
 		return _FailureReason_name_4
 	case i == 500:
 		return _FailureReason_name_5
+	case i == 600:
+		return _FailureReason_name_6
+	case i == 700:
+		return _FailureReason_name_7
 	default:
 		return "FailureReason(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
@@ -0,0 +1,62 @@
+package workflow
+
+import (
+	"github.com/element-of-surprise/coercion/plugins/registry"
+
+	"github.com/go-json-experiment/json"
+)
+
+// secretHidden replaces the values of secrets in a RawJSON.
+const secretHidden = "[secret hidden]"
+
+// RawJSON is a request or response that storage could not decode into the type of the plugin, because the
+// plugin is not registered or the stored JSON doesn't fit the plugin's type and there is no plugins.Migration
+// for it. It holds the stored JSON so that the Plan can still be read, such as to render reports.
+// It is encoded as the stored JSON.
+type RawJSON struct {
+	// JSON is the stored JSON.
+	JSON []byte
+	// Reason is why it could not be decoded.
+	Reason string
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r RawJSON) MarshalJSON() ([]byte, error) {
+	if len(r.JSON) == 0 {
+		return []byte("null"), nil
+	}
+	return r.JSON, nil
+}
+
+// Redacted returns a copy of r where the values of object members with names that may be secrets are replaced.
+// RawJSON has no struct tags, so this is done in place of the coerce:"secure" tag. See registry.IsSecretName().
+func (r RawJSON) Redacted() RawJSON {
+	var v any
+	if err := json.Unmarshal(r.JSON, &v); err != nil {
+		return RawJSON{Reason: r.Reason}
+	}
+	b, err := json.Marshal(redact(v), json.Deterministic(true))
+	if err != nil {
+		return RawJSON{Reason: r.Reason}
+	}
+	return RawJSON{JSON: b, Reason: r.Reason}
+}
+
+// redact replaces the values of members of objects in v with names that may be secrets.
+func redact(v any) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			if registry.IsSecretName(k) {
+				x[k] = secretHidden
+				continue
+			}
+			x[k] = redact(val)
+		}
+	case []any:
+		for i, val := range x {
+			x[i] = redact(val)
+		}
+	}
+	return v
+}
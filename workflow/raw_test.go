@@ -0,0 +1,37 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/go-json-experiment/json"
+)
+
+func TestRawJSON(t *testing.T) {
+	t.Parallel()
+
+	raw := RawJSON{
+		JSON:   []byte(`{"User":"me","Password":"secret","Nested":[{"APIKey":"key","Name":"name"}]}`),
+		Reason: "plugin not found",
+	}
+
+	b, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("TestRawJSON(marshal): got err == %s, want err == nil", err)
+	}
+	if string(b) != string(raw.JSON) {
+		t.Errorf("TestRawJSON(marshal): got %s, want %s", b, raw.JSON)
+	}
+
+	got := raw.Redacted()
+	want := `{"Nested":[{"APIKey":"[secret hidden]","Name":"name"}],"Password":"[secret hidden]","User":"me"}`
+	if string(got.JSON) != want {
+		t.Errorf("TestRawJSON(redacted): got %s, want %s", got.JSON, want)
+	}
+	if got.Reason != raw.Reason {
+		t.Errorf("TestRawJSON(redacted): got Reason %q, want %q", got.Reason, raw.Reason)
+	}
+
+	if got := (RawJSON{JSON: []byte("{")}).Redacted(); got.JSON != nil {
+		t.Errorf("TestRawJSON(redacted invalid JSON): got %s, want nil", got.JSON)
+	}
+}
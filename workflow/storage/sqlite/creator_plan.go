@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/workflow"
 
 	"github.com/go-json-experiment/json"
@@ -21,6 +20,7 @@ const insertPlan = `
 		name,
 		descr,
 		meta,
+		maxduration,
 		prechecks,
 		postchecks,
 		contchecks,
@@ -30,7 +30,7 @@ const insertPlan = `
 		state_end,
 		submit_time,
 		reason
-	) VALUES ($id, $group_id, $name, $descr, $meta, $prechecks, $postchecks, $contchecks, $blocks,
+	) VALUES ($id, $group_id, $name, $descr, $meta, $maxduration, $prechecks, $postchecks, $contchecks, $blocks,
 	$state_status, $state_start, $state_end, $submit_time, $reason)`
 
 var zeroTime = time.Unix(0, 0)
@@ -53,6 +53,7 @@ func commitPlan(ctx context.Context, conn *sqlite.Conn, p *workflow.Plan) (err e
 	stmt.SetText("$name", p.Name)
 	stmt.SetText("$descr", p.Descr)
 	stmt.SetBytes("$meta", p.Meta)
+	stmt.SetInt64("$maxduration", int64(p.MaxDuration))
 	if p.PreChecks != nil {
 		stmt.SetText("$prechecks", p.PreChecks.ID.String())
 	}
@@ -107,10 +108,14 @@ const insertChecks = `
 		plan_id,
 		actions,
 		delay,
+		tolerance,
+		retention,
+		gate,
+		quorum,
 		state_status,
 		state_start,
 		state_end
-	) VALUES ($id, $plan_id, $actions, $delay,
+	) VALUES ($id, $plan_id, $actions, $delay, $tolerance, $retention, $gate, $quorum,
 	$state_status, $state_start, $state_end)`
 
 func commitChecks(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, checks *workflow.Checks) error {
@@ -132,6 +137,28 @@ func commitChecks(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, chec
 	stmt.SetText("$plan_id", planID.String())
 	stmt.SetBytes("$actions", actions)
 	stmt.SetInt64("$delay", int64(checks.Delay))
+	if checks.Tolerance != nil {
+		b, err := json.Marshal(checks.Tolerance)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(tolerance): %w", err)
+		}
+		stmt.SetBytes("$tolerance", b)
+	}
+	stmt.SetInt64("$retention", int64(checks.Retention))
+	if checks.Gate != nil {
+		b, err := json.Marshal(checks.Gate)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(gate): %w", err)
+		}
+		stmt.SetBytes("$gate", b)
+	}
+	if checks.Quorum != nil {
+		b, err := json.Marshal(checks.Quorum)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(quorum): %w", err)
+		}
+		stmt.SetBytes("$quorum", b)
+	}
 	stmt.SetInt64("$state_status", int64(checks.State.Status))
 	stmt.SetInt64("$state_start", checks.State.Start.UnixNano())
 	stmt.SetInt64("$state_end", checks.State.End.UnixNano())
@@ -147,6 +174,12 @@ func commitChecks(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, chec
 		}
 	}
 
+	for _, run := range checks.Runs {
+		if err := commitCheckRun(conn, checks, run); err != nil {
+			return fmt.Errorf("commitCheckRun: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -159,6 +192,7 @@ const insertBlock = `
 		pos,
 		entrancedelay,
 		exitdelay,
+		maxduration,
 		prechecks,
 		postchecks,
 		contchecks,
@@ -168,7 +202,7 @@ const insertBlock = `
 		state_status,
 		state_start,
 		state_end
-	) VALUES ($id, $plan_id, $name, $descr, $pos, $entrancedelay, $exitdelay, $prechecks, $postchecks, $contchecks, $sequences,
+	) VALUES ($id, $plan_id, $name, $descr, $pos, $entrancedelay, $exitdelay, $maxduration, $prechecks, $postchecks, $contchecks, $sequences,
 	$concurrency, $toleratedfailures,$state_status, $state_start, $state_end)`
 
 func commitBlock(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos int, block *workflow.Block) error {
@@ -195,6 +229,7 @@ func commitBlock(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos i
 	stmt.SetInt64("$pos", int64(pos))
 	stmt.SetInt64("$entrancedelay", int64(block.EntranceDelay))
 	stmt.SetInt64("$exitdelay", int64(block.ExitDelay))
+	stmt.SetInt64("$maxduration", int64(block.MaxDuration))
 	if block.PreChecks != nil {
 		stmt.SetText("$prechecks", block.PreChecks.ID.String())
 	}
@@ -231,11 +266,16 @@ const insertSequence = `
 		name,
 		descr,
 		pos,
+		prechecks,
+		postchecks,
+		contchecks,
 		actions,
+		maxduration,
 		state_status,
 		state_start,
 		state_end
-	) VALUES ($id, $plan_id, $name, $descr, $pos, $actions, $state_status, $state_start, $state_end)`
+	) VALUES ($id, $plan_id, $name, $descr, $pos, $prechecks, $postchecks, $contchecks, $actions, $maxduration,
+	$state_status, $state_start, $state_end)`
 
 func commitSequence(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos int, seq *workflow.Sequence) error {
 	stmt, err := conn.Prepare(insertSequence)
@@ -243,6 +283,12 @@ func commitSequence(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, po
 		return fmt.Errorf("conn.Prepare(insertSequence): %w", err)
 	}
 
+	for _, c := range []*workflow.Checks{seq.PreChecks, seq.PostChecks, seq.ContChecks} {
+		if err := commitChecks(ctx, conn, planID, c); err != nil {
+			return fmt.Errorf("commitSequence(commitChecks): %w", err)
+		}
+	}
+
 	actions, err := idsToJSON(seq.Actions)
 	if err != nil {
 		return fmt.Errorf("idsToJSON(actions): %w", err)
@@ -253,7 +299,17 @@ func commitSequence(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, po
 	stmt.SetText("$name", seq.Name)
 	stmt.SetText("$descr", seq.Descr)
 	stmt.SetInt64("$pos", int64(pos))
+	if seq.PreChecks != nil {
+		stmt.SetText("$prechecks", seq.PreChecks.ID.String())
+	}
+	if seq.PostChecks != nil {
+		stmt.SetText("$postchecks", seq.PostChecks.ID.String())
+	}
+	if seq.ContChecks != nil {
+		stmt.SetText("$contchecks", seq.ContChecks.ID.String())
+	}
 	stmt.SetBytes("$actions", actions)
+	stmt.SetInt64("$maxduration", int64(seq.MaxDuration))
 	stmt.SetInt64("$state_status", int64(seq.State.Status))
 	stmt.SetInt64("$state_start", seq.State.Start.UnixNano())
 	stmt.SetInt64("$state_end", seq.State.End.UnixNano())
@@ -279,15 +335,23 @@ const insertAction = `
 		descr,
 		pos,
 		plugin,
+		version,
 		timeout,
 		retries,
+		retrypolicy,
+		retryon,
+		permanenton,
+		maxduration,
 		req,
+		req_version,
 		attempts,
+		spent,
+		checkpoint,
 		state_status,
 		state_start,
 		state_end
-	) VALUES ($id, $plan_id, $name, $descr, $pos, $plugin, $timeout, $retries, $req, $attempts,
-	$state_status, $state_start, $state_end)`
+	) VALUES ($id, $plan_id, $name, $descr, $pos, $plugin, $version, $timeout, $retries, $retrypolicy, $retryon, $permanenton, $maxduration, $req, $req_version, $attempts,
+	$spent, $checkpoint, $state_status, $state_start, $state_end)`
 
 func commitAction(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos int, action *workflow.Action) error {
 	stmt, err := conn.Prepare(insertAction)
@@ -305,18 +369,47 @@ func commitAction(ctx context.Context, conn *sqlite.Conn, planID uuid.UUID, pos
 		return fmt.Errorf("can't encode action.Attempts: %w", err)
 	}
 
+	if action.RetryPolicy != nil {
+		b, err := json.Marshal(action.RetryPolicy)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(retryPolicy): %w", err)
+		}
+		stmt.SetBytes("$retrypolicy", b)
+	}
+	if len(action.RetryOn) > 0 {
+		b, err := json.Marshal(action.RetryOn)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(retryOn): %w", err)
+		}
+		stmt.SetBytes("$retryon", b)
+	}
+	if len(action.PermanentOn) > 0 {
+		b, err := json.Marshal(action.PermanentOn)
+		if err != nil {
+			return fmt.Errorf("json.Marshal(permanentOn): %w", err)
+		}
+		stmt.SetBytes("$permanenton", b)
+	}
+
 	stmt.SetText("$id", action.ID.String())
 	stmt.SetText("$plan_id", planID.String())
 	stmt.SetText("$name", action.Name)
 	stmt.SetText("$descr", action.Descr)
 	stmt.SetInt64("$pos", int64(pos))
 	stmt.SetText("$plugin", action.Plugin)
+	stmt.SetText("$version", action.Version)
 	stmt.SetInt64("$timeout", int64(action.Timeout))
 	stmt.SetInt64("$retries", int64(action.Retries))
+	stmt.SetInt64("$maxduration", int64(action.MaxDuration))
 	stmt.SetBytes("$req", req)
+	stmt.SetText("$req_version", action.ReqVersion)
 	if attempts != nil {
 		stmt.SetBytes("$attempts", attempts)
 	}
+	stmt.SetInt64("$spent", int64(action.Spent))
+	if action.Checkpoint != nil {
+		stmt.SetBytes("$checkpoint", action.Checkpoint)
+	}
 	stmt.SetInt64("$state_status", int64(action.State.Status))
 	stmt.SetInt64("$state_start", action.State.Start.UnixNano())
 	stmt.SetInt64("$state_end", action.State.End.UnixNano())
@@ -347,24 +440,6 @@ func encodeAttempts(attempts []*workflow.Attempt) ([]byte, error) {
 	return json.Marshal(out)
 }
 
-// decodeAttempts decodes a JSON array of JSON encoded attempts as byte slices into a slice of attempts.
-func decodeAttempts(rawAttempts []byte, plug plugins.Plugin) ([]*workflow.Attempt, error) {
-	rawList := make([][]byte, 0)
-	if err := json.Unmarshal(rawAttempts, &rawList); err != nil {
-		return nil, fmt.Errorf("json.Unmarshal(rawAttempts): %w", err)
-	}
-
-	attempts := make([]*workflow.Attempt, 0, len(rawList))
-	for _, raw := range rawList {
-		var a = &workflow.Attempt{Resp: plug.Response()}
-		if err := json.Unmarshal(raw, a); err != nil {
-			return nil, fmt.Errorf("json.Unmarshal(raw): %w", err)
-		}
-		attempts = append(attempts, a)
-	}
-	return attempts, nil
-}
-
 type ider interface {
 	GetID() uuid.UUID
 }
@@ -17,6 +17,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
+	"github.com/gostdlib/ops/retry/exponential"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
@@ -31,7 +32,7 @@ type setters interface {
 func init() {
 	ctx := context.Background()
 
-	build, err := builder.New("test", "test", builder.WithGroupID(mustUUID()))
+	build, err := builder.New("test", "test", builder.WithGroupID(mustUUID()), builder.WithMaxDuration(time.Hour))
 	if err != nil {
 		panic(err)
 	}
@@ -40,10 +41,16 @@ func init() {
 	checkAction2 := &workflow.Action{Name: "action", Descr: "action", Plugin: plugins.CheckPluginName, Req: nil}
 	checkAction3 := &workflow.Action{Name: "action", Descr: "action", Plugin: plugins.CheckPluginName, Req: nil}
 	seqAction1 := &workflow.Action{
-		Name:   "action",
-		Descr:  "action",
-		Plugin: plugins.HelloPluginName,
-		Req:    plugins.HelloReq{Say: "hello"},
+		Name:        "action",
+		Descr:       "action",
+		Plugin:      plugins.HelloPluginName,
+		Req:         plugins.HelloReq{Say: "hello"},
+		RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+		RetryOn:     []pluglib.ErrCode{1, 2},
+		PermanentOn: []pluglib.ErrCode{3},
+		MaxDuration: time.Minute,
+		Spent:       30 * time.Second,
+		Checkpoint:  []byte("checkpoint"),
 		Attempts: []*workflow.Attempt{
 			{
 				Err:   &pluglib.Error{Message: "internal error"},
@@ -58,11 +65,36 @@ func init() {
 		},
 	}
 
-	build.AddChecks(builder.PreChecks, &workflow.Checks{})
+	seqAction2 := &workflow.Action{
+		Name:       "typed",
+		Descr:      "typed",
+		Plugin:     plugins.TypedPluginName,
+		Version:    ">=0.9.0",
+		Req:        &plugins.TypedReq{Count: 1},
+		ReqVersion: "1.0.0",
+		Attempts: []*workflow.Attempt{
+			{
+				// Each attempt must be decoded with the version that executed it.
+				Resp:          &plugins.TypedRespV0{Total: 1},
+				Err:           &pluglib.Error{Message: "internal error"},
+				PluginVersion: "0.9.0",
+				Start:         time.Now().Add(-1 * time.Minute),
+				End:           time.Now(),
+			},
+			{
+				Resp:          &plugins.TypedResp{Counted: 1},
+				PluginVersion: "1.0.0",
+				Start:         time.Now().Add(-1 * time.Second),
+				End:           time.Now(),
+			},
+		},
+	}
+
+	build.AddChecks(builder.PreChecks, &workflow.Checks{Gate: &workflow.Gate{Interval: time.Second, Timeout: time.Minute}})
 	build.AddAction(clone.Action(ctx, checkAction1))
 	build.Up()
 
-	build.AddChecks(builder.ContChecks, &workflow.Checks{Delay: 32 * time.Second})
+	build.AddChecks(builder.ContChecks, &workflow.Checks{Delay: 32 * time.Second, Tolerance: &workflow.Tolerance{Failures: 2, Window: 5}, Retention: 10, Quorum: &workflow.Quorum{Count: 1}})
 	build.AddAction(clone.Action(ctx, checkAction2))
 	build.Up()
 
@@ -75,6 +107,7 @@ func init() {
 		Descr:             "block",
 		EntranceDelay:     1 * time.Second,
 		ExitDelay:         1 * time.Second,
+		MaxDuration:       30 * time.Minute,
 		ToleratedFailures: 1,
 		Concurrency:       1,
 	})
@@ -91,8 +124,12 @@ func init() {
 	build.AddAction(checkAction3)
 	build.Up()
 
-	build.AddSequence(&workflow.Sequence{Name: "sequence", Descr: "sequence"})
+	build.AddSequence(&workflow.Sequence{Name: "sequence", Descr: "sequence", MaxDuration: 10 * time.Minute})
+	build.AddChecks(builder.PreChecks, &workflow.Checks{})
+	build.AddAction(clone.Action(ctx, checkAction1))
+	build.Up()
 	build.AddAction(seqAction1)
+	build.AddAction(seqAction2)
 	build.Up()
 
 	plan, err = build.Plan()
@@ -111,6 +148,10 @@ func init() {
 			},
 		)
 	}
+	plan.ContChecks.Runs = []*workflow.CheckRun{
+		{Iteration: 1, Status: workflow.Failed, Start: time.Now(), End: time.Now()},
+		{Iteration: 2, Status: workflow.Completed, Start: time.Now(), End: time.Now()},
+	}
 }
 
 func mustUUID() uuid.UUID {
@@ -142,7 +183,7 @@ func dbSetup() (path string, pool *sqlitex.Pool, err error) {
 	}
 	defer pool.Put(conn)
 
-	if err := createTables(context.Background(), conn); err != nil {
+	if err := migrate(context.Background(), conn); err != nil {
 		return "", nil, err
 	}
 
@@ -172,6 +213,8 @@ func TestCommitPlan(t *testing.T) {
 	reg := registry.New()
 	reg.Register(&plugins.CheckPlugin{})
 	reg.Register(&plugins.HelloPlugin{})
+	reg.Register(plugins.NewTypedPlugin())
+	reg.Register(plugins.NewTypedPluginV0())
 
 	// TODO(element-of-surprise): Add checks to verify the data in the database
 	reader := &reader{
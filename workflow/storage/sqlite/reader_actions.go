@@ -3,12 +3,12 @@ package sqlite
 import (
 	"context"
 	"fmt"
-	"reflect"
 	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/go-json-experiment/json"
 	"github.com/google/uuid"
+	"github.com/gostdlib/ops/retry/exponential"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
@@ -71,37 +71,43 @@ func (r reader) actionRowToAction(ctx context.Context, stmt *sqlite.Stmt) (*work
 	a.Name = stmt.GetText("name")
 	a.Descr = stmt.GetText("descr")
 	a.Plugin = stmt.GetText("plugin")
+	a.Version = stmt.GetText("version")
+	a.ReqVersion = stmt.GetText("req_version")
 	a.Timeout = time.Duration(stmt.GetInt64("timeout"))
 	a.Retries = int(stmt.GetInt64("retries"))
+	a.MaxDuration = time.Duration(stmt.GetInt64("maxduration"))
+	a.Spent = time.Duration(stmt.GetInt64("spent"))
+	a.Checkpoint = fieldToBytes("checkpoint", stmt)
 	a.State, err = fieldToState(stmt)
 	if err != nil {
 		return nil, fmt.Errorf("actionRowToAction: %w", err)
 	}
-
-	plug := r.reg.Plugin(a.Plugin)
-	if plug == nil {
-		return nil, fmt.Errorf("couldn't find plugin %s", a.Plugin)
+	if b := fieldToBytes("retrypolicy", stmt); b != nil {
+		a.RetryPolicy = &exponential.Policy{}
+		if err := json.Unmarshal(b, a.RetryPolicy); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal retry policy: %w", err)
+		}
 	}
-
-	b := fieldToBytes("req", stmt)
-	if len(b) > 0 {
-		req := plug.Request()
-		if req != nil {
-			if reflect.TypeOf(req).Kind() != reflect.Pointer {
-				if err := json.Unmarshal(b, &req); err != nil {
-					return nil, fmt.Errorf("couldn't unmarshal request: %w", err)
-				}
-			} else {
-				if err := json.Unmarshal(b, req); err != nil {
-					return nil, fmt.Errorf("couldn't unmarshal request: %w", err)
-				}
-			}
-			a.Req = req
+	if b := fieldToBytes("retryon", stmt); b != nil {
+		if err := json.Unmarshal(b, &a.RetryOn); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal retry on: %w", err)
 		}
 	}
-	b = fieldToBytes("attempts", stmt)
-	if len(b) > 0 {
-		a.Attempts, err = decodeAttempts(b, plug)
+	if b := fieldToBytes("permanenton", stmt); b != nil {
+		if err := json.Unmarshal(b, &a.PermanentOn); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal permanent on: %w", err)
+		}
+	}
+
+	// The plugin may be gone or its types may have changed. The Action is still read so that its history
+	// can be seen, with what can't be decoded as a workflow.RawJSON.
+	plug := r.reg.PluginVersion(a.Plugin, a.Version)
+
+	if b := fieldToBytes("req", stmt); len(b) > 0 {
+		a.Req = decodeReq(b, a.ReqVersion, plug)
+	}
+	if b := fieldToBytes("attempts", stmt); len(b) > 0 {
+		a.Attempts, err = decodeAttempts(b, a.Plugin, plug, r.reg)
 		if err != nil {
 			return nil, fmt.Errorf("couldn't decode attempts: %w", err)
 		}
@@ -73,6 +73,7 @@ func (p reader) blockRowToBlock(ctx context.Context, conn *sqlite.Conn, stmt *sq
 	b.Descr = stmt.GetText("descr")
 	b.EntranceDelay = time.Duration(stmt.GetInt64("entrancedelay"))
 	b.ExitDelay = time.Duration(stmt.GetInt64("exitdelay"))
+	b.MaxDuration = time.Duration(stmt.GetInt64("maxduration"))
 	b.State, err = fieldToState(stmt)
 	if err != nil {
 		return nil, fmt.Errorf("blockRowToBlock: %w", err)
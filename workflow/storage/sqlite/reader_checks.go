@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/go-json-experiment/json"
 	"github.com/google/uuid"
 	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
@@ -69,6 +70,25 @@ func (p reader) checksRowToChecks(ctx context.Context, conn *sqlite.Conn, stmt *
 		return nil, fmt.Errorf("checksRowToChecks: couldn't convert ID to UUID: %w", err)
 	}
 	c.Delay = time.Duration(stmt.GetInt64("delay"))
+	if b := fieldToBytes("tolerance", stmt); b != nil {
+		c.Tolerance = &workflow.Tolerance{}
+		if err := json.Unmarshal(b, c.Tolerance); err != nil {
+			return nil, fmt.Errorf("checksRowToChecks: couldn't unmarshal tolerance: %w", err)
+		}
+	}
+	c.Retention = int(stmt.GetInt64("retention"))
+	if b := fieldToBytes("gate", stmt); b != nil {
+		c.Gate = &workflow.Gate{}
+		if err := json.Unmarshal(b, c.Gate); err != nil {
+			return nil, fmt.Errorf("checksRowToChecks: couldn't unmarshal gate: %w", err)
+		}
+	}
+	if b := fieldToBytes("quorum", stmt); b != nil {
+		c.Quorum = &workflow.Quorum{}
+		if err := json.Unmarshal(b, c.Quorum); err != nil {
+			return nil, fmt.Errorf("checksRowToChecks: couldn't unmarshal quorum: %w", err)
+		}
+	}
 	c.State, err = fieldToState(stmt)
 	if err != nil {
 		return nil, fmt.Errorf("checksRowToChecks: %w", err)
@@ -77,6 +97,116 @@ func (p reader) checksRowToChecks(ctx context.Context, conn *sqlite.Conn, stmt *
 	if err != nil {
 		return nil, fmt.Errorf("couldn't get actions ids: %w", err)
 	}
+	c.Runs, err = p.fetchCheckRunsSummary(conn, c.ID)
+	if err != nil {
+		return nil, fmt.Errorf("checksRowToChecks: %w", err)
+	}
 
 	return c, nil
 }
+
+// fetchCheckRunsSummary fetches the runs of a Checks without the results of the Actions.
+func (p reader) fetchCheckRunsSummary(conn *sqlite.Conn, id uuid.UUID) ([]*workflow.CheckRun, error) {
+	var runs []*workflow.CheckRun
+	err := sqlitex.Execute(
+		conn,
+		fetchCheckRunsSummary,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{
+				"$checks_id": id.String(),
+			},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				run, err := checkRunRowToCheckRun(stmt)
+				if err != nil {
+					return fmt.Errorf("couldn't convert row to check run: %w", err)
+				}
+				runs = append(runs, run)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch check runs: %w", err)
+	}
+	return runs, nil
+}
+
+// CheckRuns implements storage.Reader.CheckRuns().
+func (p reader) CheckRuns(ctx context.Context, checksID uuid.UUID, after int, limit int) ([]*workflow.CheckRun, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be greater than 0")
+	}
+
+	conn, err := p.pool.Take(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer p.pool.Put(conn)
+
+	var runs []*workflow.CheckRun
+	err = sqlitex.Execute(
+		conn,
+		fetchCheckRuns,
+		&sqlitex.ExecOptions{
+			Named: map[string]any{
+				"$checks_id": checksID.String(),
+				"$after":     after,
+				"$limit":     limit,
+			},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				run, err := checkRunRowToCheckRun(stmt)
+				if err != nil {
+					return fmt.Errorf("couldn't convert row to check run: %w", err)
+				}
+				if b := fieldToBytes("actions", stmt); b != nil {
+					run.Actions, err = p.decodeActionRuns(b)
+					if err != nil {
+						return fmt.Errorf("couldn't decode actions of run(%d): %w", run.Iteration, err)
+					}
+				}
+				runs = append(runs, run)
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't fetch check runs: %w", err)
+	}
+	return runs, nil
+}
+
+// checkRunRowToCheckRun converts a sqlite row to a workflow.CheckRun without the results of the Actions.
+func checkRunRowToCheckRun(stmt *sqlite.Stmt) (*workflow.CheckRun, error) {
+	state, err := fieldToState(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return &workflow.CheckRun{
+		Iteration: int(stmt.GetInt64("iteration")),
+		Status:    state.Status,
+		Start:     state.Start,
+		End:       state.End,
+	}, nil
+}
+
+// decodeActionRuns decodes the results of the Actions in a CheckRun that were encoded with encodeActionRuns().
+func (p reader) decodeActionRuns(b []byte) ([]*workflow.ActionRun, error) {
+	var entries []actionRunEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(actions): %w", err)
+	}
+
+	runs := make([]*workflow.ActionRun, 0, len(entries))
+	for _, e := range entries {
+		run := &workflow.ActionRun{ID: e.ID, Status: e.Status}
+		if len(e.Attempts) > 0 {
+			var err error
+			run.Attempts, err = decodeAttempts(e.Attempts, e.Plugin, p.reg.Plugin(e.Plugin), p.reg)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't decode attempts: %w", err)
+			}
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
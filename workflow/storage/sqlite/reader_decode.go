@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+)
+
+// decodeReq decodes b, a request stored by version from of plug, into the request of plug. The request
+// is migrated if plug has a plugins.Migration for from. If plug is nil, has no request type or the request
+// can't be decoded, a workflow.RawJSON is returned.
+func decodeReq(b []byte, from string, plug plugins.Plugin) any {
+	if len(b) == 0 || bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	if plug == nil {
+		return workflow.RawJSON{JSON: b, Reason: "plugin not found"}
+	}
+	req := plug.Request()
+	if req == nil {
+		return workflow.RawJSON{JSON: b, Reason: fmt.Sprintf("plugin(%s) has no request type", plug.Name())}
+	}
+
+	mb, err := plugins.MigrateReq(plug, from, b)
+	if err != nil {
+		return workflow.RawJSON{JSON: b, Reason: err.Error()}
+	}
+	req, err = plugins.DecodeInto(mb, req)
+	if err != nil {
+		return workflow.RawJSON{JSON: b, Reason: fmt.Sprintf("couldn't decode request: %v", err)}
+	}
+	return req
+}
+
+// decodeResp is like decodeReq, but for a response. The response is decoded with the version of the plugin
+// with name that executed the attempt, if it is in reg. Otherwise plug is used.
+func decodeResp(b []byte, from, name string, plug plugins.Plugin, reg *registry.Register) any {
+	if len(b) == 0 || bytes.Equal(b, []byte("null")) {
+		return nil
+	}
+	if from != "" {
+		if p := reg.PluginVersion(name, "="+from); p != nil {
+			plug = p
+		}
+	}
+	if plug == nil {
+		return workflow.RawJSON{JSON: b, Reason: "plugin not found"}
+	}
+
+	mb, err := plugins.MigrateResp(plug, from, b)
+	if err != nil {
+		return workflow.RawJSON{JSON: b, Reason: err.Error()}
+	}
+	resp, err := plugins.DecodeInto(mb, plug.Response())
+	if err != nil {
+		return workflow.RawJSON{JSON: b, Reason: fmt.Sprintf("couldn't decode response: %v", err)}
+	}
+	return resp
+}
+
+// decodeAttempts decodes a JSON array of JSON encoded attempts as byte slices into a slice of attempts.
+// name is the name of the plugin and plug is the version of it the Action uses, which may be nil.
+// See decodeResp() for how responses are decoded.
+func decodeAttempts(rawAttempts []byte, name string, plug plugins.Plugin, reg *registry.Register) ([]*workflow.Attempt, error) {
+	rawList := make([][]byte, 0)
+	if err := json.Unmarshal(rawAttempts, &rawList); err != nil {
+		return nil, fmt.Errorf("json.Unmarshal(rawAttempts): %w", err)
+	}
+
+	attempts := make([]*workflow.Attempt, 0, len(rawList))
+	for _, raw := range rawList {
+		// The response is kept as JSON until we know the version of the plugin that executed the attempt.
+		resp := &jsontext.Value{}
+		var a = &workflow.Attempt{Resp: resp}
+		if err := json.Unmarshal(raw, a); err != nil {
+			return nil, fmt.Errorf("json.Unmarshal(raw): %w", err)
+		}
+		a.Resp = decodeResp(*resp, a.PluginVersion, name, plug, reg)
+		// An attempt without log lines is stored as an empty list.
+		if len(a.Log) == 0 {
+			a.Log = nil
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, nil
+}
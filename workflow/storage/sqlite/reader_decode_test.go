@@ -0,0 +1,202 @@
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/go-json-experiment/json"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+const migratingName = "migrating"
+
+type migratingReq struct {
+	Count int
+}
+
+type migratingResp struct {
+	Counted int
+}
+
+// migratingPlugin is version 2.0.0 of a plugin whose version 1 request was {"Num": n} and response was {"Total": n}.
+type migratingPlugin struct {
+	*plugins.Typed[migratingReq, *migratingResp]
+}
+
+func (migratingPlugin) Migrations() []plugins.Migration {
+	return []plugins.Migration{
+		{
+			From: "^1.0.0",
+			Req:  func(b []byte) ([]byte, error) { return bytes.Replace(b, []byte(`"Num"`), []byte(`"Count"`), 1), nil },
+			Resp: func(b []byte) ([]byte, error) {
+				return bytes.Replace(b, []byte(`"Total"`), []byte(`"Counted"`), 1), nil
+			},
+		},
+	}
+}
+
+func newMigratingPlugin() migratingPlugin {
+	p, err := plugins.NewTyped(
+		plugins.TypedArgs[migratingReq, *migratingResp]{
+			Name:    migratingName,
+			Version: "2.0.0",
+			Execute: func(ctx context.Context, req migratingReq) (*migratingResp, *plugins.Error) {
+				return &migratingResp{Counted: req.Count}, nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return migratingPlugin{p}
+}
+
+// noReqPlugin is a plugin whose Request() is nil.
+type noReqPlugin struct {
+	plugins.Plugin
+}
+
+func (noReqPlugin) Request() any {
+	return nil
+}
+
+func TestDecodeReq(t *testing.T) {
+	t.Parallel()
+
+	plug := newMigratingPlugin()
+
+	tests := []struct {
+		name string
+		b    string
+		from string
+		plug plugins.Plugin
+		want any
+	}{
+		{
+			name: "Plugin not found",
+			b:    `{"Count":1}`,
+			from: "2.0.0",
+			want: workflow.RawJSON{JSON: []byte(`{"Count":1}`), Reason: "plugin not found"},
+		},
+		{
+			name: "Nothing stored",
+			b:    `null`,
+			from: "2.0.0",
+			plug: plug,
+			want: nil,
+		},
+		{
+			name: "Plugin has no request type",
+			b:    `{"Count":1}`,
+			from: "2.0.0",
+			plug: noReqPlugin{plug},
+			want: workflow.RawJSON{JSON: []byte(`{"Count":1}`)},
+		},
+		{
+			name: "Can't decode",
+			b:    `{"Count":"one"}`,
+			from: "2.0.0",
+			plug: plug,
+			want: workflow.RawJSON{JSON: []byte(`{"Count":"one"}`)},
+		},
+		{
+			name: "Current version",
+			b:    `{"Count":1}`,
+			from: "2.0.0",
+			plug: plug,
+			want: migratingReq{Count: 1},
+		},
+		{
+			name: "Migrated",
+			b:    `{"Num":1}`,
+			from: "1.2.0",
+			plug: plug,
+			want: migratingReq{Count: 1},
+		},
+	}
+
+	for _, test := range tests {
+		got := decodeReq([]byte(test.b), test.from, test.plug)
+		// The reason for a decode error comes from the json package, so only check there is one.
+		if raw, ok := got.(workflow.RawJSON); ok && test.plug != nil {
+			if raw.Reason == "" {
+				t.Errorf("TestDecodeReq(%s): got RawJSON without a Reason", test.name)
+			}
+			raw.Reason = ""
+			got = raw
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestDecodeReq(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+}
+
+func TestDecodeAttempts(t *testing.T) {
+	t.Parallel()
+
+	reg := registry.New()
+	reg.MustRegister(newMigratingPlugin())
+
+	attempts := []*workflow.Attempt{
+		{Resp: workflow.RawJSON{JSON: []byte(`{"Total":1}`)}, PluginVersion: "1.0.0"},
+		{Resp: &migratingResp{Counted: 2}, PluginVersion: "2.0.0"},
+		{Err: &plugins.Error{Message: "failed"}, PluginVersion: "2.0.0"},
+	}
+	b, err := encodeAttempts(attempts)
+	if err != nil {
+		t.Fatalf("TestDecodeAttempts: couldn't encode attempts: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		plug plugins.Plugin
+		want []*workflow.Attempt
+	}{
+		{
+			name: "Plugin not found",
+			want: []*workflow.Attempt{
+				{Resp: workflow.RawJSON{JSON: []byte(`{"Total":1}`), Reason: "plugin not found"}, PluginVersion: "1.0.0"},
+				// The exact version is in the registry.
+				{Resp: &migratingResp{Counted: 2}, PluginVersion: "2.0.0"},
+				{Err: &plugins.Error{Message: "failed"}, PluginVersion: "2.0.0"},
+			},
+		},
+		{
+			name: "Migrated",
+			plug: reg.Plugin(migratingName),
+			want: []*workflow.Attempt{
+				{Resp: &migratingResp{Counted: 1}, PluginVersion: "1.0.0"},
+				{Resp: &migratingResp{Counted: 2}, PluginVersion: "2.0.0"},
+				{Err: &plugins.Error{Message: "failed"}, PluginVersion: "2.0.0"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := decodeAttempts(b, migratingName, test.plug, reg)
+		if err != nil {
+			t.Errorf("TestDecodeAttempts(%s): got err == %s, want err == nil", test.name, err)
+			continue
+		}
+		if diff := pretty.Compare(test.want, got); diff != "" {
+			t.Errorf("TestDecodeAttempts(%s): -want/+got:\n%s", test.name, diff)
+		}
+	}
+
+	// Attempts that were read as a RawJSON are written back as they were stored.
+	got, _ := decodeAttempts(b, "orphaned", nil, registry.New())
+	b2, err := encodeAttempts(got)
+	if err != nil {
+		t.Fatalf("TestDecodeAttempts(round trip): couldn't encode attempts: %s", err)
+	}
+	var want, gotList [][]byte
+	json.Unmarshal(b, &want)
+	json.Unmarshal(b2, &gotList)
+	if diff := pretty.Compare(want, gotList); diff != "" {
+		t.Errorf("TestDecodeAttempts(round trip): -want/+got:\n%s", diff)
+	}
+}
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/google/uuid"
@@ -44,6 +45,7 @@ func (p reader) fetchPlan(ctx context.Context, id uuid.UUID) (*workflow.Plan, er
 				}
 				plan.Name = stmt.GetText("name")
 				plan.Descr = stmt.GetText("descr")
+				plan.MaxDuration = time.Duration(stmt.GetInt64("maxduration"))
 				plan.SubmitTime, err = timeFromField("submit_time", stmt)
 				if err != nil {
 					return fmt.Errorf("couldn't get plan submit time: %w", err)
@@ -3,6 +3,7 @@ package sqlite
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/google/uuid"
@@ -70,10 +71,23 @@ func (p reader) sequenceRowToSequence(ctx context.Context, conn *sqlite.Conn, st
 	}
 	s.Name = stmt.GetText("name")
 	s.Descr = stmt.GetText("descr")
+	s.MaxDuration = time.Duration(stmt.GetInt64("maxduration"))
 	s.State, err = fieldToState(stmt)
 	if err != nil {
 		return nil, fmt.Errorf("sequenceRowToSequence: %w", err)
 	}
+	s.PreChecks, err = p.fieldToCheck(ctx, "prechecks", conn, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read sequence prechecks: %w", err)
+	}
+	s.ContChecks, err = p.fieldToCheck(ctx, "contchecks", conn, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read sequence contchecks: %w", err)
+	}
+	s.PostChecks, err = p.fieldToCheck(ctx, "postchecks", conn, stmt)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read sequence postchecks: %w", err)
+	}
 	s.Actions, err = p.fieldToActions(ctx, conn, stmt)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't read sequence actions: %w", err)
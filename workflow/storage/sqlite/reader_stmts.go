@@ -15,6 +15,7 @@ SELECT
  	name,
 	descr,
 	meta,
+	maxduration,
 	prechecks,
 	postchecks,
 	contchecks,
@@ -36,6 +37,7 @@ SELECT
 	pos,
 	entrancedelay,
 	exitdelay,
+	maxduration,
 	prechecks,
 	postchecks,
 	contchecks,
@@ -54,19 +56,49 @@ SELECT
 	plan_id,
 	actions,
 	delay,
+	tolerance,
+	retention,
+	gate,
+	quorum,
 	state_status,
 	state_start,
 	state_end
 FROM checks
 where id = $id`
 
+const fetchCheckRunsSummary = `
+SELECT
+	iteration,
+	state_status,
+	state_start,
+	state_end
+FROM check_runs
+WHERE checks_id = $checks_id
+ORDER BY iteration ASC`
+
+const fetchCheckRuns = `
+SELECT
+	iteration,
+	actions,
+	state_status,
+	state_start,
+	state_end
+FROM check_runs
+WHERE checks_id = $checks_id AND iteration > $after
+ORDER BY iteration ASC
+LIMIT $limit`
+
 const fetchSequencesByID = `
 SELECT
 	id,
 	plan_id,
 	name,
 	descr,
+	prechecks,
+	postchecks,
+	contchecks,
 	actions,
+	maxduration,
 	state_status,
 	state_start,
 	state_end
@@ -80,10 +112,18 @@ SELECT
 	name,
 	descr,
 	plugin,
+	version,
 	timeout,
 	retries,
+	retrypolicy,
+	retryon,
+	permanenton,
+	maxduration,
 	req,
+	req_version,
 	attempts,
+	spent,
+	checkpoint,
 	state_status,
 	state_start,
 	state_end
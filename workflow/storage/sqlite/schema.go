@@ -1,9 +1,81 @@
 package sqlite
 
+// schemaVersion is the version of the schema in this file, which is stored in PRAGMA user_version.
+// When a column is added to a table, add it to a new entry in migrations and increment schemaVersion.
+// New tables are created by tables.
+const schemaVersion = 11
+
+// column is a column that a migration adds to a table if it does not have it. A NOT NULL column must
+// have a DEFAULT in def, which is given to existing rows. If drop is set, the column is instead removed
+// from the table if it has it.
+type column struct {
+	table string
+	name  string
+	def   string
+	drop  bool
+}
+
+// migrations upgrade a database from the schema version at their index to the next version.
+var migrations = [][]column{
+	// 0 -> 1: the MaxDuration of Plans, Blocks and Sequences.
+	{
+		{table: "plans", name: "maxduration", def: "INTEGER NOT NULL DEFAULT 0"},
+		{table: "blocks", name: "maxduration", def: "INTEGER NOT NULL DEFAULT 0"},
+		{table: "sequences", name: "maxduration", def: "INTEGER NOT NULL DEFAULT 0"},
+	},
+	// 1 -> 2: the MaxDuration budget of Actions and the time they spent.
+	{
+		{table: "actions", name: "maxduration", def: "INTEGER NOT NULL DEFAULT 0"},
+		{table: "actions", name: "spent", def: "INTEGER NOT NULL DEFAULT 0"},
+	},
+	// 2 -> 3: the retry policy and retry rules of Actions.
+	{
+		{table: "actions", name: "retrypolicy", def: "BLOB"},
+		{table: "actions", name: "retryon", def: "BLOB"},
+		{table: "actions", name: "permanenton", def: "BLOB"},
+	},
+	// 3 -> 4: the failure Tolerance of Checks.
+	{
+		{table: "checks", name: "tolerance", def: "BLOB"},
+	},
+	// 4 -> 5: the run retention of Checks. The history of runs moved from the runs column to the check_runs table.
+	{
+		{table: "checks", name: "retention", def: "INTEGER NOT NULL DEFAULT 0"},
+		{table: "checks", name: "runs", drop: true},
+	},
+	// 5 -> 6: the Gate of Checks.
+	{
+		{table: "checks", name: "gate", def: "BLOB"},
+	},
+	// 6 -> 7: the Quorum of Checks.
+	{
+		{table: "checks", name: "quorum", def: "BLOB"},
+	},
+	// 7 -> 8: the Checks of Sequences.
+	{
+		{table: "sequences", name: "prechecks", def: "TEXT"},
+		{table: "sequences", name: "postchecks", def: "TEXT"},
+		{table: "sequences", name: "contchecks", def: "TEXT"},
+	},
+	// 8 -> 9: the checkpoint of Actions.
+	{
+		{table: "actions", name: "checkpoint", def: "BLOB"},
+	},
+	// 9 -> 10: the plugin version of Actions.
+	{
+		{table: "actions", name: "version", def: "TEXT NOT NULL DEFAULT ''"},
+	},
+	// 10 -> 11: the version of the plugin that wrote the request of an Action.
+	{
+		{table: "actions", name: "req_version", def: "TEXT NOT NULL DEFAULT ''"},
+	},
+}
+
 var tables = []string{
 	planSchema,
 	blocksSchema,
 	checksSchema,
+	checkRunsSchema,
 	sequencesSchema,
 	actionsSchema,
 }
@@ -15,6 +87,7 @@ CREATE Table If Not Exists plans (
 	name TEXT NOT NULL,
 	descr TEXT NOT NULL,
 	meta BLOB,
+	maxduration INTEGER NOT NULL,
 	prechecks TEXT,
 	postchecks TEXT,
 	contchecks TEXT,
@@ -35,6 +108,7 @@ CREATE Table If Not Exists blocks (
     pos INTEGER NOT NULL,
     entrancedelay INTEGER NOT NULL,
     exitdelay INTEGER NOT NULL,
+    maxduration INTEGER NOT NULL,
     prechecks TEXT,
     postchecks TEXT,
     contchecks TEXT,
@@ -52,11 +126,27 @@ CREATE Table If Not Exists checks (
     plan_id TEXT NOT NULL,
     actions BLOB NOT NULL,
     delay INTEGER NOT NULL,
+    tolerance BLOB,
+    retention INTEGER NOT NULL,
+    gate BLOB,
+    quorum BLOB,
     state_status INTEGER NOT NULL,
     state_start INTEGER NOT NULL,
     state_end INTEGER NOT NULL
 );`
 
+var checkRunsSchema = `
+CREATE Table If Not Exists check_runs (
+    checks_id TEXT NOT NULL,
+    plan_id TEXT NOT NULL,
+    iteration INTEGER NOT NULL,
+    actions BLOB,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL,
+    PRIMARY KEY (checks_id, iteration)
+);`
+
 var sequencesSchema = `
 CREATE Table If Not Exists sequences (
     id TEXT PRIMARY KEY,
@@ -64,7 +154,11 @@ CREATE Table If Not Exists sequences (
     name TEXT NOT NULL,
     descr TEXT NOT NULL,
     pos INTEGER NOT NULL,
+    prechecks TEXT,
+    postchecks TEXT,
+    contchecks TEXT,
     actions BLOB NOT NULL,
+    maxduration INTEGER NOT NULL,
     state_status INTEGER NOT NULL,
     state_start INTEGER NOT NULL,
     state_end INTEGER NOT NULL
@@ -78,10 +172,18 @@ CREATE Table If Not Exists actions (
     descr TEXT NOT NULL,
     pos INTEGER NOT NULL,
     plugin TEXT NOT NULL,
+    version TEXT NOT NULL,
     timeout INTEGER NOT NULL,
     retries INTEGER NOT NULL,
+    retrypolicy BLOB,
+    retryon BLOB,
+    permanenton BLOB,
+    maxduration INTEGER NOT NULL,
     req BLOB,
+    req_version TEXT NOT NULL,
     attempts BLOB,
+    spent INTEGER NOT NULL,
+    checkpoint BLOB,
     state_status INTEGER NOT NULL,
     state_start INTEGER NOT NULL,
     state_end INTEGER NOT NULL
@@ -0,0 +1,309 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins"
+	"github.com/google/go-cmp/cmp"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// baselineTables is the schema before it was versioned.
+var baselineTables = []string{
+	`CREATE Table If Not Exists plans (
+	id TEXT PRIMARY KEY,
+	group_id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	descr TEXT NOT NULL,
+	meta BLOB,
+	prechecks TEXT,
+	postchecks TEXT,
+	contchecks TEXT,
+	blocks BLOB NOT NULL,
+	state_status INTEGER NOT NULL,
+	state_start INTEGER NOT NULL,
+	state_end INTEGER NOT NULL,
+	submit_time INTEGER NOT NULL,
+	reason INTEGER
+);`,
+	`CREATE Table If Not Exists blocks (
+    id TEXT PRIMARY KEY,
+    plan_id BLOB NOT NULL,
+    name TEXT NOT NULL,
+    descr TEXT NOT NULL,
+    pos INTEGER NOT NULL,
+    entrancedelay INTEGER NOT NULL,
+    exitdelay INTEGER NOT NULL,
+    prechecks TEXT,
+    postchecks TEXT,
+    contchecks TEXT,
+    sequences BLOB NOT NULL,
+    concurrency INTEGER NOT NULL,
+    toleratedfailures INTEGER NOT NULL,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+	`CREATE Table If Not Exists checks (
+    id TEXT PRIMARY KEY,
+    plan_id TEXT NOT NULL,
+    actions BLOB NOT NULL,
+    delay INTEGER NOT NULL,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+	`CREATE Table If Not Exists sequences (
+    id TEXT PRIMARY KEY,
+    plan_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    descr TEXT NOT NULL,
+    pos INTEGER NOT NULL,
+    actions BLOB NOT NULL,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+	`CREATE Table If Not Exists actions (
+    id TEXT PRIMARY KEY,
+    plan_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    descr TEXT NOT NULL,
+    pos INTEGER NOT NULL,
+    plugin TEXT NOT NULL,
+    timeout INTEGER NOT NULL,
+    retries INTEGER NOT NULL,
+    req BLOB,
+    attempts BLOB,
+    state_status INTEGER NOT NULL,
+    state_start INTEGER NOT NULL,
+    state_end INTEGER NOT NULL
+);`,
+}
+
+// baselinePlan writes a Plan to the baseline tables the way the baseline version of this package did and
+// returns the Plan as it should be read after the tables are migrated.
+func baselinePlan(conn *sqlite.Conn) (*workflow.Plan, error) {
+	planID, blockID, seqID, actionID := mustUUID(), mustUUID(), mustUUID(), mustUUID()
+	submit := time.Unix(0, time.Now().UnixNano())
+	state := func() *workflow.State { return &workflow.State{Status: workflow.Completed} }
+
+	rows := []struct {
+		q    string
+		args map[string]any
+	}{
+		{
+			q: `INSERT INTO plans (id, group_id, name, descr, blocks, state_status, state_start, state_end, submit_time, reason)
+				VALUES ($id, '', 'plan', 'plan', $blocks, $status, 0, 0, $submit, 0);`,
+			args: map[string]any{"$id": planID.String(), "$blocks": fmt.Sprintf(`["%s"]`, blockID), "$status": int64(workflow.Completed), "$submit": submit.UnixNano()},
+		},
+		{
+			q: `INSERT INTO blocks (id, plan_id, name, descr, pos, entrancedelay, exitdelay, sequences, concurrency, toleratedfailures, state_status, state_start, state_end)
+				VALUES ($id, $plan, 'block', 'block', 0, 0, 0, $seqs, 1, 0, $status, 0, 0);`,
+			args: map[string]any{"$id": blockID.String(), "$plan": planID.String(), "$seqs": fmt.Sprintf(`["%s"]`, seqID), "$status": int64(workflow.Completed)},
+		},
+		{
+			q: `INSERT INTO sequences (id, plan_id, name, descr, pos, actions, state_status, state_start, state_end)
+				VALUES ($id, $plan, 'seq', 'seq', 0, $actions, $status, 0, 0);`,
+			args: map[string]any{"$id": seqID.String(), "$plan": planID.String(), "$actions": fmt.Sprintf(`["%s"]`, actionID), "$status": int64(workflow.Completed)},
+		},
+		{
+			q: `INSERT INTO actions (id, plan_id, name, descr, pos, plugin, timeout, retries, req, state_status, state_start, state_end)
+				VALUES ($id, $plan, 'action', 'action', 0, $plugin, $timeout, 1, $req, $status, 0, 0);`,
+			args: map[string]any{"$id": actionID.String(), "$plan": planID.String(), "$plugin": plugins.HelloPluginName, "$timeout": int64(time.Minute), "$req": []byte(`{"Say":"hello"}`), "$status": int64(workflow.Completed)},
+		},
+	}
+	for _, row := range rows {
+		if err := sqlitex.Execute(conn, row.q, &sqlitex.ExecOptions{Named: row.args}); err != nil {
+			return nil, err
+		}
+	}
+
+	action := &workflow.Action{ID: actionID, Name: "action", Descr: "action", Plugin: plugins.HelloPluginName, Timeout: time.Minute, Retries: 1, Req: plugins.HelloReq{Say: "hello"}, State: state()}
+	seq := &workflow.Sequence{ID: seqID, Name: "seq", Descr: "seq", Actions: []*workflow.Action{action}, State: state()}
+	block := &workflow.Block{ID: blockID, Name: "block", Descr: "block", Concurrency: 1, Sequences: []*workflow.Sequence{seq}, State: state()}
+	return &workflow.Plan{ID: planID, Name: "plan", Descr: "plan", SubmitTime: submit, Blocks: []*workflow.Block{block}, State: state()}, nil
+}
+
+func TestMigrate(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	root := t.TempDir()
+	pool, err := sqlitex.NewPool(filepath.Join(root, "workstream.db"), sqlitex.PoolOptions{Flags: sqlite.OpenReadWrite | sqlite.OpenCreate, PoolSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := pool.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range baselineTables {
+		if err := sqlitex.ExecuteTransient(conn, table, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Some databases have the runs column that held the history of ContChecks before check_runs.
+	if err := sqlitex.ExecuteTransient(conn, "ALTER TABLE checks ADD COLUMN runs BLOB;", nil); err != nil {
+		t.Fatal(err)
+	}
+	old, err := baselinePlan(conn)
+	if err != nil {
+		t.Fatalf("TestMigrate(baseline plan): %s", err)
+	}
+	pool.Put(conn)
+	pool.Close()
+
+	reg := registry.New()
+	reg.Register(&plugins.CheckPlugin{})
+	reg.Register(&plugins.HelloPlugin{})
+	reg.Register(plugins.NewTypedPlugin())
+	reg.Register(plugins.NewTypedPluginV0())
+
+	// Open twice to make sure that migrating an up to date database does nothing.
+	for i := 0; i < 2; i++ {
+		v, err := New(ctx, root, reg)
+		if err != nil {
+			t.Fatalf("TestMigrate(open %d): got err == %s, want err == nil", i, err)
+		}
+
+		got, err := v.Read(ctx, old.ID)
+		if err != nil {
+			t.Fatalf("TestMigrate(open %d): couldn't read baseline plan: %s", i, err)
+		}
+		if diff := cmp.Diff(old, got, cmp.AllowUnexported(workflow.Action{})); diff != "" {
+			t.Errorf("TestMigrate(open %d): baseline plan: -want/+got:\n%s", i, diff)
+		}
+
+		if i == 0 {
+			conn, err := v.pool.Take(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			runs := false
+			err = sqlitex.ExecuteTransient(
+				conn,
+				"PRAGMA table_info(checks);",
+				&sqlitex.ExecOptions{
+					ResultFunc: func(stmt *sqlite.Stmt) error {
+						runs = runs || stmt.GetText("name") == "runs"
+						return nil
+					},
+				},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if runs {
+				t.Errorf("TestMigrate: checks table still has the runs column")
+			}
+			err = commitPlan(ctx, conn, plan)
+			v.pool.Put(conn)
+			if err != nil {
+				t.Fatalf("TestMigrate: couldn't write plan to migrated tables: %s", err)
+			}
+		}
+		got, err = v.Read(ctx, plan.ID)
+		if err != nil {
+			t.Fatalf("TestMigrate(open %d): couldn't read plan: %s", i, err)
+		}
+		if diff := cmp.Diff(plan, got, cmp.AllowUnexported(workflow.Action{})); diff != "" {
+			t.Errorf("TestMigrate(open %d): plan: -want/+got:\n%s", i, diff)
+		}
+		if err := v.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateNewerVersion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	root := t.TempDir()
+	v, err := New(ctx, root, registry.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := v.pool.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sqlitex.ExecuteTransient(conn, fmt.Sprintf("PRAGMA user_version = %d;", schemaVersion+1), nil)
+	v.pool.Put(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Close(ctx)
+
+	if _, err := New(ctx, root, registry.New()); err == nil {
+		t.Errorf("TestMigrateNewerVersion: got err == nil, want err != nil")
+	}
+}
+
+// TestMigrateFromVersion tests that a database at each schema version is migrated to schemaVersion.
+func TestMigrateFromVersion(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	reg := registry.New()
+	reg.Register(&plugins.CheckPlugin{})
+	reg.Register(&plugins.HelloPlugin{})
+	reg.Register(plugins.NewTypedPlugin())
+	reg.Register(plugins.NewTypedPluginV0())
+
+	for ver := 0; ver <= schemaVersion; ver++ {
+		root := t.TempDir()
+		pool, err := sqlitex.NewPool(filepath.Join(root, "workstream.db"), sqlitex.PoolOptions{Flags: sqlite.OpenReadWrite | sqlite.OpenCreate, PoolSize: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn, err := pool.Take(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, table := range baselineTables {
+			if err := sqlitex.ExecuteTransient(conn, table, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, m := range migrations[:ver] {
+			for _, c := range m {
+				if err := alterColumn(conn, c); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		if err := sqlitex.ExecuteTransient(conn, fmt.Sprintf("PRAGMA user_version = %d;", ver), nil); err != nil {
+			t.Fatal(err)
+		}
+		pool.Put(conn)
+		pool.Close()
+
+		v, err := New(ctx, root, reg)
+		if err != nil {
+			t.Errorf("TestMigrateFromVersion(%d): got err == %s, want err == nil", ver, err)
+			continue
+		}
+		conn, err = v.pool.Take(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = commitPlan(ctx, conn, plan)
+		v.pool.Put(conn)
+		if err != nil {
+			t.Errorf("TestMigrateFromVersion(%d): couldn't write plan to migrated tables: %s", ver, err)
+		}
+		if err := v.Close(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
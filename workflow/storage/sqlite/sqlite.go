@@ -103,12 +103,13 @@ func New(ctx context.Context, root string, reg *registry.Register, options ...Op
 
 	conn, err := pool.Take(ctx)
 	if err != nil {
+		pool.Close()
 		return nil, err
 	}
-	defer pool.Put(conn)
-
-	if err = createTables(ctx, conn); err != nil {
-		conn.Close()
+	err = migrate(ctx, conn)
+	pool.Put(conn)
+	if err != nil {
+		pool.Close()
 		return nil, err
 	}
 
@@ -122,6 +123,107 @@ func New(ctx context.Context, root string, reg *registry.Register, options ...Op
 	return r, nil
 }
 
+// migrate creates the tables of a new database, or upgrades the tables of an existing database to schemaVersion.
+// It returns an error if the database was written by a newer version of this package.
+func migrate(ctx context.Context, conn *sqlite.Conn) (err error) {
+	defer sqlitex.Transaction(conn)(&err)
+
+	var ver int
+	err = sqlitex.ExecuteTransient(
+		conn,
+		"PRAGMA user_version;",
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				ver = int(stmt.ColumnInt64(0))
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't read schema version: %w", err)
+	}
+	if ver > schemaVersion {
+		return fmt.Errorf("database has schema version %d, which is newer than the supported version %d", ver, schemaVersion)
+	}
+
+	exists, err := hasTable(conn, "plans")
+	if err != nil {
+		return err
+	}
+	if exists {
+		for _, m := range migrations[ver:] {
+			for _, c := range m {
+				if err := alterColumn(conn, c); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := createTables(ctx, conn); err != nil {
+		return err
+	}
+	if err := sqlitex.ExecuteTransient(conn, fmt.Sprintf("PRAGMA user_version = %d;", schemaVersion), nil); err != nil {
+		return fmt.Errorf("couldn't set schema version: %w", err)
+	}
+	return nil
+}
+
+// hasTable returns true if the database has a table with name.
+func hasTable(conn *sqlite.Conn, name string) (bool, error) {
+	found := false
+	err := sqlitex.ExecuteTransient(
+		conn,
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name = $name;",
+		&sqlitex.ExecOptions{
+			Named: map[string]any{"$name": name},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				found = true
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return false, fmt.Errorf("couldn't look for table(%s): %w", name, err)
+	}
+	return found, nil
+}
+
+// alterColumn adds c to its table if the table does not have it, or drops c if c.drop is set and the table
+// has it. Databases written before the schema was versioned may already have some of the columns.
+func alterColumn(conn *sqlite.Conn, c column) error {
+	found := false
+	err := sqlitex.ExecuteTransient(
+		conn,
+		fmt.Sprintf("PRAGMA table_info(%s);", c.table),
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				if stmt.GetText("name") == c.name {
+					found = true
+				}
+				return nil
+			},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't read columns of table(%s): %w", c.table, err)
+	}
+
+	switch {
+	case c.drop && found:
+		q := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", c.table, c.name)
+		if err := sqlitex.ExecuteTransient(conn, q, nil); err != nil {
+			return fmt.Errorf("couldn't drop column(%s) from table(%s): %w", c.name, c.table, err)
+		}
+	case !c.drop && !found:
+		q := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", c.table, c.name, c.def)
+		if err := sqlitex.ExecuteTransient(conn, q, nil); err != nil {
+			return fmt.Errorf("couldn't add column(%s) to table(%s): %w", c.name, c.table, err)
+		}
+	}
+	return nil
+}
+
 func createTables(ctx context.Context, conn *sqlite.Conn) error {
 	for _, table := range tables {
 		if err := sqlitex.ExecuteTransient(
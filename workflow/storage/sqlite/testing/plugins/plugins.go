@@ -10,6 +10,7 @@ import (
 
 const HelloPluginName = "github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins.HelloPlugin"
 const CheckPluginName = "github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins.CheckPlugin"
+const TypedPluginName = "github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins.TypedPlugin"
 
 type HelloReq struct {
 	Say string
@@ -33,8 +34,8 @@ func (h *HelloPlugin) Execute(ctx context.Context, req any) (any, *plugins.Error
 	if err := h.ValidateReq(req); err != nil {
 		return nil, &plugins.Error{Message: err.Error(), Permanent: true}
 	}
-	r := req.(*HelloReq)
-	return &HelloResp{Said: r.Say}, nil
+	r := req.(HelloReq)
+	return HelloResp{Said: r.Say}, nil
 }
 
 // ValidateReq validates the request object.
@@ -121,3 +122,51 @@ func (c *CheckPlugin) RetryPolicy() exponential.Policy {
 func (c *CheckPlugin) Init() error {
 	return nil
 }
+
+type TypedReq struct {
+	Count int
+}
+
+type TypedResp struct {
+	Counted int
+}
+
+// NewTypedPlugin returns a plugin built with plugins.NewTyped() that uses pointers for its request and response.
+// This is version 1.0.0.
+func NewTypedPlugin() *plugins.Typed[*TypedReq, *TypedResp] {
+	p, err := plugins.NewTyped(
+		plugins.TypedArgs[*TypedReq, *TypedResp]{
+			Name:    TypedPluginName,
+			Version: "1.0.0",
+			Execute: func(ctx context.Context, req *TypedReq) (*TypedResp, *plugins.Error) {
+				return &TypedResp{Counted: req.Count}, nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// TypedRespV0 is the response of version 0.9.0 of the typed plugin.
+type TypedRespV0 struct {
+	Total int
+}
+
+// NewTypedPluginV0 returns version 0.9.0 of the plugin returned by NewTypedPlugin(), which has a different response.
+func NewTypedPluginV0() *plugins.Typed[*TypedReq, *TypedRespV0] {
+	p, err := plugins.NewTyped(
+		plugins.TypedArgs[*TypedReq, *TypedRespV0]{
+			Name:    TypedPluginName,
+			Version: "0.9.0",
+			Execute: func(ctx context.Context, req *TypedReq) (*TypedRespV0, *plugins.Error) {
+				return &TypedRespV0{Total: req.Count}, nil
+			},
+		},
+	)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
@@ -47,6 +47,8 @@ func (a actionUpdater) UpdateAction(ctx context.Context, action *workflow.Action
 		return fmt.Errorf("ActionWriter.Write: %w", err)
 	}
 	stmt.SetBytes("$attempts", b)
+	stmt.SetInt64("$spent", int64(action.Spent))
+	stmt.SetBytes("$checkpoint", action.Checkpoint)
 
 	_, err = stmt.Step()
 	if err != nil {
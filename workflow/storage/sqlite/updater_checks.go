@@ -8,6 +8,9 @@ import (
 	"github.com/element-of-surprise/coercion/internal/private"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/element-of-surprise/coercion/workflow/storage"
+	"github.com/go-json-experiment/json"
+	"github.com/google/uuid"
+	"zombiezen.com/go/sqlite"
 	"zombiezen.com/go/sqlite/sqlitex"
 )
 
@@ -50,3 +53,96 @@ func (c checksUpdater) UpdateChecks(ctx context.Context, check *workflow.Checks)
 	return nil
 
 }
+
+// RecordCheckRun implements storage.ChecksUpdater.RecordCheckRun().
+func (c checksUpdater) RecordCheckRun(ctx context.Context, check *workflow.Checks, run *workflow.CheckRun) (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	conn, err := c.pool.Take(context.WithoutCancel(ctx))
+	if err != nil {
+		return fmt.Errorf("couldn't get a connection from the pool: %w", err)
+	}
+	defer c.pool.Put(conn)
+
+	defer sqlitex.Transaction(conn)(&err)
+
+	if err := commitCheckRun(conn, check, run); err != nil {
+		return fmt.Errorf("ChecksWriter.RecordCheckRun: %w", err)
+	}
+
+	stmt, err := conn.Prepare(deleteCheckRuns)
+	if err != nil {
+		return fmt.Errorf("ChecksWriter.RecordCheckRun: %w", err)
+	}
+	stmt.SetText("$checks_id", check.ID.String())
+	stmt.SetInt64("$iteration", int64(run.Iteration-check.RunRetention()))
+
+	if _, err = stmt.Step(); err != nil {
+		return fmt.Errorf("ChecksWriter.RecordCheckRun: %w", err)
+	}
+	return nil
+}
+
+// commitCheckRun writes a run of the Checks to the check_runs table.
+func commitCheckRun(conn *sqlite.Conn, check *workflow.Checks, run *workflow.CheckRun) error {
+	stmt, err := conn.Prepare(insertCheckRun)
+	if err != nil {
+		return fmt.Errorf("conn.Prepare(insertCheckRun): %w", err)
+	}
+
+	actions, err := encodeActionRuns(check, run.Actions)
+	if err != nil {
+		return fmt.Errorf("can't encode run.Actions: %w", err)
+	}
+
+	stmt.SetText("$checks_id", check.ID.String())
+	stmt.SetInt64("$iteration", int64(run.Iteration))
+	if actions != nil {
+		stmt.SetBytes("$actions", actions)
+	} else {
+		stmt.SetNull("$actions")
+	}
+	stmt.SetInt64("$state_status", int64(run.Status))
+	stmt.SetInt64("$state_start", run.Start.UnixNano())
+	stmt.SetInt64("$state_end", run.End.UnixNano())
+
+	if _, err = stmt.Step(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// actionRunEntry is how a workflow.ActionRun is stored. Plugin is needed to decode the Attempts.
+type actionRunEntry struct {
+	ID       uuid.UUID
+	Plugin   string
+	Status   workflow.Status
+	Attempts []byte
+}
+
+// encodeActionRuns encodes the results of the Actions in a CheckRun. check is used to find
+// the plugin of each Action.
+func encodeActionRuns(check *workflow.Checks, runs []*workflow.ActionRun) ([]byte, error) {
+	if len(runs) == 0 {
+		return nil, nil
+	}
+
+	plugins := make(map[uuid.UUID]string, len(check.Actions))
+	for _, a := range check.Actions {
+		plugins[a.ID] = a.Plugin
+	}
+
+	entries := make([]actionRunEntry, 0, len(runs))
+	for _, r := range runs {
+		attempts, err := encodeAttempts(r.Attempts)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(
+			entries,
+			actionRunEntry{ID: r.ID, Plugin: plugins[r.ID], Status: r.Status, Attempts: attempts},
+		)
+	}
+	return json.Marshal(entries)
+}
@@ -0,0 +1,110 @@
+package sqlite
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	pluglib "github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/storage/sqlite/testing/plugins"
+	"github.com/element-of-surprise/coercion/workflow/utils/clone"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRecordCheckRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	path, pool, err := dbSetup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	defer pool.Close()
+
+	conn, err := pool.Take(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := commitPlan(ctx, conn, plan); err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(conn)
+
+	reg := registry.New()
+	reg.Register(&plugins.CheckPlugin{})
+	reg.Register(&plugins.HelloPlugin{})
+	reg.Register(plugins.NewTypedPlugin())
+
+	r := reader{pool: pool, reg: reg}
+	u := checksUpdater{mu: &sync.Mutex{}, pool: pool}
+
+	checks := clone.Checks(ctx, plan.ContChecks, clone.WithKeepState())
+	checks.Retention = 3
+
+	start := time.Now()
+	var want []*workflow.CheckRun
+	for i := 3; i <= 7; i++ {
+		run := &workflow.CheckRun{
+			Iteration: i,
+			Status:    workflow.Completed,
+			Start:     start.Add(time.Duration(i) * time.Second),
+			End:       start.Add(time.Duration(i)*time.Second + time.Millisecond),
+		}
+		for _, a := range checks.Actions {
+			ar := &workflow.ActionRun{ID: a.ID, Status: workflow.Completed}
+			if i%2 == 0 {
+				ar.Status = workflow.Failed
+				ar.Attempts = []*workflow.Attempt{
+					{Err: &pluglib.Error{Message: "error"}, Start: run.Start, End: run.End},
+				}
+			}
+			run.Actions = append(run.Actions, ar)
+		}
+		if err := u.RecordCheckRun(ctx, checks, run); err != nil {
+			t.Fatalf("TestRecordCheckRun: RecordCheckRun(%d): %v", i, err)
+		}
+		want = append(want, run)
+	}
+	// Only the last 3 runs are retained.
+	want = want[2:]
+
+	var got []*workflow.CheckRun
+	after := 0
+	for {
+		runs, err := r.CheckRuns(ctx, checks.ID, after, 2)
+		if err != nil {
+			t.Fatalf("TestRecordCheckRun: CheckRuns(): %v", err)
+		}
+		if len(runs) == 0 {
+			break
+		}
+		got = append(got, runs...)
+		after = runs[len(runs)-1].Iteration
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("TestRecordCheckRun: -want/+got:\n%s", diff)
+	}
+
+	stored, err := r.Read(ctx, plan.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, run := range want {
+		run.Actions = nil
+	}
+	if diff := cmp.Diff(want, stored.ContChecks.Runs); diff != "" {
+		t.Errorf("TestRecordCheckRun(Read): -want/+got:\n%s", diff)
+	}
+
+	if _, err := r.CheckRuns(ctx, checks.ID, 0, 0); err == nil {
+		t.Errorf("TestRecordCheckRun: CheckRuns(limit 0): got err == nil, want err != nil")
+	}
+}
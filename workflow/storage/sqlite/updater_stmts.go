@@ -17,6 +17,22 @@ SET
 	state_end = $state_end
 WHERE id = $id`
 
+const insertCheckRun = `
+INSERT INTO check_runs (
+	checks_id,
+	plan_id,
+	iteration,
+	actions,
+	state_status,
+	state_start,
+	state_end
+) VALUES ($checks_id, (SELECT plan_id FROM checks WHERE id = $checks_id), $iteration, $actions,
+$state_status, $state_start, $state_end)`
+
+const deleteCheckRuns = `
+DELETE FROM check_runs
+WHERE checks_id = $checks_id AND iteration <= $iteration`
+
 const updateBlock = `
 UPDATE blocks
 SET
@@ -37,6 +53,8 @@ const updateAction = `
 UPDATE actions
 SET
 	attempts = $attempts,
+	spent = $spent,
+	checkpoint = $checkpoint,
 	state_status = $state_status,
 	state_start = $state_start,
 	state_end = $state_end
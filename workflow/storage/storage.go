@@ -94,6 +94,10 @@ type Reader interface {
 	// ListPlans returns a list of all Plan IDs in the storage. This should
 	// return with most recent submiited first.
 	List(ctx context.Context, limit int) (chan Stream[ListResult], error)
+	// CheckRuns returns the runs of the Checks with the ID that have an Iteration greater than after,
+	// oldest first. At most limit runs are returned. Use the Iteration of the last run returned as after
+	// to get the next page. Unlike Read, the runs include the results of the Actions.
+	CheckRuns(ctx context.Context, checksID uuid.UUID, after int, limit int) ([]*workflow.CheckRun, error)
 
 	private.Storage
 }
@@ -130,6 +134,9 @@ type BlockUpdater interface {
 type ChecksUpdater interface {
 	// Update writes Checks states data to storage but not underlying data.
 	UpdateChecks(context.Context, *workflow.Checks) error
+	// RecordCheckRun writes a run of the Checks to storage. Runs older than the Checks' RunRetention()
+	// are removed.
+	RecordCheckRun(context.Context, *workflow.Checks, *workflow.CheckRun) error
 
 	private.Storage
 }
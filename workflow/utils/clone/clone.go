@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/element-of-surprise/coercion/workflow"
 
 	"github.com/brunoga/deep"
+	"github.com/gostdlib/ops/retry/exponential"
 )
 
 type cloneOptions struct {
@@ -88,10 +90,11 @@ func Plan(ctx context.Context, p *workflow.Plan, options ...Option) *workflow.Pl
 	copy(meta, p.Meta)
 
 	np := &workflow.Plan{
-		Name:    p.Name,
-		Descr:   p.Descr,
-		GroupID: p.GroupID,
-		Meta:    meta,
+		Name:        p.Name,
+		Descr:       p.Descr,
+		GroupID:     p.GroupID,
+		Meta:        meta,
+		MaxDuration: p.MaxDuration,
 	}
 
 	if opts.keepState {
@@ -157,13 +160,18 @@ func Checks(ctx context.Context, c *workflow.Checks, options ...Option) *workflo
 	opts.callNum++
 
 	clone := &workflow.Checks{
-		Delay:   c.Delay,
-		Actions: make([]*workflow.Action, len(c.Actions)),
+		Delay:     c.Delay,
+		Actions:   make([]*workflow.Action, len(c.Actions)),
+		Tolerance: cloneTolerance(c.Tolerance),
+		Retention: c.Retention,
+		Gate:      cloneGate(c.Gate),
+		Quorum:    cloneQuorum(c.Quorum),
 	}
 
 	if opts.keepState {
 		clone.ID = c.ID
 		clone.State = cloneState(c.State)
+		clone.Runs = cloneRuns(c.Runs)
 	}
 
 	for i := 0; i < len(c.Actions); i++ {
@@ -194,6 +202,7 @@ func Block(ctx context.Context, b *workflow.Block, options ...Option) *workflow.
 		Descr:             b.Descr,
 		EntranceDelay:     b.EntranceDelay,
 		ExitDelay:         b.ExitDelay,
+		MaxDuration:       b.MaxDuration,
 		Concurrency:       b.Concurrency,
 		ToleratedFailures: b.ToleratedFailures,
 	}
@@ -258,9 +267,10 @@ func Sequence(ctx context.Context, s *workflow.Sequence, options ...Option) *wor
 	opts.callNum++
 
 	ns := &workflow.Sequence{
-		Name:    s.Name,
-		Descr:   s.Descr,
-		Actions: make([]*workflow.Action, len(s.Actions)),
+		Name:        s.Name,
+		Descr:       s.Descr,
+		Actions:     make([]*workflow.Action, len(s.Actions)),
+		MaxDuration: s.MaxDuration,
 	}
 
 	if opts.keepState {
@@ -268,6 +278,16 @@ func Sequence(ctx context.Context, s *workflow.Sequence, options ...Option) *wor
 		ns.State = cloneState(s.State)
 	}
 
+	if s.PreChecks != nil {
+		ns.PreChecks = Checks(ctx, s.PreChecks, withOptions(opts))
+	}
+	if s.ContChecks != nil {
+		ns.ContChecks = Checks(ctx, s.ContChecks, withOptions(opts))
+	}
+	if s.PostChecks != nil {
+		ns.PostChecks = Checks(ctx, s.PostChecks, withOptions(opts))
+	}
+
 	for i, a := range s.Actions {
 		ns.Actions[i] = Action(ctx, a, withOptions(opts))
 	}
@@ -292,16 +312,24 @@ func Action(ctx context.Context, a *workflow.Action, options ...Option) *workflo
 	opts.callNum++
 
 	na := &workflow.Action{
-		Name:    a.Name,
-		Descr:   a.Descr,
-		Plugin:  a.Plugin,
-		Timeout: a.Timeout,
-		Retries: a.Retries,
-		Req:     deep.MustCopy(a.Req),
+		Name:        a.Name,
+		Descr:       a.Descr,
+		Plugin:      a.Plugin,
+		Version:     a.Version,
+		Timeout:     a.Timeout,
+		Retries:     a.Retries,
+		RetryPolicy: clonePolicy(a.RetryPolicy),
+		RetryOn:     slices.Clone(a.RetryOn),
+		PermanentOn: slices.Clone(a.PermanentOn),
+		MaxDuration: a.MaxDuration,
+		Req:         deep.MustCopy(a.Req),
 	}
 
 	if opts.keepState {
 		na.ID = a.ID
+		na.Spent = a.Spent
+		na.Checkpoint = slices.Clone(a.Checkpoint)
+		na.ReqVersion = a.ReqVersion
 		na.State = cloneState(a.State)
 		na.Attempts = cloneAttempts(a.Attempts)
 	}
@@ -313,6 +341,74 @@ func Action(ctx context.Context, a *workflow.Action, options ...Option) *workflo
 	return na
 }
 
+// cloneTolerance clones a *workflow.Tolerance.
+func cloneTolerance(t *workflow.Tolerance) *workflow.Tolerance {
+	if t == nil {
+		return nil
+	}
+	n := *t
+	return &n
+}
+
+// cloneGate clones a *workflow.Gate.
+func cloneGate(g *workflow.Gate) *workflow.Gate {
+	if g == nil {
+		return nil
+	}
+	n := *g
+	return &n
+}
+
+// cloneQuorum clones a *workflow.Quorum.
+func cloneQuorum(q *workflow.Quorum) *workflow.Quorum {
+	if q == nil {
+		return nil
+	}
+	n := *q
+	return &n
+}
+
+// cloneRuns clones a []*workflow.CheckRun.
+func cloneRuns(runs []*workflow.CheckRun) []*workflow.CheckRun {
+	if runs == nil {
+		return nil
+	}
+
+	n := make([]*workflow.CheckRun, len(runs))
+	for i, run := range runs {
+		r := *run
+		r.Actions = cloneActionRuns(run.Actions)
+		n[i] = &r
+	}
+	return n
+}
+
+// cloneActionRuns clones a []*workflow.ActionRun.
+func cloneActionRuns(runs []*workflow.ActionRun) []*workflow.ActionRun {
+	if runs == nil {
+		return nil
+	}
+
+	n := make([]*workflow.ActionRun, len(runs))
+	for i, run := range runs {
+		n[i] = &workflow.ActionRun{
+			ID:       run.ID,
+			Status:   run.Status,
+			Attempts: cloneAttempts(run.Attempts),
+		}
+	}
+	return n
+}
+
+// clonePolicy clones an *exponential.Policy.
+func clonePolicy(p *exponential.Policy) *exponential.Policy {
+	if p == nil {
+		return nil
+	}
+	n := *p
+	return &n
+}
+
 // cloneState clones a *workflow.State.
 func cloneState(state *workflow.State) *workflow.State {
 	if state == nil {
@@ -335,10 +431,15 @@ func cloneAttempts(attempts []*workflow.Attempt) []*workflow.Attempt {
 	sl := make([]*workflow.Attempt, 0, len(attempts))
 	for _, attempt := range attempts {
 		na := &workflow.Attempt{
-			Resp:  deep.MustCopy(attempt.Resp),
-			Err:   cloneErr(attempt.Err),
-			Start: attempt.Start,
-			End:   attempt.End,
+			Resp:          deep.MustCopy(attempt.Resp),
+			Err:           cloneErr(attempt.Err),
+			Stack:         attempt.Stack,
+			PluginVersion: attempt.PluginVersion,
+			Log:           slices.Clone(attempt.Log),
+			LogDropped:    attempt.LogDropped,
+			Progress:      attempt.Progress,
+			Start:         attempt.Start,
+			End:           attempt.End,
 		}
 		sl = append(sl, na)
 	}
@@ -464,6 +565,11 @@ func secureStruct(ptr reflect.Value) reflect.Value {
 	if _, ok := val.Interface().(time.Time); ok {
 		return ptr
 	}
+	// A RawJSON has no tags to find secrets with.
+	if raw, ok := val.Interface().(workflow.RawJSON); ok {
+		val.Set(reflect.ValueOf(raw.Redacted()))
+		return ptr
+	}
 
 	typ := val.Type()
 	for i := 0; i < val.NumField(); i++ {
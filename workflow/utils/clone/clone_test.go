@@ -8,6 +8,7 @@ import (
 	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/workflow"
 	"github.com/google/uuid"
+	"github.com/gostdlib/ops/retry/exponential"
 
 	"github.com/kylelemons/godebug/pretty"
 )
@@ -47,14 +48,15 @@ func TestPlan(t *testing.T) {
 	}
 
 	plan := &workflow.Plan{
-		ID:         id,
-		Name:       "plan1",
-		Descr:      "descr",
-		GroupID:    id,
-		Meta:       []byte("hello"),
-		PreChecks:  Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
-		PostChecks: Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
-		ContChecks: Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
+		ID:          id,
+		Name:        "plan1",
+		Descr:       "descr",
+		GroupID:     id,
+		Meta:        []byte("hello"),
+		MaxDuration: time.Hour,
+		PreChecks:   Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
+		PostChecks:  Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
+		ContChecks:  Checks(ctx, checks, WithKeepSecrets(), WithKeepState()),
 		Blocks: []*workflow.Block{
 			Block(ctx, block, WithKeepSecrets(), WithKeepState()),
 		},
@@ -79,14 +81,15 @@ func TestPlan(t *testing.T) {
 			name: "no options",
 			plan: plan,
 			want: &workflow.Plan{
-				Name:       "plan1",
-				Descr:      "descr",
-				GroupID:    id,
-				Meta:       []byte("hello"),
-				PreChecks:  Checks(ctx, checks),
-				PostChecks: Checks(ctx, checks),
-				ContChecks: Checks(ctx, checks),
-				Blocks:     []*workflow.Block{Block(ctx, plan.Blocks[0])},
+				Name:        "plan1",
+				Descr:       "descr",
+				GroupID:     id,
+				Meta:        []byte("hello"),
+				MaxDuration: time.Hour,
+				PreChecks:   Checks(ctx, checks),
+				PostChecks:  Checks(ctx, checks),
+				ContChecks:  Checks(ctx, checks),
+				Blocks:      []*workflow.Block{Block(ctx, plan.Blocks[0])},
 			},
 		},
 		{
@@ -100,15 +103,16 @@ func TestPlan(t *testing.T) {
 			plan:    plan,
 			options: cloneOptions{keepState: true},
 			want: &workflow.Plan{
-				ID:         id,
-				Name:       "plan1",
-				Descr:      "descr",
-				GroupID:    id,
-				Meta:       []byte("hello"),
-				PreChecks:  Checks(ctx, checks, WithKeepState()),
-				PostChecks: Checks(ctx, checks, WithKeepState()),
-				ContChecks: Checks(ctx, checks, WithKeepState()),
-				Blocks:     []*workflow.Block{Block(ctx, plan.Blocks[0], WithKeepState())},
+				ID:          id,
+				Name:        "plan1",
+				Descr:       "descr",
+				GroupID:     id,
+				Meta:        []byte("hello"),
+				MaxDuration: time.Hour,
+				PreChecks:   Checks(ctx, checks, WithKeepState()),
+				PostChecks:  Checks(ctx, checks, WithKeepState()),
+				ContChecks:  Checks(ctx, checks, WithKeepState()),
+				Blocks:      []*workflow.Block{Block(ctx, plan.Blocks[0], WithKeepState())},
 				State: &workflow.State{
 					Status: workflow.Completed,
 					Start:  start,
@@ -122,14 +126,15 @@ func TestPlan(t *testing.T) {
 			plan:    plan,
 			options: cloneOptions{keepSecrets: true, callNum: 1},
 			want: &workflow.Plan{
-				Name:       "plan1",
-				Descr:      "descr",
-				GroupID:    id,
-				Meta:       []byte("hello"),
-				PreChecks:  Checks(ctx, checks, WithKeepSecrets()),
-				PostChecks: Checks(ctx, checks, WithKeepSecrets()),
-				ContChecks: Checks(ctx, checks, WithKeepSecrets()),
-				Blocks:     []*workflow.Block{Block(ctx, plan.Blocks[0], WithKeepSecrets())},
+				Name:        "plan1",
+				Descr:       "descr",
+				GroupID:     id,
+				Meta:        []byte("hello"),
+				MaxDuration: time.Hour,
+				PreChecks:   Checks(ctx, checks, WithKeepSecrets()),
+				PostChecks:  Checks(ctx, checks, WithKeepSecrets()),
+				ContChecks:  Checks(ctx, checks, WithKeepSecrets()),
+				Blocks:      []*workflow.Block{Block(ctx, plan.Blocks[0], WithKeepSecrets())},
 			},
 		},
 	}
@@ -172,6 +177,7 @@ func TestBlock(t *testing.T) {
 		Descr:         "descr",
 		EntranceDelay: 1 * time.Second,
 		ExitDelay:     1 * time.Second,
+		MaxDuration:   time.Minute,
 		PreChecks: &workflow.Checks{
 			Actions: []*workflow.Action{
 				Action(ctx, action, WithKeepState(), WithKeepSecrets()),
@@ -214,6 +220,7 @@ func TestBlock(t *testing.T) {
 				Descr:         "descr",
 				EntranceDelay: 1 * time.Second,
 				ExitDelay:     1 * time.Second,
+				MaxDuration:   time.Minute,
 				PreChecks: &workflow.Checks{
 					Actions: []*workflow.Action{
 						actionSecretRemoved,
@@ -252,6 +259,7 @@ func TestBlock(t *testing.T) {
 				Descr:         "descr",
 				EntranceDelay: 1 * time.Second,
 				ExitDelay:     1 * time.Second,
+				MaxDuration:   time.Minute,
 				PreChecks: &workflow.Checks{
 					Actions: []*workflow.Action{
 						actionSecretRemoved,
@@ -286,6 +294,7 @@ func TestBlock(t *testing.T) {
 				Descr:         "descr",
 				EntranceDelay: 1 * time.Second,
 				ExitDelay:     1 * time.Second,
+				MaxDuration:   time.Minute,
 				PreChecks: &workflow.Checks{
 					Actions: []*workflow.Action{
 						action,
@@ -337,6 +346,13 @@ func TestChecks(t *testing.T) {
 				Req:  Req{Data: "Hello"},
 			},
 		},
+		Tolerance: &workflow.Tolerance{Consecutive: 2},
+		Retention: 10,
+		Gate:      &workflow.Gate{Interval: time.Second, Timeout: time.Minute},
+		Quorum:    &workflow.Quorum{Percent: 50},
+		Runs: []*workflow.CheckRun{
+			{Status: workflow.Failed, Start: start, End: start},
+		},
 		State: &workflow.State{
 			Status: workflow.Completed,
 			Start:  start,
@@ -359,7 +375,11 @@ func TestChecks(t *testing.T) {
 			name:   "no options",
 			checks: checks,
 			want: &workflow.Checks{
-				Delay: 1 * time.Second,
+				Delay:     1 * time.Second,
+				Tolerance: &workflow.Tolerance{Consecutive: 2},
+				Retention: 10,
+				Gate:      &workflow.Gate{Interval: time.Second, Timeout: time.Minute},
+				Quorum:    &workflow.Quorum{Percent: 50},
 				Actions: []*workflow.Action{
 					{
 						Name: "action1",
@@ -386,7 +406,11 @@ func TestChecks(t *testing.T) {
 			checks:  checks,
 			options: cloneOptions{keepSecrets: false, callNum: 1},
 			want: &workflow.Checks{
-				Delay: 1 * time.Second,
+				Delay:     1 * time.Second,
+				Tolerance: &workflow.Tolerance{Consecutive: 2},
+				Retention: 10,
+				Gate:      &workflow.Gate{Interval: time.Second, Timeout: time.Minute},
+				Quorum:    &workflow.Quorum{Percent: 50},
 				Actions: []*workflow.Action{
 					{
 						Name: "action1",
@@ -432,6 +456,10 @@ func TestSequence(t *testing.T) {
 		ID:    id,
 		Name:  "name",
 		Descr: "descr",
+		PreChecks: &workflow.Checks{
+			Delay:   time.Second,
+			Actions: []*workflow.Action{{Name: "check"}},
+		},
 		Actions: []*workflow.Action{
 			{
 				Name: "action1",
@@ -461,6 +489,10 @@ func TestSequence(t *testing.T) {
 			want: &workflow.Sequence{
 				Name:  "name",
 				Descr: "descr",
+				PreChecks: &workflow.Checks{
+					Delay:   time.Second,
+					Actions: []*workflow.Action{{Name: "check"}},
+				},
 				Actions: []*workflow.Action{
 					{
 						Name: "action1",
@@ -489,6 +521,10 @@ func TestSequence(t *testing.T) {
 			want: &workflow.Sequence{
 				Name:  "name",
 				Descr: "descr",
+				PreChecks: &workflow.Checks{
+					Delay:   time.Second,
+					Actions: []*workflow.Action{{Name: "check"}},
+				},
 				Actions: []*workflow.Action{
 					{
 						Name: "action1",
@@ -530,15 +566,22 @@ func TestAction(t *testing.T) {
 	}
 
 	action := &workflow.Action{
-		ID:     id,
-		Name:   "name",
-		Descr:  "descr",
-		Plugin: "plugin",
+		ID:      id,
+		Name:    "name",
+		Descr:   "descr",
+		Plugin:  "plugin",
+		Version: "^1.2.0",
 		Req: Req{
 			Data: "hello",
 		},
-		Timeout: 10 * time.Second,
-		Retries: 2,
+		Timeout:     10 * time.Second,
+		Retries:     2,
+		RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+		RetryOn:     []plugins.ErrCode{1},
+		PermanentOn: []plugins.ErrCode{2},
+		MaxDuration: time.Minute,
+		Spent:       time.Second,
+		Checkpoint:  []byte("checkpoint"),
 		Attempts: []*workflow.Attempt{
 			{Start: start},
 		},
@@ -564,14 +607,19 @@ func TestAction(t *testing.T) {
 			name:   "no options",
 			action: action,
 			want: &workflow.Action{
-				Name:   "name",
-				Descr:  "descr",
-				Plugin: "plugin",
+				Name:    "name",
+				Descr:   "descr",
+				Plugin:  "plugin",
+				Version: "^1.2.0",
 				Req: Req{
 					Data: SecureStr,
 				},
-				Timeout: 10 * time.Second,
-				Retries: 2,
+				Timeout:     10 * time.Second,
+				Retries:     2,
+				RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+				RetryOn:     []plugins.ErrCode{1},
+				PermanentOn: []plugins.ErrCode{2},
+				MaxDuration: time.Minute,
 			},
 		},
 		{
@@ -586,15 +634,22 @@ func TestAction(t *testing.T) {
 			options:    cloneOptions{keepState: true},
 			replaceReq: Req{Data: SecureStr},
 			want: &workflow.Action{
-				ID:     id,
-				Name:   "name",
-				Descr:  "descr",
-				Plugin: "plugin",
+				ID:      id,
+				Name:    "name",
+				Descr:   "descr",
+				Plugin:  "plugin",
+				Version: "^1.2.0",
 				Req: Req{
 					Data: SecureStr,
 				},
-				Timeout: 10 * time.Second,
-				Retries: 2,
+				Timeout:     10 * time.Second,
+				Retries:     2,
+				RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+				RetryOn:     []plugins.ErrCode{1},
+				PermanentOn: []plugins.ErrCode{2},
+				MaxDuration: time.Minute,
+				Spent:       time.Second,
+				Checkpoint:  []byte("checkpoint"),
 				Attempts: []*workflow.Attempt{
 					{Start: start},
 				},
@@ -609,14 +664,19 @@ func TestAction(t *testing.T) {
 			action:  action,
 			options: cloneOptions{callNum: 1},
 			want: &workflow.Action{
-				Name:   "name",
-				Descr:  "descr",
-				Plugin: "plugin",
+				Name:    "name",
+				Descr:   "descr",
+				Plugin:  "plugin",
+				Version: "^1.2.0",
 				Req: Req{
 					Data: "hello",
 				},
-				Timeout: 10 * time.Second,
-				Retries: 2,
+				Timeout:     10 * time.Second,
+				Retries:     2,
+				RetryPolicy: &exponential.Policy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: time.Minute},
+				RetryOn:     []plugins.ErrCode{1},
+				PermanentOn: []plugins.ErrCode{2},
+				MaxDuration: time.Minute,
 			},
 		},
 	}
@@ -641,6 +701,42 @@ func TestAction(t *testing.T) {
 	}
 }
 
+func TestActionRawJSON(t *testing.T) {
+	t.Parallel()
+
+	raw := workflow.RawJSON{JSON: []byte(`{"Password":"secret","User":"me"}`), Reason: "plugin not found"}
+	redacted := workflow.RawJSON{JSON: []byte(`{"Password":"[secret hidden]","User":"me"}`), Reason: "plugin not found"}
+
+	action := &workflow.Action{
+		Name:     "name",
+		Plugin:   "plugin",
+		Req:      raw,
+		Attempts: []*workflow.Attempt{{Resp: raw}},
+	}
+
+	tests := []struct {
+		name    string
+		options []Option
+		want    workflow.RawJSON
+	}{
+		{name: "Secrets removed", options: []Option{WithKeepState()}, want: redacted},
+		{name: "Secrets kept", options: []Option{WithKeepState(), WithKeepSecrets()}, want: raw},
+	}
+
+	for _, test := range tests {
+		got := Action(context.Background(), action, test.options...)
+		if diff := pretty.Compare(test.want, got.Req); diff != "" {
+			t.Errorf("TestActionRawJSON(%s): Req: -want/+got:\n%s", test.name, diff)
+		}
+		if diff := pretty.Compare(test.want, got.Attempts[0].Resp); diff != "" {
+			t.Errorf("TestActionRawJSON(%s): Resp: -want/+got:\n%s", test.name, diff)
+		}
+	}
+	if diff := pretty.Compare(raw, action.Req); diff != "" {
+		t.Errorf("TestActionRawJSON: original was changed: -want/+got:\n%s", diff)
+	}
+}
+
 func TestCloneState(t *testing.T) {
 	t.Parallel()
 
@@ -724,8 +820,13 @@ func TestCloneAttempts(t *testing.T) {
 						Message:   "not found",
 						Permanent: true,
 					},
-					Start: start,
-					End:   end,
+					Stack:         "goroutine 1 [running]:",
+					PluginVersion: "1.2.0",
+					Log:           []workflow.LogLine{{Time: start, Text: "working"}},
+					LogDropped:    2,
+					Progress:      50,
+					Start:         start,
+					End:           end,
 				},
 			},
 			want: []*workflow.Attempt{
@@ -748,8 +849,13 @@ func TestCloneAttempts(t *testing.T) {
 						Message:   "not found",
 						Permanent: true,
 					},
-					Start: start,
-					End:   end,
+					Stack:         "goroutine 1 [running]:",
+					PluginVersion: "1.2.0",
+					Log:           []workflow.LogLine{{Time: start, Text: "working"}},
+					LogDropped:    2,
+					Progress:      50,
+					Start:         start,
+					End:           end,
 				},
 			},
 		},
@@ -18,6 +18,7 @@ import (
 	"github.com/element-of-surprise/coercion/workflow/utils/html/internal/embedded"
 	"github.com/element-of-surprise/coercion/workflow/utils/walk"
 
+	"github.com/google/uuid"
 	"github.com/spf13/afero"
 
 	_ "embed"
@@ -42,11 +43,40 @@ func (bp *bufPool) Put(b *bytes.Buffer) {
 }
 
 type renderOptions struct {
+	runs CheckRunsReader
 }
 
 // RenderOption is an optional argument for Render.
 type RenderOption func(renderOptions) (renderOptions, error)
 
+// CheckRunsReader reads the history of runs of continuous checks. storage.Vault implements this.
+type CheckRunsReader interface {
+	CheckRuns(ctx context.Context, checksID uuid.UUID, after int, limit int) ([]*workflow.CheckRun, error)
+}
+
+// WithCheckRuns has Render read the full history of the continuous checks from r, which includes
+// the results of the Actions of each run. Without this, only the Checks.Runs in the Plan are rendered.
+func WithCheckRuns(r CheckRunsReader) RenderOption {
+	return func(opts renderOptions) (renderOptions, error) {
+		if r == nil {
+			return opts, fmt.Errorf("WithCheckRuns: reader cannot be nil")
+		}
+		opts.runs = r
+		return opts, nil
+	}
+}
+
+// checkRunsPage is the data used to render the checkruns.tmpl.
+type checkRunsPage struct {
+	Checks *workflow.Checks
+	Runs   []*workflow.CheckRun
+	// Names are the names of Checks.Actions by their ID.
+	Names map[uuid.UUID]string
+}
+
+// checkRunsPageSize is the number of runs read at a time with a CheckRunsReader.
+const checkRunsPageSize = 100
+
 var bufferPool = &bufPool{
 	pool: sync.Pool{
 		New: func() any {
@@ -88,6 +118,23 @@ func Render(ctx context.Context, plan *workflow.Plan, options ...RenderOption) (
 		defer bufferPool.Put(b)
 
 		switch item.Value.Type() {
+		case workflow.OTCheck:
+			checks := item.Checks()
+			// Only continuous checks have runs.
+			if len(checks.Runs) == 0 {
+				continue
+			}
+			page, err := newCheckRunsPage(ctx, checks, opts.runs)
+			if err != nil {
+				return nil, err
+			}
+			if err := embedded.Tmpls.ExecuteTemplate(b, "checkruns.tmpl", page); err != nil {
+				return nil, err
+			}
+			fs.Mkdir("checks", 0755)
+			if err := afero.WriteFile(fs, fmt.Sprintf("checks/%s.html", checks.ID), b.Bytes(), 0644); err != nil {
+				return nil, err
+			}
 		case workflow.OTSequence:
 			seq := item.Sequence()
 			if err := embedded.Tmpls.ExecuteTemplate(b, "sequence.tmpl", seq); err != nil {
@@ -113,6 +160,31 @@ func Render(ctx context.Context, plan *workflow.Plan, options ...RenderOption) (
 	return FS{fs}, nil
 }
 
+// newCheckRunsPage returns the data to render the runs of checks. If r is nil, checks.Runs is used.
+func newCheckRunsPage(ctx context.Context, checks *workflow.Checks, r CheckRunsReader) (checkRunsPage, error) {
+	page := checkRunsPage{Checks: checks, Runs: checks.Runs, Names: make(map[uuid.UUID]string, len(checks.Actions))}
+	for _, a := range checks.Actions {
+		page.Names[a.ID] = a.Name
+	}
+	if r == nil {
+		return page, nil
+	}
+
+	page.Runs = nil
+	after := 0
+	for {
+		runs, err := r.CheckRuns(ctx, checks.ID, after, checkRunsPageSize)
+		if err != nil {
+			return checkRunsPage{}, fmt.Errorf("couldn't read the runs of checks(%s): %w", checks.ID, err)
+		}
+		if len(runs) == 0 {
+			return page, nil
+		}
+		page.Runs = append(page.Runs, runs...)
+		after = runs[len(runs)-1].Iteration
+	}
+}
+
 type downloadOptions struct {
 	executable    bool
 	renderOptions []RenderOption
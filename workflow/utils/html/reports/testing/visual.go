@@ -32,6 +32,11 @@ func makePlan() *workflow.Plan {
 				Resp: Resp{
 					FieldA: "FieldA",
 				},
+				Log: []workflow.LogLine{
+					{Time: time.Now(), Text: "starting"},
+					{Time: time.Now(), Text: "done"},
+				},
+				Progress: 100,
 			}
 		}
 		return &workflow.Action{
@@ -64,6 +69,12 @@ func makePlan() *workflow.Plan {
 				actionWithAttempts("Check Site is Reliable", workflow.Completed, 1),
 				actionWithAttempts("Check Network Connectivity", workflow.Completed, 1),
 			},
+			Tolerance: &workflow.Tolerance{Failures: 2, Window: 5},
+			Runs: []*workflow.CheckRun{
+				{Iteration: 1, Status: workflow.Completed, Start: time.Now().Add(-2 * time.Minute), End: time.Now().Add(-2 * time.Minute)},
+				{Iteration: 2, Status: workflow.Failed, Start: time.Now().Add(-1 * time.Minute), End: time.Now().Add(-1 * time.Minute)},
+				{Iteration: 3, Status: workflow.Completed, Start: time.Now(), End: time.Now()},
+			},
 		},
 		Blocks: []*workflow.Block{
 			{
@@ -82,6 +93,13 @@ func makePlan() *workflow.Plan {
 						ID:    uuid.New(),
 						Name:  "Setup Kubernetes Cluster",
 						State: &workflow.State{Status: workflow.Completed},
+						PreChecks: &workflow.Checks{
+							ID:    uuid.New(),
+							State: &workflow.State{Status: workflow.Completed},
+							Actions: []*workflow.Action{
+								actionWithAttempts("Check Cluster Quota", workflow.Completed, 1),
+							},
+						},
 						Actions: []*workflow.Action{
 							actionWithAttempts("Setup Kubernetes Cluster", workflow.Running, 2),
 						},
@@ -0,0 +1,445 @@
+/*
+Package replay records the requests and responses of the plugins in a Plan that was run, and replays them through
+substitute plugins. This allows a program that builds Plans to be tested against the behavior of a real run, without
+the infrastructure the plugins need.
+
+A Recording is made from a Plan read from a storage.Vault after it ran, and saved to a file:
+
+	plan, err := vault.Read(ctx, id)
+	...
+	rec, err := replay.Record(ctx, plan)
+	...
+	err = rec.Write(f)
+
+In a test, a Replayer provides a registry.Register with a substitute for each plugin. A substitute has the name,
+version and types of the real plugin, but Execute() returns the recorded responses and Init() does nothing:
+
+	rec, err := replay.Read(f)
+	...
+	replayer, err := replay.New(rec, realRegistry)
+	...
+	ws, err := workstream.New(ctx, replayer.Registry(), vault)
+	// Build the Plan with the program under test, then submit and run it.
+	...
+	for _, m := range replayer.Mismatches() {
+		t.Error(m)
+	}
+
+Actions are matched to the Recording by their Path in the Plan. The recorded Attempts are replayed by attempt number,
+and the last one is repeated if there are more attempts than were recorded, such as for ContChecks. A request that is
+different from the recorded request is a Mismatch with a diff, as is an Action that was not in the Recording.
+
+Fields with the coerce:"secure" tag are not recorded, so they are ignored when requests are compared.
+*/
+package replay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/element-of-surprise/coercion/plugins"
+	"github.com/element-of-surprise/coercion/plugins/execinfo"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/element-of-surprise/coercion/workflow/utils/clone"
+	"github.com/element-of-surprise/coercion/workflow/utils/walk"
+	"github.com/go-json-experiment/json"
+	"github.com/go-json-experiment/json/jsontext"
+	"github.com/google/uuid"
+	"github.com/gostdlib/ops/retry/exponential"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+// Recording holds the requests and responses of the Actions of a Plan that was run.
+type Recording struct {
+	// PlanID is the ID of the Plan that was recorded.
+	PlanID uuid.UUID
+	// PlanName is the name of the Plan that was recorded.
+	PlanName string
+	// Actions are the recorded Actions, in the order they are walked in the Plan.
+	Actions []Action
+}
+
+// Action is the record of an Action.
+type Action struct {
+	// Path locates the Action in the Plan. See Path().
+	Path string
+	// Plugin is the name of the plugin of the Action.
+	Plugin string
+	// Req is the request of the Action as JSON.
+	Req jsontext.Value
+	// Attempts are the attempts of the Action.
+	Attempts []Attempt `json:",omitempty"`
+}
+
+// Attempt is the record of an attempt of an Action.
+type Attempt struct {
+	// Resp is the response as JSON. It is not set if the attempt failed.
+	Resp jsontext.Value `json:",omitempty"`
+	// Err is the error of the attempt.
+	Err *plugins.Error `json:",omitempty"`
+	// PluginVersion is the version of the plugin that executed the attempt.
+	PluginVersion string `json:",omitempty"`
+}
+
+// Record returns a Recording of plan, which is usually read from a storage.Vault after it ran.
+func Record(ctx context.Context, plan *workflow.Plan) (*Recording, error) {
+	if plan == nil {
+		return nil, fmt.Errorf("plan is nil")
+	}
+
+	rec := &Recording{PlanID: plan.ID, PlanName: plan.Name}
+	for item := range walk.Plan(ctx, plan) {
+		if item.Value.Type() != workflow.OTAction {
+			continue
+		}
+		// Cloning without secrets zeroes the fields with the coerce:"secure" tag.
+		action := clone.Action(ctx, item.Action(), clone.WithKeepState())
+		path := Path(item.Chain, item.Action())
+
+		req, err := json.Marshal(action.Req)
+		if err != nil {
+			return nil, fmt.Errorf("Action(%s) request: %w", path, err)
+		}
+		ra := Action{Path: path, Plugin: action.Plugin, Req: req}
+		for _, a := range action.Attempts {
+			at := Attempt{Err: a.Err, PluginVersion: a.PluginVersion}
+			if a.Err == nil && a.Resp != nil {
+				at.Resp, err = json.Marshal(a.Resp)
+				if err != nil {
+					return nil, fmt.Errorf("Action(%s) response: %w", path, err)
+				}
+			}
+			ra.Attempts = append(ra.Attempts, at)
+		}
+		rec.Actions = append(rec.Actions, ra)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// Write writes the Recording to w as JSON.
+func (r *Recording) Write(w io.Writer) error {
+	return json.MarshalWrite(w, r, json.Deterministic(true), jsontext.WithIndent("  "))
+}
+
+// Read reads a Recording written by Recording.Write() from r.
+func Read(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+	if err := json.UnmarshalRead(r, rec); err != nil {
+		return nil, fmt.Errorf("couldn't read recording: %w", err)
+	}
+	return rec, nil
+}
+
+// Path returns the path of action in a Plan, where chain is the chain of objects that led to it, starting with
+// the Plan. The path is made of the names of the Blocks and Sequences, the kind of Checks and the name of the
+// Action, such as "block0/seq/deploy" or "block0/PreChecks/healthy". If a parent has several children
+// with the same name, the index among them is added after a "#" for all but the first, such as "seq#1".
+// Names are used so that the path does not change when objects with other names are added to the Plan.
+func Path(chain []workflow.Object, action *workflow.Action) string {
+	var parts []string
+	for i := 1; i < len(chain); i++ {
+		parts = append(parts, pathPart(chain[i-1], chain[i]))
+	}
+	if len(chain) > 0 {
+		parts = append(parts, pathPart(chain[len(chain)-1], action))
+	}
+	return strings.Join(parts, "/")
+}
+
+// pathPart returns the part of a path for obj, whose parent is parent.
+func pathPart(parent, obj workflow.Object) string {
+	if c, ok := obj.(*workflow.Checks); ok {
+		return checksName(parent, c)
+	}
+
+	var siblings []workflow.Object
+	switch p := parent.(type) {
+	case *workflow.Plan:
+		for _, b := range p.Blocks {
+			siblings = append(siblings, b)
+		}
+	case *workflow.Block:
+		for _, s := range p.Sequences {
+			siblings = append(siblings, s)
+		}
+	case *workflow.Sequence:
+		for _, a := range p.Actions {
+			siblings = append(siblings, a)
+		}
+	case *workflow.Checks:
+		for _, a := range p.Actions {
+			siblings = append(siblings, a)
+		}
+	}
+
+	name := objName(obj)
+	n := 0
+	for _, s := range siblings {
+		if s == obj {
+			break
+		}
+		if objName(s) == name {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Sprintf("%s#%d", name, n)
+	}
+	return name
+}
+
+// checksName returns the name of the field of parent that holds c.
+func checksName(parent workflow.Object, c *workflow.Checks) string {
+	var pre, cont, post *workflow.Checks
+	switch p := parent.(type) {
+	case *workflow.Plan:
+		pre, cont, post = p.PreChecks, p.ContChecks, p.PostChecks
+	case *workflow.Block:
+		pre, cont, post = p.PreChecks, p.ContChecks, p.PostChecks
+	case *workflow.Sequence:
+		pre, cont, post = p.PreChecks, p.ContChecks, p.PostChecks
+	}
+	switch c {
+	case pre:
+		return "PreChecks"
+	case cont:
+		return "ContChecks"
+	case post:
+		return "PostChecks"
+	}
+	return "Checks"
+}
+
+func objName(obj workflow.Object) string {
+	switch o := obj.(type) {
+	case *workflow.Block:
+		return o.Name
+	case *workflow.Sequence:
+		return o.Name
+	case *workflow.Action:
+		return o.Name
+	}
+	return ""
+}
+
+// Mismatch is a difference between an execution during a replay and the Recording.
+type Mismatch struct {
+	// Path is the path of the Action. See Path().
+	Path string
+	// Plugin is the name of the plugin that was executed.
+	Plugin string
+	// Diff describes the difference. For a request that is different from the recorded one, this is
+	// a diff of the recorded request and the request.
+	Diff string
+}
+
+// String implements fmt.Stringer.
+func (m Mismatch) String() string {
+	return fmt.Sprintf("Action(%s) with plugin(%s): %s", m.Path, m.Plugin, m.Diff)
+}
+
+// Replayer replays a Recording through substitute plugins. Safe for concurrent use.
+type Replayer struct {
+	actions map[string]Action
+	reg     *registry.Register
+
+	mu         sync.Mutex
+	mismatches []Mismatch
+	played     map[string]bool
+}
+
+// New creates a Replayer for rec. reg holds the real plugins, which are used for their names, versions and
+// types. The real plugins are not executed or initialized.
+func New(rec *Recording, reg *registry.Register) (*Replayer, error) {
+	if rec == nil {
+		return nil, fmt.Errorf("recording is nil")
+	}
+
+	r := &Replayer{
+		actions: make(map[string]Action, len(rec.Actions)),
+		reg:     registry.New(),
+		played:  map[string]bool{},
+	}
+	for _, a := range rec.Actions {
+		if _, ok := r.actions[a.Path]; ok {
+			return nil, fmt.Errorf("recording has more than one Action at path %q", a.Path)
+		}
+		r.actions[a.Path] = a
+	}
+	for p := range reg.Plugins() {
+		if err := r.reg.Register(&plugin{Plugin: p, replayer: r}); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Registry returns a registry.Register with a substitute for each real plugin.
+func (r *Replayer) Registry() *registry.Register {
+	return r.reg
+}
+
+// Mismatches returns the Mismatches found so far.
+func (r *Replayer) Mismatches() []Mismatch {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.mismatches)
+}
+
+// Unplayed returns the paths of the recorded Actions that were not executed, such as Actions that were
+// removed from the Plan.
+func (r *Replayer) Unplayed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []string
+	for path := range r.actions {
+		if !r.played[path] {
+			out = append(out, path)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func (r *Replayer) mismatch(m Mismatch) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mismatches = append(r.mismatches, m)
+}
+
+// execute replays the Recording for an execution of p.
+func (r *Replayer) execute(ctx context.Context, p plugins.Plugin, req any) (any, *plugins.Error) {
+	info, ok := execinfo.From(ctx)
+	if !ok {
+		return nil, &plugins.Error{Message: "replay: execution has no execinfo.Info", Permanent: true}
+	}
+	action := findAction(info)
+	if action == nil {
+		return nil, &plugins.Error{Message: fmt.Sprintf("replay: Action(%s) was not found in its chain", info.ActionName), Permanent: true}
+	}
+	path := Path(info.Chain, action)
+
+	rec, ok := r.actions[path]
+	if !ok {
+		r.mismatch(Mismatch{Path: path, Plugin: p.Name(), Diff: "Action is not in the recording"})
+		return nil, &plugins.Error{Message: fmt.Sprintf("replay: Action(%s) is not in the recording", path), Permanent: true}
+	}
+	r.mu.Lock()
+	r.played[path] = true
+	r.mu.Unlock()
+
+	if rec.Plugin != p.Name() {
+		r.mismatch(Mismatch{Path: path, Plugin: p.Name(), Diff: fmt.Sprintf("recorded with plugin(%s)", rec.Plugin)})
+	}
+	if diff, err := diffReq(ctx, rec.Req, req); err != nil {
+		return nil, &plugins.Error{Message: fmt.Sprintf("replay: %s", err), Permanent: true}
+	} else if diff != "" {
+		r.mismatch(Mismatch{Path: path, Plugin: p.Name(), Diff: "request is different (-recorded/+got):\n" + diff})
+	}
+
+	if len(rec.Attempts) == 0 {
+		return nil, &plugins.Error{Message: fmt.Sprintf("replay: Action(%s) has no recorded attempts", path), Permanent: true}
+	}
+	at := rec.Attempts[min(max(info.Attempt, 1), len(rec.Attempts))-1]
+	if at.Err != nil {
+		return nil, at.Err
+	}
+
+	resp := p.Response()
+	if len(at.Resp) == 0 {
+		return resp, nil
+	}
+	resp, err := plugins.DecodeInto(at.Resp, resp)
+	if err != nil {
+		return nil, &plugins.Error{Message: fmt.Sprintf("replay: couldn't decode the recorded response of Action(%s): %s", path, err), Permanent: true}
+	}
+	return resp, nil
+}
+
+// findAction returns the Action that info is for from the parent at the end of the chain.
+func findAction(info execinfo.Info) *workflow.Action {
+	if len(info.Chain) == 0 {
+		return nil
+	}
+	var actions []*workflow.Action
+	switch p := info.Chain[len(info.Chain)-1].(type) {
+	case *workflow.Sequence:
+		actions = p.Actions
+	case *workflow.Checks:
+		actions = p.Actions
+	}
+	for _, a := range actions {
+		if a.ID == info.ActionID {
+			return a
+		}
+	}
+	return nil
+}
+
+// diffReq returns a diff of the recorded request and req, or "" if they are the same. Fields with
+// the coerce:"secure" tag are removed from req, as they are not recorded.
+func diffReq(ctx context.Context, recorded jsontext.Value, req any) (string, error) {
+	secured := clone.Action(ctx, &workflow.Action{Req: req}).Req
+	b, err := json.Marshal(secured)
+	if err != nil {
+		return "", fmt.Errorf("couldn't encode the request: %w", err)
+	}
+	if bytes.Equal(b, recorded) {
+		return "", nil
+	}
+
+	var want, got any
+	if err := json.Unmarshal(recorded, &want); err != nil {
+		return "", fmt.Errorf("couldn't decode the recorded request: %w", err)
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		return "", fmt.Errorf("couldn't decode the request: %w", err)
+	}
+	return pretty.Compare(want, got), nil
+}
+
+// plugin is a substitute for a real plugin that replays a Recording.
+type plugin struct {
+	plugins.Plugin
+	replayer *Replayer
+}
+
+var _ plugins.Versioner = (*plugin)(nil)
+
+// Execute implements plugins.Plugin.Execute() by replaying the Recording.
+func (p *plugin) Execute(ctx context.Context, req any) (any, *plugins.Error) {
+	return p.replayer.execute(ctx, p.Plugin, req)
+}
+
+// Init implements plugins.Plugin.Init(). The real plugin is not initialized, as it is not executed.
+func (p *plugin) Init() error {
+	return nil
+}
+
+// Version implements plugins.Versioner with the version of the real plugin.
+func (p *plugin) Version() string {
+	return plugins.VersionOf(p.Plugin)
+}
+
+// RetryPolicy implements plugins.Plugin.RetryPolicy(). Retries are not delayed during a replay.
+func (p *plugin) RetryPolicy() exponential.Policy {
+	return exponential.Policy{
+		InitialInterval:     time.Millisecond,
+		Multiplier:          1.1,
+		RandomizationFactor: 0,
+		MaxInterval:         time.Millisecond,
+	}
+}
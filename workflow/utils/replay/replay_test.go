@@ -0,0 +1,167 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/element-of-surprise/coercion/internal/etoe"
+	"github.com/element-of-surprise/coercion/plugins/fake"
+	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/workflow"
+	"github.com/google/uuid"
+	"github.com/kylelemons/godebug/pretty"
+)
+
+type Req struct {
+	Target   string
+	Replicas int
+	Password string `coerce:"secure"`
+}
+
+type Resp struct {
+	Result string
+}
+
+func TestPath(t *testing.T) {
+	t.Parallel()
+
+	a0, a1, a2 := &workflow.Action{Name: "a"}, &workflow.Action{Name: "b"}, &workflow.Action{Name: "a"}
+	check := &workflow.Action{Name: "healthy"}
+	seq0 := &workflow.Sequence{Name: "seq", Actions: []*workflow.Action{a0, a1, a2}}
+	seq1 := &workflow.Sequence{Name: "seq"}
+	pre := &workflow.Checks{Actions: []*workflow.Action{check}}
+	block := &workflow.Block{Name: "block", PreChecks: pre, Sequences: []*workflow.Sequence{seq1, seq0}}
+	plan := &workflow.Plan{Blocks: []*workflow.Block{block}}
+
+	tests := []struct {
+		name   string
+		chain  []workflow.Object
+		action *workflow.Action
+		want   string
+	}{
+		{name: "First of a name", chain: []workflow.Object{plan, block, seq0}, action: a0, want: "block/seq#1/a"},
+		{name: "Unique name", chain: []workflow.Object{plan, block, seq0}, action: a1, want: "block/seq#1/b"},
+		{name: "Second of a name", chain: []workflow.Object{plan, block, seq0}, action: a2, want: "block/seq#1/a#1"},
+		{name: "Checks", chain: []workflow.Object{plan, block, pre}, action: check, want: "block/PreChecks/healthy"},
+	}
+
+	for _, test := range tests {
+		if got := Path(test.chain, test.action); got != test.want {
+			t.Errorf("TestPath(%s): got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// buildPlan builds a Plan with one Sequence of Actions with the requests in reqs.
+func buildPlan(t *testing.T, reqs ...Req) *workflow.Plan {
+	t.Helper()
+
+	actions := make([]*workflow.Action, 0, len(reqs))
+	for _, req := range reqs {
+		actions = append(actions, &workflow.Action{Name: req.Target, Descr: "deploy", Plugin: "fake/deploy", Req: req, Retries: 1})
+	}
+	return etoe.Plan(t, actions...)
+}
+
+func TestRecordReplay(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	// b fails once and is retried.
+	retried := fake.FailTimes(1, 7, fake.Succeed(Resp{Result: "deployed b"}))
+	real := fake.MustNew(
+		fake.Args[Req, Resp]{
+			Name: "fake/deploy",
+			ByRequest: func(req Req) fake.Behavior {
+				if req.Target == "b" {
+					return retried
+				}
+				return fake.Succeed(Resp{Result: "deployed " + req.Target})
+			},
+		},
+	)
+	reg := registry.New()
+	reg.MustRegister(real)
+
+	ran := etoe.Run(t, reg, buildPlan(t, Req{Target: "a", Password: "hunter2"}, Req{Target: "b"}, Req{Target: "c", Replicas: 1}))
+	rec, err := Record(ctx, ran)
+	if err != nil {
+		t.Fatalf("TestRecordReplay(Record): got err == %s, want err == nil", err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := rec.Write(buf); err != nil {
+		t.Fatalf("TestRecordReplay(Write): got err == %s, want err == nil", err)
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Errorf("TestRecordReplay(Write): recording holds a secure field:\n%s", buf.String())
+	}
+	rec, err = Read(buf)
+	if err != nil {
+		t.Fatalf("TestRecordReplay(Read): got err == %s, want err == nil", err)
+	}
+	if len(rec.Actions) != 3 || len(rec.Actions[1].Attempts) != 2 {
+		t.Fatalf("TestRecordReplay(Read): got %s, want 3 Actions with 2 Attempts for b", pretty.Sprint(rec))
+	}
+
+	calls := len(real.Calls())
+	replayer, err := New(rec, reg)
+	if err != nil {
+		t.Fatalf("TestRecordReplay(New): got err == %s, want err == nil", err)
+	}
+
+	// The Plan that is replayed changes the request of c and adds d. a and b are the same, and the
+	// password of a is not compared.
+	replayed := etoe.Run(
+		t,
+		replayer.Registry(),
+		buildPlan(t, Req{Target: "a", Password: "other"}, Req{Target: "b"}, Req{Target: "c", Replicas: 2}, Req{Target: "d"}),
+	)
+
+	if got := len(real.Calls()); got != calls {
+		t.Errorf("TestRecordReplay: real plugin was called %d times during the replay, want 0", got-calls)
+	}
+	actions := replayed.Blocks[0].Sequences[0].Actions
+	if got := actions[0].Attempts[0].Resp.(Resp).Result; got != "deployed a" {
+		t.Errorf("TestRecordReplay(a): got response %q, want %q", got, "deployed a")
+	}
+	b := actions[1].Attempts
+	if len(b) != 2 || b[0].Err == nil || b[0].Err.Code != 7 || b[1].Resp.(Resp).Result != "deployed b" {
+		t.Errorf("TestRecordReplay(b): got attempts %s, want a failure with code 7 and then a response", pretty.Sprint(b))
+	}
+	if replayed.State.Status != workflow.Failed {
+		t.Errorf("TestRecordReplay: got status %v, want %v because d is not in the recording", replayed.State.Status, workflow.Failed)
+	}
+
+	mismatches := replayer.Mismatches()
+	paths := map[string]string{}
+	for _, m := range mismatches {
+		paths[m.Path] = m.Diff
+	}
+	if len(mismatches) != 2 {
+		t.Fatalf("TestRecordReplay(Mismatches): got %v, want mismatches for c and d", mismatches)
+	}
+	if diff := paths["block/seq/c"]; !strings.Contains(diff, "request is different") {
+		t.Errorf("TestRecordReplay(Mismatches): got diff %q for c, want a request diff", diff)
+	}
+	if diff := paths["block/seq/d"]; diff != "Action is not in the recording" {
+		t.Errorf("TestRecordReplay(Mismatches): got diff %q for d, want it to not be in the recording", diff)
+	}
+	if got := replayer.Unplayed(); len(got) != 0 {
+		t.Errorf("TestRecordReplay(Unplayed): got %v, want none", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	if _, err := New(nil, registry.New()); err == nil {
+		t.Errorf("TestNew(nil recording): got err == nil, want err != nil")
+	}
+	rec := &Recording{PlanID: uuid.New(), Actions: []Action{{Path: "a"}, {Path: "a"}}}
+	if _, err := New(rec, registry.New()); err == nil {
+		t.Errorf("TestNew(duplicate path): got err == nil, want err != nil")
+	}
+}
@@ -3,6 +3,7 @@ package walk
 
 import (
 	"context"
+	"slices"
 
 	"github.com/element-of-surprise/coercion/workflow"
 )
@@ -106,7 +107,8 @@ func walkChecks(ctx context.Context, ch chan Item, chain []workflow.Object, chec
 		return false
 	}
 
-	chain = append(chain, checks)
+	// Clip the chain so that siblings appending to it do not overwrite each other's Chain.
+	chain = append(slices.Clip(chain), checks)
 	if checks.Actions != nil {
 		for _, action := range checks.Actions {
 			if ok := emit(ctx, ch, Item{Chain: chain, Value: action}); !ok {
@@ -123,7 +125,7 @@ func walkBlock(ctx context.Context, ch chan Item, chain []workflow.Object, block
 		return false
 	}
 
-	chain = append(chain, block)
+	chain = append(slices.Clip(chain), block)
 	if block.PreChecks != nil {
 		if ok := walkChecks(ctx, ch, chain, block.PreChecks); !ok {
 			return false
@@ -156,7 +158,17 @@ func walkSequence(ctx context.Context, ch chan Item, chain []workflow.Object, se
 		return false
 	}
 
-	chain = append(chain, sequence)
+	chain = append(slices.Clip(chain), sequence)
+	if sequence.PreChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, sequence.PreChecks); !ok {
+			return false
+		}
+	}
+	if sequence.ContChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, sequence.ContChecks); !ok {
+			return false
+		}
+	}
 	if sequence.Actions != nil {
 		for _, action := range sequence.Actions {
 			if ok := emit(ctx, ch, Item{Chain: chain, Value: action}); !ok {
@@ -164,6 +176,11 @@ func walkSequence(ctx context.Context, ch chan Item, chain []workflow.Object, se
 			}
 		}
 	}
+	if sequence.PostChecks != nil {
+		if ok := walkChecks(ctx, ch, chain, sequence.PostChecks); !ok {
+			return false
+		}
+	}
 	return true
 }
 
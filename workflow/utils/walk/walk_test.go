@@ -75,6 +75,16 @@ func TestPlan(t *testing.T) {
 					{
 						Name:  "plan_block_sequence",
 						Descr: "plan_block_sequence",
+						PreChecks: &workflow.Checks{
+							Actions: []*workflow.Action{
+								{Name: "plan_block_sequence_precheck_action"},
+							},
+						},
+						PostChecks: &workflow.Checks{
+							Actions: []*workflow.Action{
+								{Name: "plan_block_sequence_postcheck_action"},
+							},
+						},
 						Actions: []*workflow.Action{
 							{
 								Name:  "plan_block_action",
@@ -105,7 +115,11 @@ func TestPlan(t *testing.T) {
 		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].ContChecks},
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].ContChecks}, Value: plan.Blocks[0].ContChecks.Actions[0]},
 		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].Sequences[0]},
+		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].Sequences[0]}, Value: plan.Blocks[0].Sequences[0].PreChecks},
+		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].Sequences[0], plan.Blocks[0].Sequences[0].PreChecks}, Value: plan.Blocks[0].Sequences[0].PreChecks.Actions[0]},
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].Sequences[0]}, Value: plan.Blocks[0].Sequences[0].Actions[0]},
+		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].Sequences[0]}, Value: plan.Blocks[0].Sequences[0].PostChecks},
+		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].Sequences[0], plan.Blocks[0].Sequences[0].PostChecks}, Value: plan.Blocks[0].Sequences[0].PostChecks.Actions[0]},
 		{Chain: []workflow.Object{plan, plan.Blocks[0]}, Value: plan.Blocks[0].PostChecks},
 		{Chain: []workflow.Object{plan, plan.Blocks[0], plan.Blocks[0].PostChecks}, Value: plan.Blocks[0].PostChecks.Actions[0]},
 		{Chain: []workflow.Object{plan}, Value: plan.PostChecks},
@@ -5,14 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/plugins/registry"
+	"github.com/element-of-surprise/coercion/plugins/version"
 
 	"github.com/google/uuid"
+	"github.com/gostdlib/ops/retry/exponential"
 )
 
 //go:generate stringer -type=Status
@@ -55,6 +58,10 @@ const (
 	FRContCheck FailureReason = 400 // ContCheck
 	// FRStopped represents a failure reason that occurred because the workflow was stopped.
 	FRStopped FailureReason = 500 // Stopped
+	// FRTimeout represents a failure reason that occurred because the Plan exceeded its MaxDuration.
+	FRTimeout FailureReason = 600 // Timeout
+	// FRStorage represents a failure reason that occurred because writes to storage could not be recovered.
+	FRStorage FailureReason = 700 // Storage
 )
 
 // State represents the internal state of a workflow object.
@@ -124,6 +131,10 @@ type Plan struct {
 	// Meta is any type of metadata that the user wants to store with the workflow.
 	// This is not used by the workflow engine. Optional.
 	Meta []byte
+	// MaxDuration is the maximum amount of time the Plan may run. Once exceeded, no new Blocks
+	// or Sequences are started, PostChecks are run and the Plan fails with FRTimeout.
+	// Work that is already running is allowed to finish. Optional, defaults to no limit.
+	MaxDuration time.Duration
 
 	// PreChecks are actions that are executed before the workflow starts.
 	// Any error will cause the workflow to fail. Optional.
@@ -218,6 +229,12 @@ func (p *Plan) validate() ([]validator, error) {
 	if !p.SubmitTime.IsZero() {
 		return nil, fmt.Errorf("submit time should not be set by the user")
 	}
+	if p.MaxDuration < 0 {
+		return nil, fmt.Errorf("max duration cannot be negative")
+	}
+	if err := checksSettings(p.PreChecks, p.ContChecks, p.PostChecks); err != nil {
+		return nil, err
+	}
 
 	vals := []validator{p.PreChecks, p.ContChecks, p.PostChecks}
 	for _, b := range p.Blocks {
@@ -237,11 +254,158 @@ type Checks struct {
 	// Actions is a list of actions that are executed in parallel. Any error will
 	// cause the workflow to fail. Required.
 	Actions []*Action
-
+	// Tolerance is the number of failed runs that are tolerated before the checks fail.
+	// This is only used by continuous checks. Optional. Defaults to failing on the first failed run.
+	Tolerance *Tolerance
+	// Retention is the number of runs of continuous checks that are kept in storage. Older runs
+	// are removed. This is only used by continuous checks. Optional. Defaults to MaxCheckRuns.
+	Retention int
+	// Gate makes the checks run until all Actions pass instead of failing on the first failure.
+	// This is only used by pre and post checks. Optional.
+	Gate *Gate
+	// Quorum is the number of Actions that must pass for the checks to pass. Optional. Defaults to all Actions.
+	Quorum *Quorum
+
+	// Runs is the result of each run of continuous checks, oldest first. Only the most recent
+	// runs are kept and they do not include the Actions of the run. Use storage.Reader.CheckRuns()
+	// to get the full history. Should not be set by the user.
+	Runs []*CheckRun
 	// State represents the internal state of the object. Should not be set by the user.
 	State *State
 }
 
+// MaxCheckRuns is the maximum number of runs kept for a Checks.
+const MaxCheckRuns = 1000
+
+// RunRetention returns the number of runs that are kept for the Checks.
+func (c *Checks) RunRetention() int {
+	if c == nil || c.Retention == 0 {
+		return MaxCheckRuns
+	}
+	return c.Retention
+}
+
+// Tolerance describes when failed runs of continuous checks cause the checks to fail.
+// If both Consecutive and Failures are set, reaching either fails the checks.
+type Tolerance struct {
+	// Consecutive fails the checks after this many failed runs in a row. Optional.
+	Consecutive int
+	// Failures fails the checks when this many runs in the last Window runs have failed.
+	// Must be set with Window. Optional.
+	Failures int
+	// Window is the number of most recent runs that Failures is counted over.
+	// Must be set with Failures and be greater than or equal to it. Optional.
+	Window int
+}
+
+func (t *Tolerance) validate() error {
+	if t == nil {
+		return nil
+	}
+	if t.Consecutive < 0 || t.Failures < 0 || t.Window < 0 {
+		return fmt.Errorf("tolerance values cannot be negative")
+	}
+	if (t.Failures == 0) != (t.Window == 0) {
+		return fmt.Errorf("tolerance Failures and Window must be set together")
+	}
+	if t.Failures > t.Window {
+		return fmt.Errorf("tolerance Failures(%d) cannot be greater than Window(%d)", t.Failures, t.Window)
+	}
+	if t.Consecutive > MaxCheckRuns || t.Window > MaxCheckRuns {
+		return fmt.Errorf("tolerance Consecutive and Window cannot be greater than %d", MaxCheckRuns)
+	}
+	if t.Consecutive == 0 && t.Failures == 0 {
+		return fmt.Errorf("tolerance must set Consecutive or Failures and Window")
+	}
+	return nil
+}
+
+// Quorum is the number of Actions in a Checks that must pass for the Checks to pass.
+// Only one of Count or Percent may be set.
+type Quorum struct {
+	// Count is the number of Actions that must pass. Must be less than or equal to the number of Actions.
+	Count int
+	// Percent is the percentage of Actions that must pass, from 1 to 100. The number of Actions
+	// this requires is rounded up.
+	Percent int
+}
+
+// Required returns the number of Actions out of total that must pass.
+func (q *Quorum) Required(total int) int {
+	if q == nil {
+		return total
+	}
+	if q.Count > 0 {
+		return q.Count
+	}
+	return (total*q.Percent + 99) / 100
+}
+
+func (q *Quorum) validate(actions int) error {
+	if q == nil {
+		return nil
+	}
+	if (q.Count == 0) == (q.Percent == 0) {
+		return fmt.Errorf("quorum must set one of Count or Percent")
+	}
+	if q.Count < 0 || q.Count > actions {
+		return fmt.Errorf("quorum Count(%d) must be between 1 and the number of Actions(%d)", q.Count, actions)
+	}
+	if q.Percent < 0 || q.Percent > 100 {
+		return fmt.Errorf("quorum Percent(%d) must be between 1 and 100", q.Percent)
+	}
+	return nil
+}
+
+// Gate describes how checks are run when they wait for a condition to become true. The Actions are run
+// until they all pass or the Timeout is reached. A Block's PreChecks with a Gate can be used in place
+// of an EntranceDelay.
+type Gate struct {
+	// Interval is the time to wait between runs. If not set, the retry policy of the first Action
+	// is used, which is either the Action's RetryPolicy or its plugin's. Optional.
+	Interval time.Duration
+	// Timeout is the maximum amount of time to wait for the Actions to pass. Required.
+	Timeout time.Duration
+}
+
+func (g *Gate) validate() error {
+	if g == nil {
+		return nil
+	}
+	if g.Interval < 0 {
+		return fmt.Errorf("gate Interval cannot be negative")
+	}
+	if g.Timeout <= 0 {
+		return fmt.Errorf("gate Timeout must be greater than 0")
+	}
+	return nil
+}
+
+// CheckRun is the result of a single run of continuous checks.
+type CheckRun struct {
+	// Iteration is the number of the run, starting at 1.
+	Iteration int
+	// Status is the status of the run. This is either Completed or Failed.
+	Status Status
+	// Start is the time the run started.
+	Start time.Time
+	// End is the time the run ended.
+	End time.Time
+	// Actions are the results of the Actions in the run, in the same order as Checks.Actions.
+	// This is only set when read with storage.Reader.CheckRuns().
+	Actions []*ActionRun
+}
+
+// ActionRun is the result of an Action during a single run of continuous checks.
+type ActionRun struct {
+	// ID is the ID of the Action.
+	ID uuid.UUID
+	// Status is the status of the Action at the end of the run.
+	Status Status
+	// Attempts are the attempts made by the Action during the run.
+	Attempts []*Attempt
+}
+
 // GetID is a getter for the ID field.
 func (c *Checks) GetID() uuid.UUID {
 	if c == nil {
@@ -298,6 +462,24 @@ func (c *Checks) validate() ([]validator, error) {
 	if c.State != nil {
 		return nil, fmt.Errorf("internal settings should not be set by the user")
 	}
+	if c.Runs != nil {
+		return nil, fmt.Errorf("runs should not be set by the user")
+	}
+	if err := c.Tolerance.validate(); err != nil {
+		return nil, err
+	}
+	if err := c.Gate.validate(); err != nil {
+		return nil, err
+	}
+	if err := c.Quorum.validate(len(c.Actions)); err != nil {
+		return nil, err
+	}
+	if c.Retention < 0 || c.Retention > MaxCheckRuns {
+		return nil, fmt.Errorf("retention must be between 0 and %d", MaxCheckRuns)
+	}
+	if t := c.Tolerance; t != nil && max(t.Consecutive, t.Window) > c.RunRetention() {
+		return nil, fmt.Errorf("tolerance Consecutive and Window cannot be greater than the Retention(%d)", c.RunRetention())
+	}
 
 	vals := make([]validator, len(c.Actions))
 	for i := 0; i < len(c.Actions); i++ {
@@ -307,6 +489,21 @@ func (c *Checks) validate() ([]validator, error) {
 	return vals, nil
 }
 
+// checksSettings returns an error if PreChecks or PostChecks have a Tolerance or Retention, which are only
+// supported by ContChecks, or if ContChecks have a Gate, which is only supported by PreChecks and PostChecks.
+func checksSettings(pre, cont, post *Checks) error {
+	if pre != nil && (pre.Tolerance != nil || pre.Retention != 0) {
+		return fmt.Errorf("PreChecks cannot have a Tolerance or Retention")
+	}
+	if post != nil && (post.Tolerance != nil || post.Retention != 0) {
+		return fmt.Errorf("PostChecks cannot have a Tolerance or Retention")
+	}
+	if cont != nil && cont.Gate != nil {
+		return fmt.Errorf("ContChecks cannot have a Gate")
+	}
+	return nil
+}
+
 // Block represents a set of replated work. It contains a list of sequences that are executed with
 // a configurable amount of concurrency. If a block fails, the workflow will fail. Only one block
 // can be executed at a time.
@@ -319,9 +516,14 @@ type Block struct {
 	Descr string
 
 	// EntranceDelay is the amount of time to wait before the block starts. This defaults to 0.
+	// To wait for a condition instead of a fixed time, use PreChecks with a Gate.
 	EntranceDelay time.Duration
 	// ExitDelay is the amount of time to wait after the block has completed. This defaults to 0.
 	ExitDelay time.Duration
+	// MaxDuration is the maximum amount of time the Block may run, not including the EntranceDelay.
+	// Once exceeded, no new Sequences are started, PostChecks are run and the Block fails.
+	// Sequences that are already running are allowed to finish. Optional, defaults to no limit.
+	MaxDuration time.Duration
 
 	// PreChecks are actions that are executed before the block starts.
 	// Any error will cause the block to fail. Optional.
@@ -416,6 +618,13 @@ func (b *Block) validate() ([]validator, error) {
 		return nil, fmt.Errorf("at least one sequence is required")
 	}
 
+	if b.MaxDuration < 0 {
+		return nil, fmt.Errorf("max duration cannot be negative")
+	}
+	if err := checksSettings(b.PreChecks, b.ContChecks, b.PostChecks); err != nil {
+		return nil, err
+	}
+
 	vals := []validator{b.PreChecks, b.ContChecks, b.PostChecks}
 	for _, seq := range b.Sequences {
 		vals = append(vals, seq)
@@ -431,8 +640,22 @@ type Sequence struct {
 	Name string
 	// Descr is a description of the sequence. Required.
 	Descr string
+
+	// PreChecks are actions that are executed before the sequence starts.
+	// Any error will cause the sequence to fail. Optional.
+	PreChecks *Checks
+	// ContChecks are actions that are executed while the sequence is running. Optional.
+	ContChecks *Checks
+	// PostChecks are actions that are executed after the sequence has completed.
+	// Any error will cause the sequence to fail. Optional.
+	PostChecks *Checks
+
 	// Actions is a list of actions that are executed in sequence. Any error will cause the workflow to fail. Required.
 	Actions []*Action
+	// MaxDuration is the maximum amount of time the Sequence may run. Once exceeded, no new Actions
+	// are started, PostChecks are run and the Sequence fails. An Action that is already running is
+	// allowed to finish. Optional, defaults to no limit.
+	MaxDuration time.Duration
 
 	// State represents settings that should not be set by the user, but users can query.
 	State *State
@@ -503,7 +726,19 @@ func (s *Sequence) validate() ([]validator, error) {
 		return nil, fmt.Errorf("at least one Action is required")
 	}
 
-	vals := make([]validator, 0, len(s.Actions))
+	if s.MaxDuration < 0 {
+		return nil, fmt.Errorf("max duration cannot be negative")
+	}
+	if err := checksSettings(s.PreChecks, s.ContChecks, s.PostChecks); err != nil {
+		return nil, err
+	}
+
+	vals := make([]validator, 0, len(s.Actions)+3)
+	for _, c := range []*Checks{s.PreChecks, s.ContChecks, s.PostChecks} {
+		if c != nil {
+			vals = append(vals, c)
+		}
+	}
 	for _, a := range s.Actions {
 		vals = append(vals, a)
 	}
@@ -513,10 +748,22 @@ func (s *Sequence) validate() ([]validator, error) {
 // Attempt is the result of an action that is executed by a plugin.
 // Nothing in Attempt should be set by the user.
 type Attempt struct {
-	// Resp is the response object that is returned by the plugin.
+	// Resp is the response object that is returned by the plugin. When read from storage, this is a RawJSON
+	// if it could not be decoded into the plugin's response.
 	Resp any
 	// Err is the plugin error that is returned by the plugin. If this is not nil, the attempt failed.
 	Err *plugins.Error
+	// Stack is the stack trace of the plugin if it panicked during the attempt. Err will describe the panic.
+	Stack string
+	// PluginVersion is the version of the plugin that executed the attempt. Empty if the plugin is unversioned.
+	PluginVersion string
+	// Log holds the last lines the plugin logged with execinfo.Log() during the attempt. The number
+	// of lines and their length are bounded. See LogDropped.
+	Log []LogLine
+	// LogDropped is the number of lines that were dropped from the start of Log because of its bounds.
+	LogDropped int
+	// Progress is the last progress percentage, from 0 to 100, the plugin reported with execinfo.Progress().
+	Progress int
 
 	// Start is the time the attempt started.
 	Start time.Time
@@ -524,6 +771,14 @@ type Attempt struct {
 	End time.Time
 }
 
+// LogLine is a line logged by a plugin during an Attempt.
+type LogLine struct {
+	// Time is when the line was logged.
+	Time time.Time
+	// Text is the text of the line.
+	Text string
+}
+
 // Action represents a single action that is executed by a plugin.
 type Action struct {
 	// ID is a unique identifier for the object. Should not be set by the user.
@@ -534,16 +789,41 @@ type Action struct {
 	Descr string
 	// Plugin is the name of the plugin that is executed. Required.
 	Plugin string
+	// Version is a version.Constraint that selects the version of the plugin, such as "1.2.0" or "^1.2.0".
+	// The latest version that matches is used. Optional, defaults to the latest version.
+	Version string
 	// Timeout is the amount of time to wait for the Action to complete. This defaults to 30 seconds and
 	// must be at least 5 seconds.
 	Timeout time.Duration
 	// Retries is the number of times to retry the Action if it fails. This defaults to 0.
 	Retries int
-	// Req is the request object that is passed to the plugin.
+	// RetryPolicy overrides the plugin's RetryPolicy() for this Action. Optional.
+	RetryPolicy *exponential.Policy
+	// RetryOn is a list of error codes that may be retried. If set, an error returned by the plugin
+	// with a code not in this list will not be retried. Optional.
+	RetryOn []plugins.ErrCode
+	// PermanentOn is a list of error codes that will not be retried, even if the plugin says they
+	// can be. Optional.
+	PermanentOn []plugins.ErrCode
+	// MaxDuration is the total amount of time the Action may take across all attempts, including
+	// the time spent waiting between retries. An attempt's Timeout is reduced to fit in what remains
+	// and no new attempt is started once it is used up. Optional, defaults to no limit.
+	MaxDuration time.Duration
+	// Req is the request object that is passed to the plugin. When read from storage, this is a RawJSON
+	// if it could not be decoded into the plugin's request.
 	Req any
+	// ReqVersion is the version of the plugin that Req was validated with when the Plan was submitted.
+	// Storage uses it to migrate Req if the plugin's request changes. This should not be set by the user.
+	ReqVersion string
 
 	// Attempts is the attempts of the action. This should not be set by the user.
 	Attempts []*Attempt
+	// Spent is the amount of time the Action has spent executing, including the time between retries.
+	// This is what has been used of MaxDuration. This should not be set by the user.
+	Spent time.Duration
+	// Checkpoint is the last checkpoint the plugin saved with execinfo.SaveCheckpoint(). It is given
+	// back to the plugin on every attempt so it can continue where it stopped. This should not be set by the user.
+	Checkpoint []byte
 	// State represents settings that should not be set by the user, but users can query.
 	State *State
 
@@ -591,6 +871,9 @@ func (a *Action) Defaults() {
 	a.State = &State{
 		Status: NotStarted,
 	}
+	if p := a.register.PluginVersion(a.Plugin, a.Version); p != nil {
+		a.ReqVersion = plugins.VersionOf(p)
+	}
 }
 
 // HasRegister determines if a Register has been set.
@@ -640,9 +923,39 @@ func (a *Action) validate() ([]validator, error) {
 		a.Retries = 0
 	}
 
-	plug := a.register.Plugin(a.Plugin)
+	if a.MaxDuration < 0 {
+		return nil, fmt.Errorf("max duration cannot be negative")
+	}
+	if a.RetryPolicy != nil {
+		if err := registry.ValidatePolicy(*a.RetryPolicy); err != nil {
+			return nil, fmt.Errorf("retry policy: %w", err)
+		}
+	}
+	for _, c := range a.PermanentOn {
+		if slices.Contains(a.RetryOn, c) {
+			return nil, fmt.Errorf("error code %v cannot be in both RetryOn and PermanentOn", c)
+		}
+	}
+	if a.Spent != 0 {
+		return nil, fmt.Errorf("spent should not be set by the user")
+	}
+	if a.Checkpoint != nil {
+		return nil, fmt.Errorf("checkpoint should not be set by the user")
+	}
+	if a.ReqVersion != "" {
+		return nil, fmt.Errorf("req version should not be set by the user")
+	}
+
+	if _, err := version.ParseConstraint(a.Version); err != nil {
+		return nil, fmt.Errorf("version: %w", err)
+	}
+
+	plug := a.register.PluginVersion(a.Plugin, a.Version)
 
 	if plug == nil {
+		if a.Version != "" && a.register.Plugin(a.Plugin) != nil {
+			return nil, fmt.Errorf("plugin %q has no version matching %q, have %v", a.Plugin, a.Version, a.register.Versions(a.Plugin))
+		}
 		return nil, fmt.Errorf("plugin %q not found", a.Plugin)
 	}
 
@@ -3,6 +3,7 @@ package workflow
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/element-of-surprise/coercion/plugins"
 	"github.com/element-of-surprise/coercion/plugins/registry"
@@ -93,6 +94,42 @@ func TestPlanValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: PreChecks has a Tolerance",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.PreChecks = &Checks{Tolerance: &Tolerance{Consecutive: 2}}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: ContChecks has a Gate",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.ContChecks = &Checks{Gate: &Gate{Timeout: time.Minute}}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: PreChecks has a Retention",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.PreChecks = &Checks{Retention: 2}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: MaxDuration is negative",
+			plan: func() *Plan {
+				p := goodPlan()
+				p.MaxDuration = -1
+				return p
+			},
+			err: true,
+		},
 		{
 			name:       "Success",
 			plan:       goodPlan,
@@ -312,11 +349,166 @@ func TestContCheckValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: Runs != nil",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Runs = []*CheckRun{}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Tolerance is empty",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Tolerance = &Tolerance{}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Tolerance Failures without Window",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Tolerance = &Tolerance{Failures: 2}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Tolerance Failures > Window",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Tolerance = &Tolerance{Failures: 3, Window: 2}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Tolerance Consecutive > MaxCheckRuns",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Tolerance = &Tolerance{Consecutive: MaxCheckRuns + 1}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Gate Timeout not set",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Gate = &Gate{Interval: time.Second}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Gate Interval is negative",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Gate = &Gate{Interval: -1, Timeout: time.Minute}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Success with Gate",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Gate = &Gate{Interval: time.Second, Timeout: time.Minute}
+				return p
+			},
+			vals: []validator{goodContChecks().Actions[0]},
+		},
+		{
+			name: "Error: Quorum is empty",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Quorum = &Quorum{}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Quorum sets Count and Percent",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Quorum = &Quorum{Count: 1, Percent: 50}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Quorum Count > number of Actions",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Quorum = &Quorum{Count: len(p.Actions) + 1}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Quorum Percent > 100",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Quorum = &Quorum{Percent: 101}
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Success with Quorum",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Quorum = &Quorum{Percent: 50}
+				return p
+			},
+			vals: []validator{goodContChecks().Actions[0]},
+		},
+		{
+			name: "Error: Retention is negative",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Retention = -1
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Retention > MaxCheckRuns",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Retention = MaxCheckRuns + 1
+				return p
+			},
+			err: true,
+		},
+		{
+			name: "Error: Tolerance Window > Retention",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Retention = 4
+				p.Tolerance = &Tolerance{Failures: 2, Window: 5}
+				return p
+			},
+			err: true,
+		},
 		{
 			name:      "Success",
 			contCheck: goodContChecks,
 			vals:      []validator{goodContChecks().Actions[0]},
 		},
+		{
+			name: "Success with Tolerance",
+			contCheck: func() *Checks {
+				p := goodContChecks()
+				p.Tolerance = &Tolerance{Consecutive: 3, Failures: 2, Window: 5}
+				p.Retention = 5
+				return p
+			},
+			vals: []validator{goodContChecks().Actions[0]},
+		},
 	}
 
 	for _, test := range tests {
@@ -339,6 +531,29 @@ func TestContCheckValidate(t *testing.T) {
 	}
 }
 
+func TestQuorumRequired(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		quorum *Quorum
+		total  int
+		want   int
+	}{
+		{name: "nil Quorum requires all", total: 20, want: 20},
+		{name: "Count", quorum: &Quorum{Count: 18}, total: 20, want: 18},
+		{name: "Percent", quorum: &Quorum{Percent: 90}, total: 20, want: 18},
+		{name: "Percent rounds up", quorum: &Quorum{Percent: 90}, total: 21, want: 19},
+		{name: "100 Percent", quorum: &Quorum{Percent: 100}, total: 3, want: 3},
+	}
+
+	for _, test := range tests {
+		if got := test.quorum.Required(test.total); got != test.want {
+			t.Errorf("TestQuorumRequired(%s): got %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
 func TestBlockValidate(t *testing.T) {
 	t.Parallel()
 
@@ -413,6 +628,24 @@ func TestBlockValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: PostChecks has a Tolerance",
+			block: func() *Block {
+				b := goodBlock()
+				b.PostChecks = &Checks{Tolerance: &Tolerance{Consecutive: 2}}
+				return b
+			},
+			err: true,
+		},
+		{
+			name: "Error: MaxDuration is negative",
+			block: func() *Block {
+				b := goodBlock()
+				b.MaxDuration = -1
+				return b
+			},
+			err: true,
+		},
 		{
 			name:  "Success",
 			block: goodBlock,
@@ -512,11 +745,48 @@ func TestSequenceValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: MaxDuration is negative",
+			sequence: func() *Sequence {
+				s := goodSequence()
+				s.MaxDuration = -1
+				return s
+			},
+			err: true,
+		},
+		{
+			name: "Error: ContChecks has a Gate",
+			sequence: func() *Sequence {
+				s := goodSequence()
+				s.ContChecks = &Checks{Actions: []*Action{{}}, Gate: &Gate{Timeout: time.Minute}}
+				return s
+			},
+			err: true,
+		},
+		{
+			name: "Error: PreChecks has a Tolerance",
+			sequence: func() *Sequence {
+				s := goodSequence()
+				s.PreChecks = &Checks{Actions: []*Action{{}}, Tolerance: &Tolerance{Consecutive: 1}}
+				return s
+			},
+			err: true,
+		},
 		{
 			name:     "Success",
 			sequence: goodSequence,
 			vals:     []validator{goodSequence().Actions[0]},
 		},
+		{
+			name: "Success: with checks",
+			sequence: func() *Sequence {
+				s := goodSequence()
+				s.PreChecks = &Checks{Actions: []*Action{{}}}
+				s.PostChecks = &Checks{Actions: []*Action{{}}}
+				return s
+			},
+			vals: []validator{&Checks{Actions: []*Action{{}}}, &Checks{Actions: []*Action{{}}}, goodSequence().Actions[0]},
+		},
 	}
 
 	for _, test := range tests {
@@ -569,11 +839,20 @@ func (v validatePlugin) ValidateReq(req any) error {
 	return nil
 }
 
+type versionedValidatePlugin struct {
+	validatePlugin
+}
+
+func (versionedValidatePlugin) Version() string {
+	return "1.2.0"
+}
+
 func TestActionValidate(t *testing.T) {
 	t.Parallel()
 
 	reg := registry.New()
 	reg.Register(validatePlugin{})
+	reg.Register(versionedValidatePlugin{})
 
 	goodAction := func() *Action {
 		return &Action{
@@ -633,6 +912,61 @@ func TestActionValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: MaxDuration is negative",
+			action: func() *Action {
+				a := goodAction()
+				a.MaxDuration = -1
+				return a
+			},
+			err: true,
+		},
+		{
+			name: "Error: RetryPolicy is invalid",
+			action: func() *Action {
+				a := goodAction()
+				a.RetryPolicy = &exponential.Policy{}
+				return a
+			},
+			err: true,
+		},
+		{
+			name: "Error: ErrCode in RetryOn and PermanentOn",
+			action: func() *Action {
+				a := goodAction()
+				a.RetryOn = []plugins.ErrCode{1, 2}
+				a.PermanentOn = []plugins.ErrCode{2}
+				return a
+			},
+			err: true,
+		},
+		{
+			name: "Error: Spent is set",
+			action: func() *Action {
+				a := goodAction()
+				a.Spent = time.Second
+				return a
+			},
+			err: true,
+		},
+		{
+			name: "Error: Checkpoint is set",
+			action: func() *Action {
+				a := goodAction()
+				a.Checkpoint = []byte("checkpoint")
+				return a
+			},
+			err: true,
+		},
+		{
+			name: "Error: ReqVersion is set",
+			action: func() *Action {
+				a := goodAction()
+				a.ReqVersion = "1.2.0"
+				return a
+			},
+			err: true,
+		},
 		{
 			name: "Error: Plugin not found",
 			action: func() *Action {
@@ -642,6 +976,24 @@ func TestActionValidate(t *testing.T) {
 			},
 			err: true,
 		},
+		{
+			name: "Error: Version is invalid",
+			action: func() *Action {
+				a := goodAction()
+				a.Version = ">>1.2.0"
+				return a
+			},
+			err: true,
+		},
+		{
+			name: "Error: no plugin version matches",
+			action: func() *Action {
+				a := goodAction()
+				a.Version = "^2.0.0"
+				return a
+			},
+			err: true,
+		},
 		{
 			name: "Error: Req doesn't validate",
 			action: func() *Action {
@@ -655,6 +1007,25 @@ func TestActionValidate(t *testing.T) {
 			name:   "Success",
 			action: goodAction,
 		},
+		{
+			name: "Success with retry rules",
+			action: func() *Action {
+				a := goodAction()
+				p := plugins.SecondsRetryPolicy()
+				a.RetryPolicy = &p
+				a.RetryOn = []plugins.ErrCode{1}
+				a.PermanentOn = []plugins.ErrCode{2}
+				return a
+			},
+		},
+		{
+			name: "Success with version",
+			action: func() *Action {
+				a := goodAction()
+				a.Version = "^1.0.0"
+				return a
+			},
+		},
 	}
 
 	for _, test := range tests {